@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+// CalendarConnectionHandler handles HTTP requests for the CalDAV calendars users connect.
+// Callers may only manage their own connection.
+type CalendarConnectionHandler struct {
+	connections *services.CalendarConnectionService
+}
+
+// NewCalendarConnectionHandler creates a new instance of CalendarConnectionHandler
+func NewCalendarConnectionHandler(connections *services.CalendarConnectionService) *CalendarConnectionHandler {
+	return &CalendarConnectionHandler{connections: connections}
+}
+
+// GetCalendarConnection handles retrieving a user's calendar connection, without its password
+func (h *CalendarConnectionHandler) GetCalendarConnection(c *gin.Context) {
+	userID, ok := connectionOwner(c)
+	if !ok {
+		return
+	}
+
+	connection, err := h.connections.Get(userID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// UpdateCalendarConnection handles connecting the CalDAV calendar a user's availability is pulled from
+func (h *CalendarConnectionHandler) UpdateCalendarConnection(c *gin.Context) {
+	userID, ok := connectionOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCalendarConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, err := h.connections.Connect(userID, req)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, connection)
+}
+
+// DeleteCalendarConnection handles disconnecting a user's calendar
+func (h *CalendarConnectionHandler) DeleteCalendarConnection(c *gin.Context) {
+	userID, ok := connectionOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := h.connections.Disconnect(userID); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// connectionOwner returns the user whose calendar connection is addressed, checking that the
+// caller is that user. Guests have no calendar connection.
+func connectionOwner(c *gin.Context) (string, bool) {
+	return addressedOwner(c, "Calendar connections can only be managed by their owner")
+}
+
+// addressedOwner returns the user named by the user_id path parameter, checking that the caller
+// is that user and not a guest. Anonymous callers are refused, others get the forbidden message.
+func addressedOwner(c *gin.Context, forbidden string) (string, bool) {
+	userID := c.Param("user_id")
+	principal, ok := middleware.RequirePrincipal(c)
+	if !ok {
+		return "", false
+	}
+	if principal.Invite != nil || principal.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbidden})
+		return "", false
+	}
+	return userID, true
+}
@@ -1,48 +1,109 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/middleware"
 	"github.com/shani34/meeting-scheduler/api/models"
 	"github.com/shani34/meeting-scheduler/api/services"
 	"github.com/shani34/meeting-scheduler/internal/repository"
 )
 
-// EventHandler handles HTTP requests for event-related operations
+// maxCalendarUpload bounds the size of uploaded calendar files
+const maxCalendarUpload = 1 << 20
+
+// EventHandler handles HTTP requests for event-related operations.
+// Every operation on an existing event is checked against the caller's role in it.
 type EventHandler struct {
-	eventRepo      *repository.EventRepository
-	availabilityRepo *repository.AvailabilityRepository
-	scheduler      *services.SchedulerService
+	eventRepo      repository.EventStore
+	events         *services.EventService
+	availabilities *services.AvailabilityService
+	policy         *services.EventPolicy
+	invites        *services.InviteService
+	calendars      *services.CalendarService
+	syncs          *services.CalendarSyncService // Nil when calendar connections are disabled
 }
 
 // NewEventHandler creates a new instance of EventHandler
-func NewEventHandler(eventRepo *repository.EventRepository, availabilityRepo *repository.AvailabilityRepository, scheduler *services.SchedulerService) *EventHandler {
+func NewEventHandler(
+	eventRepo repository.EventStore,
+	events *services.EventService,
+	availabilities *services.AvailabilityService,
+	policy *services.EventPolicy,
+	invites *services.InviteService,
+	calendars *services.CalendarService,
+	syncs *services.CalendarSyncService,
+) *EventHandler {
 	return &EventHandler{
 		eventRepo:      eventRepo,
-		availabilityRepo: availabilityRepo,
-		scheduler:      scheduler,
+		events:         events,
+		availabilities: availabilities,
+		policy:         policy,
+		invites:        invites,
+		calendars:      calendars,
+		syncs:          syncs,
 	}
 }
 
-// CreateEvent handles the creation of a new event
+// CreateEvent handles the creation of a new event, organized by the caller
 func (h *EventHandler) CreateEvent(c *gin.Context) {
-	var event models.Event
-	if err := c.ShouldBindJSON(&event); err != nil {
+	principal, ok := middleware.RequirePrincipal(c)
+	if !ok {
+		return
+	}
+	if principal.Invite != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Guests cannot create events"})
+		return
+	}
+
+	var req models.CreateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if userID, ok := duplicateParticipant(req.Participants); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Participant listed more than once: " + userID})
+		return
+	}
+
+	if err := services.ValidateRecurrence(req.Recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurrence: " + err.Error()})
+		return
+	}
+
+	if req.Status == "" {
+		req.Status = models.EventStatusPolling
+	}
+
 	// Set event ID and timestamps
-	event.ID = uuid.New().String()
-	event.CreatedAt = time.Now()
-	event.UpdatedAt = time.Now()
+	event := models.Event{
+		ID:           uuid.New().String(),
+		Title:        req.Title,
+		Description:  req.Description,
+		Duration:     req.Duration,
+		TimeSlots:    req.TimeSlots,
+		Participants: req.Participants,
+		Recurrence:   req.Recurrence,
+		Status:       req.Status,
+		Deadline:     req.Deadline,
+		CreatedBy:    principal.UserID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
 
 	// Create event in database
-	if err := h.eventRepo.CreateEvent(&event); err != nil {
+	if err := h.events.Create(&event); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
@@ -58,9 +119,8 @@ func (h *EventHandler) GetEvent(c *gin.Context) {
 		return
 	}
 
-	event, err := h.eventRepo.GetEvent(eventID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	event, ok := h.authorize(c, eventID, services.ActionViewEvent)
+	if !ok {
 		return
 	}
 
@@ -75,18 +135,29 @@ func (h *EventHandler) UpdateEvent(c *gin.Context) {
 		return
 	}
 
-	var event models.Event
-	if err := c.ShouldBindJSON(&event); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if _, ok := h.authorize(c, eventID, services.ActionEditEvent); !ok {
+		return
+	}
+
+	var req models.UpdateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if userID, ok := duplicateParticipant(req.Participants); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Participant listed more than once: " + userID})
 		return
 	}
 
-	// Ensure event ID matches
-	event.ID = eventID
-	event.UpdatedAt = time.Now()
+	if err := services.ValidateRecurrence(req.Recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recurrence: " + err.Error()})
+		return
+	}
 
-	if err := h.eventRepo.UpdateEvent(&event); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+	event, err := h.events.Update(eventID, req)
+	if err != nil {
+		writeServiceError(c, err)
 		return
 	}
 
@@ -101,6 +172,10 @@ func (h *EventHandler) DeleteEvent(c *gin.Context) {
 		return
 	}
 
+	if _, ok := h.authorize(c, eventID, services.ActionDeleteEvent); !ok {
+		return
+	}
+
 	if err := h.eventRepo.DeleteEvent(eventID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
 		return
@@ -109,63 +184,121 @@ func (h *EventHandler) DeleteEvent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// SubmitAvailability handles submitting participant availability
-func (h *EventHandler) SubmitAvailability(c *gin.Context) {
+// GetOptimalTimeSlots handles finding optimal time slots for an event
+func (h *EventHandler) GetOptimalTimeSlots(c *gin.Context) {
 	eventID := c.Query("event_id")
 	if eventID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Event ID is required"})
 		return
 	}
 
-	var availability models.ParticipantAvailability
-	if err := c.ShouldBindJSON(&availability); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	// Get event details
+	event, ok := h.authorize(c, eventID, services.ActionViewRecommendations)
+	if !ok {
+		return
+	}
+
+	// Find optimal time slots
+	recommendations, err := h.events.Recommendations(event)
+	if errors.Is(err, services.ErrInvalidRecurrence) {
+		writeServiceError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
 		return
 	}
 
-	// Set availability ID and timestamps
-	availability.ID = uuid.New().String()
-	availability.EventID = eventID
-	availability.CreatedAt = time.Now()
-	availability.UpdatedAt = time.Now()
+	c.JSON(http.StatusOK, recommendations)
+}
+
+// ExportCalendar renders an event as an iCalendar file: its final slot once finalized, and
+// its top recommended slots as tentative events before
+func (h *EventHandler) ExportCalendar(c *gin.Context) {
+	candidates := services.DefaultCalendarCandidates
+	if value := c.Query("candidates"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxCalendarCandidates {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("candidates must be a number between 1 and %d", services.MaxCalendarCandidates)})
+			return
+		}
+		candidates = n
+	}
 
-	if err := h.availabilityRepo.CreateAvailability(&availability); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit availability"})
+	event, ok := h.authorize(c, c.Param("id"), services.ActionViewEvent)
+	if !ok {
 		return
 	}
+	// Candidate slots reveal the recommendations
+	if event.FinalSlot == nil {
+		if event, ok = h.authorize(c, event.ID, services.ActionViewRecommendations); !ok {
+			return
+		}
+	}
 
-	c.JSON(http.StatusCreated, availability)
+	calendar, err := h.calendars.Export(event, candidates)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+event.ID+`.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar)
 }
 
-// GetOptimalTimeSlots handles finding optimal time slots for an event
-func (h *EventHandler) GetOptimalTimeSlots(c *gin.Context) {
-	eventID := c.Query("event_id")
-	if eventID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Event ID is required"})
+// OpenEvent starts collecting availability for a draft event
+func (h *EventHandler) OpenEvent(c *gin.Context) {
+	if _, ok := h.authorize(c, c.Param("id"), services.ActionEditEvent); !ok {
 		return
 	}
 
-	// Get event details
-	event, err := h.eventRepo.GetEvent(eventID)
+	event, err := h.events.Open(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// FinalizeEvent locks in one of the event's recommended time slots
+func (h *EventHandler) FinalizeEvent(c *gin.Context) {
+	if _, ok := h.authorize(c, c.Param("id"), services.ActionFinalizeEvent); !ok {
+		return
+	}
+
+	var req models.FinalizeEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get all participant availabilities
-	availabilities, err := h.eventRepo.GetParticipantAvailabilities(eventID)
+	event, err := h.events.Finalize(c.Param("id"), req.StartTime, c.GetString("user_id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get participant availabilities"})
+		writeServiceError(c, err)
 		return
 	}
 
-	// Find optimal time slots
-	recommendations := h.scheduler.FindOptimalTimeSlots(event, availabilities)
+	c.JSON(http.StatusOK, event)
+}
 
-	c.JSON(http.StatusOK, recommendations)
+// CancelEvent cancels an event
+func (h *EventHandler) CancelEvent(c *gin.Context) {
+	if _, ok := h.authorize(c, c.Param("id"), services.ActionEditEvent); !ok {
+		return
+	}
+
+	event, err := h.events.Cancel(c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
 }
 
-// CreateAvailability handles the creation of participant availability
+// CreateAvailability handles submitting participant availability. A user resubmitting
+// to the same event replaces the availability they submitted before.
 func (h *EventHandler) CreateAvailability(c *gin.Context) {
 	var req models.CreateAvailabilityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -173,35 +306,457 @@ func (h *EventHandler) CreateAvailability(c *gin.Context) {
 		return
 	}
 
-	availability := &models.ParticipantAvailability{
-		ID:        uuid.New().String(),
-		EventID:   req.EventID,
-		UserID:    c.GetString("user_id"), // Assuming user_id is set by auth middleware
-		TimeSlots: req.TimeSlots,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// Older clients pass the event ID as a query parameter, guests may rely on their invite
+	if req.EventID == "" {
+		req.EventID = c.Query("event_id")
+	}
+	principal, _ := middleware.PrincipalFrom(c)
+	if req.EventID == "" && principal != nil && principal.Invite != nil {
+		req.EventID = principal.Invite.EventID
+	}
+	if req.EventID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event ID is required"})
+		return
+	}
+
+	if _, who, ok := h.availabilitySubmitter(c, req.EventID, req.GuestName, req.GuestEmail); ok {
+		h.submitAvailability(c, req.EventID, who, req.TimeSlots)
+	}
+}
+
+// ImportAvailability replaces the caller's availability for an event with the free time left by
+// an uploaded iCalendar file, sent as the request body or as the "file" field of a form. Callers
+// are identified as for CreateAvailability, with open-invite guests' details in form or query fields.
+func (h *EventHandler) ImportAvailability(c *gin.Context) {
+	request := c.Request
+	request.Body = http.MaxBytesReader(c.Writer, request.Body, maxCalendarUpload)
+	var calendar io.Reader
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Calendar file is required"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			writeServiceError(c, err)
+			return
+		}
+		defer opened.Close()
+		calendar = opened
+	} else {
+		// Read the body before form fields are looked up, which may consume it
+		body, err := io.ReadAll(request.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Calendar file is too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read calendar file"})
+			return
+		}
+		calendar = bytes.NewReader(body)
+	}
+
+	event, who, ok := h.availabilitySubmitter(c, c.Param("id"),
+		request.FormValue("guest_name"), request.FormValue("guest_email"))
+	if !ok {
+		return
+	}
+
+	slots, err := h.calendars.ImportAvailability(event, calendar)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	h.submitAvailability(c, event.ID, who, slots)
+}
+
+// SyncAvailability replaces the caller's availability for an event with the free time left by the
+// calendar they connected. Callers are identified as for CreateAvailability.
+func (h *EventHandler) SyncAvailability(c *gin.Context) {
+	if principal, _ := middleware.PrincipalFrom(c); principal != nil && principal.Invite != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Guests cannot sync a calendar"})
+		return
+	}
+	event, who, ok := h.availabilitySubmitter(c, c.Param("id"), "", "")
+	if !ok {
+		return
+	}
+
+	availability, err := h.syncs.Sync(c.Request.Context(), event.ID, who.userID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
 	}
 
-	// TODO: Save availability to database
 	c.JSON(http.StatusCreated, availability)
 }
 
+// submitter identifies who submits availability to an event
+type submitter struct {
+	userID string
+	guest  *models.GuestDetails
+	// personal is the invite issued to a guest responding through an open invite, stored along with
+	// their submission, and token is its token
+	personal *models.EventInvite
+	token    string
+}
+
+// availabilitySubmitter authorizes the caller to submit availability to an event and identifies
+// them: guests holding an invite by the invite, others as the authenticated user. It writes the
+// error response when not ok.
+func (h *EventHandler) availabilitySubmitter(
+	c *gin.Context,
+	eventID, guestName, guestEmail string,
+) (*models.Event, submitter, bool) {
+	event, ok := h.authorize(c, eventID, services.ActionSubmitAvailability)
+	if !ok {
+		return nil, submitter{}, false
+	}
+
+	principal, _ := middleware.PrincipalFrom(c) // Anonymous callers were refused above
+	if principal.Invite == nil {
+		return event, submitter{userID: principal.UserID}, true
+	}
+	var who submitter
+	invite := principal.Invite
+	if invite.Email == "" {
+		personal, token, err := h.personalInvite(event.ID, invite, guestName, guestEmail)
+		if err != nil {
+			writeServiceError(c, err)
+			return nil, submitter{}, false
+		}
+		invite, who.personal, who.token = personal, personal, token
+	}
+	userID, guest, err := services.Guest(invite, guestName, "")
+	if err != nil {
+		writeServiceError(c, err)
+		return nil, submitter{}, false
+	}
+	who.userID, who.guest = userID, guest
+	return event, who, true
+}
+
+// personalInvite prepares the personal invite of a guest responding through an open invite, which is
+// stored along with their submission. Guests whose email already responded must use the personal
+// invite they were issued then.
+func (h *EventHandler) personalInvite(
+	eventID string,
+	open *models.EventInvite,
+	guestName, guestEmail string,
+) (*models.EventInvite, string, error) {
+	_, guest, err := services.Guest(open, guestName, guestEmail)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := h.events.CheckAcceptingAvailability(eventID); err != nil {
+		return nil, "", err
+	}
+	responded, err := h.availabilities.GuestResponded(eventID, guest.Email)
+	if err != nil {
+		return nil, "", err
+	}
+	if responded {
+		return nil, "", services.ErrGuestAlreadyResponded
+	}
+	return h.invites.Personal(open, guest.Name, guest.Email)
+}
+
+// submitAvailability stores the availability of a user or guest and writes the response. Guests
+// responding through an open invite get the token of their personal invite in the X-Invite-Token
+// header for them to change their availability with.
+func (h *EventHandler) submitAvailability(c *gin.Context, eventID string, who submitter, slots []models.TimeSlot) {
+	var availability *models.ParticipantAvailability
+	var err error
+	switch {
+	case who.personal != nil:
+		availability, err = h.availabilities.SubmitAsNewGuest(eventID, who.userID, who.guest, who.personal, slots)
+	case who.guest != nil:
+		availability, err = h.availabilities.SubmitAsGuest(eventID, who.userID, who.guest, slots)
+	default:
+		availability, err = h.availabilities.Submit(eventID, who.userID, slots)
+	}
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	if who.personal != nil {
+		h.invites.Issued(who.personal, who.token)
+		c.Header(middleware.InviteTokenHeader, who.token)
+	}
+
+	c.JSON(http.StatusCreated, availability)
+}
+
+// GetAvailability handles retrieving a participant availability by ID
+func (h *EventHandler) GetAvailability(c *gin.Context) {
+	availability, err := h.availabilities.Get(c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	// Participants always see their own availability
+	if availability.UserID != c.GetString("user_id") {
+		if _, ok := h.authorize(c, availability.EventID, services.ActionViewAvailabilities); !ok {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, availability)
+}
+
+// ListEventAvailabilities handles retrieving every availability submitted to an event
+func (h *EventHandler) ListEventAvailabilities(c *gin.Context) {
+	if _, ok := h.authorize(c, c.Param("id"), services.ActionViewAvailabilities); !ok {
+		return
+	}
+
+	availabilities, err := h.availabilities.ListForEvent(c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, availabilities)
+}
+
 // UpdateAvailability updates participant availability
 func (h *EventHandler) UpdateAvailability(c *gin.Context) {
-	availabilityID := c.Param("id")
 	var req models.UpdateAvailabilityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// TODO: Update availability in database
-	c.JSON(http.StatusOK, gin.H{"message": "Availability updated", "id": availabilityID})
+	if !h.authorizeAvailability(c, c.Param("id")) {
+		return
+	}
+
+	availability, err := h.availabilities.Update(c.Param("id"), c.GetString("user_id"), req.TimeSlots)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, availability)
 }
 
 // DeleteAvailability deletes participant availability
 func (h *EventHandler) DeleteAvailability(c *gin.Context) {
-	availabilityID := c.Param("id")
-	// TODO: Delete availability from database
-	c.JSON(http.StatusOK, gin.H{"message": "Availability deleted", "id": availabilityID})
-} 
\ No newline at end of file
+	if !h.authorizeAvailability(c, c.Param("id")) {
+		return
+	}
+
+	if err := h.availabilities.Delete(c.Param("id"), c.GetString("user_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListEventRoles lists the role of every user taking part in an event
+func (h *EventHandler) ListEventRoles(c *gin.Context) {
+	event, ok := h.authorize(c, c.Param("id"), services.ActionViewEvent)
+	if !ok {
+		return
+	}
+
+	roles, err := h.policy.Roles(event)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// AssignEventRole grants a user a role in an event
+func (h *EventHandler) AssignEventRole(c *gin.Context) {
+	var req models.AssignEventRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, ok := h.authorize(c, c.Param("id"), services.ActionManageRoles)
+	if !ok {
+		return
+	}
+
+	assignment, err := h.policy.Assign(event, c.Param("user_id"), req.Role)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+// UnassignEventRole withdraws the role a user was granted in an event
+func (h *EventHandler) UnassignEventRole(c *gin.Context) {
+	event, ok := h.authorize(c, c.Param("id"), services.ActionManageRoles)
+	if !ok {
+		return
+	}
+
+	if err := h.policy.Unassign(event, c.Param("user_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateInvite issues an invite link guests can submit availability with
+func (h *EventHandler) CreateInvite(c *gin.Context) {
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, ok := h.authorize(c, c.Param("id"), services.ActionManageInvites)
+	if !ok {
+		return
+	}
+
+	invite, token, err := h.invites.Create(event.ID, c.GetString("user_id"), req)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateInviteResponse{Invite: *invite, Token: token})
+}
+
+// ListInvites lists the invites to an event. Tokens are only returned when an invite is created.
+func (h *EventHandler) ListInvites(c *gin.Context) {
+	event, ok := h.authorize(c, c.Param("id"), services.ActionManageInvites)
+	if !ok {
+		return
+	}
+
+	invites, err := h.invites.List(event.ID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite stops an invite from being used
+func (h *EventHandler) RevokeInvite(c *gin.Context) {
+	event, ok := h.authorize(c, c.Param("id"), services.ActionManageInvites)
+	if !ok {
+		return
+	}
+
+	if err := h.invites.Revoke(event.ID, c.Param("invite_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// authorize loads an event and checks that the caller may perform the action on it, writing the
+// error response otherwise
+func (h *EventHandler) authorize(c *gin.Context, eventID string, action services.Action) (*models.Event, bool) {
+	return authorizeEvent(c, h.events, h.policy, eventID, action)
+}
+
+// authorizeEvent loads an event and checks that the caller may perform the action on it, writing the
+// error response otherwise. Anonymous callers may not perform any action.
+func authorizeEvent(
+	c *gin.Context,
+	events *services.EventService,
+	policy *services.EventPolicy,
+	eventID string,
+	action services.Action,
+) (*models.Event, bool) {
+	principal, ok := middleware.RequirePrincipal(c)
+	if !ok {
+		return nil, false
+	}
+
+	var event *models.Event
+	var err error
+	if principal.Invite != nil {
+		event, err = policy.AuthorizeGuest(eventID, principal.UserID, principal.Invite, action)
+	} else {
+		event, err = policy.Authorize(eventID, principal.UserID, action)
+	}
+	if err != nil {
+		writeServiceError(c, err)
+		return nil, false
+	}
+	return event, true
+}
+
+// authorizeAvailability checks that the caller may still manage availability in the event an
+// availability was submitted to. Ownership of the availability is checked by the service.
+func (h *EventHandler) authorizeAvailability(c *gin.Context, availabilityID string) bool {
+	availability, err := h.availabilities.Get(availabilityID)
+	if err != nil {
+		writeServiceError(c, err)
+		return false
+	}
+	_, ok := h.authorize(c, availability.EventID, services.ActionSubmitAvailability)
+	return ok
+}
+
+// duplicateParticipant returns the first user ID listed more than once
+func duplicateParticipant(participants []models.EventParticipant) (string, bool) {
+	seen := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		if seen[p.UserID] {
+			return p.UserID, true
+		}
+		seen[p.UserID] = true
+	}
+	return "", false
+}
+
+// writeServiceError maps service errors onto HTTP responses
+func writeServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrEventNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+	case errors.Is(err, services.ErrAvailabilityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Availability not found"})
+	case errors.Is(err, services.ErrNotAvailabilityOwner), errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInviteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+	case errors.Is(err, services.ErrInvalidInvite), errors.Is(err, services.ErrGuestEmailRequired),
+		errors.Is(err, services.ErrInvalidCalendar), errors.Is(err, services.ErrInvalidRecurrence):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	case errors.Is(err, services.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCalendarConnectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar connection not found"})
+	case errors.Is(err, services.ErrInvalidCalendarConnection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCalendarUnavailable):
+		// The cause tells hosts and ports apart, so it is logged rather than shown
+		log.Printf("Failed to read calendar: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Calendar server unavailable"})
+	case errors.Is(err, services.ErrInvalidRoleChange), errors.Is(err, services.ErrGuestAlreadyResponded),
+		errors.Is(err, services.ErrGuestAlreadyInvited):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition), errors.Is(err, services.ErrEventClosed),
+		errors.Is(err, services.ErrEventLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSlotNotRecommended):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
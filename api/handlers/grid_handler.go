@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+const (
+	gridWriteWait      = 10 * time.Second      // Time allowed to write a message to the client
+	gridPongWait       = 60 * time.Second      // Time allowed between two messages from the client
+	gridPingPeriod     = gridPongWait * 9 / 10 // Pings keep idle clients answering within gridPongWait
+	gridMaxMessageSize = 64 << 10
+)
+
+// gridUpgrader accepts WebSocket connections from pages served by the same host only
+var gridUpgrader = websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+
+// GridHandler serves the collaborative availability grids of events over WebSockets
+type GridHandler struct {
+	grids  *services.GridService
+	events *services.EventService
+	policy *services.EventPolicy
+}
+
+// NewGridHandler creates a new instance of GridHandler
+func NewGridHandler(grids *services.GridService, events *services.EventService, policy *services.EventPolicy) *GridHandler {
+	return &GridHandler{
+		grids:  grids,
+		events: events,
+		policy: policy,
+	}
+}
+
+// JoinGrid handles opening the availability grid of an event. The connection is upgraded to a
+// WebSocket exchanging JSON messages: the client paints cells, the server sends a snapshot of the
+// grid followed by every change to availability and presence.
+func (h *GridHandler) JoinGrid(c *gin.Context) {
+	// Painting submits availability, and the grid shows everyone's
+	event, ok := authorizeEvent(c, h.events, h.policy, c.Param("id"), services.ActionViewAvailabilities)
+	if !ok {
+		return
+	}
+	if _, ok := authorizeEvent(c, h.events, h.policy, event.ID, services.ActionSubmitAvailability); !ok {
+		return
+	}
+	userID, guest, ok := gridPainter(c)
+	if !ok {
+		return
+	}
+
+	client, snapshot, err := h.grids.Join(event, userID, guest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get availabilities"})
+		return
+	}
+	defer client.Leave()
+	conn, err := gridUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // The upgrader answered already
+	}
+	defer conn.Close()
+
+	go writeGridMessages(conn, client, snapshot)
+
+	conn.SetReadLimit(gridMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(gridPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(gridPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(gridPongWait))
+
+		var req models.GridRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			client.Reply(models.GridMessage{Type: models.GridMessageError, Error: "Invalid message: " + err.Error()})
+			continue
+		}
+		switch req.Type {
+		case models.GridMessagePaint:
+			if err := h.grids.Paint(client, req); err != nil {
+				client.Reply(models.GridMessage{Type: models.GridMessageError, Error: gridErrorMessage(err)})
+			}
+		default:
+			client.Reply(models.GridMessage{Type: models.GridMessageError, Error: "Unknown message type " + string(req.Type)})
+		}
+	}
+}
+
+// gridPainter identifies the caller painting a grid by their credentials only: users by their
+// principal, guests by their invite. Guests of an open invite paint with the personal invite they are
+// issued when they first submit their availability, as anyone holding the open invite could claim
+// their email. The caller was authorized already.
+func gridPainter(c *gin.Context) (string, *models.GuestDetails, bool) {
+	principal, _ := middleware.PrincipalFrom(c)
+	if principal.Invite == nil {
+		return principal.UserID, nil, true
+	}
+	if principal.Invite.Email == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Guests using an open invite must submit their availability first, then open the grid with their personal invite",
+		})
+		return "", nil, false
+	}
+	userID, guest, err := services.Guest(principal.Invite, c.Query("name"), "")
+	if err != nil {
+		writeServiceError(c, err)
+		return "", nil, false
+	}
+	return userID, guest, true
+}
+
+// writeGridMessages sends the snapshot then the changes received by the client, pinging it while
+// idle. All writes happen here, as a WebSocket has one writer at a time.
+func writeGridMessages(conn *websocket.Conn, client *services.GridClient, snapshot *models.GridMessage) {
+	ping := time.NewTicker(gridPingPeriod)
+	defer ping.Stop()
+	// Closing the connection stops the reader too
+	defer conn.Close()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(gridWriteWait))
+	if err := conn.WriteJSON(snapshot); err != nil {
+		return
+	}
+	for {
+		select {
+		case message, ok := <-client.Messages():
+			_ = conn.SetWriteDeadline(time.Now().Add(gridWriteWait))
+			if !ok {
+				// Left, or fell behind and should join again
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, ""))
+				return
+			}
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(gridWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// gridErrorMessage describes why a grid edit failed, hiding unexpected errors
+func gridErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrInvalidGridEdit), errors.Is(err, services.ErrEventClosed):
+		return err.Error()
+	case errors.Is(err, services.ErrEventNotFound):
+		return "Event not found"
+	default:
+		log.Printf("Failed to save grid edit: %v", err)
+		return "Failed to save availability"
+	}
+}
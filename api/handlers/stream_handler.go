@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+// StreamHandler pushes live updates of events to clients over Server-Sent Events
+type StreamHandler struct {
+	streams   *services.EventStreamBroker
+	events    *services.EventService
+	policy    *services.EventPolicy
+	heartbeat time.Duration
+}
+
+// NewStreamHandler creates a new instance of StreamHandler, writing a heartbeat to idle streams
+// every heartbeat, or every DefaultStreamHeartbeat when it is not positive
+func NewStreamHandler(
+	streams *services.EventStreamBroker,
+	events *services.EventService,
+	policy *services.EventPolicy,
+	heartbeat time.Duration,
+) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = services.DefaultStreamHeartbeat
+	}
+	return &StreamHandler{
+		streams:   streams,
+		events:    events,
+		policy:    policy,
+		heartbeat: heartbeat,
+	}
+}
+
+// StreamEvent handles streaming the availability submitted to an event and its recommendations as
+// they change. Clients resume with the Last-Event-ID header, or the last_event_id query parameter
+// where they cannot set headers.
+func (h *StreamHandler) StreamEvent(c *gin.Context) {
+	event, ok := authorizeEvent(c, h.events, h.policy, c.Param("id"), services.ActionViewRecommendations)
+	if !ok {
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	subscription, backlog, err := h.streams.Subscribe(event, lastEventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations"})
+		return
+	}
+	defer subscription.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Keeps proxies such as nginx from buffering the stream
+	c.Status(http.StatusOK)
+	for _, message := range backlog {
+		writeStreamMessage(c.Writer, message)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case message, ok := <-subscription.Messages():
+			if !ok {
+				return // Fell behind, the client resumes from the last message it got
+			}
+			writeStreamMessage(c.Writer, message)
+		case <-heartbeat.C:
+			// Comments are ignored by clients but keep proxies from closing idle connections
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeStreamMessage(w io.Writer, message services.StreamMessage) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", message.ID, message.Type, message.Data)
+}
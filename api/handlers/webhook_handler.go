@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+// WebhookHandler handles HTTP requests for webhook subscriptions. The webhooks of an event are
+// managed by its organizers, global webhooks by the configured webhook admins.
+type WebhookHandler struct {
+	webhooks *services.WebhookService
+	events   *services.EventService
+	policy   *services.EventPolicy
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler
+func NewWebhookHandler(
+	webhooks *services.WebhookService,
+	events *services.EventService,
+	policy *services.EventPolicy,
+) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks: webhooks,
+		events:   events,
+		policy:   policy,
+	}
+}
+
+// CreateWebhook handles subscribing a URL to changes. The secret signing its payloads is only
+// returned here.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.authorize(c, req.EventID) {
+		return
+	}
+
+	webhook, secret, err := h.webhooks.Create(c.GetString("user_id"), req)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateWebhookResponse{Webhook: *webhook, Secret: secret})
+}
+
+// ListWebhooks handles listing the webhooks of the event named by the event_id query parameter,
+// or the global webhooks without it
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	eventID := c.Query("event_id")
+	if !h.authorize(c, eventID) {
+		return
+	}
+
+	webhooks, err := h.webhooks.List(eventID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// DeleteWebhook handles unsubscribing a webhook
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if _, ok := h.authorizeWebhook(c); !ok {
+		return
+	}
+
+	if err := h.webhooks.Delete(c.Param("id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries handles listing the latest deliveries of a webhook, to debug its receiver
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	webhook, ok := h.authorizeWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.webhooks.Deliveries(webhook.ID)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// authorizeWebhook loads the webhook named in the path and checks that the caller may manage it
+func (h *WebhookHandler) authorizeWebhook(c *gin.Context) (*models.Webhook, bool) {
+	webhook, err := h.webhooks.Get(c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return nil, false
+	}
+	return webhook, h.authorize(c, webhook.EventID)
+}
+
+// authorize checks that the caller may manage the webhooks of an event, or the global webhooks when
+// eventID is empty, writing the error response otherwise. Guests never manage webhooks.
+func (h *WebhookHandler) authorize(c *gin.Context, eventID string) bool {
+	if eventID != "" {
+		_, ok := authorizeEvent(c, h.events, h.policy, eventID, services.ActionManageWebhooks)
+		return ok
+	}
+
+	principal, ok := middleware.RequirePrincipal(c)
+	if !ok {
+		return false
+	}
+	if principal.Invite != nil || !h.webhooks.IsAdmin(principal.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Global webhooks can only be managed by webhook admins"})
+		return false
+	}
+	return true
+}
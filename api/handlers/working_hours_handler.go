@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// WorkingHoursHandler handles HTTP requests for participant working hours
+type WorkingHoursHandler struct {
+	workingHoursRepo repository.WorkingHoursStore
+}
+
+// NewWorkingHoursHandler creates a new instance of WorkingHoursHandler
+func NewWorkingHoursHandler(workingHoursRepo repository.WorkingHoursStore) *WorkingHoursHandler {
+	return &WorkingHoursHandler{workingHoursRepo: workingHoursRepo}
+}
+
+// GetWorkingHours handles retrieving a participant's working hours
+func (h *WorkingHoursHandler) GetWorkingHours(c *gin.Context) {
+	// Working hours tell where and when someone works, so only they can read them
+	userID, ok := addressedOwner(c, "Working hours can only be read by their owner")
+	if !ok {
+		return
+	}
+
+	profile, err := h.workingHoursRepo.GetWorkingHours(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Working hours not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get working hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateWorkingHours handles registering a participant's home time zone and working hours
+func (h *WorkingHoursHandler) UpdateWorkingHours(c *gin.Context) {
+	// Working hours change how every event the user takes part in is scored
+	userID, ok := addressedOwner(c, "Working hours can only be changed by their owner")
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWorkingHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile := &models.WorkingHours{
+		UserID:    userID,
+		TimeZone:  req.TimeZone,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		WorkDays:  req.WorkDays,
+		UpdatedAt: time.Now(),
+	}
+	if err := services.ValidateWorkingHours(profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.workingHoursRepo.UpsertWorkingHours(profile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save working hours"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
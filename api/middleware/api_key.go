@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+// APIKeyHeader carries API keys
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator authenticates the API keys sent in the X-API-Key header
+type APIKeyAuthenticator struct {
+	keys *services.APIKeyService
+}
+
+// NewAPIKeyAuthenticator creates a new instance of APIKeyAuthenticator
+func NewAPIKeyAuthenticator(keys *services.APIKeyService) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// Authenticate looks up the API key of a request
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw := r.Header.Get(APIKeyHeader)
+	if raw == "" {
+		return nil, ErrNoCredentials
+	}
+
+	key, err := a.keys.Verify(raw)
+	if errors.Is(err, services.ErrInvalidAPIKey) {
+		return nil, &credentialError{public: "Invalid API key"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{UserID: key.UserID, Method: MethodAPIKey}, nil
+}
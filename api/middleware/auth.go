@@ -0,0 +1,121 @@
+// Package middleware contains the gin middleware shared by every route
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// Keys under which the authenticated caller is stored on the gin context
+const (
+	PrincipalKey = "principal"
+	UserIDKey    = "user_id"
+)
+
+// Authentication methods recorded on a Principal
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+	MethodInvite = "invite"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no credentials it handles
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID string
+	Method string              // How the caller authenticated, e.g. MethodJWT
+	Invite *models.EventInvite // The invite of a guest, who may only act on the invited event
+}
+
+// Authenticator identifies the caller of a request from one kind of credentials.
+// It returns ErrNoCredentials when the request carries none of its kind, so the next
+// authenticator can be tried, and any other error when the credentials are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticate rejects requests that none of the authenticators accept, and stores the
+// principal of accepted requests on the context under PrincipalKey and its user under UserIDKey
+func Authenticate(authenticators ...Authenticator) gin.HandlerFunc {
+	return authenticate(authenticators, true)
+}
+
+// AuthenticateOptional is like Authenticate but lets requests without credentials through anonymously
+func AuthenticateOptional(authenticators ...Authenticator) gin.HandlerFunc {
+	return authenticate(authenticators, false)
+}
+
+func authenticate(authenticators []Authenticator, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(c.Request)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			var rejected *credentialError
+			if errors.As(err, &rejected) {
+				unauthorized(c, rejected.public)
+				return
+			}
+			if err != nil {
+				log.Printf("Error authenticating request: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+				return
+			}
+
+			c.Set(PrincipalKey, principal)
+			c.Set(UserIDKey, principal.UserID)
+			c.Next()
+			return
+		}
+		if required {
+			unauthorized(c, "Authentication required")
+		}
+	}
+}
+
+// PrincipalFrom returns the principal Authenticate stored on the context
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*Principal)
+	return principal, ok
+}
+
+// RequirePrincipal returns the principal Authenticate stored on the context, rejecting
+// anonymous requests as unauthenticated
+func RequirePrincipal(c *gin.Context) (*Principal, bool) {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		unauthorized(c, "Authentication required")
+	}
+	return principal, ok
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="meeting-scheduler"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+// credentialError rejects a request with a message that is safe to return to the caller
+type credentialError struct {
+	public string
+	err    error
+}
+
+func (e *credentialError) Error() string {
+	if e.err == nil {
+		return e.public
+	}
+	return e.public + ": " + e.err.Error()
+}
+
+func (e *credentialError) Unwrap() error { return e.err }
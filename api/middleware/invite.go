@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shani34/meeting-scheduler/api/services"
+)
+
+// InviteTokenHeader carries guest invite tokens. Links can pass the token in the invite query parameter instead.
+const InviteTokenHeader = "X-Invite-Token"
+
+// InviteAuthenticator authenticates guests holding an invite token
+type InviteAuthenticator struct {
+	invites *services.InviteService
+}
+
+// NewInviteAuthenticator creates a new instance of InviteAuthenticator
+func NewInviteAuthenticator(invites *services.InviteService) *InviteAuthenticator {
+	return &InviteAuthenticator{invites: invites}
+}
+
+// Authenticate verifies the invite token of a request. Guests of an invite addressed to one
+// invitee are identified right away; guests of an open invite identify themselves when submitting.
+func (a *InviteAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get(InviteTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("invite")
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	invite, err := a.invites.Verify(token)
+	switch {
+	case errors.Is(err, services.ErrInvalidInvite):
+		return nil, &credentialError{public: "Invalid invite"}
+	case errors.Is(err, services.ErrInviteExpired):
+		return nil, &credentialError{public: "Invite expired"}
+	case errors.Is(err, services.ErrInviteRevoked):
+		return nil, &credentialError{public: "Invite revoked"}
+	case err != nil:
+		return nil, err
+	}
+
+	principal := &Principal{Method: MethodInvite, Invite: invite}
+	if invite.Email != "" {
+		principal.UserID = services.GuestUserID(invite.ID)
+	}
+	return principal, nil
+}
@@ -1,64 +1,409 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// PreferenceLevel represents how strongly a participant wants to meet during a time slot
+type PreferenceLevel string
+
+// Preference levels a participant can attach to an available time slot
+const (
+	PreferenceIfNeeded  PreferenceLevel = "if_needed"
+	PreferenceAvailable PreferenceLevel = "available"
+	PreferencePreferred PreferenceLevel = "preferred"
+)
 
 // TimeSlot represents a time slot with start and end times
 type TimeSlot struct {
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	TimeZone  string    `json:"time_zone"`
+	StartTime  time.Time       `json:"start_time"`
+	EndTime    time.Time       `json:"end_time"`
+	TimeZone   string          `json:"time_zone"`
+	Preference PreferenceLevel `json:"preference,omitempty" binding:"omitempty,oneof=if_needed available preferred"` // Defaults to available
+}
+
+// EventParticipant represents an invited participant and their role in scheduling
+type EventParticipant struct {
+	UserID   string   `json:"user_id" binding:"required"`
+	Required bool     `json:"required"`                                   // The slot is invalid without this participant
+	Weight   *float64 `json:"weight,omitempty" binding:"omitempty,min=0"` // Weight of an optional participant, defaults to 1, 0 does not count
 }
 
+// Recurrence describes how an event repeats using an RFC 5545 recurrence rule
+type Recurrence struct {
+	RRule    string      `json:"rrule"`             // e.g. "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO;COUNT=10"
+	ExDates  []time.Time `json:"exdates,omitempty"` // Days on which the event does not take place
+	TimeZone string      `json:"time_zone"`         // Zone occurrences are expanded in, defaults to the first time slot's zone
+}
+
+// EventStatus represents the lifecycle stage of an event
+type EventStatus string
+
+// Event lifecycle stages
+const (
+	EventStatusDraft     EventStatus = "draft"     // Being prepared, not yet collecting availability
+	EventStatusPolling   EventStatus = "polling"   // Collecting participant availability
+	EventStatusFinalized EventStatus = "finalized" // A time slot has been locked in
+	EventStatusCancelled EventStatus = "cancelled"
+)
+
 // Event represents a meeting event
 type Event struct {
-	ID        string     `json:"id"`
-	Title     string     `json:"title"`
-	Duration  int        `json:"duration"` // Duration in minutes
-	TimeSlots []TimeSlot `json:"time_slots"`
-	CreatedBy string     `json:"created_by"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID           string             `json:"id"`
+	Title        string             `json:"title"`
+	Description  string             `json:"description,omitempty"`
+	Duration     int                `json:"duration"` // Duration in minutes
+	TimeSlots    []TimeSlot         `json:"time_slots"`
+	Participants []EventParticipant `json:"participants"`
+	Recurrence   *Recurrence        `json:"recurrence,omitempty"` // The time slots describe the first occurrence
+	Status       EventStatus        `json:"status"`
+	Deadline     *time.Time         `json:"response_deadline,omitempty"` // Availability is rejected after this time
+	FinalSlot    *TimeSlot          `json:"final_slot,omitempty"`
+	FinalizedBy  string             `json:"finalized_by,omitempty"`
+	FinalizedAt  *time.Time         `json:"finalized_at,omitempty"`
+	CreatedBy    string             `json:"created_by"`
+	Revision     int                `json:"revision"` // Grows with every change to the event or its availability
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
 }
 
 // ParticipantAvailability represents a participant's available time slots
 type ParticipantAvailability struct {
+	ID        string        `json:"id"`
+	EventID   string        `json:"event_id"`
+	UserID    string        `json:"user_id"`
+	TimeSlots []TimeSlot    `json:"time_slots" binding:"dive"`
+	Guest     *GuestDetails `json:"guest,omitempty"` // Set when a guest submitted through an invite
+	// Source is AvailabilitySourceCalendar when the availability was last pulled from the user's
+	// connected calendar, and empty when it was last submitted by hand
+	Source    string    `json:"source,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AvailabilitySourceCalendar marks availability pulled from a connected calendar
+const AvailabilitySourceCalendar = "calendar"
+
+// GuestDetails records who submitted an availability through an invite
+type GuestDetails struct {
+	InviteID string `json:"invite_id"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email"`
+}
+
+// ScoreBreakdown explains how the score of a recommended time slot was computed
+type ScoreBreakdown struct {
+	RequiredAttendees    int     `json:"required_attendees"`
+	OptionalAttendees    int     `json:"optional_attendees"`
+	OptionalWeight       float64 `json:"optional_weight"` // Summed weight of the optional attendees
+	PreferredAttendees   int     `json:"preferred_attendees"`
+	IfNeededAttendees    int     `json:"if_needed_attendees"`
+	PreferenceAdjustment float64 `json:"preference_adjustment"` // Added for preferred and subtracted for if-needed attendees
+	OffHoursAttendees    int     `json:"off_hours_attendees"`
+	OffHoursPenalty      float64 `json:"off_hours_penalty"` // Subtracted for attendees outside their working hours
+}
+
+// WorkingHours represents a participant's home time zone and local working-hours profile
+type WorkingHours struct {
+	UserID    string    `json:"user_id"`
+	TimeZone  string    `json:"time_zone"`
+	StartTime string    `json:"start_time"` // Local start of the working day as "HH:MM", defaults to 09:00
+	EndTime   string    `json:"end_time"`   // Local end of the working day as "HH:MM", defaults to 17:00
+	WorkDays  []int     `json:"work_days"`  // Working weekdays (0 = Sunday), defaults to Monday to Friday
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventRole is the part a user plays in an event, which decides what they may do with it
+type EventRole string
+
+// Event roles, from most to least privileged
+const (
+	EventRoleOrganizer   EventRole = "organizer"    // Created the event and manages who takes part
+	EventRoleCoOrganizer EventRole = "co_organizer" // Edits and finalizes the event alongside the organizer
+	EventRoleParticipant EventRole = "participant"  // Submits their own availability
+	EventRoleViewer      EventRole = "viewer"       // Reads the event and its recommendations
+	EventRoleGuest       EventRole = "guest"        // Holds an invite; reads the event and submits availability
+)
+
+// EventRoleAssignment grants a user a role in an event. Users listed as participants of an event
+// are participants without an assignment.
+type EventRoleAssignment struct {
+	EventID string    `json:"event_id"`
+	UserID  string    `json:"user_id"`
+	Role    EventRole `json:"role"`
+}
+
+// EventInvite lets guests without an account submit availability to an event. An invite addressed
+// to one invitee is bound to their email; an open invite can be shared with anyone.
+type EventInvite struct {
 	ID        string     `json:"id"`
 	EventID   string     `json:"event_id"`
-	UserID    string     `json:"user_id"`
-	TimeSlots []TimeSlot `json:"time_slots"`
+	Name      string     `json:"name,omitempty"`
+	Email     string     `json:"email,omitempty"` // Empty for open invites
+	CreatedBy string     `json:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// APIKey lets a user authenticate without a token. Only a hash of the key is stored.
+type APIKey struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	KeyHash   string    `json:"-"` // Hex-encoded SHA-256 of the key
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalendarConnection points at the CalDAV calendar a user's availability is pulled from.
+// The password is stored sealed and never returned.
+type CalendarConnection struct {
+	UserID       string     `json:"user_id"`
+	URL          string     `json:"url"`
+	Username     string     `json:"username,omitempty"`
+	Secret       string     `json:"-"` // Password sealed with the configured credentials key
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"` // Why the last sync failed, empty after a success
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// CalendarSyncTarget is an event whose availability is pulled from a participant's calendar
+type CalendarSyncTarget struct {
+	EventID string
+	UserID  string
+}
+
+// EventReminder records a reminder sent to a participant who had not responded to an event
+type EventReminder struct {
+	EventID string
+	Offset  time.Duration // How long before the response deadline the reminder was due
+	UserID  string        // The participant, or the user ID of an invited guest
+	SentAt  time.Time
+}
+
+// ChangeType names a change to an event that other systems can be notified of
+type ChangeType string
+
+// Changes to events
+const (
+	ChangeEventCreated          ChangeType = "event.created"
+	ChangeEventUpdated          ChangeType = "event.updated"
+	ChangeEventOpened           ChangeType = "event.opened"
+	ChangeEventFinalized        ChangeType = "event.finalized"
+	ChangeEventCancelled        ChangeType = "event.cancelled"
+	ChangeAvailabilitySubmitted ChangeType = "availability.submitted"
+	ChangeAvailabilityWithdrawn ChangeType = "availability.withdrawn"
+)
+
+// Webhook subscribes a URL to changes to one event, or to every event when EventID is empty
+type Webhook struct {
+	ID          string       `json:"id"`
+	EventID     string       `json:"event_id,omitempty"`
+	URL         string       `json:"url"`
+	ChangeTypes []ChangeType `json:"change_types,omitempty"` // Delivered changes, all when empty
+	Secret      string       `json:"-"`                      // Signs payloads
+	CreatedBy   string       `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// WebhookDeliveryStatus is the state of a payload queued for a webhook
+type WebhookDeliveryStatus string
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // Waiting for its next attempt
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered" // Accepted by the receiver
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // Given up on after the last attempt
+)
+
+// WebhookDelivery is a payload queued for a webhook, kept after delivery as a log
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhook_id"`
+	ChangeType     ChangeType            `json:"change_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	ResponseStatus int                   `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the body posted to webhooks
+type WebhookPayload struct {
+	ID           string                   `json:"id"` // Identifies the change, the same for every webhook notified of it
+	Type         ChangeType               `json:"type"`
+	OccurredAt   time.Time                `json:"occurred_at"`
+	Event        *Event                   `json:"event"`
+	Availability *ParticipantAvailability `json:"availability,omitempty"` // For availability changes
+}
+
+// ParticipantLocalTime represents a recommended time slot in a participant's local time
+type ParticipantLocalTime struct {
+	UserID             string    `json:"user_id"`
+	TimeZone           string    `json:"time_zone"`
+	StartTime          time.Time `json:"start_time"`
+	EndTime            time.Time `json:"end_time"`
+	WithinWorkingHours *bool     `json:"within_working_hours,omitempty"` // Unset when the participant has no working hours
 }
 
 // RecommendedTimeSlot represents a recommended meeting time slot
 type RecommendedTimeSlot struct {
-	TimeSlot     TimeSlot `json:"time_slot"`
-	Participants []string `json:"participants"`
-	MissingUsers []string `json:"missing_users"`
-	Score        int      `json:"score"`
+	TimeSlot     TimeSlot               `json:"time_slot"`
+	Participants []string               `json:"participants"`
+	MissingUsers []string               `json:"missing_users"`
+	Score        float64                `json:"score"`
+	Breakdown    ScoreBreakdown         `json:"breakdown"`
+	LocalTimes   []ParticipantLocalTime `json:"local_times"`
+	Occurrences  []time.Time            `json:"occurrences,omitempty"`           // Occurrence starts of a recurring event
+	Attendance   map[string]int         `json:"occurrence_attendance,omitempty"` // Occurrences each user can attend
+}
+
+// StreamAvailability tells the clients streaming an event that someone submitted or withdrew
+// availability, without their time slots
+type StreamAvailability struct {
+	EventID   string    `json:"event_id"`
+	UserID    string    `json:"user_id"`
+	GuestName string    `json:"guest_name,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StreamEvent tells the clients streaming an event that it was edited, opened, finalized or cancelled
+type StreamEvent struct {
+	EventID string     `json:"event_id"`
+	Change  ChangeType `json:"change"`
+	Event   *Event     `json:"event"`
+}
+
+// StreamRecommendations carries the recommended time slots of an event to the clients streaming it
+type StreamRecommendations struct {
+	EventID         string                `json:"event_id"`
+	Recommendations []RecommendedTimeSlot `json:"recommendations"`
+}
+
+// GridMessageType names the messages exchanged over the availability grid of an event
+type GridMessageType string
+
+// Availability grid messages
+const (
+	GridMessagePaint        GridMessageType = "paint"        // Sent by clients to mark cells available or not
+	GridMessageSnapshot     GridMessageType = "snapshot"     // The grid as it is when the client joins
+	GridMessageAvailability GridMessageType = "availability" // Someone's availability changed
+	GridMessagePresence     GridMessageType = "presence"     // Someone joined or left the grid
+	GridMessageError        GridMessageType = "error"        // A message of the client was rejected
+)
+
+// GridRequest is a message sent by a client of an availability grid
+type GridRequest struct {
+	Type       GridMessageType `json:"type"`
+	Cells      []time.Time     `json:"cells"` // Start times of the painted cells
+	Available  bool            `json:"available"`
+	Preference PreferenceLevel `json:"preference,omitempty"` // Of available cells, defaults to available
+}
+
+// GridAvailability is the availability of one user on a grid, as ranges of time
+type GridAvailability struct {
+	UserID    string     `json:"user_id"`
+	TimeSlots []TimeSlot `json:"time_slots"` // Empty once the user withdrew their availability
+}
+
+// GridMessage is a message sent to the clients of an availability grid
+type GridMessage struct {
+	Type           GridMessageType    `json:"type"`
+	UserID         string             `json:"user_id,omitempty"`      // The client, in snapshots
+	CellMinutes    int                `json:"cell_minutes,omitempty"` // In snapshots
+	Windows        []TimeSlot         `json:"windows,omitempty"`      // The event's time slots, in snapshots
+	Availabilities []GridAvailability `json:"availabilities,omitempty"`
+	Viewers        []string           `json:"viewers,omitempty"` // Users currently on the grid
+	Error          string             `json:"error,omitempty"`
 }
 
 // CreateEventRequest represents the request body for creating an event
 type CreateEventRequest struct {
-	Title     string     `json:"title" binding:"required"`
-	Duration  int        `json:"duration" binding:"required"`
-	TimeSlots []TimeSlot `json:"time_slots" binding:"required"`
+	Title        string             `json:"title" binding:"required"`
+	Description  string             `json:"description"`
+	Duration     int                `json:"duration" binding:"required"`
+	TimeSlots    []TimeSlot         `json:"time_slots" binding:"required"`
+	Participants []EventParticipant `json:"participants" binding:"dive"`
+	Recurrence   *Recurrence        `json:"recurrence"`
+	Status       EventStatus        `json:"status" binding:"omitempty,oneof=draft polling"` // Defaults to polling
+	Deadline     *time.Time         `json:"response_deadline"`
 }
 
 // UpdateEventRequest represents the request body for updating an event
 type UpdateEventRequest struct {
-	Title     string     `json:"title"`
-	Duration  int        `json:"duration"`
-	TimeSlots []TimeSlot `json:"time_slots"`
+	Title        string             `json:"title" binding:"required"`
+	Description  string             `json:"description"`
+	Duration     int                `json:"duration" binding:"required"`
+	TimeSlots    []TimeSlot         `json:"time_slots" binding:"required"`
+	Participants []EventParticipant `json:"participants" binding:"dive"`
+	Recurrence   *Recurrence        `json:"recurrence"`
+	Deadline     *time.Time         `json:"response_deadline"`
+}
+
+// FinalizeEventRequest represents the request body for locking in a recommended time slot
+type FinalizeEventRequest struct {
+	StartTime time.Time `json:"start_time" binding:"required"` // Start of the chosen recommended time slot
 }
 
 // CreateAvailabilityRequest represents the request body for creating participant availability
 type CreateAvailabilityRequest struct {
-	EventID   string     `json:"event_id" binding:"required"`
-	TimeSlots []TimeSlot `json:"time_slots" binding:"required"`
+	EventID    string     `json:"event_id"` // Falls back to the event_id query parameter
+	GuestName  string     `json:"guest_name"`
+	GuestEmail string     `json:"guest_email"` // Identifies guests submitting through an open invite
+	TimeSlots  []TimeSlot `json:"time_slots" binding:"required,dive"`
 }
 
 // UpdateAvailabilityRequest represents the request body for updating participant availability
 type UpdateAvailabilityRequest struct {
-	TimeSlots []TimeSlot `json:"time_slots" binding:"required"`
-} 
\ No newline at end of file
+	TimeSlots []TimeSlot `json:"time_slots" binding:"required,dive"`
+}
+
+// AssignEventRoleRequest represents the request body for granting a user a role in an event
+type AssignEventRoleRequest struct {
+	Role EventRole `json:"role" binding:"required,oneof=co_organizer participant viewer"`
+}
+
+// CreateInviteRequest represents the request body for inviting guests to an event
+type CreateInviteRequest struct {
+	Name      string     `json:"name"`
+	Email     string     `json:"email" binding:"omitempty,email"` // Leave empty for an open invite
+	ExpiresAt *time.Time `json:"expires_at"`                      // Defaults to the configured invite lifetime
+}
+
+// CreateInviteResponse returns a new invite with the token guests present to use it
+type CreateInviteResponse struct {
+	Invite EventInvite `json:"invite"`
+	Token  string      `json:"token"` // Shown only once
+}
+
+// UpdateWorkingHoursRequest represents the request body for registering a participant's working hours
+type UpdateWorkingHoursRequest struct {
+	TimeZone  string `json:"time_zone" binding:"required"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	WorkDays  []int  `json:"work_days"`
+}
+
+// UpdateCalendarConnectionRequest represents the request body for connecting a CalDAV calendar
+type UpdateCalendarConnectionRequest struct {
+	URL      string `json:"url" binding:"required"` // Calendar collection to query
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CreateWebhookRequest represents the request body for subscribing a webhook
+type CreateWebhookRequest struct {
+	URL         string       `json:"url" binding:"required"`
+	EventID     string       `json:"event_id"`     // Leave empty to be notified of every event
+	ChangeTypes []ChangeType `json:"change_types"` // Leave empty to be notified of every change
+}
+
+// CreateWebhookResponse returns a new webhook with the secret its payloads are signed with
+type CreateWebhookResponse struct {
+	Webhook Webhook `json:"webhook"`
+	Secret  string  `json:"secret"` // Shown only once
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// apiKeyPrefix marks generated API keys so they are recognisable in configuration and logs
+const apiKeyPrefix = "msk_"
+
+var (
+	// ErrInvalidAPIKey is returned when a presented API key is unknown or revoked
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyNotFound is returned when revoking an API key that does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyService issues and verifies API keys. Keys are random, so a plain SHA-256 hash is enough
+// to keep them unusable if the database leaks while still allowing lookups by hash.
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyStore
+	now        func() time.Time
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService
+func NewAPIKeyService(apiKeyRepo repository.APIKeyStore) *APIKeyService {
+	return &APIKeyService{apiKeyRepo: apiKeyRepo, now: time.Now}
+}
+
+// Create issues a new API key for a user. The key itself is returned only here; just its hash is stored.
+func (s *APIKeyService) Create(userID, name string) (*models.APIKey, string, error) {
+	if strings.TrimSpace(userID) == "" {
+		return nil, "", errors.New("user ID is required")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	raw := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		KeyHash:   HashAPIKey(raw),
+		CreatedAt: s.now(),
+	}
+	if err := s.apiKeyRepo.CreateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+// Verify returns the stored API key matching a presented key
+func (s *APIKeyService) Verify(raw string) (*models.APIKey, error) {
+	if raw == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(HashAPIKey(raw))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Revoke deletes an API key so it can no longer be used
+func (s *APIKeyService) Revoke(id string) error {
+	err := s.apiKeyRepo.DeleteAPIKey(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// HashAPIKey returns the hash under which an API key is stored
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
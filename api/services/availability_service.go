@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+var (
+	// ErrAvailabilityNotFound is returned when an availability does not exist
+	ErrAvailabilityNotFound = errors.New("availability not found")
+	// ErrNotAvailabilityOwner is returned when a user changes an availability someone else submitted
+	ErrNotAvailabilityOwner = errors.New("availability belongs to another user")
+)
+
+// AvailabilityService manages the availability participants submit to events.
+// Each user holds at most one availability per event, so resubmitting replaces it.
+type AvailabilityService struct {
+	availabilityRepo repository.AvailabilityStore
+	eventRepo        repository.EventStore
+	events           *EventService
+	now              func() time.Time
+}
+
+// NewAvailabilityService creates a new instance of AvailabilityService
+func NewAvailabilityService(
+	availabilityRepo repository.AvailabilityStore,
+	eventRepo repository.EventStore,
+	events *EventService,
+) *AvailabilityService {
+	return &AvailabilityService{
+		availabilityRepo: availabilityRepo,
+		eventRepo:        eventRepo,
+		events:           events,
+		now:              time.Now,
+	}
+}
+
+// Submit stores a user's availability for an event, replacing the one they submitted before
+func (s *AvailabilityService) Submit(eventID, userID string, slots []models.TimeSlot) (*models.ParticipantAvailability, error) {
+	return s.submit(eventID, userID, nil, "", slots, s.availabilityRepo.UpsertAvailability)
+}
+
+// SubmitFromCalendar stores the availability pulled from a user's connected calendar, replacing the
+// one they submitted before
+func (s *AvailabilityService) SubmitFromCalendar(eventID, userID string, slots []models.TimeSlot) (*models.ParticipantAvailability, error) {
+	return s.submit(eventID, userID, nil, models.AvailabilitySourceCalendar, slots, s.availabilityRepo.UpsertAvailability)
+}
+
+// SubmitAsGuest stores the availability a guest submitted through an invite, recording who they are
+func (s *AvailabilityService) SubmitAsGuest(
+	eventID, userID string,
+	guest *models.GuestDetails,
+	slots []models.TimeSlot,
+) (*models.ParticipantAvailability, error) {
+	return s.submit(eventID, userID, guest, "", slots, s.availabilityRepo.UpsertAvailability)
+}
+
+// SubmitAsNewGuest stores the first availability a guest submits through an open invite together with
+// the personal invite issued to them, so a failed submission leaves no invite behind. Emails that
+// already have an invite to the event are refused with ErrGuestAlreadyInvited.
+func (s *AvailabilityService) SubmitAsNewGuest(
+	eventID, userID string,
+	guest *models.GuestDetails,
+	invite *models.EventInvite,
+	slots []models.TimeSlot,
+) (*models.ParticipantAvailability, error) {
+	availability, err := s.submit(eventID, userID, guest, "", slots, func(availability *models.ParticipantAvailability) error {
+		return s.availabilityRepo.CreateGuestResponse(invite, availability)
+	})
+	if errors.Is(err, repository.ErrAlreadyInvited) {
+		return nil, ErrGuestAlreadyInvited
+	}
+	return availability, err
+}
+
+// submit stores an availability with save, which creates it or replaces the one submitted before
+func (s *AvailabilityService) submit(
+	eventID, userID string,
+	guest *models.GuestDetails,
+	source string,
+	slots []models.TimeSlot,
+	save func(*models.ParticipantAvailability) error,
+) (*models.ParticipantAvailability, error) {
+	event, err := s.events.CheckAcceptingAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	availability := &models.ParticipantAvailability{
+		ID:        uuid.New().String(),
+		EventID:   eventID,
+		UserID:    userID,
+		TimeSlots: slots,
+		Guest:     guest,
+		Source:    source,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := save(availability); err != nil {
+		return nil, err
+	}
+	s.events.publish(models.ChangeAvailabilitySubmitted, event, availability)
+	return availability, nil
+}
+
+// Get retrieves an availability, translating missing records into ErrAvailabilityNotFound
+func (s *AvailabilityService) Get(availabilityID string) (*models.ParticipantAvailability, error) {
+	availability, err := s.availabilityRepo.GetAvailability(availabilityID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAvailabilityNotFound
+	}
+	return availability, err
+}
+
+// ListForEvent retrieves every availability submitted to an event
+func (s *AvailabilityService) ListForEvent(eventID string) ([]models.ParticipantAvailability, error) {
+	if _, err := s.events.GetEvent(eventID); err != nil {
+		return nil, err
+	}
+	availabilities, err := s.eventRepo.GetParticipantAvailabilities(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if availabilities == nil {
+		availabilities = []models.ParticipantAvailability{}
+	}
+	return availabilities, nil
+}
+
+// Submitted returns the availability a user submitted to an event, or nil when they submitted none
+func (s *AvailabilityService) Submitted(eventID, userID string) (*models.ParticipantAvailability, error) {
+	availabilities, err := s.ListForEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range availabilities {
+		if availabilities[i].UserID == userID {
+			return &availabilities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// GuestResponded reports whether a guest with the email already submitted availability to an event
+func (s *AvailabilityService) GuestResponded(eventID, email string) (bool, error) {
+	availabilities, err := s.ListForEvent(eventID)
+	if err != nil {
+		return false, err
+	}
+	for _, availability := range availabilities {
+		if availability.Guest != nil && availability.Guest.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Update replaces the time slots of an availability on behalf of the user who submitted it, by hand
+func (s *AvailabilityService) Update(availabilityID, userID string, slots []models.TimeSlot) (*models.ParticipantAvailability, error) {
+	availability, event, err := s.owned(availabilityID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	availability.TimeSlots = slots
+	availability.Source = ""
+	availability.UpdatedAt = s.now()
+	if err := s.availabilityRepo.UpdateAvailability(availability); err != nil {
+		return nil, err
+	}
+	s.events.publish(models.ChangeAvailabilitySubmitted, event, availability)
+	return availability, nil
+}
+
+// Delete withdraws an availability on behalf of the user who submitted it
+func (s *AvailabilityService) Delete(availabilityID, userID string) error {
+	availability, event, err := s.owned(availabilityID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.availabilityRepo.DeleteAvailability(availabilityID); err != nil {
+		return err
+	}
+	s.events.publish(models.ChangeAvailabilityWithdrawn, event, availability)
+	return nil
+}
+
+// owned loads an availability the user may change, their own on an event still accepting
+// availability, along with the event
+func (s *AvailabilityService) owned(availabilityID, userID string) (*models.ParticipantAvailability, *models.Event, error) {
+	availability, err := s.Get(availabilityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := CheckAvailabilityOwner(availability, userID); err != nil {
+		return nil, nil, err
+	}
+	event, err := s.events.CheckAcceptingAvailability(availability.EventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return availability, event, nil
+}
+
+// CheckAvailabilityOwner returns ErrNotAvailabilityOwner unless the user submitted the availability
+func CheckAvailabilityOwner(availability *models.ParticipantAvailability, userID string) error {
+	if userID == "" || availability.UserID != userID {
+		return ErrNotAvailabilityOwner
+	}
+	return nil
+}
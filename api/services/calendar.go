@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+)
+
+// calendarProductID identifies the server as the producer of exported calendars
+const calendarProductID = "-//Meeting Scheduler//Meeting Scheduler API//EN"
+
+// Bounds on the candidate time slots exported for events that are not finalized
+const (
+	DefaultCalendarCandidates = 3
+	MaxCalendarCandidates     = 10
+)
+
+// calendarZoneYears bounds how far ahead time zones of recurring events are described
+const calendarZoneYears = 10
+
+// maxCalendarOccurrences bounds the occurrences expanded to find the end of a recurring event
+const maxCalendarOccurrences = 1000
+
+// CalendarService renders events as iCalendar (RFC 5545) files
+type CalendarService struct {
+	events      *EventService
+	emailDomain string
+	now         func() time.Time
+}
+
+// NewCalendarService creates a new instance of CalendarService. Users whose ID is not an email
+// address are given one at emailDomain in ORGANIZER and ATTENDEE lines.
+func NewCalendarService(events *EventService, emailDomain string) *CalendarService {
+	return &CalendarService{events: events, emailDomain: emailDomain, now: time.Now}
+}
+
+// Export renders an event as a calendar file. A finalized event is exported at its final slot;
+// other events as up to candidates tentative events, one per top recommended time slot.
+//
+// Every export of an event reuses the same UIDs with a growing SEQUENCE, so calendar clients
+// replace earlier imports: the final slot takes over the UID of the top candidate and the other
+// candidates are exported as cancelled.
+func (s *CalendarService) Export(event *models.Event, candidates int) ([]byte, error) {
+	availabilities, err := s.events.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	recommendations, err := s.events.recommend(event, availabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &calendarExport{
+		CalendarService: s,
+		event:           event,
+		availabilities:  availabilities,
+		stamp:           s.now(),
+		zones:           make(map[string]*zoneRange),
+	}
+	export.modified, export.sequence = calendarRevision(event, availabilities)
+
+	var events []*ical.Component
+	if event.FinalSlot != nil {
+		status := "CONFIRMED"
+		if event.Status == models.EventStatusCancelled {
+			status = "CANCELLED"
+		}
+		events = append(events, export.vevent(0, *event.FinalSlot, event.Title, status,
+			findRecommendation(recommendations, *event.FinalSlot)))
+		// Withdraw the other candidates clients may have imported before finalization
+		for rank := 1; rank < candidates && rank < len(recommendations); rank++ {
+			events = append(events, export.vevent(rank, recommendations[rank].TimeSlot,
+				candidateSummary(event, rank), "CANCELLED", nil))
+		}
+	} else {
+		status := "TENTATIVE"
+		if event.Status == models.EventStatusCancelled {
+			status = "CANCELLED"
+		}
+		for rank := 0; rank < candidates && rank < len(recommendations); rank++ {
+			events = append(events, export.vevent(rank, recommendations[rank].TimeSlot,
+				candidateSummary(event, rank), status, &recommendations[rank]))
+		}
+	}
+
+	calendar := ical.NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", calendarProductID)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	calendar.Add("METHOD", "PUBLISH")
+	calendar.Add("X-WR-CALNAME", ical.Text(event.Title))
+	for _, zone := range export.zoneList() {
+		calendar.AddComponent(ical.Timezone(zone.loc, zone.from, zone.to))
+	}
+	for _, vevent := range events {
+		calendar.AddComponent(vevent)
+	}
+
+	var buf bytes.Buffer
+	if err := calendar.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// calendarExport holds what the events of one exported calendar share
+type calendarExport struct {
+	*CalendarService
+	event          *models.Event
+	availabilities []models.ParticipantAvailability
+	stamp          time.Time
+	modified       time.Time
+	sequence       int
+	zones          map[string]*zoneRange
+	zoneOrder      []string
+}
+
+// zoneRange is the span of time a VTIMEZONE component must describe
+type zoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// vevent renders one time slot of the event. The rank selects the UID; recommendation, when
+// known, tells which attendees are available.
+func (x *calendarExport) vevent(rank int, slot models.TimeSlot, summary, status string, recommendation *models.RecommendedTimeSlot) *ical.Component {
+	// Slots are written in the event's own zone rather than the UTC of recommendations
+	event := x.event
+	loc := recurrenceLocation(event)
+	start, end := slot.StartTime.In(loc), slot.EndTime.In(loc)
+
+	vevent := ical.NewComponent("VEVENT")
+	vevent.Add("UID", calendarUID(event.ID, rank))
+	vevent.Add("DTSTAMP", ical.DateTime(x.stamp))
+	vevent.Add("SEQUENCE", fmt.Sprint(x.sequence))
+	vevent.Add("CREATED", ical.DateTime(event.CreatedAt))
+	vevent.Add("LAST-MODIFIED", ical.DateTime(x.modified))
+	vevent.TimeProperty("DTSTART", start)
+	vevent.TimeProperty("DTEND", end)
+	vevent.Add("SUMMARY", ical.Text(summary))
+	if event.Description != "" {
+		vevent.Add("DESCRIPTION", ical.Text(event.Description))
+	}
+	vevent.Add("STATUS", status)
+	if status != "CONFIRMED" {
+		// Proposed and withdrawn times do not block anyone's calendar
+		vevent.Add("TRANSP", "TRANSPARENT")
+	}
+
+	last := end
+	if event.Recurrence != nil && event.Recurrence.RRule != "" {
+		last = x.addRecurrence(vevent, start, end)
+	}
+	x.cover(loc, start, last)
+
+	if event.CreatedBy != "" {
+		vevent.Add("ORGANIZER", x.calendarAddress(event.CreatedBy), ical.Param{Name: "CN", Value: event.CreatedBy})
+	}
+	x.addAttendees(vevent, recommendation)
+	return vevent
+}
+
+// addRecurrence adds the recurrence rule and excluded dates of the event to a VEVENT starting at
+// start, and returns the end of its last occurrence, bounded by calendarZoneYears
+func (x *calendarExport) addRecurrence(vevent *ical.Component, start, end time.Time) time.Time {
+	recurrence := x.event.Recurrence
+	vevent.Add("RRULE", strings.ToUpper(recurrence.RRule))
+	for _, exdate := range recurrence.ExDates {
+		day := exdate.In(start.Location())
+		vevent.TimeProperty("EXDATE", time.Date(day.Year(), day.Month(), day.Day(),
+			start.Hour(), start.Minute(), start.Second(), 0, start.Location()))
+	}
+
+	limit := start.AddDate(calendarZoneYears, 0, 0)
+	rule, err := ParseRRule(recurrence.RRule, start.Location())
+	if err != nil {
+		return limit // Validated when the event was saved
+	}
+	occurrences := rule.Occurrences(start, recurrence.ExDates, maxCalendarOccurrences)
+	if len(occurrences) == 0 {
+		return end
+	}
+	last := occurrences[len(occurrences)-1].Add(end.Sub(start))
+	if last.After(limit) || (len(occurrences) == maxCalendarOccurrences) {
+		return limit
+	}
+	return last
+}
+
+// addAttendees lists the event's participants, then everyone else who submitted availability.
+// Attendees available at the recommended slot have accepted it.
+func (x *calendarExport) addAttendees(vevent *ical.Component, recommendation *models.RecommendedTimeSlot) {
+	available := make(map[string]bool)
+	if recommendation != nil {
+		for _, userID := range recommendation.Participants {
+			available[userID] = true
+		}
+	}
+	partstat := func(userID string) string {
+		if available[userID] {
+			return "ACCEPTED"
+		}
+		return "NEEDS-ACTION"
+	}
+
+	listed := make(map[string]bool, len(x.event.Participants))
+	for _, participant := range x.event.Participants {
+		listed[participant.UserID] = true
+		role := "OPT-PARTICIPANT"
+		if participant.Required {
+			role = "REQ-PARTICIPANT"
+		}
+		vevent.Add("ATTENDEE", x.calendarAddress(participant.UserID),
+			ical.Param{Name: "CN", Value: participant.UserID},
+			ical.Param{Name: "ROLE", Value: role},
+			ical.Param{Name: "PARTSTAT", Value: partstat(participant.UserID)},
+		)
+	}
+	for _, availability := range x.availabilities {
+		if listed[availability.UserID] {
+			continue
+		}
+		address, name := x.calendarAddress(availability.UserID), availability.UserID
+		if guest := availability.Guest; guest != nil {
+			address = "mailto:" + guest.Email
+			if guest.Name != "" {
+				name = guest.Name
+			} else {
+				name = guest.Email
+			}
+		}
+		vevent.Add("ATTENDEE", address,
+			ical.Param{Name: "CN", Value: name},
+			ical.Param{Name: "ROLE", Value: "OPT-PARTICIPANT"},
+			ical.Param{Name: "PARTSTAT", Value: partstat(availability.UserID)},
+		)
+	}
+}
+
+// cover records that the VTIMEZONE of loc must describe the time from start to end
+func (x *calendarExport) cover(loc *time.Location, start, end time.Time) {
+	if ical.IsUTC(loc) {
+		return
+	}
+	// Observances start at the beginning of the year so the first one precedes every event
+	from := time.Date(start.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	zone, ok := x.zones[loc.String()]
+	if !ok {
+		x.zones[loc.String()] = &zoneRange{loc: loc, from: from, to: end}
+		x.zoneOrder = append(x.zoneOrder, loc.String())
+		return
+	}
+	if from.Before(zone.from) {
+		zone.from = from
+	}
+	if end.After(zone.to) {
+		zone.to = end
+	}
+}
+
+// zoneList returns the zones used by the exported events in order of first use
+func (x *calendarExport) zoneList() []*zoneRange {
+	zones := make([]*zoneRange, 0, len(x.zoneOrder))
+	for _, name := range x.zoneOrder {
+		zones = append(zones, x.zones[name])
+	}
+	return zones
+}
+
+// calendarAddress returns the CAL-ADDRESS of a user
+func (s *CalendarService) calendarAddress(userID string) string {
+	return "mailto:" + emailAddress(userID, s.emailDomain)
+}
+
+// calendarUID returns the UID of an exported event. The top candidate and the final slot share
+// the UID of the event itself.
+func calendarUID(eventID string, rank int) string {
+	if rank == 0 {
+		return eventID + "@meeting-scheduler"
+	}
+	return fmt.Sprintf("%s-candidate-%d@meeting-scheduler", eventID, rank+1)
+}
+
+// candidateSummary titles a candidate time slot by its rank
+func candidateSummary(event *models.Event, rank int) string {
+	return fmt.Sprintf("%s (option %d)", event.Title, rank+1)
+}
+
+// calendarRevision returns when an event or the availability submitted to it last changed, and
+// the SEQUENCE of exports made since: the event's revision, which grows with every change.
+func calendarRevision(event *models.Event, availabilities []models.ParticipantAvailability) (time.Time, int) {
+	modified := event.UpdatedAt
+	for _, availability := range availabilities {
+		if availability.UpdatedAt.After(modified) {
+			modified = availability.UpdatedAt
+		}
+	}
+	return modified, event.Revision
+}
+
+// findRecommendation returns the recommendation starting at the same time as a slot
+func findRecommendation(recommendations []models.RecommendedTimeSlot, slot models.TimeSlot) *models.RecommendedTimeSlot {
+	for i := range recommendations {
+		if recommendations[i].TimeSlot.StartTime.Equal(slot.StartTime) {
+			return &recommendations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/caldav"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+var (
+	// ErrCalendarConnectionNotFound is returned when a user has not connected a calendar
+	ErrCalendarConnectionNotFound = errors.New("calendar connection not found")
+	// ErrInvalidCalendarConnection is returned when a calendar connection cannot be used
+	ErrInvalidCalendarConnection = errors.New("invalid calendar connection")
+)
+
+// CalendarConnectionService manages the CalDAV calendars users connect, and reads their busy time
+// from them as an availability source. Passwords are stored sealed with AES-GCM.
+type CalendarConnectionService struct {
+	connections repository.CalendarConnectionStore
+	aead        cipher.AEAD
+	client      *caldav.Client
+	now         func() time.Time
+}
+
+// NewCalendarConnectionService creates a new instance of CalendarConnectionService. Passwords are
+// sealed with a key derived from credentialsKey, which must stay the same for stored passwords
+// to remain readable.
+func NewCalendarConnectionService(
+	connections repository.CalendarConnectionStore,
+	credentialsKey string,
+	client *caldav.Client,
+) (*CalendarConnectionService, error) {
+	key := sha256.Sum256([]byte(credentialsKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CalendarConnectionService{
+		connections: connections,
+		aead:        aead,
+		client:      client,
+		now:         time.Now,
+	}, nil
+}
+
+// Connect creates or replaces the calendar a user's availability is pulled from. Calendars on the
+// server's own networks are refused.
+func (s *CalendarConnectionService) Connect(userID string, req models.UpdateCalendarConnectionRequest) (*models.CalendarConnection, error) {
+	calendarURL, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (calendarURL.Scheme != "http" && calendarURL.Scheme != "https") || calendarURL.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidCalendarConnection)
+	}
+	if calendarURL.User != nil {
+		return nil, fmt.Errorf("%w: give credentials as username and password, not in the url", ErrInvalidCalendarConnection)
+	}
+	if err := s.client.CheckURL(calendarURL); err != nil {
+		return nil, fmt.Errorf("%w: url must not target the server's own networks, %v", ErrInvalidCalendarConnection, err)
+	}
+
+	secret, err := s.seal(userID, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	connection := &models.CalendarConnection{
+		UserID:    userID,
+		URL:       calendarURL.String(),
+		Username:  req.Username,
+		Secret:    secret,
+		UpdatedAt: s.now(),
+	}
+	if err := s.connections.UpsertCalendarConnection(connection); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+// Get retrieves the calendar connection of a user
+func (s *CalendarConnectionService) Get(userID string) (*models.CalendarConnection, error) {
+	connection, err := s.connections.GetCalendarConnection(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCalendarConnectionNotFound
+	}
+	return connection, err
+}
+
+// Disconnect deletes the calendar connection of a user. Availability already pulled from the calendar is kept.
+func (s *CalendarConnectionService) Disconnect(userID string) error {
+	err := s.connections.DeleteCalendarConnection(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrCalendarConnectionNotFound
+	}
+	return err
+}
+
+// Busy queries the connected calendar of a user for their busy time between start and end.
+// It returns repository.ErrNotFound when the user has not connected a calendar.
+func (s *CalendarConnectionService) Busy(ctx context.Context, userID string, start, end time.Time) ([]*ical.Component, error) {
+	connection, err := s.connections.GetCalendarConnection(userID)
+	if err != nil {
+		return nil, err
+	}
+	password, err := s.open(userID, connection.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Busy(ctx, caldav.Account{URL: connection.URL, Username: connection.Username, Password: password}, start, end)
+}
+
+// seal encrypts a password, prefixing it with a random nonce. The password is bound to the user
+// it belongs to, so it cannot be copied to another user's connection.
+func (s *CalendarConnectionService) seal(userID, password string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(password), []byte(userID))), nil
+}
+
+// open decrypts a password sealed by seal
+func (s *CalendarConnectionService) open(userID, secret string) (string, error) {
+	unreadable := fmt.Errorf("%w: the stored password cannot be decrypted, connect the calendar again", ErrInvalidCalendarConnection)
+	sealed, err := base64.StdEncoding.DecodeString(secret)
+	nonceSize := s.aead.NonceSize()
+	if err != nil || len(sealed) < nonceSize {
+		return "", unreadable
+	}
+	password, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(userID))
+	if err != nil {
+		return "", unreadable
+	}
+	return string(password), nil
+}
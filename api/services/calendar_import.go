@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// ErrInvalidCalendar is returned when an uploaded calendar cannot be read
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+// busyPeriod is a span of time taken in an imported calendar
+type busyPeriod struct {
+	start, end time.Time
+}
+
+// ImportAvailability reads the busy time of an iCalendar file, from its events and VFREEBUSY
+// components, and returns the free time left in the windows of an event. Busy recurring events
+// are expanded, and the windows of a recurring event are repeated for every occurrence the
+// scheduler considers. Free time too short to hold the meeting is dropped.
+//
+// Events marked transparent or cancelled do not take time. Times without a zone, and all-day
+// events, are read in the event's time zone.
+func (s *CalendarService) ImportAvailability(event *models.Event, r io.Reader) ([]models.TimeSlot, error) {
+	components, err := ical.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+	windows, err := s.events.scheduler.availabilityWindows(event)
+	if err != nil {
+		return nil, err
+	}
+	return freeTime(event, windows, components)
+}
+
+// SourceAvailability returns the free time left in the windows of an event by the busy time a
+// source reports for a user, read the same way as ImportAvailability reads uploaded calendars.
+// The source is asked for the busy time spanned by the windows only.
+func (s *CalendarService) SourceAvailability(
+	ctx context.Context,
+	event *models.Event,
+	source repository.AvailabilitySource,
+	userID string,
+) ([]models.TimeSlot, error) {
+	windows, err := s.events.scheduler.availabilityWindows(event)
+	if err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return []models.TimeSlot{}, nil
+	}
+	start, end := windows[0].StartTime, windows[0].EndTime
+	for _, window := range windows[1:] {
+		if window.StartTime.Before(start) {
+			start = window.StartTime
+		}
+		if window.EndTime.After(end) {
+			end = window.EndTime
+		}
+	}
+
+	components, err := source.Busy(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return freeTime(event, windows, components)
+}
+
+// freeTime subtracts the busy time described by calendar components from the windows of an event
+func freeTime(event *models.Event, windows []models.TimeSlot, components []*ical.Component) ([]models.TimeSlot, error) {
+	var until time.Time
+	for _, window := range windows {
+		if window.EndTime.After(until) {
+			until = window.EndTime
+		}
+	}
+	busy, err := readBusy(components, recurrenceLocation(event), until)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCalendar, err)
+	}
+	return freeSlots(windows, busy, time.Duration(event.Duration)*time.Minute), nil
+}
+
+// availabilityWindows returns the time slots of an event, repeated in each occurrence of a
+// recurring event the same way candidates are
+func (s *SchedulerService) availabilityWindows(event *models.Event) ([]models.TimeSlot, error) {
+	shifts, err := s.occurrenceShifts(event)
+	if err != nil {
+		return nil, err
+	}
+	loc := recurrenceLocation(event)
+	windows := make([]models.TimeSlot, 0, len(event.TimeSlots)*len(shifts))
+	for _, shift := range shifts {
+		for _, slot := range event.TimeSlots {
+			slotLoc := loadLocation(slot.TimeZone)
+			windows = append(windows, models.TimeSlot{
+				StartTime: slot.StartTime.In(loc).AddDate(0, 0, shift).In(slotLoc),
+				EndTime:   slot.EndTime.In(loc).AddDate(0, 0, shift).In(slotLoc),
+				TimeZone:  slot.TimeZone,
+			})
+		}
+	}
+	return windows, nil
+}
+
+// readBusy collects the busy periods of calendar components that start before until
+func readBusy(roots []*ical.Component, loc *time.Location, until time.Time) ([]busyPeriod, error) {
+	var events, freeBusy []*ical.Component
+	var collect func(components []*ical.Component)
+	collect = func(components []*ical.Component) {
+		for _, c := range components {
+			switch c.Name {
+			case "VEVENT":
+				events = append(events, c)
+			case "VFREEBUSY":
+				freeBusy = append(freeBusy, c)
+			}
+			collect(c.Components)
+		}
+	}
+	collect(roots)
+
+	// Moved and cancelled occurrences of a recurring event are listed separately under its UID
+	overridden := make(map[string][]time.Time)
+	for _, event := range events {
+		if prop, ok := event.Get("RECURRENCE-ID"); ok {
+			t, _, err := propertyTime(prop, loc)
+			if err != nil {
+				return nil, err
+			}
+			uid, _ := event.Get("UID")
+			overridden[uid.Value] = append(overridden[uid.Value], t)
+		}
+	}
+
+	var busy []busyPeriod
+	for _, event := range events {
+		if !takesTime(event) {
+			continue
+		}
+		var exdates []time.Time
+		if _, ok := event.Get("RECURRENCE-ID"); !ok {
+			uid, _ := event.Get("UID")
+			exdates = overridden[uid.Value]
+		}
+		periods, err := eventPeriods(event, loc, until, exdates)
+		if err != nil {
+			return nil, err
+		}
+		busy = append(busy, periods...)
+	}
+
+	for _, component := range freeBusy {
+		for _, prop := range component.All("FREEBUSY") {
+			if strings.EqualFold(prop.Param("FBTYPE"), "FREE") {
+				continue
+			}
+			for _, value := range strings.Split(prop.Value, ",") {
+				period, err := parsePeriod(value)
+				if err != nil {
+					return nil, err
+				}
+				busy = append(busy, period)
+			}
+		}
+	}
+	return busy, nil
+}
+
+// takesTime reports whether an event blocks the time it spans
+func takesTime(event *ical.Component) bool {
+	if transp, ok := event.Get("TRANSP"); ok && strings.EqualFold(transp.Value, "TRANSPARENT") {
+		return false
+	}
+	if status, ok := event.Get("STATUS"); ok && strings.EqualFold(status.Value, "CANCELLED") {
+		return false
+	}
+	return true
+}
+
+// eventPeriods returns the periods an event takes, expanding its recurrence up to until
+func eventPeriods(event *ical.Component, loc *time.Location, until time.Time, exdates []time.Time) ([]busyPeriod, error) {
+	dtstart, ok := event.Get("DTSTART")
+	if !ok {
+		return nil, errors.New("event without DTSTART")
+	}
+	start, allDay, err := propertyTime(dtstart, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	end := start
+	if dtend, ok := event.Get("DTEND"); ok {
+		if end, _, err = propertyTime(dtend, loc); err != nil {
+			return nil, err
+		}
+	} else if duration, ok := event.Get("DURATION"); ok {
+		d, err := ical.ParseDuration(duration.Value)
+		if err != nil {
+			return nil, err
+		}
+		end = start.Add(d)
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	length := end.Sub(start)
+	if length <= 0 {
+		return nil, nil
+	}
+
+	rrule, ok := event.Get("RRULE")
+	if !ok {
+		return []busyPeriod{{start: start, end: end}}, nil
+	}
+	rule, err := ParseRRule(rrule.Value, start.Location())
+	if err != nil {
+		return nil, fmt.Errorf("unsupported recurrence rule %q: %v", rrule.Value, err)
+	}
+	for _, prop := range event.All("EXDATE") {
+		for _, value := range strings.Split(prop.Value, ",") {
+			prop.Value = value
+			exdate, _, err := propertyTime(prop, start.Location())
+			if err != nil {
+				return nil, err
+			}
+			exdates = append(exdates, exdate)
+		}
+	}
+
+	var periods []busyPeriod
+	for _, occurrence := range rule.OccurrencesBefore(start, exdates, until) {
+		periods = append(periods, busyPeriod{start: occurrence, end: occurrence.Add(length)})
+	}
+	return periods, nil
+}
+
+// propertyTime parses a DATE or DATE-TIME property, in the zone named by its TZID parameter or
+// else in loc, and reports whether it is a DATE
+func propertyTime(prop ical.Property, loc *time.Location) (time.Time, bool, error) {
+	if tzid := prop.Param("TZID"); tzid != "" {
+		zone, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q in %s", tzid, prop.Name)
+		}
+		loc = zone
+	}
+	t, err := ical.ParseTime(prop.Value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s %q", prop.Name, prop.Value)
+	}
+	allDay := prop.Param("VALUE") == "DATE" || len(prop.Value) == len("20060102")
+	return t, allDay, nil
+}
+
+// parsePeriod parses a PERIOD value, either start/end or start/duration, in UTC
+func parsePeriod(value string) (busyPeriod, error) {
+	from, to, ok := strings.Cut(value, "/")
+	if !ok {
+		return busyPeriod{}, fmt.Errorf("invalid period %q", value)
+	}
+	start, err := ical.ParseTime(from, time.UTC)
+	if err != nil {
+		return busyPeriod{}, fmt.Errorf("invalid period %q", value)
+	}
+	if strings.HasPrefix(to, "P") || strings.HasPrefix(to, "+P") {
+		d, err := ical.ParseDuration(to)
+		if err != nil {
+			return busyPeriod{}, err
+		}
+		return busyPeriod{start: start, end: start.Add(d)}, nil
+	}
+	end, err := ical.ParseTime(to, time.UTC)
+	if err != nil {
+		return busyPeriod{}, fmt.Errorf("invalid period %q", value)
+	}
+	return busyPeriod{start: start, end: end}, nil
+}
+
+// freeSlots subtracts the busy periods from each window and keeps the free time of at least minLength
+func freeSlots(windows []models.TimeSlot, busy []busyPeriod, minLength time.Duration) []models.TimeSlot {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start.Before(busy[j].start) })
+
+	free := make([]models.TimeSlot, 0)
+	keep := func(window models.TimeSlot, start, end time.Time) {
+		if end.Sub(start) > 0 && end.Sub(start) >= minLength {
+			loc := window.StartTime.Location()
+			free = append(free, models.TimeSlot{StartTime: start.In(loc), EndTime: end.In(loc), TimeZone: window.TimeZone})
+		}
+	}
+	for _, window := range windows {
+		cursor := window.StartTime
+		for _, period := range busy {
+			if !period.end.After(cursor) {
+				continue
+			}
+			if !period.start.Before(window.EndTime) {
+				break
+			}
+			if period.start.After(cursor) {
+				keep(window, cursor, period.start)
+			}
+			cursor = period.end
+		}
+		if cursor.Before(window.EndTime) {
+			keep(window, cursor, window.EndTime)
+		}
+	}
+	return free
+}
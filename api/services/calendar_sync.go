@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/caldav"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// ErrCalendarUnavailable is returned when a participant's calendar cannot be read
+var ErrCalendarUnavailable = errors.New("calendar unavailable")
+
+// DefaultCalendarSyncInterval is the default time between two refreshes of availability pulled from calendars
+const DefaultCalendarSyncInterval = 15 * time.Minute
+
+// CalendarSyncService fills participants' availability with the free time an availability source
+// reports. Participants syncing replace what they submitted before; periodic refreshes only replace
+// availability pulled from the source, leaving availability entered by hand alone. Availability the
+// source still agrees with is left as it is. The outcome of each sync is recorded on the participant's
+// calendar connection.
+type CalendarSyncService struct {
+	calendars      *CalendarService
+	availabilities *AvailabilityService
+	connections    repository.CalendarConnectionStore
+	source         repository.AvailabilitySource
+	now            func() time.Time
+}
+
+// NewCalendarSyncService creates a new instance of CalendarSyncService
+func NewCalendarSyncService(
+	calendars *CalendarService,
+	availabilities *AvailabilityService,
+	connections repository.CalendarConnectionStore,
+	source repository.AvailabilitySource,
+) *CalendarSyncService {
+	return &CalendarSyncService{
+		calendars:      calendars,
+		availabilities: availabilities,
+		connections:    connections,
+		source:         source,
+		now:            time.Now,
+	}
+}
+
+// Sync replaces a user's availability for an event with the free time left by their calendar
+func (s *CalendarSyncService) Sync(ctx context.Context, eventID, userID string) (*models.ParticipantAvailability, error) {
+	return s.sync(ctx, eventID, userID, true)
+}
+
+// Refresh replaces a user's availability for an event with the free time left by their calendar,
+// unless they last submitted it by hand
+func (s *CalendarSyncService) Refresh(ctx context.Context, eventID, userID string) (*models.ParticipantAvailability, error) {
+	return s.sync(ctx, eventID, userID, false)
+}
+
+// sync pulls a user's availability from their calendar. Availability submitted by hand is only
+// replaced when overwrite is set, and availability already matching the calendar is kept.
+func (s *CalendarSyncService) sync(ctx context.Context, eventID, userID string, overwrite bool) (*models.ParticipantAvailability, error) {
+	event, err := s.calendars.events.CheckAcceptingAvailability(eventID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.availabilities.Submitted(eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+	fromCalendar := current != nil && current.Source == models.AvailabilitySourceCalendar
+	if current != nil && !fromCalendar && !overwrite {
+		return current, nil
+	}
+
+	slots, err := s.calendars.SourceAvailability(ctx, event, s.source, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCalendarConnectionNotFound
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrCalendarUnavailable, err)
+	}
+	s.record(userID, err)
+	if err != nil {
+		return nil, err
+	}
+	if fromCalendar && sameSlots(current.TimeSlots, slots) {
+		return current, nil
+	}
+	return s.availabilities.SubmitFromCalendar(eventID, userID, slots)
+}
+
+// sameSlots reports whether two availabilities hold the same time slots, whatever their order
+func sameSlots(a, b []models.TimeSlot) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	byStart := func(x, y models.TimeSlot) int { return x.StartTime.Compare(y.StartTime) }
+	slices.SortFunc(a, byStart)
+	slices.SortFunc(b, byStart)
+	for i := range a {
+		if !a[i].StartTime.Equal(b[i].StartTime) || !a[i].EndTime.Equal(b[i].EndTime) ||
+			a[i].TimeZone != b[i].TimeZone || preferenceOf(a[i]) != preferenceOf(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// preferenceOf is the preference level of a slot, slots without one being plainly available
+func preferenceOf(slot models.TimeSlot) models.PreferenceLevel {
+	if slot.Preference == "" {
+		return models.PreferenceAvailable
+	}
+	return slot.Preference
+}
+
+// Targets lists the participants whose availability is pulled from their calendar, in every event
+// still collecting availability
+func (s *CalendarSyncService) Targets() ([]models.CalendarSyncTarget, error) {
+	return s.connections.ListCalendarSyncTargets(s.now())
+}
+
+// record stores the outcome of a sync on the user's calendar connection. Only rejected credentials
+// are told apart, as other causes would tell which hosts and ports answer.
+func (s *CalendarSyncService) record(userID string, syncErr error) {
+	message := ""
+	switch {
+	case errors.Is(syncErr, caldav.ErrUnauthorized):
+		message = caldav.ErrUnauthorized.Error()
+	case syncErr != nil:
+		message = "calendar server unavailable"
+	}
+	err := s.connections.RecordCalendarSync(userID, s.now(), message)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Printf("Failed to record calendar sync of user %s: %v", userID, err)
+	}
+}
+
+// CalendarSyncWorker periodically refreshes the availability participants pull from their calendars
+type CalendarSyncWorker struct {
+	syncs    *CalendarSyncService
+	interval time.Duration
+}
+
+// NewCalendarSyncWorker creates a new instance of CalendarSyncWorker
+func NewCalendarSyncWorker(syncs *CalendarSyncService, interval time.Duration) *CalendarSyncWorker {
+	if interval <= 0 {
+		interval = DefaultCalendarSyncInterval
+	}
+	return &CalendarSyncWorker{syncs: syncs, interval: interval}
+}
+
+// Run refreshes availability until the context is cancelled
+func (w *CalendarSyncWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue refreshes the availability of every participant with a connected calendar
+func (w *CalendarSyncWorker) ProcessDue(ctx context.Context) {
+	targets, err := w.syncs.Targets()
+	if err != nil {
+		log.Printf("Failed to list calendars to sync: %v", err)
+		return
+	}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := w.syncs.Refresh(ctx, target.EventID, target.UserID); err != nil {
+			log.Printf("Failed to sync availability of user %s in event %s: %v", target.UserID, target.EventID, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// Change describes something that happened to an event
+type Change struct {
+	ID           string // Identifies the change, for receivers to ignore ones seen before
+	Type         models.ChangeType
+	Event        *models.Event
+	Availability *models.ParticipantAvailability // For availability changes
+	OccurredAt   time.Time
+}
+
+// Notifier is told about changes to events. Notify is called on the path of the request that made
+// the change, so notifiers must not block on slow work.
+type Notifier interface {
+	Notify(change Change)
+}
+
+// ChangeFeed passes the changes published by the services to every subscribed notifier
+type ChangeFeed struct {
+	mu        sync.RWMutex
+	notifiers []Notifier
+}
+
+// Subscribe adds a notifier told about every change published from now on
+func (f *ChangeFeed) Subscribe(notifier Notifier) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifiers = append(f.notifiers, notifier)
+}
+
+// Publish tells every subscribed notifier about a change
+func (f *ChangeFeed) Publish(change Change) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, notifier := range f.notifiers {
+		notifier.Notify(change)
+	}
+}
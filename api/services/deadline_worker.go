@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultQuorum is the default fraction of participants that must be able to attend
+// the top recommendation for an event to be finalized automatically at its deadline
+const DefaultQuorum = 0.5
+
+// DefaultDeadlineCheckInterval is the default time between two scans for passed deadlines
+const DefaultDeadlineCheckInterval = time.Minute
+
+// DeadlineWorker periodically finalizes events whose response deadline passed
+type DeadlineWorker struct {
+	events   *EventService
+	quorum   float64
+	interval time.Duration
+}
+
+// NewDeadlineWorker creates a new instance of DeadlineWorker
+func NewDeadlineWorker(events *EventService, quorum float64, interval time.Duration) *DeadlineWorker {
+	if interval <= 0 {
+		interval = DefaultDeadlineCheckInterval
+	}
+	return &DeadlineWorker{
+		events:   events,
+		quorum:   quorum,
+		interval: interval,
+	}
+}
+
+// Run checks for passed deadlines until the context is cancelled
+func (w *DeadlineWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue finalizes every event whose deadline passed since the last run
+func (w *DeadlineWorker) ProcessDue() {
+	ids, err := w.events.DueEventIDs()
+	if err != nil {
+		log.Printf("Failed to list events past their deadline: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		finalized, err := w.events.FinalizeAtDeadline(id, w.quorum)
+		if err != nil {
+			log.Printf("Failed to process deadline of event %s: %v", id, err)
+			continue
+		}
+		if finalized {
+			log.Printf("Finalized event %s at its response deadline", id)
+		} else {
+			log.Printf("Event %s reached its response deadline without meeting the quorum", id)
+		}
+	}
+}
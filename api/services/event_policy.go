@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+var (
+	// ErrForbidden is returned when a user's role in an event does not allow an action
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidRoleChange is returned when a role assignment would leave an event without its organizer
+	ErrInvalidRoleChange = errors.New("invalid role change")
+)
+
+// Action is something a user does with an event, described for error messages
+type Action string
+
+// Actions checked by the event policy
+const (
+	ActionViewEvent           Action = "viewing the event"
+	ActionViewRecommendations Action = "viewing recommendations"
+	ActionEditEvent           Action = "editing the event"
+	ActionFinalizeEvent       Action = "finalizing the event"
+	ActionDeleteEvent         Action = "deleting the event"
+	ActionManageRoles         Action = "managing roles"
+	ActionViewAvailabilities  Action = "viewing availabilities"
+	ActionSubmitAvailability  Action = "submitting availability"
+	ActionManageInvites       Action = "managing invites"
+	ActionManageWebhooks      Action = "managing webhooks"
+)
+
+// permissions lists the roles allowed to perform each action
+var permissions = map[Action][]models.EventRole{
+	ActionViewEvent: {
+		models.EventRoleOrganizer, models.EventRoleCoOrganizer, models.EventRoleParticipant, models.EventRoleViewer,
+		models.EventRoleGuest,
+	},
+	ActionViewRecommendations: {
+		models.EventRoleOrganizer, models.EventRoleCoOrganizer, models.EventRoleParticipant, models.EventRoleViewer,
+	},
+	ActionEditEvent:          {models.EventRoleOrganizer, models.EventRoleCoOrganizer},
+	ActionFinalizeEvent:      {models.EventRoleOrganizer, models.EventRoleCoOrganizer},
+	ActionDeleteEvent:        {models.EventRoleOrganizer},
+	ActionManageRoles:        {models.EventRoleOrganizer},
+	ActionViewAvailabilities: {models.EventRoleOrganizer, models.EventRoleCoOrganizer, models.EventRoleParticipant},
+	ActionSubmitAvailability: {
+		models.EventRoleOrganizer, models.EventRoleCoOrganizer, models.EventRoleParticipant, models.EventRoleGuest,
+	},
+	ActionManageInvites:  {models.EventRoleOrganizer, models.EventRoleCoOrganizer},
+	ActionManageWebhooks: {models.EventRoleOrganizer, models.EventRoleCoOrganizer},
+}
+
+// roleNames describes roles in error messages
+var roleNames = map[models.EventRole]string{
+	models.EventRoleOrganizer:   "the organizer",
+	models.EventRoleCoOrganizer: "a co-organizer",
+	models.EventRoleParticipant: "a participant",
+	models.EventRoleViewer:      "a viewer",
+	models.EventRoleGuest:       "a guest",
+}
+
+// ForbiddenError explains why a user may not perform an action on an event
+type ForbiddenError struct {
+	UserID string
+	Role   models.EventRole // Empty when the user has no role in the event
+	Action Action
+}
+
+func (e *ForbiddenError) Error() string {
+	allowed := make([]string, 0, len(permissions[e.Action]))
+	for _, role := range permissions[e.Action] {
+		allowed = append(allowed, strings.ReplaceAll(string(role), "_", "-"))
+	}
+	user := e.UserID
+	if user == "" {
+		user = "the caller"
+	}
+	who := fmt.Sprintf("%s has no role in this event", user)
+	if e.Role != "" {
+		who = fmt.Sprintf("%s is %s of this event", user, roleNames[e.Role])
+	}
+	return fmt.Sprintf("%s; %s requires the %s role", who, e.Action, strings.Join(allowed, " or "))
+}
+
+func (e *ForbiddenError) Unwrap() error { return ErrForbidden }
+
+// EventPolicy decides what users may do with an event based on their role in it.
+// Roles are granted explicitly, except that users listed as participants of an event
+// are participants unless granted another role.
+type EventPolicy struct {
+	eventRepo repository.EventStore
+	roleRepo  repository.EventRoleStore
+}
+
+// NewEventPolicy creates a new instance of EventPolicy
+func NewEventPolicy(eventRepo repository.EventStore, roleRepo repository.EventRoleStore) *EventPolicy {
+	return &EventPolicy{eventRepo: eventRepo, roleRepo: roleRepo}
+}
+
+// Authorize loads an event and checks that the user may perform the action on it
+func (p *EventPolicy) Authorize(eventID, userID string, action Action) (*models.Event, error) {
+	event, err := p.loadEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := p.RoleOf(event, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !Allows(role, action) {
+		return nil, &ForbiddenError{UserID: userID, Role: role, Action: action}
+	}
+	return event, nil
+}
+
+// AuthorizeGuest loads an event and checks that a guest holding an invite may perform the action on it.
+// Guests only hold a role in the event they were invited to.
+func (p *EventPolicy) AuthorizeGuest(eventID, guestID string, invite *models.EventInvite, action Action) (*models.Event, error) {
+	event, err := p.loadEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	var role models.EventRole
+	if invite.EventID == event.ID {
+		role = models.EventRoleGuest
+	}
+	if !Allows(role, action) {
+		return nil, &ForbiddenError{UserID: guestID, Role: role, Action: action}
+	}
+	return event, nil
+}
+
+// loadEvent retrieves an event, translating missing records into ErrEventNotFound
+func (p *EventPolicy) loadEvent(eventID string) (*models.Event, error) {
+	event, err := p.eventRepo.GetEvent(eventID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrEventNotFound
+	}
+	return event, err
+}
+
+// RoleOf returns the role a user holds in an event, or an empty role when they hold none
+func (p *EventPolicy) RoleOf(event *models.Event, userID string) (models.EventRole, error) {
+	role, err := p.roleRepo.GetEventRole(event.ID, userID)
+	if err == nil {
+		return role, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return "", err
+	}
+	for _, participant := range event.Participants {
+		if participant.UserID == userID {
+			return models.EventRoleParticipant, nil
+		}
+	}
+	return "", nil
+}
+
+// Allows reports whether a role may perform an action
+func Allows(role models.EventRole, action Action) bool {
+	for _, allowed := range permissions[action] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles lists the role of every user taking part in an event, including listed participants
+func (p *EventPolicy) Roles(event *models.Event) ([]models.EventRoleAssignment, error) {
+	assignments, err := p.roleRepo.ListEventRoles(event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[string]bool, len(assignments))
+	for _, assignment := range assignments {
+		assigned[assignment.UserID] = true
+	}
+	for _, participant := range event.Participants {
+		if !assigned[participant.UserID] {
+			assignments = append(assignments, models.EventRoleAssignment{
+				EventID: event.ID,
+				UserID:  participant.UserID,
+				Role:    models.EventRoleParticipant,
+			})
+		}
+	}
+	return assignments, nil
+}
+
+// Assign grants a user a role in an event. The organizer role cannot be granted or taken away.
+func (p *EventPolicy) Assign(event *models.Event, userID string, role models.EventRole) (*models.EventRoleAssignment, error) {
+	if role == models.EventRoleOrganizer {
+		return nil, fmt.Errorf("%w: an event has a single organizer", ErrInvalidRoleChange)
+	}
+	if err := p.checkNotOrganizer(event, userID); err != nil {
+		return nil, err
+	}
+
+	assignment := &models.EventRoleAssignment{EventID: event.ID, UserID: userID, Role: role}
+	if err := p.roleRepo.AssignEventRole(assignment); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// Unassign withdraws the role a user was granted in an event. Users listed as participants
+// remain participants.
+func (p *EventPolicy) Unassign(event *models.Event, userID string) error {
+	if err := p.checkNotOrganizer(event, userID); err != nil {
+		return err
+	}
+	err := p.roleRepo.DeleteEventRole(event.ID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// checkNotOrganizer refuses changes to the role of an event's organizer
+func (p *EventPolicy) checkNotOrganizer(event *models.Event, userID string) error {
+	role, err := p.roleRepo.GetEventRole(event.ID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if role == models.EventRoleOrganizer {
+		return fmt.Errorf("%w: the organizer's role cannot be changed", ErrInvalidRoleChange)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+var (
+	// ErrEventNotFound is returned when an event does not exist
+	ErrEventNotFound = errors.New("event not found")
+	// ErrInvalidTransition is returned when an event cannot move to the requested status
+	ErrInvalidTransition = errors.New("invalid event status transition")
+	// ErrSlotNotRecommended is returned when finalizing with a slot that is not currently recommended
+	ErrSlotNotRecommended = errors.New("time slot is not a current recommendation")
+	// ErrEventClosed is returned when availability is submitted to an event that no longer accepts it
+	ErrEventClosed = errors.New("event is not accepting availability")
+	// ErrEventLocked is returned when editing an event that was finalized or cancelled
+	ErrEventLocked = errors.New("event can no longer be edited")
+
+	errQuorumNotMet = errors.New("top recommendation does not meet the quorum")
+)
+
+// AutoFinalizer is recorded as the finalizer of events finalized when their response deadline passed
+const AutoFinalizer = "system"
+
+// eventTransitions lists the statuses each status may move to
+var eventTransitions = map[models.EventStatus][]models.EventStatus{
+	models.EventStatusDraft:     {models.EventStatusPolling, models.EventStatusCancelled},
+	models.EventStatusPolling:   {models.EventStatusFinalized, models.EventStatusCancelled},
+	models.EventStatusFinalized: {models.EventStatusCancelled},
+}
+
+// statusChanges names the change published when an event moves to each status
+var statusChanges = map[models.EventStatus]models.ChangeType{
+	models.EventStatusPolling:   models.ChangeEventOpened,
+	models.EventStatusFinalized: models.ChangeEventFinalized,
+	models.EventStatusCancelled: models.ChangeEventCancelled,
+}
+
+// CanTransition reports whether an event may move from one status to another
+func CanTransition(from, to models.EventStatus) bool {
+	for _, next := range eventTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// EventService drives the event lifecycle: draft, polling, finalized and cancelled
+type EventService struct {
+	eventRepo        repository.EventStore
+	workingHoursRepo repository.WorkingHoursStore
+	scheduler        *SchedulerService
+	changes          *ChangeFeed
+	now              func() time.Time
+}
+
+// NewEventService creates a new instance of EventService
+func NewEventService(
+	eventRepo repository.EventStore,
+	workingHoursRepo repository.WorkingHoursStore,
+	scheduler *SchedulerService,
+) *EventService {
+	return &EventService{
+		eventRepo:        eventRepo,
+		workingHoursRepo: workingHoursRepo,
+		scheduler:        scheduler,
+		changes:          &ChangeFeed{},
+		now:              time.Now,
+	}
+}
+
+// Changes returns the feed of changes made through this service and the services built on it
+func (s *EventService) Changes() *ChangeFeed {
+	return s.changes
+}
+
+// publish tells the subscribed notifiers about a change to an event
+func (s *EventService) publish(changeType models.ChangeType, event *models.Event, availability *models.ParticipantAvailability) {
+	s.changes.Publish(Change{
+		ID:           uuid.New().String(),
+		Type:         changeType,
+		Event:        event,
+		Availability: availability,
+		OccurredAt:   s.now(),
+	})
+}
+
+// Create stores a new event
+func (s *EventService) Create(event *models.Event) error {
+	if err := s.eventRepo.CreateEvent(event); err != nil {
+		return err
+	}
+	s.publish(models.ChangeEventCreated, event, nil)
+	return nil
+}
+
+// Update replaces the details, time slots and participants of a draft or polling event. Finalized
+// and cancelled events keep the details their final time was chosen with.
+func (s *EventService) Update(eventID string, req models.UpdateEventRequest) (*models.Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Status != models.EventStatusDraft && event.Status != models.EventStatusPolling {
+		return nil, fmt.Errorf("%w: event is %s", ErrEventLocked, event.Status)
+	}
+
+	event.Title = req.Title
+	event.Description = req.Description
+	event.Duration = req.Duration
+	event.TimeSlots = req.TimeSlots
+	event.Participants = req.Participants
+	event.Recurrence = req.Recurrence
+	event.Deadline = req.Deadline
+	event.UpdatedAt = s.now()
+	if err := s.eventRepo.UpdateEvent(event); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrEventNotFound
+		}
+		if errors.Is(err, repository.ErrStatusChanged) {
+			return nil, fmt.Errorf("%w: event left %s while it was edited", ErrEventLocked, event.Status)
+		}
+		return nil, fmt.Errorf("updating event: %w", err)
+	}
+	s.publish(models.ChangeEventUpdated, event, nil)
+	return event, nil
+}
+
+// GetEvent retrieves an event, translating missing records into ErrEventNotFound
+func (s *EventService) GetEvent(eventID string) (*models.Event, error) {
+	event, err := s.eventRepo.GetEvent(eventID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrEventNotFound
+	}
+	return event, err
+}
+
+// Recommendations computes the recommended time slots of an event from the submitted availability
+func (s *EventService) Recommendations(event *models.Event) ([]models.RecommendedTimeSlot, error) {
+	availabilities, err := s.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	return s.recommend(event, availabilities)
+}
+
+// recommend ranks the time slots of an event given the availability submitted to it
+func (s *EventService) recommend(event *models.Event, availabilities []models.ParticipantAvailability) ([]models.RecommendedTimeSlot, error) {
+	// Get working hours of everyone involved
+	userIDs := make([]string, 0, len(availabilities)+len(event.Participants))
+	for _, availability := range availabilities {
+		userIDs = append(userIDs, availability.UserID)
+	}
+	for _, participant := range event.Participants {
+		userIDs = append(userIDs, participant.UserID)
+	}
+	profiles, err := s.workingHoursRepo.GetWorkingHoursForUsers(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("getting working hours: %w", err)
+	}
+
+	return s.scheduler.FindOptimalTimeSlots(event, availabilities, profiles...)
+}
+
+// CheckAcceptingAvailability returns the event if participants may still submit availability to it
+func (s *EventService) CheckAcceptingAvailability(eventID string) (*models.Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Status != models.EventStatusPolling {
+		return nil, fmt.Errorf("%w: event is %s", ErrEventClosed, event.Status)
+	}
+	if event.Deadline != nil && s.now().After(*event.Deadline) {
+		return nil, fmt.Errorf("%w: response deadline passed at %s", ErrEventClosed, event.Deadline.Format(time.RFC3339))
+	}
+	return event, nil
+}
+
+// Open starts collecting availability for a draft event
+func (s *EventService) Open(eventID string) (*models.Event, error) {
+	return s.transition(eventID, models.EventStatusPolling, nil)
+}
+
+// Cancel cancels a draft, polling or finalized event
+func (s *EventService) Cancel(eventID string) (*models.Event, error) {
+	return s.transition(eventID, models.EventStatusCancelled, nil)
+}
+
+// Finalize locks in the recommended time slot starting at start, recording who chose it
+func (s *EventService) Finalize(eventID string, start time.Time, finalizedBy string) (*models.Event, error) {
+	return s.transition(eventID, models.EventStatusFinalized, func(event *models.Event) error {
+		recommendations, err := s.Recommendations(event)
+		if err != nil {
+			return err
+		}
+		for _, recommendation := range recommendations {
+			if recommendation.TimeSlot.StartTime.Equal(start) {
+				s.lockIn(event, recommendation, finalizedBy)
+				return nil
+			}
+		}
+		return ErrSlotNotRecommended
+	})
+}
+
+// DueEventIDs lists the polling events whose response deadline passed and was not handled yet
+func (s *EventService) DueEventIDs() ([]string, error) {
+	return s.eventRepo.ListDueEventIDs(s.now())
+}
+
+// FinalizeAtDeadline finalizes an event whose response deadline passed with its top recommendation,
+// provided that at least the quorum fraction of everyone involved can attend it. It reports whether
+// the event was finalized, which it is not when it left polling meanwhile, for instance because it
+// was cancelled while the recommendations were computed. Either way the deadline is marked as
+// processed so it is only handled once.
+func (s *EventService) FinalizeAtDeadline(eventID string, quorum float64) (bool, error) {
+	_, err := s.transition(eventID, models.EventStatusFinalized, func(event *models.Event) error {
+		recommendations, err := s.Recommendations(event)
+		if err != nil {
+			return err
+		}
+		if len(recommendations) == 0 || !MeetsQuorum(recommendations[0], quorum) {
+			return errQuorumNotMet
+		}
+		s.lockIn(event, recommendations[0], AutoFinalizer)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errQuorumNotMet) && !errors.Is(err, ErrInvalidTransition) {
+		return false, err
+	}
+	finalized := err == nil
+
+	if err := s.eventRepo.MarkDeadlineProcessed(eventID); err != nil {
+		return finalized, fmt.Errorf("marking deadline processed: %w", err)
+	}
+	return finalized, nil
+}
+
+// MeetsQuorum reports whether at least the quorum fraction of the participants involved in a
+// recommendation, attending or missing, can attend it
+func MeetsQuorum(recommendation models.RecommendedTimeSlot, quorum float64) bool {
+	total := len(recommendation.Participants) + len(recommendation.MissingUsers)
+	if total == 0 {
+		return false
+	}
+	return float64(len(recommendation.Participants)) >= quorum*float64(total)
+}
+
+// lockIn records the chosen recommendation and who chose it on the event
+func (s *EventService) lockIn(event *models.Event, recommendation models.RecommendedTimeSlot, finalizedBy string) {
+	finalizedAt := s.now()
+	slot := recommendation.TimeSlot
+	event.FinalSlot = &slot
+	event.FinalizedBy = finalizedBy
+	event.FinalizedAt = &finalizedAt
+}
+
+// transition validates and persists a status change, running prepare on the loaded event first. The
+// change is only written if the event still has the status it was loaded with, so concurrent changes
+// cannot both apply: the later one fails with ErrInvalidTransition.
+func (s *EventService) transition(eventID string, to models.EventStatus, prepare func(*models.Event) error) (*models.Event, error) {
+	event, err := s.GetEvent(eventID)
+	if err != nil {
+		return nil, err
+	}
+	from := event.Status
+	if !CanTransition(from, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	if prepare != nil {
+		if err := prepare(event); err != nil {
+			return nil, err
+		}
+	}
+
+	event.Status = to
+	event.UpdatedAt = s.now()
+	if err := s.eventRepo.UpdateEventStatus(event, from); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrEventNotFound
+		}
+		if errors.Is(err, repository.ErrStatusChanged) {
+			return nil, fmt.Errorf("%w: event left %s before it could move to %s", ErrInvalidTransition, from, to)
+		}
+		return nil, fmt.Errorf("updating event status: %w", err)
+	}
+	if changeType, ok := statusChanges[to]; ok {
+		s.publish(changeType, event, nil)
+	}
+	return event, nil
+}
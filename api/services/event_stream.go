@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// Messages pushed to the clients streaming an event
+const (
+	StreamAvailabilitySubmitted  = "availability-submitted"
+	StreamAvailabilityWithdrawn  = "availability-withdrawn"
+	StreamEventUpdated           = "event-updated"
+	StreamRecommendationsChanged = "recommendations-changed"
+)
+
+// DefaultStreamHistory is the default number of messages kept per event for clients resuming a stream
+const DefaultStreamHistory = 100
+
+// DefaultStreamHeartbeat is the default time between two heartbeats on an idle stream
+const DefaultStreamHeartbeat = 15 * time.Second
+
+const (
+	// streamRetention is how long an event nobody streams keeps its messages for clients to resume from
+	streamRetention = 10 * time.Minute
+	// streamBuffer is the number of messages a client can fall behind by before it is dropped
+	streamBuffer = 32
+)
+
+// StreamMessage is a message pushed to the clients streaming an event
+type StreamMessage struct {
+	ID   uint64 // Increases with each message of the event, clients resume after the last one they got
+	Type string
+	Data json.RawMessage
+}
+
+// EventStreamBroker pushes the availability submitted to events, the changes made to the events and
+// the recommendations that follow to the clients streaming them. It is told about changes through the change feed and keeps the
+// latest messages of each event streamed recently, so clients reconnecting miss nothing.
+type EventStreamBroker struct {
+	events  *EventService
+	history int
+
+	mu      sync.Mutex
+	streams map[string]*eventStream
+	now     func() time.Time
+}
+
+// eventStream is the state of an event somebody streams or streamed recently
+type eventStream struct {
+	lastID          uint64
+	history         []StreamMessage // Oldest first
+	recommendations json.RawMessage // Last pushed, nil until known
+	subscribers     map[*StreamSubscription]struct{}
+	idleSince       time.Time // When the last subscriber left
+	pending         []Change  // Changes waiting to be turned into messages, in order
+	processing      bool
+}
+
+// StreamSubscription receives the messages of one event
+type StreamSubscription struct {
+	broker   *EventStreamBroker
+	stream   *eventStream
+	messages chan StreamMessage
+	closed   bool
+}
+
+// NewEventStreamBroker creates a new instance of EventStreamBroker, keeping history messages per
+// event for resuming clients, or DefaultStreamHistory when history is not positive
+func NewEventStreamBroker(events *EventService, history int) *EventStreamBroker {
+	if history <= 0 {
+		history = DefaultStreamHistory
+	}
+	return &EventStreamBroker{
+		events:  events,
+		history: history,
+		streams: make(map[string]*eventStream),
+		now:     time.Now,
+	}
+}
+
+// Subscribe starts streaming an event. A client resuming a stream gives the ID of the last message
+// it got and receives the messages it missed; other clients start from the current recommendations.
+// Either way, those messages are returned to be sent before the ones received by the subscription.
+func (b *EventStreamBroker) Subscribe(event *models.Event, lastEventID string) (*StreamSubscription, []StreamMessage, error) {
+	b.mu.Lock()
+	b.prune()
+	stream, ok := b.streams[event.ID]
+	if !ok {
+		// IDs start from the time the stream is created, so IDs from a stream that was dropped
+		// or lived in a server since restarted are never mistaken for recent ones
+		stream = &eventStream{
+			lastID:      uint64(b.now().UnixMicro()),
+			subscribers: make(map[*StreamSubscription]struct{}),
+		}
+		b.streams[event.ID] = stream
+	}
+	subscription := &StreamSubscription{broker: b, stream: stream, messages: make(chan StreamMessage, streamBuffer)}
+	stream.subscribers[subscription] = struct{}{}
+	if missed, ok := stream.since(lastEventID); ok {
+		b.mu.Unlock()
+		return subscription, missed, nil
+	}
+	current := stream.lastID
+	b.mu.Unlock()
+
+	// Messages published meanwhile are queued on the subscription, after the recommendations
+	recommendations, err := b.recommendations(event)
+	if err != nil {
+		subscription.Close()
+		return nil, nil, err
+	}
+	b.mu.Lock()
+	if stream.recommendations == nil {
+		stream.recommendations = recommendations
+	}
+	b.mu.Unlock()
+	return subscription, []StreamMessage{{ID: current, Type: StreamRecommendationsChanged, Data: recommendations}}, nil
+}
+
+// since returns the messages after the given ID, or false when some were dropped already or the
+// ID is not one of this stream's
+func (s *eventStream) since(lastEventID string) ([]StreamMessage, bool) {
+	last, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || last > s.lastID {
+		return nil, false
+	}
+	if last < s.lastID && (len(s.history) == 0 || last+1 < s.history[0].ID) {
+		return nil, false
+	}
+	var missed []StreamMessage
+	for _, message := range s.history {
+		if message.ID > last {
+			missed = append(missed, message)
+		}
+	}
+	return missed, true
+}
+
+// Messages returns the channel messages are received on. It is closed when the subscription is
+// closed, or when the client fell too far behind and should resume the stream.
+func (s *StreamSubscription) Messages() <-chan StreamMessage {
+	return s.messages
+}
+
+// Close stops the subscription
+func (s *StreamSubscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// drop removes a subscription from its stream. The caller holds the lock.
+func (b *EventStreamBroker) drop(subscription *StreamSubscription) {
+	if subscription.closed {
+		return
+	}
+	subscription.closed = true
+	stream := subscription.stream
+	delete(stream.subscribers, subscription)
+	close(subscription.messages)
+	if len(stream.subscribers) == 0 {
+		stream.idleSince = b.now()
+	}
+}
+
+// prune forgets the events nobody streamed for a while. The caller holds the lock.
+func (b *EventStreamBroker) prune() {
+	now := b.now()
+	for id, stream := range b.streams {
+		if len(stream.subscribers) == 0 && !stream.processing && now.Sub(stream.idleSince) > streamRetention {
+			delete(b.streams, id)
+		}
+	}
+}
+
+// Notify queues the messages of availability and event changes for the clients streaming the event
+func (b *EventStreamBroker) Notify(change Change) {
+	if change.Type == models.ChangeEventCreated {
+		return // Nobody streams an event before it exists
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stream, ok := b.streams[change.Event.ID]
+	if !ok {
+		return // Nobody streams the event
+	}
+	stream.pending = append(stream.pending, change)
+	if !stream.processing {
+		stream.processing = true
+		go b.process(stream)
+	}
+}
+
+// process turns the pending changes of a stream into messages, one at a time so they keep their order
+func (b *EventStreamBroker) process(stream *eventStream) {
+	for {
+		b.mu.Lock()
+		if len(stream.pending) == 0 {
+			stream.processing = false
+			b.mu.Unlock()
+			return
+		}
+		change := stream.pending[0]
+		stream.pending = stream.pending[1:]
+		b.mu.Unlock()
+
+		b.handle(stream, change)
+	}
+}
+
+// handle pushes a change, followed by the new recommendations. They follow availability changes
+// only when they changed, and every event change since the event's slots or status may have changed.
+func (b *EventStreamBroker) handle(stream *eventStream, change Change) {
+	messageType, notice := streamNotice(change)
+	data, err := json.Marshal(notice)
+	if err != nil {
+		log.Printf("Failed to encode stream message of event %s: %v", change.Event.ID, err)
+		return
+	}
+	recommendations, err := b.recommendations(change.Event)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.push(stream, messageType, data)
+	if err != nil {
+		log.Printf("Failed to compute recommendations of event %s for its stream: %v", change.Event.ID, err)
+		return
+	}
+	if messageType == StreamEventUpdated || !bytes.Equal(recommendations, stream.recommendations) {
+		stream.recommendations = recommendations
+		b.push(stream, StreamRecommendationsChanged, recommendations)
+	}
+}
+
+// streamNotice returns the type and data of the message telling streaming clients about a change
+func streamNotice(change Change) (string, any) {
+	if change.Availability == nil {
+		return StreamEventUpdated, models.StreamEvent{EventID: change.Event.ID, Change: change.Type, Event: change.Event}
+	}
+
+	messageType := StreamAvailabilitySubmitted
+	if change.Type == models.ChangeAvailabilityWithdrawn {
+		messageType = StreamAvailabilityWithdrawn
+	}
+	notice := models.StreamAvailability{
+		EventID:   change.Event.ID,
+		UserID:    change.Availability.UserID,
+		UpdatedAt: change.OccurredAt,
+	}
+	if change.Availability.Guest != nil {
+		notice.GuestName = change.Availability.Guest.Name
+	}
+	return messageType, notice
+}
+
+// push records a message and sends it to every subscriber. The caller holds the lock.
+func (b *EventStreamBroker) push(stream *eventStream, messageType string, data json.RawMessage) {
+	stream.lastID++
+	message := StreamMessage{ID: stream.lastID, Type: messageType, Data: data}
+	stream.history = append(stream.history, message)
+	if len(stream.history) > b.history {
+		stream.history = append([]StreamMessage(nil), stream.history[len(stream.history)-b.history:]...)
+	}
+	for subscription := range stream.subscribers {
+		select {
+		case subscription.messages <- message:
+		default:
+			// Slow clients are dropped rather than holding everyone up, they resume from the history
+			b.drop(subscription)
+		}
+	}
+}
+
+// recommendations encodes the current recommendations of an event as a message
+func (b *EventStreamBroker) recommendations(event *models.Event) (json.RawMessage, error) {
+	recommendations, err := b.events.Recommendations(event)
+	if err != nil {
+		return nil, err
+	}
+	if recommendations == nil {
+		recommendations = []models.RecommendedTimeSlot{}
+	}
+	return json.Marshal(models.StreamRecommendations{EventID: event.ID, Recommendations: recommendations})
+}
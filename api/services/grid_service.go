@@ -0,0 +1,338 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// ErrInvalidGridEdit is returned when a grid edit paints cells outside the event's time slots or
+// is otherwise malformed
+var ErrInvalidGridEdit = errors.New("invalid grid edit")
+
+// gridBuffer is the number of messages a grid client can fall behind by before it is dropped
+const gridBuffer = 64
+
+// GridService runs the collaborative availability grids of events. Clients paint cells of the
+// grid, which are merged into their availability, and see everyone's availability and who else is
+// on the grid as it changes. Changes made elsewhere, through the API or calendar sync, show up too.
+type GridService struct {
+	events         *EventService
+	availabilities *AvailabilityService
+	cell           time.Duration
+
+	mu    sync.Mutex
+	rooms map[string]*gridRoom
+}
+
+// gridRoom is the grid of one event, while somebody is on it
+type gridRoom struct {
+	clients map[*GridClient]struct{}
+	edits   sync.Mutex // Serializes the edits made on the grid, so none is lost
+}
+
+// GridClient is somebody on the grid of an event
+type GridClient struct {
+	service  *GridService
+	room     *gridRoom
+	eventID  string
+	userID   string
+	guest    *models.GuestDetails // Who the guest painting is, nil for users
+	messages chan models.GridMessage
+	closed   bool
+}
+
+// NewGridService creates a new instance of GridService whose grids are made of cells lasting cell,
+// or DefaultSlotStep when it is not positive
+func NewGridService(events *EventService, availabilities *AvailabilityService, cell time.Duration) *GridService {
+	if cell <= 0 {
+		cell = DefaultSlotStep
+	}
+	return &GridService{
+		events:         events,
+		availabilities: availabilities,
+		cell:           cell,
+		rooms:          make(map[string]*gridRoom),
+	}
+}
+
+// Join puts a user on the grid of an event, with the details of the guest they are when they paint
+// through an invite. It returns the client, receiving the changes from now on, and the snapshot of
+// the grid to show first.
+func (s *GridService) Join(event *models.Event, userID string, guest *models.GuestDetails) (*GridClient, *models.GridMessage, error) {
+	s.mu.Lock()
+	room, ok := s.rooms[event.ID]
+	if !ok {
+		room = &gridRoom{clients: make(map[*GridClient]struct{})}
+		s.rooms[event.ID] = room
+	}
+	client := &GridClient{
+		service:  s,
+		room:     room,
+		eventID:  event.ID,
+		userID:   userID,
+		guest:    guest,
+		messages: make(chan models.GridMessage, gridBuffer),
+	}
+	room.clients[client] = struct{}{}
+	viewers := room.viewers()
+	s.broadcast(room, models.GridMessage{Type: models.GridMessagePresence, Viewers: viewers}, client)
+	s.mu.Unlock()
+
+	// Changes made meanwhile are queued on the client, after the snapshot. They replace whole
+	// availabilities, so replaying one the snapshot already shows does no harm.
+	availabilities, err := s.events.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		client.Leave()
+		return nil, nil, fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	snapshot := &models.GridMessage{
+		Type:           models.GridMessageSnapshot,
+		UserID:         userID,
+		CellMinutes:    int(s.cell / time.Minute),
+		Windows:        event.TimeSlots,
+		Availabilities: make([]models.GridAvailability, 0, len(availabilities)),
+		Viewers:        viewers,
+	}
+	for _, availability := range availabilities {
+		snapshot.Availabilities = append(snapshot.Availabilities, models.GridAvailability{
+			UserID:    availability.UserID,
+			TimeSlots: availability.TimeSlots,
+		})
+	}
+	return client, snapshot, nil
+}
+
+// viewers lists the users on a grid, each once however many clients they have open. The caller
+// holds the lock.
+func (r *gridRoom) viewers() []string {
+	seen := make(map[string]bool, len(r.clients))
+	viewers := make([]string, 0, len(r.clients))
+	for client := range r.clients {
+		if !seen[client.userID] {
+			seen[client.userID] = true
+			viewers = append(viewers, client.userID)
+		}
+	}
+	sort.Strings(viewers)
+	return viewers
+}
+
+// Messages returns the channel the client receives changes on. It is closed when the client left,
+// or when it fell too far behind and should join again.
+func (c *GridClient) Messages() <-chan models.GridMessage {
+	return c.messages
+}
+
+// Reply sends a message to this client only
+func (c *GridClient) Reply(message models.GridMessage) {
+	c.service.mu.Lock()
+	defer c.service.mu.Unlock()
+	c.service.send(c, message)
+}
+
+// Leave takes the client off the grid
+func (c *GridClient) Leave() {
+	c.service.mu.Lock()
+	defer c.service.mu.Unlock()
+	c.service.drop(c)
+}
+
+// drop removes a client from its grid and tells the others. The caller holds the lock.
+func (s *GridService) drop(client *GridClient) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	close(client.messages)
+	room := client.room
+	delete(room.clients, client)
+	if len(room.clients) == 0 {
+		delete(s.rooms, client.eventID)
+		return
+	}
+	s.broadcast(room, models.GridMessage{Type: models.GridMessagePresence, Viewers: room.viewers()}, nil)
+}
+
+// broadcast sends a message to every client of a grid but except. The caller holds the lock.
+func (s *GridService) broadcast(room *gridRoom, message models.GridMessage, except *GridClient) {
+	for client := range room.clients {
+		if client != except {
+			s.send(client, message)
+		}
+	}
+}
+
+// send queues a message for a client, dropping the client when it fell too far behind. The caller
+// holds the lock.
+func (s *GridService) send(client *GridClient, message models.GridMessage) {
+	if client.closed {
+		return
+	}
+	select {
+	case client.messages <- message:
+	default:
+		s.drop(client)
+	}
+}
+
+// Notify pushes availability changes to the clients on the event's grid
+func (s *GridService) Notify(change Change) {
+	var slots []models.TimeSlot
+	switch change.Type {
+	case models.ChangeAvailabilitySubmitted:
+		slots = change.Availability.TimeSlots
+	case models.ChangeAvailabilityWithdrawn:
+		slots = []models.TimeSlot{}
+	default:
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[change.Event.ID]
+	if !ok {
+		return
+	}
+	s.broadcast(room, models.GridMessage{
+		Type:           models.GridMessageAvailability,
+		Availabilities: []models.GridAvailability{{UserID: change.Availability.UserID, TimeSlots: slots}},
+	}, nil)
+}
+
+// Paint marks cells of the grid available, or not, in the client's availability. Cells painted
+// together are merged into ranges, as are ranges left adjacent with the same preference. Every
+// client on the grid, including this one, receives the resulting availability.
+func (s *GridService) Paint(client *GridClient, req models.GridRequest) error {
+	switch req.Preference {
+	case "", models.PreferenceIfNeeded, models.PreferenceAvailable, models.PreferencePreferred:
+	default:
+		return fmt.Errorf("%w: unknown preference %q", ErrInvalidGridEdit, req.Preference)
+	}
+	if len(req.Cells) == 0 {
+		return fmt.Errorf("%w: no cells to paint", ErrInvalidGridEdit)
+	}
+	event, err := s.events.CheckAcceptingAvailability(client.eventID)
+	if err != nil {
+		return err
+	}
+	ranges, err := s.cellRanges(event, req.Cells)
+	if err != nil {
+		return err
+	}
+
+	client.room.edits.Lock()
+	defer client.room.edits.Unlock()
+	availabilities, err := s.events.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		return fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	var current *models.ParticipantAvailability
+	for i := range availabilities {
+		if availabilities[i].UserID == client.userID {
+			current = &availabilities[i]
+		}
+	}
+
+	var slots []models.TimeSlot
+	if current != nil {
+		slots = current.TimeSlots
+	}
+	for _, painted := range ranges {
+		// New ranges follow the time zone the user submitted in before
+		if len(slots) > 0 {
+			painted.TimeZone = slots[0].TimeZone
+		}
+		painted.Preference = req.Preference
+		slots = paintSlots(slots, painted, req.Available)
+	}
+
+	if len(slots) == 0 {
+		if current == nil {
+			return nil
+		}
+		return s.availabilities.Delete(current.ID, client.userID)
+	}
+	_, err = s.availabilities.SubmitAsGuest(event.ID, client.userID, client.guest, slots)
+	return err
+}
+
+// cellRanges checks that cells are cells of the event's time slots and merges adjacent ones into
+// ranges. Cells start every cell duration from the start of each time slot, the last one of a slot
+// ending with it.
+func (s *GridService) cellRanges(event *models.Event, cells []time.Time) ([]models.TimeSlot, error) {
+	cellSlots := make([]models.TimeSlot, 0, len(cells))
+	for _, start := range cells {
+		cell, ok := s.cellAt(event, start)
+		if !ok {
+			return nil, fmt.Errorf("%w: no cell starts at %s", ErrInvalidGridEdit, start.Format(time.RFC3339))
+		}
+		cellSlots = append(cellSlots, cell)
+	}
+	return coalesceSlots(cellSlots), nil
+}
+
+// cellAt returns the cell of the event's time slots starting at start
+func (s *GridService) cellAt(event *models.Event, start time.Time) (models.TimeSlot, bool) {
+	for _, window := range event.TimeSlots {
+		if start.Before(window.StartTime) || !start.Before(window.EndTime) || start.Sub(window.StartTime)%s.cell != 0 {
+			continue
+		}
+		end := start.Add(s.cell)
+		if end.After(window.EndTime) {
+			end = window.EndTime
+		}
+		return models.TimeSlot{StartTime: start.UTC(), EndTime: end.UTC(), TimeZone: window.TimeZone}, true
+	}
+	return models.TimeSlot{}, false
+}
+
+// paintSlots marks the range of painted available, with its preference, or unavailable in slots
+func paintSlots(slots []models.TimeSlot, painted models.TimeSlot, available bool) []models.TimeSlot {
+	result := make([]models.TimeSlot, 0, len(slots)+1)
+	for _, slot := range slots {
+		// Keep the parts of the slot outside the painted range
+		if slot.StartTime.Before(painted.StartTime) {
+			before := slot
+			if before.EndTime.After(painted.StartTime) {
+				before.EndTime = painted.StartTime
+			}
+			result = append(result, before)
+		}
+		if slot.EndTime.After(painted.EndTime) {
+			after := slot
+			if after.StartTime.Before(painted.EndTime) {
+				after.StartTime = painted.EndTime
+			}
+			result = append(result, after)
+		}
+	}
+	if available {
+		result = append(result, painted)
+	}
+	return coalesceSlots(result)
+}
+
+// coalesceSlots sorts slots and merges the ones overlapping or adjacent that share a preference and
+// time zone
+func coalesceSlots(slots []models.TimeSlot) []models.TimeSlot {
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].StartTime.Before(slots[j].StartTime) })
+	merged := make([]models.TimeSlot, 0, len(slots))
+	for _, slot := range slots {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if !slot.StartTime.After(last.EndTime) && slot.TimeZone == last.TimeZone &&
+				preferenceTier(slot.Preference) == preferenceTier(last.Preference) {
+				if slot.EndTime.After(last.EndTime) {
+					last.EndTime = slot.EndTime
+				}
+				continue
+			}
+		}
+		merged = append(merged, slot)
+	}
+	return merged
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// preferenceTiers orders preference levels from weakest to strongest. A slot submitted with a
+// given level also counts towards every weaker tier, so tier 0 covers all of a participant's slots.
+var preferenceTiers = []models.PreferenceLevel{
+	models.PreferenceIfNeeded,
+	models.PreferenceAvailable,
+	models.PreferencePreferred,
+}
+
+// preferenceTier returns the tier index of a slot's preference level
+func preferenceTier(level models.PreferenceLevel) int {
+	for i, tier := range preferenceTiers {
+		if tier == level {
+			return i
+		}
+	}
+	return 1 // Unset levels mean plainly available
+}
+
+// sweepEvent marks the first or last feasible meeting start contributed by one merged slot
+type sweepEvent struct {
+	at          int64 // Unix nanoseconds
+	participant int
+	tier        int
+}
+
+// span is an availability slot reduced to what the sweep needs: its instants in Unix nanoseconds and
+// its preference tier. Spans hold no pointers, so sorting and merging thousands of them stays cheap.
+type span struct {
+	start, end int64
+	tier       int
+}
+
+// attendanceEngine answers "who can attend a meeting of a fixed duration starting at t"
+// with a single sweep over all participants' merged availability.
+//
+// A merged slot [a, b) can host a meeting of duration d for every start t with a <= t <= b-d,
+// so each slot becomes an open event at a and a close event at b-d. Sweeping these events in
+// time order alongside the sorted candidate starts yields, for every elementary interval between
+// consecutive events, the exact number and set of participants that are free for the full meeting.
+// The sweep runs once per preference tier so each attendee's strongest fully-covering level is known.
+type attendanceEngine struct {
+	users  []string
+	opens  []sweepEvent
+	closes []sweepEvent
+}
+
+// newAttendanceEngine merges each participant's slots and builds the sweep events for the duration.
+// Availabilities submitted more than once by the same user are combined into a single participant.
+func newAttendanceEngine(participantAvailabilities []models.ParticipantAvailability, duration time.Duration) *attendanceEngine {
+	engine := &attendanceEngine{}
+
+	index := make(map[string]int)
+	var availabilitiesByUser [][]int
+	total := 0
+	for i, pa := range participantAvailabilities {
+		user, ok := index[pa.UserID]
+		if !ok {
+			user = len(engine.users)
+			index[pa.UserID] = user
+			engine.users = append(engine.users, pa.UserID)
+			availabilitiesByUser = append(availabilitiesByUser, nil)
+		}
+		availabilitiesByUser[user] = append(availabilitiesByUser[user], i)
+		total += len(pa.TimeSlots)
+	}
+	engine.opens = make([]sweepEvent, 0, total)
+	engine.closes = make([]sweepEvent, 0, total)
+
+	// The span buffers are reused for every participant
+	var spans, tierSpans, merged []span
+	for user, availabilities := range availabilitiesByUser {
+		spans = spans[:0]
+		for _, i := range availabilities {
+			for _, slot := range participantAvailabilities[i].TimeSlots {
+				spans = append(spans, span{
+					start: slot.StartTime.UnixNano(),
+					end:   slot.EndTime.UnixNano(),
+					tier:  preferenceTier(slot.Preference),
+				})
+			}
+		}
+		slices.SortFunc(spans, func(a, b span) int {
+			return cmp.Compare(a.start, b.start)
+		})
+
+		tierSpans = spans
+		merged = mergeSpans(merged[:0], tierSpans)
+		for tier := range preferenceTiers {
+			if tier > 0 {
+				// Each tier is a subset of the previous one, so only re-merge when it shrank. Filtering
+				// keeps the spans sorted.
+				kept := 0
+				for _, sp := range tierSpans {
+					if sp.tier >= tier {
+						tierSpans[kept] = sp
+						kept++
+					}
+				}
+				if kept != len(tierSpans) {
+					tierSpans = tierSpans[:kept]
+					merged = mergeSpans(merged[:0], tierSpans)
+				}
+			}
+
+			for _, sp := range merged {
+				lastStart := sp.end - int64(duration)
+				if lastStart < sp.start {
+					continue
+				}
+				engine.opens = append(engine.opens, sweepEvent{at: sp.start, participant: user, tier: tier})
+				engine.closes = append(engine.closes, sweepEvent{at: lastStart, participant: user, tier: tier})
+			}
+		}
+	}
+
+	byTime := func(a, b sweepEvent) int {
+		return cmp.Compare(a.at, b.at)
+	}
+	slices.SortFunc(engine.opens, byTime)
+	slices.SortFunc(engine.closes, byTime)
+
+	return engine
+}
+
+// mergeSpans appends to dst the spans, sorted by start, with the ones that overlap or touch joined
+func mergeSpans(dst, sorted []span) []span {
+	for _, sp := range sorted {
+		if n := len(dst); n > 0 && sp.start <= dst[n-1].end {
+			if sp.end > dst[n-1].end {
+				dst[n-1].end = sp.end
+			}
+			continue
+		}
+		dst = append(dst, sp)
+	}
+	return dst
+}
+
+// sweep visits the candidates in start-time order and reports the participants free for each one,
+// along with the strongest preference level each of them gave for the whole candidate.
+// The candidates must be sorted by start time. Candidates nobody can attend are skipped.
+func (e *attendanceEngine) sweep(
+	candidates []models.TimeSlot,
+	visit func(index int, participants []string, preferences []models.PreferenceLevel, missingUsers []string),
+) {
+	active := make([][]int, len(preferenceTiers))
+	for tier := range active {
+		active[tier] = make([]int, len(e.users))
+	}
+	count := 0
+	nextOpen, nextClose := 0, 0
+
+	for index, candidate := range candidates {
+		t := candidate.StartTime.UnixNano()
+
+		// Opens are inclusive of t, closes are inclusive of their own instant so only drop earlier ones.
+		// Tier 0 holds every slot, so it alone decides whether a participant can attend.
+		for nextOpen < len(e.opens) && e.opens[nextOpen].at <= t {
+			open := e.opens[nextOpen]
+			if open.tier == 0 && active[0][open.participant] == 0 {
+				count++
+			}
+			active[open.tier][open.participant]++
+			nextOpen++
+		}
+		for nextClose < len(e.closes) && e.closes[nextClose].at < t {
+			closed := e.closes[nextClose]
+			active[closed.tier][closed.participant]--
+			if closed.tier == 0 && active[0][closed.participant] == 0 {
+				count--
+			}
+			nextClose++
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		participants := make([]string, 0, count)
+		preferences := make([]models.PreferenceLevel, 0, count)
+		missingUsers := make([]string, 0, len(e.users)-count)
+		for i, user := range e.users {
+			if active[0][i] == 0 {
+				missingUsers = append(missingUsers, user)
+				continue
+			}
+			tier := 0
+			for tier+1 < len(preferenceTiers) && active[tier+1][i] > 0 {
+				tier++
+			}
+			participants = append(participants, user)
+			preferences = append(preferences, preferenceTiers[tier])
+		}
+		visit(index, participants, preferences, missingUsers)
+	}
+}
+
+// slotAttendance is the outcome of sweeping one candidate slot
+type slotAttendance struct {
+	slot         models.TimeSlot
+	participants []string
+	preferences  []models.PreferenceLevel
+	missingUsers []string
+	occurrences  []time.Time    // Candidate starts in every occurrence of a recurring event
+	attended     map[string]int // Occurrences each respondent can attend, only set for recurring events
+}
+
+// attendance resolves who can attend each sorted candidate. For recurring events every candidate is
+// repeated in each occurrence by shifting it the given number of calendar days in loc, and a
+// participant attends the candidate when they are free for a strict majority of the occurrences,
+// at the weakest preference level they gave across those occurrences.
+func (e *attendanceEngine) attendance(candidates []models.TimeSlot, dayShifts []int, loc *time.Location) []slotAttendance {
+	results := make([]slotAttendance, 0)
+	if len(dayShifts) == 0 || (len(dayShifts) == 1 && dayShifts[0] == 0) {
+		e.sweep(candidates, func(index int, participants []string, preferences []models.PreferenceLevel, missingUsers []string) {
+			results = append(results, slotAttendance{
+				slot:         candidates[index],
+				participants: participants,
+				preferences:  preferences,
+				missingUsers: missingUsers,
+			})
+		})
+		return results
+	}
+
+	// Repeat every candidate in every occurrence, remembering which candidate it came from
+	length := candidates[0].EndTime.Sub(candidates[0].StartTime)
+	shifted := make([]models.TimeSlot, 0, len(candidates)*len(dayShifts))
+	origins := make([]int, 0, cap(shifted))
+	occurrences := make([][]time.Time, len(candidates))
+	for i, candidate := range candidates {
+		for _, days := range dayShifts {
+			start := candidate.StartTime.In(loc).AddDate(0, 0, days).UTC()
+			shifted = append(shifted, models.TimeSlot{StartTime: start, EndTime: start.Add(length), TimeZone: "UTC"})
+			origins = append(origins, i)
+			occurrences[i] = append(occurrences[i], start)
+		}
+	}
+	order := make([]int, len(shifted))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return shifted[a].StartTime.Compare(shifted[b].StartTime)
+	})
+	sorted := make([]models.TimeSlot, len(shifted))
+	for i, j := range order {
+		sorted[i] = shifted[j]
+	}
+
+	// Count the occurrences each participant can attend per candidate
+	attended := make([]map[string]int, len(candidates))
+	weakest := make([]map[string]int, len(candidates))
+	e.sweep(sorted, func(index int, participants []string, preferences []models.PreferenceLevel, _ []string) {
+		origin := origins[order[index]]
+		if attended[origin] == nil {
+			attended[origin] = make(map[string]int)
+			weakest[origin] = make(map[string]int)
+		}
+		for i, user := range participants {
+			tier := preferenceTier(preferences[i])
+			if current, ok := weakest[origin][user]; !ok || tier < current {
+				weakest[origin][user] = tier
+			}
+			attended[origin][user]++
+		}
+	})
+
+	for i, candidate := range candidates {
+		if attended[i] == nil {
+			continue
+		}
+		result := slotAttendance{
+			slot:        candidate,
+			occurrences: occurrences[i],
+			attended:    attended[i],
+		}
+		for _, user := range e.users {
+			if attended[i][user]*2 > len(dayShifts) {
+				result.participants = append(result.participants, user)
+				result.preferences = append(result.preferences, preferenceTiers[weakest[i][user]])
+			} else {
+				result.missingUsers = append(result.missingUsers, user)
+			}
+		}
+		if len(result.participants) > 0 {
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// inviteAudience distinguishes invite tokens from any other token signed by the server
+const inviteAudience = "meeting-scheduler/invite"
+
+// guestNamespace derives stable user IDs for guests, so a guest resubmitting replaces their availability
+var guestNamespace = uuid.MustParse("8d0f3c1e-4b7a-4e55-9a4f-2f6f1d7c9b21")
+
+var (
+	// ErrInvalidInvite is returned when an invite token is malformed or was not signed by this server
+	ErrInvalidInvite = errors.New("invalid invite")
+	// ErrInviteExpired is returned when an invite token is used after it expired
+	ErrInviteExpired = errors.New("invite expired")
+	// ErrInviteRevoked is returned when an invite token is used after the invite was revoked
+	ErrInviteRevoked = errors.New("invite revoked")
+	// ErrInviteNotFound is returned when an invite does not exist
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrGuestEmailRequired is returned when a guest submits through an open invite without an email
+	ErrGuestEmailRequired = errors.New("guests using an open invite must give their email")
+	// ErrGuestAlreadyResponded is returned when a guest submits through an open invite with the email of
+	// a guest who already responded, who can only change their availability with their personal invite
+	ErrGuestAlreadyResponded = errors.New("a guest with this email already responded, use the personal invite issued to them")
+	// ErrGuestAlreadyInvited is returned when a guest submits through an open invite with an email that
+	// already has an invite to the event, which only the invitee may respond with
+	ErrGuestAlreadyInvited = errors.New("this email was already invited, use the invite sent to it")
+)
+
+// InviteNotifier is told about invites as they are created, with their token. It is called on
+// the path of the request creating the invite, so it must not block on slow work.
+type InviteNotifier interface {
+	InviteCreated(invite *models.EventInvite, token string)
+}
+
+// InviteService issues the signed, expiring invite tokens guests submit availability with
+type InviteService struct {
+	inviteRepo repository.InviteStore
+	secret     []byte
+	ttl        time.Duration
+	notifier   InviteNotifier
+	now        func() time.Time
+}
+
+// NewInviteService creates a new instance of InviteService. Tokens are signed with secret and
+// invites last ttl unless created with another expiry.
+func NewInviteService(inviteRepo repository.InviteStore, secret []byte, ttl time.Duration) *InviteService {
+	return &InviteService{
+		inviteRepo: inviteRepo,
+		secret:     secret,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+// Create invites guests to an event and returns the invite with its token. An invite with an
+// email is bound to that invitee; without one it can be shared with anyone.
+func (s *InviteService) Create(eventID, createdBy string, req models.CreateInviteRequest) (*models.EventInvite, string, error) {
+	invite, token, err := s.prepare(eventID, createdBy, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.inviteRepo.CreateInvite(invite); err != nil {
+		return nil, "", err
+	}
+	s.Issued(invite, token)
+	return invite, token, nil
+}
+
+// prepare builds an invite and signs its token without storing the invite
+func (s *InviteService) prepare(eventID, createdBy string, req models.CreateInviteRequest) (*models.EventInvite, string, error) {
+	now := s.now()
+	expiresAt := now.Add(s.ttl)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return nil, "", fmt.Errorf("%w: expiry must be in the future", ErrInvalidInvite)
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	invite := &models.EventInvite{
+		ID:        uuid.New().String(),
+		EventID:   eventID,
+		Name:      strings.TrimSpace(req.Name),
+		Email:     normalizeEmail(req.Email),
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Second),
+		CreatedAt: now,
+	}
+	token, err := s.Token(invite)
+	if err != nil {
+		return nil, "", err
+	}
+	return invite, token, nil
+}
+
+// Issued tells the notifier about an invite once it was stored
+func (s *InviteService) Issued(invite *models.EventInvite, token string) {
+	if s.notifier != nil {
+		s.notifier.InviteCreated(invite, token)
+	}
+}
+
+// Token signs a token for an invite, valid until the invite expires or is revoked. Tokens are not
+// stored, so this also gives guests a new link to an invite they were sent before.
+func (s *InviteService) Token(invite *models.EventInvite) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ID:        invite.ID,
+		Audience:  jwt.ClaimStrings{inviteAudience},
+		ExpiresAt: jwt.NewNumericDate(invite.ExpiresAt),
+		IssuedAt:  jwt.NewNumericDate(s.now()),
+	}).SignedString(s.secret)
+}
+
+// Personal invites the guest of an open invite on their own, so only they can replace the availability
+// they submit. The invite expires with the open invite. It is not stored: it is stored along with the
+// guest's first submission by AvailabilityService.SubmitAsNewGuest, then sent to the guest with Issued
+// like any addressed invite.
+func (s *InviteService) Personal(open *models.EventInvite, name, email string) (*models.EventInvite, string, error) {
+	expiresAt := open.ExpiresAt
+	return s.prepare(open.EventID, open.CreatedBy, models.CreateInviteRequest{Name: name, Email: email, ExpiresAt: &expiresAt})
+}
+
+// SetNotifier sets the notifier told about every invite created from now on
+func (s *InviteService) SetNotifier(notifier InviteNotifier) {
+	s.notifier = notifier
+}
+
+// Verify checks the signature and expiry of an invite token and that the invite was not revoked
+func (s *InviteService) Verify(token string) (*models.EventInvite, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(inviteAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrInviteExpired
+	}
+	if err != nil {
+		return nil, ErrInvalidInvite
+	}
+
+	invite, err := s.inviteRepo.GetInvite(claims.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invite.RevokedAt != nil {
+		return nil, ErrInviteRevoked
+	}
+	return invite, nil
+}
+
+// List returns the invites to an event
+func (s *InviteService) List(eventID string) ([]models.EventInvite, error) {
+	return s.inviteRepo.ListInvites(eventID)
+}
+
+// Revoke stops an invite to an event from being used. Availability already submitted with it is kept.
+func (s *InviteService) Revoke(eventID, inviteID string) error {
+	invite, err := s.inviteRepo.GetInvite(inviteID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && invite.EventID != eventID) {
+		return ErrInviteNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if invite.RevokedAt != nil {
+		return nil
+	}
+	return s.inviteRepo.RevokeInvite(inviteID, s.now())
+}
+
+// Guest identifies the guest submitting through an invite. Invitees of an addressed invite are
+// identified by the invite; guests using an open invite by the email they give, which anyone holding
+// the invite could give, so their submissions are bound to a Personal invite.
+func Guest(invite *models.EventInvite, name, email string) (string, *models.GuestDetails, error) {
+	guest := &models.GuestDetails{InviteID: invite.ID, Name: strings.TrimSpace(name), Email: invite.Email}
+	if invite.Email != "" {
+		if guest.Name == "" {
+			guest.Name = invite.Name
+		}
+		return GuestUserID(invite.ID), guest, nil
+	}
+
+	guest.Email = normalizeEmail(email)
+	if guest.Email == "" {
+		return "", nil, ErrGuestEmailRequired
+	}
+	return GuestUserID(invite.EventID + "/" + guest.Email), guest, nil
+}
+
+// GuestUserID derives the user ID a guest submits availability under
+func GuestUserID(key string) string {
+	return uuid.NewSHA1(guestNamespace, []byte(key)).String()
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
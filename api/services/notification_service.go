@@ -0,0 +1,421 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/mail"
+)
+
+// defaultTemplates holds the built-in email templates. Each notification has a subject, a plain
+// text and an HTML template, named <notification>.subject.tmpl, .txt.tmpl and .html.tmpl.
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Notifications emailed to participants
+const (
+	NotificationInvitation = "invitation" // Asks for availability
+	NotificationReminder   = "reminder"   // Asks again before the response deadline
+	NotificationFinalized  = "finalized"  // Announces the chosen time, with a calendar file
+)
+
+// notificationQueueSize bounds the notifications waiting to be sent
+const notificationQueueSize = 256
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(ctx context.Context, msg *mail.Message) error
+}
+
+// Recipient is someone a notification is emailed to
+type Recipient struct {
+	Name  string // How the message greets them
+	Email string
+	Link  string // Where they respond, the event's link when empty
+}
+
+// NotificationData is what notification templates are executed with
+type NotificationData struct {
+	Event     *models.Event
+	Recipient string // Name of the recipient
+	Organizer string
+	Link      string // Where the recipient responds or finds the event, empty without a base URL
+	TimeZone  string // Zone times are shown in: the event's own
+}
+
+// NotificationOptions configures the messages sent by NotificationService
+type NotificationOptions struct {
+	From         string // Sender of every message
+	BaseURL      string // Address of the web application, events are linked at BaseURL/events/{id}
+	EmailDomain  string // Completes the addresses of users whose ID is not an email address
+	TemplatesDir string // Templates found in this directory replace the built-in ones
+}
+
+// notificationTemplates are the templates of one notification
+type notificationTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// NotificationService emails participants when they are invited to an event, when they are
+// reminded to respond and when a time is chosen. Notifications for changes are queued and sent
+// by a NotificationWorker, so slow mail servers never hold up requests; queued notifications
+// are lost when the server stops.
+type NotificationService struct {
+	events    *EventService
+	calendars *CalendarService
+	mailer    Mailer
+	opts      NotificationOptions
+	templates map[string]*notificationTemplates
+	queue     chan func(ctx context.Context)
+}
+
+// NewNotificationService creates a new instance of NotificationService, failing when a template
+// does not parse
+func NewNotificationService(
+	events *EventService,
+	calendars *CalendarService,
+	mailer Mailer,
+	opts NotificationOptions,
+) (*NotificationService, error) {
+	templates, err := loadNotificationTemplates(opts.TemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationService{
+		events:    events,
+		calendars: calendars,
+		mailer:    mailer,
+		opts:      opts,
+		templates: templates,
+		queue:     make(chan func(ctx context.Context), notificationQueueSize),
+	}, nil
+}
+
+// Invite asks recipients for their availability in an event
+func (s *NotificationService) Invite(ctx context.Context, event *models.Event, recipients []Recipient) error {
+	return s.send(ctx, NotificationInvitation, event, recipients, nil)
+}
+
+// Remind asks recipients again for their availability in an event
+func (s *NotificationService) Remind(ctx context.Context, event *models.Event, recipients []Recipient) error {
+	return s.send(ctx, NotificationReminder, event, recipients, nil)
+}
+
+// AnnounceFinal tells recipients the time chosen for an event, attaching it as a calendar file
+func (s *NotificationService) AnnounceFinal(ctx context.Context, event *models.Event, recipients []Recipient) error {
+	if event.FinalSlot == nil {
+		return fmt.Errorf("event %s has no final slot", event.ID)
+	}
+	calendar, err := s.calendars.Export(event, 1)
+	if err != nil {
+		return fmt.Errorf("exporting calendar: %w", err)
+	}
+	return s.send(ctx, NotificationFinalized, event, recipients, &mail.Attachment{
+		Filename:    "meeting.ics",
+		ContentType: "text/calendar; charset=utf-8; method=PUBLISH",
+		Data:        calendar,
+	})
+}
+
+// send emails a notification to every recipient separately, carrying on after failures
+func (s *NotificationService) send(
+	ctx context.Context,
+	notification string,
+	event *models.Event,
+	recipients []Recipient,
+	attachment *mail.Attachment,
+) error {
+	var errs []error
+	for _, recipient := range recipients {
+		msg, err := s.render(notification, event, recipient)
+		if err != nil {
+			return err
+		}
+		if attachment != nil {
+			msg.Attachments = append(msg.Attachments, *attachment)
+		}
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("sending %s to %s: %w", notification, recipient.Email, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// render executes the templates of a notification for one recipient
+func (s *NotificationService) render(notification string, event *models.Event, recipient Recipient) (*mail.Message, error) {
+	templates := s.templates[notification]
+	data := NotificationData{
+		Event:     event,
+		Recipient: recipient.Name,
+		Organizer: event.CreatedBy,
+		Link:      recipient.Link,
+		TimeZone:  eventTimeZone(event),
+	}
+	if data.Link == "" {
+		data.Link = s.eventLink(event.ID, "")
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := templates.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("rendering %s subject: %w", notification, err)
+	}
+	if err := templates.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("rendering %s text: %w", notification, err)
+	}
+	if err := templates.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("rendering %s HTML: %w", notification, err)
+	}
+	return &mail.Message{
+		From:    s.opts.From,
+		To:      []string{recipient.Email},
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Participants returns the participants of an event as recipients, leaving out the given users
+func (s *NotificationService) Participants(event *models.Event, except ...string) []Recipient {
+	var recipients []Recipient
+	for _, participant := range event.Participants {
+		if participant.UserID == "" || slices.Contains(except, participant.UserID) {
+			continue
+		}
+		recipients = append(recipients, s.ParticipantRecipient(participant.UserID))
+	}
+	return recipients
+}
+
+// ParticipantRecipient returns a participant as a recipient
+func (s *NotificationService) ParticipantRecipient(userID string) Recipient {
+	return Recipient{Name: userID, Email: emailAddress(userID, s.opts.EmailDomain)}
+}
+
+// GuestRecipient returns the guest holding an invite as a recipient, linked to the event with
+// their invite token
+func (s *NotificationService) GuestRecipient(invite *models.EventInvite, token string) Recipient {
+	name := invite.Name
+	if name == "" {
+		name = invite.Email
+	}
+	return Recipient{Name: name, Email: invite.Email, Link: s.eventLink(invite.EventID, token)}
+}
+
+// eventLink returns the address of an event in the web application, empty without a base URL
+func (s *NotificationService) eventLink(eventID, inviteToken string) string {
+	if s.opts.BaseURL == "" {
+		return ""
+	}
+	link := strings.TrimRight(s.opts.BaseURL, "/") + "/events/" + url.PathEscape(eventID)
+	if inviteToken != "" {
+		link += "?invite=" + url.QueryEscape(inviteToken)
+	}
+	return link
+}
+
+// Notify queues the notifications of a change: participants are invited when an event starts
+// collecting availability and told the chosen time when it is finalized
+func (s *NotificationService) Notify(change Change) {
+	event := change.Event
+	switch {
+	case change.Type == models.ChangeEventOpened,
+		change.Type == models.ChangeEventCreated && event.Status == models.EventStatusPolling:
+		s.enqueue(fmt.Sprintf("invitations to event %s", event.ID), func(ctx context.Context) error {
+			return s.Invite(ctx, event, s.Participants(event, event.CreatedBy))
+		})
+	case change.Type == models.ChangeEventFinalized:
+		s.enqueue(fmt.Sprintf("announcement of event %s", event.ID), func(ctx context.Context) error {
+			recipients, err := s.finalRecipients(event)
+			if err != nil {
+				return err
+			}
+			return s.AnnounceFinal(ctx, event, recipients)
+		})
+	}
+}
+
+// InviteCreated queues the invitation of a guest invited by email
+func (s *NotificationService) InviteCreated(invite *models.EventInvite, token string) {
+	if invite.Email == "" {
+		return
+	}
+	s.enqueue(fmt.Sprintf("invitation of %s to event %s", invite.Email, invite.EventID), func(ctx context.Context) error {
+		event, err := s.events.GetEvent(invite.EventID)
+		if err != nil {
+			return err
+		}
+		return s.Invite(ctx, event, []Recipient{s.GuestRecipient(invite, token)})
+	})
+}
+
+// finalRecipients returns everyone told the chosen time: the participants but the user who chose
+// it, and the guests who responded
+func (s *NotificationService) finalRecipients(event *models.Event) ([]Recipient, error) {
+	recipients := s.Participants(event, event.FinalizedBy)
+	availabilities, err := s.events.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	for _, availability := range availabilities {
+		if availability.Guest == nil || availability.Guest.Email == "" {
+			continue
+		}
+		name := availability.Guest.Name
+		if name == "" {
+			name = availability.Guest.Email
+		}
+		recipients = append(recipients, Recipient{Name: name, Email: availability.Guest.Email})
+	}
+	return recipients, nil
+}
+
+// enqueue queues a notification for the worker, dropping it when the queue is full
+func (s *NotificationService) enqueue(description string, send func(ctx context.Context) error) {
+	job := func(ctx context.Context) {
+		if err := send(ctx); err != nil {
+			log.Printf("Failed to send %s: %v", description, err)
+		}
+	}
+	select {
+	case s.queue <- job:
+	default:
+		log.Printf("Notification queue is full, dropped %s", description)
+	}
+}
+
+// NotificationWorker sends the notifications queued by a NotificationService
+type NotificationWorker struct {
+	notifications *NotificationService
+}
+
+// NewNotificationWorker creates a new instance of NotificationWorker
+func NewNotificationWorker(notifications *NotificationService) *NotificationWorker {
+	return &NotificationWorker{notifications: notifications}
+}
+
+// Run sends queued notifications until the context is cancelled
+func (w *NotificationWorker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-w.notifications.queue:
+			job(ctx)
+		}
+	}
+}
+
+// ProcessQueued sends the notifications queued so far
+func (w *NotificationWorker) ProcessQueued(ctx context.Context) {
+	for {
+		select {
+		case job := <-w.notifications.queue:
+			job(ctx)
+		default:
+			return
+		}
+	}
+}
+
+// eventTimeZone returns the zone an event's times are shown in
+func eventTimeZone(event *models.Event) string {
+	if event.FinalSlot != nil && event.FinalSlot.TimeZone != "" {
+		return event.FinalSlot.TimeZone
+	}
+	for _, slot := range event.TimeSlots {
+		if slot.TimeZone != "" {
+			return slot.TimeZone
+		}
+	}
+	return "UTC"
+}
+
+// emailAddress returns the email address of a user, at domain when their ID is not one
+func emailAddress(userID, domain string) string {
+	if strings.Contains(userID, "@") {
+		return userID
+	}
+	return userID + "@" + domain
+}
+
+// templateFuncs are available to notification templates
+var templateFuncs = map[string]any{
+	// formatTime shows a time with its date in a zone, e.g. "Monday 7 January 2030, 09:00 GMT"
+	"formatTime": func(t time.Time, zone string) string {
+		return t.In(loadZone(zone)).Format("Monday 2 January 2006, 15:04 MST")
+	},
+	// formatClock shows the time of day in a zone, e.g. "10:00 GMT"
+	"formatClock": func(t time.Time, zone string) string {
+		return t.In(loadZone(zone)).Format("15:04 MST")
+	},
+}
+
+func loadZone(zone string) *time.Location {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// loadNotificationTemplates parses the built-in templates, replacing those found in dir
+func loadNotificationTemplates(dir string) (map[string]*notificationTemplates, error) {
+	read := func(name string) (string, error) {
+		if dir != "" {
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				return string(content), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		content, err := defaultTemplates.ReadFile("templates/" + name)
+		return string(content), err
+	}
+
+	templates := make(map[string]*notificationTemplates)
+	for _, notification := range []string{NotificationInvitation, NotificationReminder, NotificationFinalized} {
+		parsed := &notificationTemplates{}
+		for _, part := range []string{"subject", "txt", "html"} {
+			name := notification + "." + part + ".tmpl"
+			content, err := read(name)
+			if err != nil {
+				return nil, fmt.Errorf("reading template %s: %w", name, err)
+			}
+			if part == "html" {
+				parsed.html, err = htmltemplate.New(name).Funcs(templateFuncs).Parse(content)
+			} else {
+				var t *texttemplate.Template
+				t, err = texttemplate.New(name).Funcs(templateFuncs).Parse(content)
+				if part == "subject" {
+					parsed.subject = t
+				} else {
+					parsed.text = t
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parsing template %s: %w", name, err)
+			}
+		}
+		templates[notification] = parsed
+	}
+	return templates, nil
+}
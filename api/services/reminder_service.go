@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+// DefaultReminderOffsets are how long before the response deadline participants are reminded
+var DefaultReminderOffsets = []time.Duration{48 * time.Hour, 4 * time.Hour}
+
+// DefaultReminderCheckInterval is the default time between two scans for reminders to send
+const DefaultReminderCheckInterval = time.Minute
+
+// ReminderService reminds the participants and invited guests who have not responded to an
+// event that its response deadline is coming. Reminders are due at fixed offsets before the
+// deadline and each is recorded before it is sent, so nobody gets the same reminder twice,
+// even across restarts. When several are due at once, only the one closest to the deadline is sent.
+type ReminderService struct {
+	events        *EventService
+	invites       *InviteService
+	reminders     repository.ReminderStore
+	notifications *NotificationService
+	offsets       []time.Duration // Longest first
+	now           func() time.Time
+}
+
+// NewReminderService creates a new instance of ReminderService, reminding at the given offsets
+// before deadlines, or at DefaultReminderOffsets when there are none
+func NewReminderService(
+	events *EventService,
+	invites *InviteService,
+	reminders repository.ReminderStore,
+	notifications *NotificationService,
+	offsets []time.Duration,
+) *ReminderService {
+	if len(offsets) == 0 {
+		offsets = DefaultReminderOffsets
+	}
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	return &ReminderService{
+		events:        events,
+		invites:       invites,
+		reminders:     reminders,
+		notifications: notifications,
+		offsets:       sorted,
+		now:           time.Now,
+	}
+}
+
+// DueEventIDs lists the polling events whose deadline is close enough for a reminder to be due
+func (s *ReminderService) DueEventIDs() ([]string, error) {
+	now := s.now()
+	return s.reminders.ListRemindableEventIDs(now, now.Add(s.offsets[0]))
+}
+
+// Remind sends the latest reminder due for an event to everyone who has not responded and was
+// not sent it yet. It returns the number of reminders sent.
+func (s *ReminderService) Remind(ctx context.Context, eventID string) (int, error) {
+	event, err := s.events.GetEvent(eventID)
+	if err != nil {
+		return 0, err
+	}
+	if event.Status != models.EventStatusPolling || event.Deadline == nil {
+		return 0, nil
+	}
+	now := s.now()
+	offset, ok := s.dueOffset(*event.Deadline, now)
+	if !ok {
+		return 0, nil
+	}
+
+	pending, err := s.nonResponders(event, now)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, p := range pending {
+		claimed, err := s.reminders.ClaimReminder(&models.EventReminder{
+			EventID: event.ID,
+			Offset:  offset,
+			UserID:  p.userID,
+			SentAt:  now,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recording reminder of %s: %w", p.userID, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.notifications.Remind(ctx, event, []Recipient{p.recipient}); err != nil {
+			if releaseErr := s.reminders.ReleaseReminder(event.ID, offset, p.userID); releaseErr != nil {
+				log.Printf("Failed to release reminder of %s in event %s: %v", p.userID, event.ID, releaseErr)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		sent++
+	}
+	return sent, errors.Join(errs...)
+}
+
+// dueOffset returns the offset of the latest reminder whose time has come
+func (s *ReminderService) dueOffset(deadline, now time.Time) (time.Duration, bool) {
+	for i := len(s.offsets) - 1; i >= 0; i-- {
+		if !now.Before(deadline.Add(-s.offsets[i])) {
+			return s.offsets[i], true
+		}
+	}
+	return 0, false
+}
+
+// pendingReminder is someone who has not responded to an event
+type pendingReminder struct {
+	userID    string // Identifies the reminder sent to them
+	recipient Recipient
+}
+
+// nonResponders lists the participants but the organizer, and the guests invited by email whose
+// invite is still usable, who have not submitted availability
+func (s *ReminderService) nonResponders(event *models.Event, now time.Time) ([]pendingReminder, error) {
+	availabilities, err := s.events.eventRepo.GetParticipantAvailabilities(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("getting participant availabilities: %w", err)
+	}
+	responded := make(map[string]bool, len(availabilities))
+	for _, availability := range availabilities {
+		responded[availability.UserID] = true
+	}
+
+	var pending []pendingReminder
+	for _, participant := range event.Participants {
+		if participant.UserID == event.CreatedBy || responded[participant.UserID] {
+			continue
+		}
+		pending = append(pending, pendingReminder{
+			userID:    participant.UserID,
+			recipient: s.notifications.ParticipantRecipient(participant.UserID),
+		})
+	}
+
+	invites, err := s.invites.List(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing invites: %w", err)
+	}
+	for i := range invites {
+		invite := &invites[i]
+		userID := GuestUserID(invite.ID)
+		if invite.Email == "" || invite.RevokedAt != nil || !invite.ExpiresAt.After(now) || responded[userID] {
+			continue
+		}
+		token, err := s.invites.Token(invite)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, pendingReminder{userID: userID, recipient: s.notifications.GuestRecipient(invite, token)})
+	}
+	return pending, nil
+}
+
+// ReminderWorker periodically reminds participants who have not responded before deadlines
+type ReminderWorker struct {
+	reminders *ReminderService
+	interval  time.Duration
+}
+
+// NewReminderWorker creates a new instance of ReminderWorker
+func NewReminderWorker(reminders *ReminderService, interval time.Duration) *ReminderWorker {
+	if interval <= 0 {
+		interval = DefaultReminderCheckInterval
+	}
+	return &ReminderWorker{reminders: reminders, interval: interval}
+}
+
+// Run sends due reminders until the context is cancelled
+func (w *ReminderWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue sends the reminders due in every event approaching its deadline
+func (w *ReminderWorker) ProcessDue(ctx context.Context) {
+	ids, err := w.reminders.DueEventIDs()
+	if err != nil {
+		log.Printf("Failed to list events to send reminders for: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		sent, err := w.reminders.Remind(ctx, id)
+		if err != nil {
+			log.Printf("Failed to send reminders of event %s: %v", id, err)
+		}
+		if sent > 0 {
+			log.Printf("Reminded %d participants of event %s", sent, id)
+		}
+	}
+}
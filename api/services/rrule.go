@@ -0,0 +1,363 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+)
+
+// ErrInvalidRecurrence is returned when the recurrence rule of an event cannot be expanded
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// maxRecurrencePeriods bounds the number of periods walked while expanding a rule,
+// so rules whose filters never match cannot loop forever
+const maxRecurrencePeriods = 5000
+
+// Frequency is the FREQ part of an RFC 5545 recurrence rule
+type Frequency string
+
+// Supported recurrence frequencies
+const (
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+	FrequencyYearly  Frequency = "YEARLY"
+)
+
+// WeekdayRule is a BYDAY entry such as "MO", "1FR" or "-1SU". Ordinals are only meaningful for MONTHLY rules.
+type WeekdayRule struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// RRule is a parsed RFC 5545 recurrence rule
+type RRule struct {
+	Frequency Frequency
+	Interval  int
+	Count     int
+	Until     time.Time
+	ByDay     []WeekdayRule
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRRule parses a recurrence rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10".
+// UNTIL values without a trailing Z are interpreted in loc.
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			freq := Frequency(strings.ToUpper(val))
+			switch freq {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+				rule.Frequency = freq
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("INTERVAL must be a positive integer: %q", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("COUNT must be a positive integer: %q", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := ical.ParseTime(val, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", val)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(val), ",") {
+				day, err := parseWeekdayRule(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "WKST":
+			// Weeks always start on Monday, the RFC 5545 default
+			if strings.ToUpper(val) != "MO" {
+				return nil, fmt.Errorf("unsupported WKST %q", val)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", name)
+		}
+	}
+
+	if rule.Frequency == "" {
+		return nil, errors.New("rrule must contain FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("rrule must not contain both COUNT and UNTIL")
+	}
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Frequency != FrequencyMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported with FREQ=MONTHLY")
+		}
+	}
+	return rule, nil
+}
+
+// parseWeekdayRule parses a single BYDAY entry
+func parseWeekdayRule(code string) (WeekdayRule, error) {
+	if len(code) < 2 {
+		return WeekdayRule{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	weekday, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayRule{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+
+	rule := WeekdayRule{Weekday: weekday}
+	if prefix := code[:len(code)-2]; prefix != "" {
+		ordinal, err := strconv.Atoi(prefix)
+		if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+			return WeekdayRule{}, fmt.Errorf("invalid BYDAY %q", code)
+		}
+		rule.Ordinal = ordinal
+	}
+	return rule, nil
+}
+
+// Occurrences expands the rule from dtstart in dtstart's location, skipping the given excluded
+// dates, and returns at most limit occurrence starts. The wall-clock time of dtstart is kept for
+// every occurrence, so occurrences stay at the same local time across DST changes.
+// Excluded dates match any occurrence on the same local calendar day.
+func (r *RRule) Occurrences(dtstart time.Time, exdates []time.Time, limit int) []time.Time {
+	occurrences := make([]time.Time, 0)
+	r.expand(dtstart, exdates, func(occurrence time.Time) bool {
+		occurrences = append(occurrences, occurrence)
+		return limit <= 0 || len(occurrences) < limit
+	})
+	return occurrences
+}
+
+// OccurrencesFrom expands the rule like Occurrences and returns at most limit occurrence starts at
+// or after from. Earlier occurrences still count towards the rule's COUNT.
+func (r *RRule) OccurrencesFrom(dtstart time.Time, exdates []time.Time, from time.Time, limit int) []time.Time {
+	occurrences := make([]time.Time, 0)
+	r.expand(dtstart, exdates, func(occurrence time.Time) bool {
+		if occurrence.Before(from) {
+			return true
+		}
+		occurrences = append(occurrences, occurrence)
+		return limit <= 0 || len(occurrences) < limit
+	})
+	return occurrences
+}
+
+// OccurrencesBefore expands the rule like Occurrences and returns the occurrence starts before end
+func (r *RRule) OccurrencesBefore(dtstart time.Time, exdates []time.Time, end time.Time) []time.Time {
+	occurrences := make([]time.Time, 0)
+	r.expand(dtstart, exdates, func(occurrence time.Time) bool {
+		if !occurrence.Before(end) {
+			return false
+		}
+		occurrences = append(occurrences, occurrence)
+		return true
+	})
+	return occurrences
+}
+
+// expand passes the occurrences of the rule to visit in chronological order until visit returns false
+func (r *RRule) expand(dtstart time.Time, exdates []time.Time, visit func(time.Time) bool) {
+	loc := dtstart.Location()
+	excluded := make(map[string]bool, len(exdates))
+	for _, exdate := range exdates {
+		excluded[exdate.In(loc).Format("2006-01-02")] = true
+	}
+
+	generated := 0
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		for _, day := range r.periodDates(dtstart, period) {
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(),
+				dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+			if occurrence.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && occurrence.After(r.Until) {
+				return
+			}
+			if r.Count > 0 && generated >= r.Count {
+				return
+			}
+			generated++
+
+			if excluded[occurrence.Format("2006-01-02")] {
+				continue
+			}
+			if !visit(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// periodDates returns the sorted candidate dates (at midnight UTC) of the n-th period of the rule
+func (r *RRule) periodDates(dtstart time.Time, n int) []time.Time {
+	start := time.Date(dtstart.Year(), dtstart.Month(), dtstart.Day(), 0, 0, 0, 0, time.UTC)
+	step := n * r.Interval
+	dates := make([]time.Time, 0)
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		day := start.AddDate(0, 0, step)
+		if len(r.ByDay) == 0 || r.matchesWeekday(day.Weekday()) {
+			dates = append(dates, day)
+		}
+
+	case FrequencyWeekly:
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*step)
+		if len(r.ByDay) == 0 {
+			dates = append(dates, monday.AddDate(0, 0, (int(start.Weekday())+6)%7))
+			break
+		}
+		for offset := 0; offset < 7; offset++ {
+			day := monday.AddDate(0, 0, offset)
+			if r.matchesWeekday(day.Weekday()) {
+				dates = append(dates, day)
+			}
+		}
+
+	case FrequencyMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if len(r.ByDay) == 0 {
+			if day := first.AddDate(0, 0, start.Day()-1); day.Month() == first.Month() {
+				dates = append(dates, day)
+			}
+			break
+		}
+		seen := make(map[int]bool)
+		for _, rule := range r.ByDay {
+			for _, day := range monthWeekdays(first, rule) {
+				if !seen[day.Day()] {
+					seen[day.Day()] = true
+					dates = append(dates, day)
+				}
+			}
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	case FrequencyYearly:
+		day := time.Date(start.Year()+step, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if day.Month() == start.Month() {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+func (r *RRule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// monthWeekdays returns the days of the month starting at first that match a BYDAY entry
+func monthWeekdays(first time.Time, rule WeekdayRule) []time.Time {
+	matches := make([]time.Time, 0, 5)
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == rule.Weekday {
+			matches = append(matches, day)
+		}
+	}
+
+	switch {
+	case rule.Ordinal > 0 && rule.Ordinal <= len(matches):
+		return matches[rule.Ordinal-1 : rule.Ordinal]
+	case rule.Ordinal < 0 && -rule.Ordinal <= len(matches):
+		return matches[len(matches)+rule.Ordinal : len(matches)+rule.Ordinal+1]
+	case rule.Ordinal == 0:
+		return matches
+	}
+	return nil
+}
+
+// recurrenceLocation returns the zone an event's occurrences are expanded in
+func recurrenceLocation(event *models.Event) *time.Location {
+	if event.Recurrence != nil && event.Recurrence.TimeZone != "" {
+		return loadLocation(event.Recurrence.TimeZone)
+	}
+	if len(event.TimeSlots) > 0 {
+		return loadLocation(event.TimeSlots[0].TimeZone)
+	}
+	return time.UTC
+}
+
+// ValidateRecurrence checks that an event's recurrence can be expanded
+func ValidateRecurrence(recurrence *models.Recurrence) error {
+	if recurrence == nil {
+		return nil
+	}
+	if recurrence.TimeZone != "" {
+		if _, err := time.LoadLocation(recurrence.TimeZone); err != nil {
+			return fmt.Errorf("unknown recurrence time zone %q", recurrence.TimeZone)
+		}
+	}
+	_, err := ParseRRule(recurrence.RRule, time.UTC)
+	return err
+}
+
+// ExpandOccurrences returns the starts of the first limit occurrences of an event starting at or
+// after from, in the event's time zone. Non-recurring events have a single occurrence at the start
+// of their earliest time slot, whenever it is.
+func ExpandOccurrences(event *models.Event, from time.Time, limit int) ([]time.Time, error) {
+	if len(event.TimeSlots) == 0 {
+		return nil, nil
+	}
+
+	loc := recurrenceLocation(event)
+	dtstart := firstSlotStart(event).In(loc)
+	if event.Recurrence == nil || event.Recurrence.RRule == "" {
+		return []time.Time{dtstart}, nil
+	}
+	rule, err := ParseRRule(event.Recurrence.RRule, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return rule.OccurrencesFrom(dtstart, event.Recurrence.ExDates, from, limit), nil
+}
+
+// firstSlotStart returns the start of an event's earliest time slot, which anchors its recurrence
+func firstSlotStart(event *models.Event) time.Time {
+	start := event.TimeSlots[0].StartTime
+	for _, slot := range event.TimeSlots[1:] {
+		if slot.StartTime.Before(start) {
+			start = slot.StartTime
+		}
+	}
+	return start
+}
@@ -7,116 +7,263 @@ import (
 	"github.com/shani34/meeting-scheduler/api/models"
 )
 
+// DefaultSlotStep is the default distance between consecutive candidate start times
+const DefaultSlotStep = 30 * time.Minute
+
+// DefaultOccurrenceHorizon is the default number of upcoming occurrences considered for recurring events
+const DefaultOccurrenceHorizon = 4
+
 // SchedulerService handles the business logic for finding optimal meeting times
 type SchedulerService struct {
-	// Add any dependencies here (e.g., database client)
+	slotStep          time.Duration
+	offHoursPenalty   float64
+	occurrenceHorizon int
+	now               func() time.Time
+}
+
+// SchedulerOption configures a SchedulerService
+type SchedulerOption func(*SchedulerService)
+
+// WithSlotStep sets the distance between consecutive candidate start times (e.g. 15 or 30 minutes)
+func WithSlotStep(step time.Duration) SchedulerOption {
+	return func(s *SchedulerService) {
+		if step > 0 {
+			s.slotStep = step
+		}
+	}
+}
+
+// WithOffHoursPenalty sets the score subtracted for each attendee outside their working hours
+func WithOffHoursPenalty(penalty float64) SchedulerOption {
+	return func(s *SchedulerService) {
+		if penalty >= 0 {
+			s.offHoursPenalty = penalty
+		}
+	}
+}
+
+// WithOccurrenceHorizon sets how many upcoming occurrences of a recurring event a slot must work for
+func WithOccurrenceHorizon(occurrences int) SchedulerOption {
+	return func(s *SchedulerService) {
+		if occurrences > 0 {
+			s.occurrenceHorizon = occurrences
+		}
+	}
+}
+
+// WithClock sets the clock telling which occurrences of a recurring event are still to come
+func WithClock(now func() time.Time) SchedulerOption {
+	return func(s *SchedulerService) {
+		if now != nil {
+			s.now = now
+		}
+	}
 }
 
 // NewSchedulerService creates a new instance of SchedulerService
-func NewSchedulerService() *SchedulerService {
-	return &SchedulerService{}
+func NewSchedulerService(opts ...SchedulerOption) *SchedulerService {
+	s := &SchedulerService{
+		slotStep:          DefaultSlotStep,
+		offHoursPenalty:   DefaultOffHoursPenalty,
+		occurrenceHorizon: DefaultOccurrenceHorizon,
+		now:               time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// FindOptimalTimeSlots finds the best meeting time slots based on all participants' availability
+// FindOptimalTimeSlots finds the best meeting time slots based on all participants' availability.
+// Candidates are windows of exactly event.Duration minutes slid across each event time slot
+// in steps of the configured slot step. Candidates missing a required participant are discarded,
+// and attendees with a working-hours profile penalise candidates outside their local working day.
+// For recurring events a participant only counts as attending when they are free for the candidate
+// in a majority of the next occurrences from now on. It fails when the recurrence rule is invalid.
 func (s *SchedulerService) FindOptimalTimeSlots(
 	event *models.Event,
 	participantAvailabilities []models.ParticipantAvailability,
-) []models.RecommendedTimeSlot {
+	profiles ...models.WorkingHours,
+) ([]models.RecommendedTimeSlot, error) {
 	if len(participantAvailabilities) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// Convert all time slots to UTC for comparison
+	// Generate fixed-length candidates inside the event windows
 	eventSlots := convertToUTC(event.TimeSlots)
-	participantSlots := make([][]models.TimeSlot, len(participantAvailabilities))
-	for i, pa := range participantAvailabilities {
-		participantSlots[i] = convertToUTC(pa.TimeSlots)
-	}
+	candidates := generateCandidateSlots(eventSlots, time.Duration(event.Duration)*time.Minute, s.slotStep)
 
-	// Find overlapping time slots
-	overlappingSlots := findOverlappingSlots(eventSlots, participantSlots)
+	// Recurring events must work for the majority of the next occurrences
+	dayShifts, err := s.occurrenceShifts(event)
+	if err != nil {
+		return nil, err
+	}
+	if len(dayShifts) == 0 {
+		return nil, nil // Every occurrence passed
+	}
+	loc := recurrenceLocation(event)
 
-	// Calculate scores for each overlapping slot
+	// Sweep each group of equal-length candidates against the participants' merged availability,
+	// dropping slots that miss a required participant and scoring the rest by attendee weight and preference
+	rules := newParticipantRules(event)
+	zones := newParticipantZones(participantAvailabilities, profiles)
 	recommendations := make([]models.RecommendedTimeSlot, 0)
-	for _, slot := range overlappingSlots {
-		participants, missingUsers := s.calculateParticipantAvailability(slot, participantAvailabilities)
-		recommendations = append(recommendations, models.RecommendedTimeSlot{
-			TimeSlot:     slot,
-			Participants: participants,
-			MissingUsers: missingUsers,
-			Score:        len(participants),
-		})
-	}
-
-	// Sort recommendations by score (highest first)
-	sort.Slice(recommendations, func(i, j int) bool {
-		return recommendations[i].Score > recommendations[j].Score
+	for _, group := range groupByLength(candidates) {
+		engine := newAttendanceEngine(participantAvailabilities, group[0].EndTime.Sub(group[0].StartTime))
+		absent := rules.missingInvitees(engine.users)
+		for _, attendance := range engine.attendance(group, dayShifts, loc) {
+			missingUsers := append(attendance.missingUsers, absent...)
+			if missingUsers == nil {
+				missingUsers = make([]string, 0)
+			}
+			if rules.hasRequiredMissing(missingUsers) {
+				continue
+			}
+			score, breakdown := rules.score(attendance.participants, attendance.preferences)
+			localTimes := zones.localTimes(attendance.slot, attendance.participants, missingUsers)
+			breakdown.OffHoursAttendees = countOffHours(localTimes, attendance.participants)
+			breakdown.OffHoursPenalty = float64(breakdown.OffHoursAttendees) * s.offHoursPenalty
+			recommendations = append(recommendations, models.RecommendedTimeSlot{
+				TimeSlot:     attendance.slot,
+				Participants: attendance.participants,
+				MissingUsers: missingUsers,
+				Score:        score - breakdown.OffHoursPenalty,
+				Breakdown:    breakdown,
+				LocalTimes:   localTimes,
+				Occurrences:  attendance.occurrences,
+				Attendance:   attendance.attended,
+			})
+		}
+	}
+
+	// Sort recommendations by score (highest first), earliest start first on ties
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].TimeSlot.StartTime.Before(recommendations[j].TimeSlot.StartTime)
 	})
 
-	return recommendations
+	return recommendations, nil
 }
 
-// calculateParticipantAvailability determines which participants can attend a given time slot
-func (s *SchedulerService) calculateParticipantAvailability(
-	slot models.TimeSlot,
-	participantAvailabilities []models.ParticipantAvailability,
-) ([]string, []string) {
-	participants := make([]string, 0)
-	missingUsers := make([]string, 0)
-
-	for _, pa := range participantAvailabilities {
-		if isSlotAvailable(slot, pa.TimeSlots) {
-			participants = append(participants, pa.UserID)
-		} else {
-			missingUsers = append(missingUsers, pa.UserID)
-		}
+// occurrenceShifts returns how many calendar days each of the next occurrences of the event, from
+// now on, lies after its first time slot. Non-recurring events yield no shift, and recurring events
+// whose occurrences all passed yield none.
+func (s *SchedulerService) occurrenceShifts(event *models.Event) ([]int, error) {
+	if event.Recurrence == nil || len(event.TimeSlots) == 0 {
+		return []int{0}, nil
+	}
+	occurrences, err := ExpandOccurrences(event, s.now(), s.occurrenceHorizon)
+	if err != nil {
+		return nil, err
 	}
 
-	return participants, missingUsers
+	first := firstSlotStart(event).In(recurrenceLocation(event))
+	shifts := make([]int, len(occurrences))
+	for i, occurrence := range occurrences {
+		shifts[i] = calendarDaysBetween(first, occurrence)
+	}
+	return shifts, nil
 }
 
 // Helper functions
 
 func convertToUTC(slots []models.TimeSlot) []models.TimeSlot {
 	utcSlots := make([]models.TimeSlot, len(slots))
+	locations := make(map[string]*time.Location)
 	for i, slot := range slots {
-		loc, _ := time.LoadLocation(slot.TimeZone)
+		loc, ok := locations[slot.TimeZone]
+		if !ok {
+			loc = loadLocation(slot.TimeZone)
+			locations[slot.TimeZone] = loc
+		}
 		utcSlots[i] = models.TimeSlot{
-			StartTime: slot.StartTime.In(loc).UTC(),
-			EndTime:   slot.EndTime.In(loc).UTC(),
-			TimeZone:  "UTC",
+			StartTime:  slot.StartTime.In(loc).UTC(),
+			EndTime:    slot.EndTime.In(loc).UTC(),
+			TimeZone:   "UTC",
+			Preference: slot.Preference,
 		}
 	}
 	return utcSlots
 }
 
-func findOverlappingSlots(eventSlots []models.TimeSlot, participantSlots [][]models.TimeSlot) []models.TimeSlot {
-	overlapping := make([]models.TimeSlot, 0)
+// toLocal converts a slot to the given time zone
+func toLocal(slot models.TimeSlot, loc *time.Location) models.TimeSlot {
+	return models.TimeSlot{
+		StartTime: slot.StartTime.In(loc),
+		EndTime:   slot.EndTime.In(loc),
+		TimeZone:  loc.String(),
+	}
+}
+
+// loadLocation loads a time zone by name, falling back to UTC for unknown names
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
 
+// generateCandidateSlots slides a window of the given duration across each event slot.
+// A non-positive duration falls back to the event slots themselves.
+func generateCandidateSlots(eventSlots []models.TimeSlot, duration, step time.Duration) []models.TimeSlot {
+	// Count the candidates first so they are allocated once
+	total := 0
+	for _, eventSlot := range eventSlots {
+		if duration > 0 && step > 0 && eventSlot.EndTime.Sub(eventSlot.StartTime) >= duration {
+			total += int((eventSlot.EndTime.Sub(eventSlot.StartTime)-duration)/step) + 1
+		} else {
+			total++
+		}
+	}
+	candidates := make([]models.TimeSlot, 0, total)
+	seen := make(map[int64]bool, total)
 	for _, eventSlot := range eventSlots {
-		// Find all slots that overlap with the event slot
-		for _, participantSlotList := range participantSlots {
-			for _, participantSlot := range participantSlotList {
-				if isOverlapping(eventSlot, participantSlot) {
-					overlapping = append(overlapping, eventSlot)
-					break
-				}
+		if duration <= 0 {
+			candidates = append(candidates, eventSlot)
+			continue
+		}
+		for start := eventSlot.StartTime; !start.Add(duration).After(eventSlot.EndTime); start = start.Add(step) {
+			if seen[start.UnixNano()] {
+				continue
 			}
+			seen[start.UnixNano()] = true
+			candidates = append(candidates, models.TimeSlot{
+				StartTime: start,
+				EndTime:   start.Add(duration),
+				TimeZone:  "UTC",
+			})
 		}
 	}
 
-	return overlapping
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].StartTime.Before(candidates[j].StartTime)
+	})
+	return candidates
 }
 
-func isOverlapping(slot1, slot2 models.TimeSlot) bool {
-	return slot1.StartTime.Before(slot2.EndTime) && slot2.StartTime.Before(slot1.EndTime)
+// calendarDaysBetween counts the calendar days from one local date to another
+func calendarDaysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
 }
 
-func isSlotAvailable(slot models.TimeSlot, availableSlots []models.TimeSlot) bool {
-	for _, availableSlot := range availableSlots {
-		if isOverlapping(slot, availableSlot) {
-			return true
+// groupByLength splits sorted candidates into groups of equal length, preserving order
+func groupByLength(candidates []models.TimeSlot) [][]models.TimeSlot {
+	groups := make([][]models.TimeSlot, 0)
+	index := make(map[time.Duration]int)
+	for _, candidate := range candidates {
+		length := candidate.EndTime.Sub(candidate.StartTime)
+		i, ok := index[length]
+		if !ok {
+			i = len(groups)
+			index[length] = i
+			groups = append(groups, nil)
 		}
+		groups[i] = append(groups[i], candidate)
 	}
-	return false
-} 
\ No newline at end of file
+	return groups
+}
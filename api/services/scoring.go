@@ -0,0 +1,92 @@
+package services
+
+import "github.com/shani34/meeting-scheduler/api/models"
+
+// defaultParticipantWeight is used for optional participants without an explicit weight,
+// and for respondents that were never listed on the event
+const defaultParticipantWeight = 1.0
+
+// participantRules indexes an event's participant roles by user ID
+type participantRules struct {
+	roles    map[string]models.EventParticipant
+	invitees []string
+}
+
+func newParticipantRules(event *models.Event) participantRules {
+	rules := participantRules{roles: make(map[string]models.EventParticipant)}
+	for _, p := range event.Participants {
+		if _, ok := rules.roles[p.UserID]; ok {
+			continue
+		}
+		rules.roles[p.UserID] = p
+		rules.invitees = append(rules.invitees, p.UserID)
+	}
+	return rules
+}
+
+// weight returns the weight of an optional participant
+func (r participantRules) weight(userID string) float64 {
+	if p, ok := r.roles[userID]; ok && p.Weight != nil {
+		return *p.Weight
+	}
+	return defaultParticipantWeight
+}
+
+// missingInvitees returns the listed participants that did not submit any availability
+func (r participantRules) missingInvitees(respondents []string) []string {
+	responded := make(map[string]bool, len(respondents))
+	for _, userID := range respondents {
+		responded[userID] = true
+	}
+
+	missing := make([]string, 0)
+	for _, userID := range r.invitees {
+		if !responded[userID] {
+			missing = append(missing, userID)
+		}
+	}
+	return missing
+}
+
+// hasRequiredMissing reports whether any required participant is absent from the attendees
+func (r participantRules) hasRequiredMissing(missingUsers []string) bool {
+	for _, userID := range missingUsers {
+		if r.roles[userID].Required {
+			return true
+		}
+	}
+	return false
+}
+
+// preferenceFactors scale an attendee's contribution by the preference level they gave the slot
+var preferenceFactors = map[models.PreferenceLevel]float64{
+	models.PreferenceIfNeeded:  0.5,
+	models.PreferenceAvailable: 1,
+	models.PreferencePreferred: 1.5,
+}
+
+// score ranks a slot by its attendees: every required attendee counts once and every optional
+// attendee counts by their weight, each scaled by the preference level they gave the slot
+func (r participantRules) score(participants []string, preferences []models.PreferenceLevel) (float64, models.ScoreBreakdown) {
+	var breakdown models.ScoreBreakdown
+	for i, userID := range participants {
+		contribution := 1.0
+		if r.roles[userID].Required {
+			breakdown.RequiredAttendees++
+		} else {
+			contribution = r.weight(userID)
+			breakdown.OptionalAttendees++
+			breakdown.OptionalWeight += contribution
+		}
+
+		switch preferences[i] {
+		case models.PreferencePreferred:
+			breakdown.PreferredAttendees++
+		case models.PreferenceIfNeeded:
+			breakdown.IfNeededAttendees++
+		}
+		breakdown.PreferenceAdjustment += contribution * (preferenceFactors[preferences[i]] - 1)
+	}
+	score := float64(breakdown.RequiredAttendees) + breakdown.OptionalWeight + breakdown.PreferenceAdjustment
+	return score, breakdown
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/netguard"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+)
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrInvalidWebhook is returned when a webhook has an unusable URL or an unknown change type
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// Webhook delivery defaults, used when WebhookOptions leaves them unset
+const (
+	DefaultWebhookMaxAttempts  = 8
+	DefaultWebhookRetryBase    = 30 * time.Second
+	DefaultWebhookPollInterval = 5 * time.Second
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// maxWebhookRetryDelay caps the time between two attempts of a delivery
+const maxWebhookRetryDelay = time.Hour
+
+// webhookBatchSize is the number of due deliveries loaded at a time
+const webhookBatchSize = 50
+
+// webhookDeliveryLogSize is the number of deliveries listed for a webhook
+const webhookDeliveryLogSize = 100
+
+// knownChangeTypes lists the changes webhooks can subscribe to
+var knownChangeTypes = []models.ChangeType{
+	models.ChangeEventCreated,
+	models.ChangeEventUpdated,
+	models.ChangeEventOpened,
+	models.ChangeEventFinalized,
+	models.ChangeEventCancelled,
+	models.ChangeAvailabilitySubmitted,
+	models.ChangeAvailabilityWithdrawn,
+}
+
+// WebhookOptions tunes webhook administration and delivery
+type WebhookOptions struct {
+	Admins      []string      // Users allowed to manage webhooks notified of every event
+	MaxAttempts int           // Attempts before a delivery is given up
+	RetryBase   time.Duration // Delay before the first retry, doubled after each attempt
+	// AllowedNetworks lists the loopback, private or link-local networks webhooks may still post to,
+	// such as a receiver on the same network as the server. Any other such address is refused.
+	AllowedNetworks []netip.Prefix
+}
+
+// WebhookService manages webhook subscriptions and delivers the changes they subscribe to.
+// Changes are queued in the store before they are delivered, so deliveries survive restarts,
+// and failed deliveries are retried with exponential backoff until they are given up.
+type WebhookService struct {
+	webhookRepo repository.WebhookStore
+	client      *http.Client
+	opts        WebhookOptions
+	targets     netguard.Policy
+	wake        chan struct{}
+	now         func() time.Time
+}
+
+// NewWebhookService creates a new instance of WebhookService. Deliveries are posted with client,
+// refusing to connect to addresses outside opts.AllowedNetworks that are not publicly routable.
+func NewWebhookService(webhookRepo repository.WebhookStore, client *http.Client, opts WebhookOptions) *WebhookService {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if opts.RetryBase <= 0 {
+		opts.RetryBase = DefaultWebhookRetryBase
+	}
+	targets := netguard.Policy{Allowed: opts.AllowedNetworks}
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		client:      targets.Guard(client),
+		opts:        opts,
+		targets:     targets,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// IsAdmin reports whether a user may manage webhooks notified of every event
+func (s *WebhookService) IsAdmin(userID string) bool {
+	return userID != "" && slices.Contains(s.opts.Admins, userID)
+}
+
+// Create subscribes a URL to changes and returns the webhook with the secret signing its payloads,
+// which cannot be retrieved later. URLs targeting the server's own networks are refused.
+func (s *WebhookService) Create(createdBy string, req models.CreateWebhookRequest) (*models.Webhook, string, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, "", fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if err := s.targets.CheckURL(target); err != nil {
+		return nil, "", fmt.Errorf("%w: url must not target the server's own networks, %v", ErrInvalidWebhook, err)
+	}
+	for _, changeType := range req.ChangeTypes {
+		if !slices.Contains(knownChangeTypes, changeType) {
+			return nil, "", fmt.Errorf("%w: unknown change type %q", ErrInvalidWebhook, changeType)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("generating webhook secret: %w", err)
+	}
+	webhook := &models.Webhook{
+		ID:          uuid.New().String(),
+		EventID:     req.EventID,
+		URL:         target.String(),
+		ChangeTypes: req.ChangeTypes,
+		Secret:      hex.EncodeToString(secret),
+		CreatedBy:   createdBy,
+		CreatedAt:   s.now(),
+	}
+	if err := s.webhookRepo.CreateWebhook(webhook); err != nil {
+		return nil, "", err
+	}
+	return webhook, webhook.Secret, nil
+}
+
+// Get retrieves a webhook, translating missing records into ErrWebhookNotFound
+func (s *WebhookService) Get(id string) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.GetWebhook(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// List retrieves the webhooks of an event, or the global webhooks when eventID is empty
+func (s *WebhookService) List(eventID string) ([]models.Webhook, error) {
+	webhooks, err := s.webhookRepo.ListWebhooks(eventID)
+	if err != nil {
+		return nil, err
+	}
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+	return webhooks, nil
+}
+
+// Delete unsubscribes a webhook, dropping its pending deliveries
+func (s *WebhookService) Delete(id string) error {
+	err := s.webhookRepo.DeleteWebhook(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// Deliveries lists the latest deliveries of a webhook, newest first
+func (s *WebhookService) Deliveries(id string) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.webhookRepo.ListWebhookDeliveries(id, webhookDeliveryLogSize)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// Notify queues a delivery of the change for every webhook subscribed to it and wakes the worker.
+// Failing to queue a delivery is logged, so it never fails the request that made the change.
+func (s *WebhookService) Notify(change Change) {
+	webhooks, err := s.webhookRepo.ListSubscribedWebhooks(change.Event.ID)
+	if err != nil {
+		log.Printf("Failed to list webhooks of event %s: %v", change.Event.ID, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, webhook := range webhooks {
+		if len(webhook.ChangeTypes) > 0 && !slices.Contains(webhook.ChangeTypes, change.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(models.WebhookPayload{
+				ID:           change.ID,
+				Type:         change.Type,
+				OccurredAt:   change.OccurredAt,
+				Event:        change.Event,
+				Availability: change.Availability,
+			})
+			if err != nil {
+				log.Printf("Failed to encode %s change of event %s: %v", change.Type, change.Event.ID, err)
+				return
+			}
+		}
+
+		now := s.now()
+		delivery := &models.WebhookDelivery{
+			ID:            uuid.New().String(),
+			WebhookID:     webhook.ID,
+			ChangeType:    change.Type,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		if err := s.webhookRepo.CreateWebhookDelivery(delivery); err != nil {
+			log.Printf("Failed to queue %s change for webhook %s: %v", change.Type, webhook.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Due lists the pending deliveries whose next attempt is due
+func (s *WebhookService) Due() ([]models.WebhookDelivery, error) {
+	return s.webhookRepo.ListDueWebhookDeliveries(s.now(), webhookBatchSize)
+}
+
+// Deliver makes one attempt to post a queued payload to its webhook and records the outcome.
+// A failed delivery is retried later, unless it used its last attempt.
+func (s *WebhookService) Deliver(ctx context.Context, delivery *models.WebhookDelivery) error {
+	webhook, err := s.Get(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	status, postErr := s.post(ctx, webhook, delivery)
+	delivery.ResponseStatus = status
+	now := s.now()
+	switch {
+	case postErr == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= s.opts.MaxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+		delivery.LastError = postErr.Error()
+	default:
+		delivery.LastError = postErr.Error()
+		delivery.NextAttemptAt = now.Add(s.retryDelay(delivery.Attempts))
+	}
+	return s.webhookRepo.UpdateWebhookDelivery(delivery)
+}
+
+// post sends a delivery to its webhook, returning the response status when there was a response
+func (s *WebhookService) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, delivery.ID)
+	req.Header.Set(WebhookEventHeader, string(delivery.ChangeType))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay is the time to wait after a failed attempt, doubling with every attempt
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.opts.RetryBase
+	for i := 1; i < attempts && delay < maxWebhookRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxWebhookRetryDelay)
+}
+
+// SignWebhookPayload returns the signature header of a payload: the hex encoded HMAC-SHA256 of the
+// timestamp, a dot and the body, keyed with the webhook's secret
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookWorker delivers queued webhook payloads as soon as they are queued, and retries
+// failed deliveries when they are due
+type WebhookWorker struct {
+	webhooks *WebhookService
+	interval time.Duration
+}
+
+// NewWebhookWorker creates a new instance of WebhookWorker
+func NewWebhookWorker(webhooks *WebhookService, interval time.Duration) *WebhookWorker {
+	if interval <= 0 {
+		interval = DefaultWebhookPollInterval
+	}
+	return &WebhookWorker{webhooks: webhooks, interval: interval}
+}
+
+// Run delivers payloads until the context is cancelled
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.webhooks.wake:
+		}
+	}
+}
+
+// ProcessDue attempts every delivery that is due
+func (w *WebhookWorker) ProcessDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := w.webhooks.Due()
+		if err != nil {
+			log.Printf("Failed to list due webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		for i := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+			delivery := &deliveries[i]
+			if err := w.webhooks.Deliver(ctx, delivery); err != nil {
+				log.Printf("Failed to deliver webhook delivery %s: %v", delivery.ID, err)
+			}
+			if delivery.Status == models.WebhookDeliveryDead {
+				log.Printf("Gave up on webhook delivery %s after %d attempts: %s", delivery.ID, delivery.Attempts, delivery.LastError)
+			}
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
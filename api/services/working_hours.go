@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// DefaultOffHoursPenalty is subtracted from a slot's score for each attendee outside their working hours
+const DefaultOffHoursPenalty = 0.5
+
+const clockLayout = "15:04"
+
+var defaultWorkDays = []int{
+	int(time.Monday),
+	int(time.Tuesday),
+	int(time.Wednesday),
+	int(time.Thursday),
+	int(time.Friday),
+}
+
+// ValidateWorkingHours fills in defaults for a working-hours profile and checks that it is consistent
+func ValidateWorkingHours(profile *models.WorkingHours) error {
+	if _, err := time.LoadLocation(profile.TimeZone); err != nil || profile.TimeZone == "" {
+		return fmt.Errorf("unknown time zone %q", profile.TimeZone)
+	}
+
+	if profile.StartTime == "" {
+		profile.StartTime = "09:00"
+	}
+	if profile.EndTime == "" {
+		profile.EndTime = "17:00"
+	}
+	if len(profile.WorkDays) == 0 {
+		profile.WorkDays = append([]int(nil), defaultWorkDays...)
+	}
+
+	start, err := time.Parse(clockLayout, profile.StartTime)
+	if err != nil {
+		return fmt.Errorf("start_time must be formatted as HH:MM: %q", profile.StartTime)
+	}
+	end, err := time.Parse(clockLayout, profile.EndTime)
+	if err != nil {
+		return fmt.Errorf("end_time must be formatted as HH:MM: %q", profile.EndTime)
+	}
+	if !start.Before(end) {
+		return errors.New("start_time must be before end_time")
+	}
+
+	for _, day := range profile.WorkDays {
+		if day < int(time.Sunday) || day > int(time.Saturday) {
+			return fmt.Errorf("work_days must be between 0 (Sunday) and 6 (Saturday): %d", day)
+		}
+	}
+	return nil
+}
+
+// participantZones resolves the time zone and working hours of every participant.
+// Participants without a registered profile fall back to the time zone of their submitted slots.
+type participantZones struct {
+	profiles map[string]models.WorkingHours
+	zones    map[string]*time.Location
+}
+
+func newParticipantZones(participantAvailabilities []models.ParticipantAvailability, profiles []models.WorkingHours) participantZones {
+	z := participantZones{
+		profiles: make(map[string]models.WorkingHours, len(profiles)),
+		zones:    make(map[string]*time.Location),
+	}
+	for _, profile := range profiles {
+		z.profiles[profile.UserID] = profile
+		z.zones[profile.UserID] = loadLocation(profile.TimeZone)
+	}
+	for _, pa := range participantAvailabilities {
+		if _, ok := z.zones[pa.UserID]; ok || len(pa.TimeSlots) == 0 {
+			continue
+		}
+		z.zones[pa.UserID] = loadLocation(pa.TimeSlots[0].TimeZone)
+	}
+	return z
+}
+
+// localTimes renders the slot in the local time of each of the given users
+func (z participantZones) localTimes(slot models.TimeSlot, users ...[]string) []models.ParticipantLocalTime {
+	total := 0
+	for _, group := range users {
+		total += len(group)
+	}
+	localTimes := make([]models.ParticipantLocalTime, 0, total)
+	for _, group := range users {
+		for _, userID := range group {
+			loc, ok := z.zones[userID]
+			if !ok {
+				continue
+			}
+			local := toLocal(slot, loc)
+			localTime := models.ParticipantLocalTime{
+				UserID:    userID,
+				TimeZone:  local.TimeZone,
+				StartTime: local.StartTime,
+				EndTime:   local.EndTime,
+			}
+			if profile, ok := z.profiles[userID]; ok {
+				within := withinWorkingHours(local, profile)
+				localTime.WithinWorkingHours = &within
+			}
+			localTimes = append(localTimes, localTime)
+		}
+	}
+	return localTimes
+}
+
+// countOffHours counts the attendees whose local time falls outside their working hours
+func countOffHours(localTimes []models.ParticipantLocalTime, attendees []string) int {
+	var attending map[string]bool // Only built once someone is outside their working hours
+	count := 0
+	for _, localTime := range localTimes {
+		if localTime.WithinWorkingHours == nil || *localTime.WithinWorkingHours {
+			continue
+		}
+		if attending == nil {
+			attending = make(map[string]bool, len(attendees))
+			for _, userID := range attendees {
+				attending[userID] = true
+			}
+		}
+		if attending[localTime.UserID] {
+			count++
+		}
+	}
+	return count
+}
+
+// withinWorkingHours reports whether a slot already converted to the participant's
+// local time starts and ends inside a single working day
+func withinWorkingHours(local models.TimeSlot, profile models.WorkingHours) bool {
+	workDay := false
+	for _, day := range profile.WorkDays {
+		if time.Weekday(day) == local.StartTime.Weekday() {
+			workDay = true
+			break
+		}
+	}
+	if !workDay {
+		return false
+	}
+
+	start, err := time.Parse(clockLayout, profile.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(clockLayout, profile.EndTime)
+	if err != nil {
+		return false
+	}
+
+	year, month, day := local.StartTime.Date()
+	loc := local.StartTime.Location()
+	dayStart := time.Date(year, month, day, start.Hour(), start.Minute(), 0, 0, loc)
+	dayEnd := time.Date(year, month, day, end.Hour(), end.Minute(), 0, 0, loc)
+	return !local.StartTime.Before(dayStart) && !local.EndTime.After(dayEnd)
+}
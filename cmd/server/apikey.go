@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/config"
+	"github.com/shani34/meeting-scheduler/internal/database"
+)
+
+const apiKeyUsage = "usage: server apikey create <user_id> [name] | revoke <key_id>"
+
+// runAPIKey implements the apikey subcommand
+func runAPIKey(cfg config.DatabaseConfig, args []string) error {
+	if len(args) < 2 {
+		return errors.New(apiKeyUsage)
+	}
+	if cfg.Backend == "memory" {
+		return errors.New("API keys need a persistent storage backend")
+	}
+
+	store, err := database.NewStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	keys := services.NewAPIKeyService(store.APIKeys)
+
+	switch args[0] {
+	case "create":
+		key, raw, err := keys.Create(args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created API key %s for %s\n", key.ID, key.UserID)
+		fmt.Println("Store this key now, it cannot be shown again:")
+		fmt.Println(raw)
+		return nil
+
+	case "revoke":
+		if err := keys.Revoke(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked API key %s\n", args[1])
+		return nil
+
+	default:
+		return errors.New(apiKeyUsage)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/shani34/meeting-scheduler/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = "usage: server config print"
+
+// runConfig implements the config subcommand
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+
+	// Print the effective configuration in the file format it can be loaded from
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
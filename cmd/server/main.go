@@ -1,57 +1,260 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shani34/meeting-scheduler/api/handlers"
+	"github.com/shani34/meeting-scheduler/api/middleware"
 	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/caldav"
 	"github.com/shani34/meeting-scheduler/internal/config"
 	"github.com/shani34/meeting-scheduler/internal/database"
-	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/shani34/meeting-scheduler/internal/mail"
+	"github.com/shani34/meeting-scheduler/internal/netguard"
 )
 
 func main() {
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize database connection
-	db, err := database.NewConnection(cfg.Database)
+	// Run a maintenance command instead of serving
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := runMigrate(cfg.Database, args[1:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+		case "apikey":
+			if err := runAPIKey(cfg.Database, args[1:]); err != nil {
+				log.Fatal(err)
+			}
+		case "config":
+			if err := runConfig(cfg, args[1:]); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown command %q, expected migrate, apikey or config", args[0])
+		}
+		return
+	}
+
+	// Initialize the storage backend
+	store, err := database.NewStore(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to open storage: %v", err)
 	}
-	defer db.Close()
+	defer store.Close()
 
 	// Initialize repositories
-	eventRepo := repository.NewEventRepository(db)
-	availabilityRepo := repository.NewAvailabilityRepository(db)
+	eventRepo := store.Events
+	availabilityRepo := store.Availabilities
+	workingHoursRepo := store.WorkingHours
+	eventRoleRepo := store.EventRoles
+	apiKeyRepo := store.APIKeys
+	inviteRepo := store.Invites
+	calendarRepo := store.Calendars
+	webhookRepo := store.Webhooks
+	reminderRepo := store.Reminders
 
 	// Initialize services
-	scheduler := services.NewSchedulerService()
+	scheduler := services.NewSchedulerService(
+		services.WithSlotStep(cfg.Scheduler.SlotStep),
+		services.WithOffHoursPenalty(cfg.Scheduler.OffHoursPenalty),
+		services.WithOccurrenceHorizon(cfg.Scheduler.OccurrenceHorizon),
+	)
+	eventService := services.NewEventService(eventRepo, workingHoursRepo, scheduler)
+	availabilityService := services.NewAvailabilityService(availabilityRepo, eventRepo, eventService)
+	eventPolicy := services.NewEventPolicy(eventRepo, eventRoleRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	inviteService := services.NewInviteService(inviteRepo, inviteSecret(cfg.Auth), cfg.Auth.InviteTTL)
+	calendarService := services.NewCalendarService(eventService, cfg.Calendar.EmailDomain)
+	webhookService := services.NewWebhookService(webhookRepo, &http.Client{Timeout: cfg.Webhooks.Timeout}, services.WebhookOptions{
+		Admins:          cfg.Webhooks.Admins,
+		MaxAttempts:     cfg.Webhooks.MaxAttempts,
+		RetryBase:       cfg.Webhooks.RetryBase,
+		AllowedNetworks: cfg.Webhooks.AllowedNetworks,
+	})
+	eventService.Changes().Subscribe(webhookService)
+	streamBroker := services.NewEventStreamBroker(eventService, cfg.Stream.History)
+	eventService.Changes().Subscribe(streamBroker)
+	gridService := services.NewGridService(eventService, availabilityService, cfg.Scheduler.SlotStep)
+	eventService.Changes().Subscribe(gridService)
+
+	// Finalize events whose response deadline passed in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deadlineWorker := services.NewDeadlineWorker(eventService, cfg.Scheduler.Quorum, cfg.Scheduler.DeadlineCheckInterval)
+	go deadlineWorker.Run(ctx)
+
+	// Deliver changes to webhooks in the background, retrying failed deliveries
+	webhookWorker := services.NewWebhookWorker(webhookService, cfg.Webhooks.PollInterval)
+	go webhookWorker.Run(ctx)
+
+	// Pull availability from connected CalDAV calendars, which needs a key to store their passwords
+	var connectionService *services.CalendarConnectionService
+	var syncService *services.CalendarSyncService
+	if cfg.Calendar.CredentialsKey != "" {
+		connectionService, err = services.NewCalendarConnectionService(calendarRepo, cfg.Calendar.CredentialsKey,
+			caldav.NewClient(cfg.Calendar.CalDAVTimeout, netguard.Policy{Allowed: cfg.Calendar.AllowedNetworks}))
+		if err != nil {
+			log.Fatalf("Failed to configure calendar connections: %v", err)
+		}
+		syncService = services.NewCalendarSyncService(calendarService, availabilityService, calendarRepo, connectionService)
+		calendarSyncWorker := services.NewCalendarSyncWorker(syncService, cfg.Calendar.SyncInterval)
+		go calendarSyncWorker.Run(ctx)
+	} else {
+		log.Println("No calendar credentials key configured, CalDAV calendars cannot be connected")
+	}
+
+	// Email participants about their events when a mail server is configured
+	if cfg.Mail.SMTPHost != "" {
+		sender := mail.NewSMTPSender(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			TLS:      cfg.Mail.SMTPTLS,
+			Timeout:  cfg.Mail.Timeout,
+		})
+		notificationService, err := services.NewNotificationService(eventService, calendarService, sender, services.NotificationOptions{
+			From:         cfg.Mail.From,
+			BaseURL:      cfg.Mail.BaseURL,
+			EmailDomain:  cfg.Calendar.EmailDomain,
+			TemplatesDir: cfg.Mail.TemplatesDir,
+		})
+		if err != nil {
+			log.Fatalf("Failed to load email templates: %v", err)
+		}
+		eventService.Changes().Subscribe(notificationService)
+		inviteService.SetNotifier(notificationService)
+		notificationWorker := services.NewNotificationWorker(notificationService)
+		go notificationWorker.Run(ctx)
+
+		reminderService := services.NewReminderService(eventService, inviteService, reminderRepo, notificationService, cfg.Reminders.Offsets)
+		reminderWorker := services.NewReminderWorker(reminderService, cfg.Reminders.CheckInterval)
+		go reminderWorker.Run(ctx)
+	} else {
+		log.Println("No SMTP host configured, participants are not notified by email")
+	}
 
 	// Initialize handlers
-	eventHandler := handlers.NewEventHandler(eventRepo, availabilityRepo, scheduler)
+	eventHandler := handlers.NewEventHandler(eventRepo, eventService, availabilityService, eventPolicy, inviteService, calendarService, syncService)
+	workingHoursHandler := handlers.NewWorkingHoursHandler(workingHoursRepo)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, eventService, eventPolicy)
+	streamHandler := handlers.NewStreamHandler(streamBroker, eventService, eventPolicy, cfg.Stream.HeartbeatInterval)
+	gridHandler := handlers.NewGridHandler(gridService, eventService, eventPolicy)
 
 	// Initialize router
 	router := gin.Default()
 
+	// Authenticate every route. Guests holding an invite are authenticated even when auth is disabled.
+	inviteAuthenticator := middleware.NewInviteAuthenticator(inviteService)
+	if cfg.Auth.Enabled {
+		authenticators, err := newAuthenticators(cfg.Auth, apiKeyService)
+		if err != nil {
+			log.Fatalf("Failed to configure authentication: %v", err)
+		}
+		router.Use(middleware.Authenticate(append(authenticators, inviteAuthenticator)...))
+	} else {
+		log.Println("Authentication is disabled, only guests holding an invite can take part in events")
+		router.Use(middleware.AuthenticateOptional(inviteAuthenticator))
+	}
+
 	// Event routes
 	router.POST("/events", eventHandler.CreateEvent)
 	router.GET("/events", eventHandler.GetEvent)
 	router.PUT("/events", eventHandler.UpdateEvent)
 	router.DELETE("/events", eventHandler.DeleteEvent)
+	router.POST("/events/:id/open", eventHandler.OpenEvent)
+	router.POST("/events/:id/finalize", eventHandler.FinalizeEvent)
+	router.POST("/events/:id/cancel", eventHandler.CancelEvent)
+	router.GET("/events/:id/ics", eventHandler.ExportCalendar)
+	router.GET("/events/:id/roles", eventHandler.ListEventRoles)
+	router.PUT("/events/:id/roles/:user_id", eventHandler.AssignEventRole)
+	router.DELETE("/events/:id/roles/:user_id", eventHandler.UnassignEventRole)
+	router.POST("/events/:id/invites", eventHandler.CreateInvite)
+	router.GET("/events/:id/invites", eventHandler.ListInvites)
+	router.DELETE("/events/:id/invites/:invite_id", eventHandler.RevokeInvite)
+	router.GET("/events/:id/stream", streamHandler.StreamEvent)
+	router.GET("/events/:id/grid", gridHandler.JoinGrid)
 
 	// Availability routes
-	router.POST("/availabilities", eventHandler.SubmitAvailability)
+	router.POST("/availabilities", eventHandler.CreateAvailability)
+	router.GET("/availabilities/:id", eventHandler.GetAvailability)
+	router.PUT("/availabilities/:id", eventHandler.UpdateAvailability)
+	router.DELETE("/availabilities/:id", eventHandler.DeleteAvailability)
+	router.GET("/events/:id/availabilities", eventHandler.ListEventAvailabilities)
+	router.POST("/events/:id/availabilities/import", eventHandler.ImportAvailability)
 	router.GET("/events/optimal-slots", eventHandler.GetOptimalTimeSlots)
 
+	// Working hours routes
+	router.GET("/working-hours/:user_id", workingHoursHandler.GetWorkingHours)
+	router.PUT("/working-hours/:user_id", workingHoursHandler.UpdateWorkingHours)
+
+	// Webhook routes
+	router.POST("/webhooks", webhookHandler.CreateWebhook)
+	router.GET("/webhooks", webhookHandler.ListWebhooks)
+	router.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", webhookHandler.ListWebhookDeliveries)
+
+	// Calendar connection routes
+	if connectionService != nil {
+		calendarConnectionHandler := handlers.NewCalendarConnectionHandler(connectionService)
+		router.GET("/calendar-connections/:user_id", calendarConnectionHandler.GetCalendarConnection)
+		router.PUT("/calendar-connections/:user_id", calendarConnectionHandler.UpdateCalendarConnection)
+		router.DELETE("/calendar-connections/:user_id", calendarConnectionHandler.DeleteCalendarConnection)
+		router.POST("/events/:id/availabilities/sync", eventHandler.SyncAvailability)
+	}
+
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Server.Port)
-	if err := http.ListenAndServe(":"+cfg.Server.Port, router); err != nil {
+	log.Printf("Server starting on %s", cfg.Server.Address())
+	if err := http.ListenAndServe(cfg.Server.Address(), router); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
-} 
\ No newline at end of file
+}
+
+// newAuthenticators builds the authenticators enabled by the configuration, JWTs first
+func newAuthenticators(cfg config.AuthConfig, apiKeys *services.APIKeyService) ([]middleware.Authenticator, error) {
+	var authenticators []middleware.Authenticator
+	if cfg.JWTSecret != "" || cfg.JWTPublicKeyFile != "" {
+		opts := middleware.JWTOptions{
+			HMACSecret: []byte(cfg.JWTSecret),
+			Issuer:     cfg.JWTIssuer,
+			Audience:   cfg.JWTAudience,
+		}
+		if cfg.JWTPublicKeyFile != "" {
+			key, err := middleware.LoadRSAPublicKey(cfg.JWTPublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			opts.RSAPublicKey = key
+		}
+		jwtAuthenticator, err := middleware.NewJWTAuthenticator(opts)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+	return append(authenticators, middleware.NewAPIKeyAuthenticator(apiKeys)), nil
+}
+
+// inviteSecret returns the key signing invite tokens, generating one when none is configured
+func inviteSecret(cfg config.AuthConfig) []byte {
+	if cfg.InviteSecret != "" {
+		return []byte(cfg.InviteSecret)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate invite secret: %v", err)
+	}
+	log.Println("No invite secret configured, invite links stop working when the server restarts")
+	return secret
+}
@@ -0,0 +1,174 @@
+// Package caldav queries CalDAV servers, as specified by RFC 4791, for the busy time of a calendar.
+//
+// A free-busy-query report is tried first. Servers that do not support it are asked for the
+// events in the time range instead, with a calendar-query report expanding recurring events.
+// Either way the calendar data is returned as decoded iCalendar components.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/internal/ical"
+	"github.com/shani34/meeting-scheduler/internal/netguard"
+)
+
+// maxResponseSize bounds the responses read from calendar servers
+const maxResponseSize = 4 << 20
+
+// ErrUnauthorized is returned when the server rejects the credentials
+var ErrUnauthorized = errors.New("calendar server rejected the credentials")
+
+// Account identifies a calendar collection and the credentials to read it with
+type Account struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Client queries CalDAV servers
+type Client struct {
+	http    *http.Client
+	targets netguard.Policy
+}
+
+// NewClient creates a client whose requests give up after timeout. Calendars on the server's own
+// networks are refused, except in the networks targets allows.
+func NewClient(timeout time.Duration, targets netguard.Policy) *Client {
+	return &Client{http: targets.Guard(&http.Client{Timeout: timeout}), targets: targets}
+}
+
+// CheckURL refuses calendar URLs the client would not connect to
+func (c *Client) CheckURL(calendarURL *url.URL) error {
+	return c.targets.CheckURL(calendarURL)
+}
+
+// Busy returns calendar components describing when the account is busy between start and end:
+// VFREEBUSY components from servers supporting free/busy queries, otherwise the events in the range
+func (c *Client) Busy(ctx context.Context, account Account, start, end time.Time) ([]*ical.Component, error) {
+	components, err := c.freeBusy(ctx, account, start, end)
+	if !errors.Is(err, errUnsupported) {
+		return components, err
+	}
+	return c.events(ctx, account, start, end)
+}
+
+// errUnsupported is returned when the server does not answer a free/busy query. Servers refuse
+// it with various statuses, so any of those leads to querying events instead.
+var errUnsupported = errors.New("report not supported")
+
+// freeBusy runs a free-busy-query report
+func (c *Client) freeBusy(ctx context.Context, account Account, start, end time.Time) ([]*ical.Component, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<C:free-busy-query xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		timeRange(start, end) +
+		`</C:free-busy-query>`
+	response, err := c.report(ctx, account, body)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusUnsupportedMediaType, http.StatusNotImplemented:
+		return nil, errUnsupported
+	default:
+		return nil, statusError(response)
+	}
+	components, err := ical.Decode(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading free/busy response: %w", err)
+	}
+	return components, nil
+}
+
+// events runs a calendar-query report for the events overlapping the range
+func (c *Client) events(ctx context.Context, account Account, start, end time.Time) ([]*ical.Component, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		`<D:prop><C:calendar-data>` +
+		`<C:expand start="` + ical.DateTime(start) + `" end="` + ical.DateTime(end) + `"/>` +
+		`</C:calendar-data></D:prop>` +
+		`<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">` +
+		timeRange(start, end) +
+		`</C:comp-filter></C:comp-filter></C:filter>` +
+		`</C:calendar-query>`
+	response, err := c.report(ctx, account, body)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(response)
+	}
+	var status multistatus
+	if err := xml.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("reading calendar query response: %w", err)
+	}
+
+	var components []*ical.Component
+	for _, r := range status.Responses {
+		for _, propstat := range r.Propstats {
+			if propstat.Prop.CalendarData == "" || !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			decoded, err := ical.Decode(strings.NewReader(propstat.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", r.Href, err)
+			}
+			components = append(components, decoded...)
+		}
+	}
+	return components, nil
+}
+
+// report sends a REPORT request to the calendar collection
+func (c *Client) report(ctx context.Context, account Account, body string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "REPORT", account.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	request.Header.Set("Depth", "1")
+	if account.Username != "" || account.Password != "" {
+		request.SetBasicAuth(account.Username, account.Password)
+	}
+	return c.http.Do(request)
+}
+
+// statusError describes an unexpected response
+func statusError(response *http.Response) error {
+	if response.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	return fmt.Errorf("calendar server answered %s", response.Status)
+}
+
+// timeRange writes a time-range element, whose bounds are written in UTC
+func timeRange(start, end time.Time) string {
+	return `<C:time-range start="` + ical.DateTime(start) + `" end="` + ical.DateTime(end) + `"/>`
+}
+
+// multistatus is the body of a calendar-query response
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Prop struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
@@ -1,49 +1,396 @@
+// Package config loads the server configuration.
+//
+// Settings are layered: built-in defaults, then an optional YAML or TOML file, then environment
+// variables, then command line flags. Every setting has a dotted key (e.g. "database.host") that is
+// used both in configuration files and as the name of its flag.
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/mail"
+	"net/netip"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Calendar  CalendarConfig  `yaml:"calendar"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	Mail      MailConfig      `yaml:"mail"`
+	Reminders RemindersConfig `yaml:"reminders"`
+	Stream    StreamConfig    `yaml:"stream"`
 }
 
-// NewConfig creates a new Config instance with values from environment variables
-func NewConfig() *Config {
-	return &Config{
-		DBHost:     getEnvOrDefault("DB_HOST", "localhost"),
-		DBPort:     getEnvOrDefault("DB_PORT", "5432"),
-		DBUser:     getEnvOrDefault("DB_USER", "postgres"),
-		DBPassword: getEnvOrDefault("DB_PASSWORD", "postgres"),
-		DBName:     getEnvOrDefault("DB_NAME", "meeting_scheduler"),
-		DBSSLMode:  getEnvOrDefault("DB_SSL_MODE", "disable"),
-	}
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+}
+
+// Address returns the address the HTTP server listens on
+func (s ServerConfig) Address() string {
+	return s.Host + ":" + s.Port
+}
+
+// DatabaseConfig configures the storage backend
+type DatabaseConfig struct {
+	// Backend selects where records are kept: postgres, sqlite or memory
+	Backend  string `yaml:"backend"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// SQLitePath is the database file of the sqlite storage backend
+	SQLitePath string `yaml:"sqlite_path"`
+	// AutoMigrate applies pending PostgreSQL migrations at startup. SQLite is always migrated.
+	AutoMigrate bool `yaml:"auto_migrate"`
 }
 
-// GetDBConnectionString returns the PostgreSQL connection string
-func (c *Config) GetDBConnectionString() string {
+// ConnectionString returns the PostgreSQL connection string
+func (d DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		c.DBHost,
-		c.DBPort,
-		c.DBUser,
-		c.DBPassword,
-		c.DBName,
-		c.DBSSLMode,
+		d.Host,
+		d.Port,
+		d.User,
+		d.Password,
+		d.Name,
+		d.SSLMode,
 	)
 }
 
-// getEnvOrDefault returns the value of an environment variable or a default value if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// SchedulerConfig tunes slot recommendations and deadline handling
+type SchedulerConfig struct {
+	SlotStep              time.Duration `yaml:"slot_step"`
+	OffHoursPenalty       float64       `yaml:"off_hours_penalty"`
+	OccurrenceHorizon     int           `yaml:"occurrence_horizon"`
+	Quorum                float64       `yaml:"quorum"`
+	DeadlineCheckInterval time.Duration `yaml:"deadline_check_interval"`
+}
+
+// AuthConfig configures how API callers are authenticated. API keys are stored hashed in the database.
+type AuthConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	JWTSecret        string `yaml:"jwt_secret"`          // Verifies HS256 tokens
+	JWTPublicKeyFile string `yaml:"jwt_public_key_file"` // PEM encoded RSA key verifying RS256 tokens
+	JWTIssuer        string `yaml:"jwt_issuer"`
+	JWTAudience      string `yaml:"jwt_audience"`
+	// InviteSecret signs guest invite tokens. A random secret is used when empty, so invites
+	// stop working when the server restarts.
+	InviteSecret string        `yaml:"invite_secret"`
+	InviteTTL    time.Duration `yaml:"invite_ttl"` // Default lifetime of invites
+}
+
+// CalendarConfig configures calendar exports and the CalDAV calendars availability is pulled from
+type CalendarConfig struct {
+	// EmailDomain completes the calendar addresses of users whose ID is not an email address
+	EmailDomain string `yaml:"email_domain"`
+	// CredentialsKey encrypts the passwords of connected CalDAV calendars. Calendars cannot be
+	// connected when it is empty, and changing it makes stored passwords unreadable.
+	CredentialsKey string        `yaml:"credentials_key"`
+	SyncInterval   time.Duration `yaml:"sync_interval"`  // Time between two refreshes of pulled availability
+	CalDAVTimeout  time.Duration `yaml:"caldav_timeout"` // Time allowed for a CalDAV server to answer
+	// AllowedNetworks lists the loopback, private or link-local networks CalDAV calendars may be
+	// read from, all of which are refused otherwise
+	AllowedNetworks []netip.Prefix `yaml:"allowed_networks"`
+}
+
+// WebhooksConfig configures the delivery of webhooks
+type WebhooksConfig struct {
+	// Admins lists the users allowed to manage webhooks notified of changes to every event
+	Admins       []string      `yaml:"admins"`
+	PollInterval time.Duration `yaml:"poll_interval"` // Time between two scans for deliveries to retry
+	Timeout      time.Duration `yaml:"timeout"`       // Time allowed for a receiver to answer
+	MaxAttempts  int           `yaml:"max_attempts"`  // Attempts before a delivery is given up
+	RetryBase    time.Duration `yaml:"retry_base"`    // Delay before the first retry, doubled after each attempt
+	// AllowedNetworks lists the loopback, private or link-local networks webhooks may post to, all of
+	// which are refused otherwise
+	AllowedNetworks []netip.Prefix `yaml:"allowed_networks"`
+}
+
+// MailConfig configures the SMTP server notifications are emailed through
+type MailConfig struct {
+	// SMTPHost is the mail server. Notifications are not sent when it is empty.
+	SMTPHost     string        `yaml:"smtp_host"`
+	SMTPPort     string        `yaml:"smtp_port"`
+	SMTPUsername string        `yaml:"smtp_username"` // No authentication when empty
+	SMTPPassword string        `yaml:"smtp_password"`
+	SMTPTLS      string        `yaml:"smtp_tls"` // starttls, tls or none
+	Timeout      time.Duration `yaml:"timeout"`  // Time allowed to send one message
+	From         string        `yaml:"from"`     // Sender of every message
+	// BaseURL is the address of the web application, linked from messages when set
+	BaseURL string `yaml:"base_url"`
+	// TemplatesDir holds templates replacing the built-in ones, named like them
+	TemplatesDir string `yaml:"templates_dir"`
+}
+
+// RemindersConfig configures the emails reminding participants who have not responded to an event
+type RemindersConfig struct {
+	// Offsets are how long before the response deadline reminders are sent
+	Offsets       []time.Duration `yaml:"offsets"`
+	CheckInterval time.Duration   `yaml:"check_interval"` // Time between two scans for reminders to send
+}
+
+// StreamConfig configures the live updates of events streamed to clients
+type StreamConfig struct {
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"` // Time between two heartbeats on an idle stream
+	History           int           `yaml:"history"`            // Messages kept per event for clients resuming a stream
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port: "8080",
+		},
+		Database: DatabaseConfig{
+			Backend:     "postgres",
+			Host:        "localhost",
+			Port:        "5432",
+			User:        "postgres",
+			Password:    "postgres",
+			Name:        "meeting_scheduler",
+			SSLMode:     "disable",
+			SQLitePath:  "meeting_scheduler.db",
+			AutoMigrate: true,
+		},
+		Scheduler: SchedulerConfig{
+			SlotStep:              30 * time.Minute,
+			OffHoursPenalty:       0.5,
+			OccurrenceHorizon:     4,
+			Quorum:                0.5,
+			DeadlineCheckInterval: time.Minute,
+		},
+		Auth: AuthConfig{
+			Enabled:   true,
+			InviteTTL: 7 * 24 * time.Hour,
+		},
+		Calendar: CalendarConfig{
+			EmailDomain:   "meeting-scheduler.invalid",
+			SyncInterval:  15 * time.Minute,
+			CalDAVTimeout: 30 * time.Second,
+		},
+		Webhooks: WebhooksConfig{
+			PollInterval: 5 * time.Second,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			RetryBase:    30 * time.Second,
+		},
+		Mail: MailConfig{
+			SMTPPort: "587",
+			SMTPTLS:  "starttls",
+			Timeout:  30 * time.Second,
+		},
+		Reminders: RemindersConfig{
+			Offsets:       []time.Duration{48 * time.Hour, 4 * time.Hour},
+			CheckInterval: time.Minute,
+		},
+		Stream: StreamConfig{
+			HeartbeatInterval: 15 * time.Second,
+			History:           100,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the configuration file, the environment
+// and the flags in args, in increasing order of precedence, and validates it.
+// The file is named by the -config flag or the CONFIG_FILE variable.
+// It returns the arguments remaining after the flags, which name the command to run.
+func Load(args []string) (*Config, []string, error) {
+	// Flags are parsed into a scratch config first so they can be applied last
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path of a YAML or TOML configuration file")
+	scratch := Default()
+	for _, s := range settings {
+		flags.Var(s.bind(scratch), s.key, s.usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := s.bind(cfg).Set(value); err != nil {
+				return nil, nil, fmt.Errorf("environment variable %s: invalid value %q: %v", s.env, value, err)
+			}
+		}
+	}
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		if s, ok := lookupSetting(f.Name); ok && flagErr == nil {
+			if err := s.bind(cfg).Set(f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("flag -%s: %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+	invalid := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		invalid("server.port", "must be a number between 1 and 65535, got %q", c.Server.Port)
+	}
+
+	switch c.Database.Backend {
+	case "postgres":
+		if c.Database.Host == "" {
+			invalid("database.host", "is required by the postgres backend")
+		}
+		if c.Database.Name == "" {
+			invalid("database.name", "is required by the postgres backend")
+		}
+		if port, err := strconv.Atoi(c.Database.Port); err != nil || port < 1 || port > 65535 {
+			invalid("database.port", "must be a number between 1 and 65535, got %q", c.Database.Port)
+		}
+	case "sqlite":
+		if c.Database.SQLitePath == "" {
+			invalid("database.sqlite_path", "is required by the sqlite backend")
+		}
+	case "memory":
+	default:
+		invalid("database.backend", "must be one of postgres, sqlite or memory, got %q", c.Database.Backend)
 	}
-	return defaultValue
-} 
\ No newline at end of file
+
+	if c.Scheduler.SlotStep <= 0 {
+		invalid("scheduler.slot_step", "must be positive, got %s", c.Scheduler.SlotStep)
+	}
+	if c.Scheduler.OffHoursPenalty < 0 {
+		invalid("scheduler.off_hours_penalty", "must not be negative, got %g", c.Scheduler.OffHoursPenalty)
+	}
+	if c.Scheduler.OccurrenceHorizon < 1 {
+		invalid("scheduler.occurrence_horizon", "must be at least 1, got %d", c.Scheduler.OccurrenceHorizon)
+	}
+	if c.Scheduler.Quorum < 0 || c.Scheduler.Quorum > 1 {
+		invalid("scheduler.quorum", "must be between 0 and 1, got %g", c.Scheduler.Quorum)
+	}
+	if c.Scheduler.DeadlineCheckInterval <= 0 {
+		invalid("scheduler.deadline_check_interval", "must be positive, got %s", c.Scheduler.DeadlineCheckInterval)
+	}
+
+	if c.Auth.Enabled && c.Database.Backend == "memory" && c.Auth.JWTSecret == "" && c.Auth.JWTPublicKeyFile == "" {
+		invalid("auth", "the memory backend keeps no API keys, set jwt_secret or jwt_public_key_file")
+	}
+	if c.Auth.InviteSecret != "" && c.Auth.InviteSecret == c.Auth.JWTSecret {
+		invalid("auth.invite_secret", "must differ from jwt_secret, or invite tokens would authenticate as users")
+	}
+	if c.Auth.InviteTTL <= 0 {
+		invalid("auth.invite_ttl", "must be positive, got %s", c.Auth.InviteTTL)
+	}
+
+	if c.Calendar.EmailDomain == "" || strings.ContainsAny(c.Calendar.EmailDomain, "@ ") {
+		invalid("calendar.email_domain", "must be a domain name, got %q", c.Calendar.EmailDomain)
+	}
+	if c.Calendar.SyncInterval <= 0 {
+		invalid("calendar.sync_interval", "must be positive, got %s", c.Calendar.SyncInterval)
+	}
+	if c.Calendar.CalDAVTimeout <= 0 {
+		invalid("calendar.caldav_timeout", "must be positive, got %s", c.Calendar.CalDAVTimeout)
+	}
+
+	if c.Webhooks.PollInterval <= 0 {
+		invalid("webhooks.poll_interval", "must be positive, got %s", c.Webhooks.PollInterval)
+	}
+	if c.Webhooks.Timeout <= 0 {
+		invalid("webhooks.timeout", "must be positive, got %s", c.Webhooks.Timeout)
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		invalid("webhooks.max_attempts", "must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	}
+	if c.Webhooks.RetryBase <= 0 {
+		invalid("webhooks.retry_base", "must be positive, got %s", c.Webhooks.RetryBase)
+	}
+
+	if c.Mail.SMTPHost != "" {
+		if port, err := strconv.Atoi(c.Mail.SMTPPort); err != nil || port < 1 || port > 65535 {
+			invalid("mail.smtp_port", "must be a number between 1 and 65535, got %q", c.Mail.SMTPPort)
+		}
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			invalid("mail.from", "must be an email address when smtp_host is set, got %q", c.Mail.From)
+		}
+	}
+	switch c.Mail.SMTPTLS {
+	case "starttls", "tls", "none":
+	default:
+		invalid("mail.smtp_tls", "must be one of starttls, tls or none, got %q", c.Mail.SMTPTLS)
+	}
+	if c.Mail.Timeout <= 0 {
+		invalid("mail.timeout", "must be positive, got %s", c.Mail.Timeout)
+	}
+	if c.Mail.BaseURL != "" {
+		if u, err := url.Parse(c.Mail.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("mail.base_url", "must be an absolute URL, got %q", c.Mail.BaseURL)
+		}
+	}
+
+	for _, offset := range c.Reminders.Offsets {
+		if offset <= 0 {
+			invalid("reminders.offsets", "must be positive, got %s", offset)
+		}
+	}
+	if c.Reminders.CheckInterval <= 0 {
+		invalid("reminders.check_interval", "must be positive, got %s", c.Reminders.CheckInterval)
+	}
+
+	if c.Stream.HeartbeatInterval <= 0 {
+		invalid("stream.heartbeat_interval", "must be positive, got %s", c.Stream.HeartbeatInterval)
+	}
+	if c.Stream.History < 1 {
+		invalid("stream.history", "must be at least 1, got %d", c.Stream.History)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// redactedValue replaces secrets in printed configuration
+const redactedValue = "REDACTED"
+
+// Redacted returns a copy of the configuration with secrets replaced, safe to print or log
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, s := range settings {
+		if !s.secret {
+			continue
+		}
+		value := s.bind(&redacted)
+		if value.String() != "" {
+			_ = value.Set(redactedValue)
+		}
+	}
+	return &redacted
+}
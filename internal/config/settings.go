@@ -0,0 +1,328 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting describes one configuration value and where it can be set
+type setting struct {
+	key    string // Dotted key used in configuration files and as the flag name
+	env    string
+	usage  string
+	secret bool // Redacted when the configuration is printed
+	bind   func(c *Config) value
+}
+
+// value reads and writes a single field of a Config from its textual form
+type value interface {
+	String() string
+	Set(string) error
+}
+
+var settings = []setting{
+	{key: "server.host", env: "SERVER_HOST", usage: "interface the HTTP server listens on, empty for all",
+		bind: func(c *Config) value { return (*stringValue)(&c.Server.Host) }},
+	{key: "server.port", env: "SERVER_PORT", usage: "port the HTTP server listens on",
+		bind: func(c *Config) value { return (*stringValue)(&c.Server.Port) }},
+
+	{key: "database.backend", env: "STORAGE_BACKEND", usage: "storage backend: postgres, sqlite or memory",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.Backend) }},
+	{key: "database.host", env: "DB_HOST", usage: "PostgreSQL host",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.Host) }},
+	{key: "database.port", env: "DB_PORT", usage: "PostgreSQL port",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.Port) }},
+	{key: "database.user", env: "DB_USER", usage: "PostgreSQL user",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.User) }},
+	{key: "database.password", env: "DB_PASSWORD", usage: "PostgreSQL password", secret: true,
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.Password) }},
+	{key: "database.name", env: "DB_NAME", usage: "PostgreSQL database name",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.Name) }},
+	{key: "database.ssl_mode", env: "DB_SSL_MODE", usage: "PostgreSQL sslmode",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.SSLMode) }},
+	{key: "database.sqlite_path", env: "SQLITE_PATH", usage: "database file of the sqlite backend",
+		bind: func(c *Config) value { return (*stringValue)(&c.Database.SQLitePath) }},
+	{key: "database.auto_migrate", env: "DB_AUTO_MIGRATE", usage: "apply pending PostgreSQL migrations at startup",
+		bind: func(c *Config) value { return (*boolValue)(&c.Database.AutoMigrate) }},
+
+	{key: "scheduler.slot_step", env: "SCHEDULER_SLOT_STEP", usage: "distance between candidate start times",
+		bind: func(c *Config) value { return (*durationValue)(&c.Scheduler.SlotStep) }},
+	{key: "scheduler.off_hours_penalty", env: "SCHEDULER_OFF_HOURS_PENALTY", usage: "score subtracted per attendee outside working hours",
+		bind: func(c *Config) value { return (*floatValue)(&c.Scheduler.OffHoursPenalty) }},
+	{key: "scheduler.occurrence_horizon", env: "SCHEDULER_OCCURRENCE_HORIZON", usage: "upcoming occurrences a recurring slot must work for",
+		bind: func(c *Config) value { return (*intValue)(&c.Scheduler.OccurrenceHorizon) }},
+	{key: "scheduler.quorum", env: "SCHEDULER_QUORUM", usage: "fraction of participants needed to finalize at the deadline",
+		bind: func(c *Config) value { return (*floatValue)(&c.Scheduler.Quorum) }},
+	{key: "scheduler.deadline_check_interval", env: "SCHEDULER_DEADLINE_CHECK_INTERVAL", usage: "time between two scans for passed deadlines",
+		bind: func(c *Config) value { return (*durationValue)(&c.Scheduler.DeadlineCheckInterval) }},
+
+	{key: "auth.enabled", env: "AUTH_ENABLED", usage: "require callers to authenticate",
+		bind: func(c *Config) value { return (*boolValue)(&c.Auth.Enabled) }},
+	{key: "auth.jwt_secret", env: "AUTH_JWT_SECRET", usage: "secret verifying HS256 tokens", secret: true,
+		bind: func(c *Config) value { return (*stringValue)(&c.Auth.JWTSecret) }},
+	{key: "auth.jwt_public_key_file", env: "AUTH_JWT_PUBLIC_KEY_FILE", usage: "PEM encoded RSA public key verifying RS256 tokens",
+		bind: func(c *Config) value { return (*stringValue)(&c.Auth.JWTPublicKeyFile) }},
+	{key: "auth.jwt_issuer", env: "AUTH_JWT_ISSUER", usage: "required issuer of tokens, if set",
+		bind: func(c *Config) value { return (*stringValue)(&c.Auth.JWTIssuer) }},
+	{key: "auth.jwt_audience", env: "AUTH_JWT_AUDIENCE", usage: "required audience of tokens, if set",
+		bind: func(c *Config) value { return (*stringValue)(&c.Auth.JWTAudience) }},
+	{key: "auth.invite_secret", env: "AUTH_INVITE_SECRET", usage: "secret signing guest invite tokens", secret: true,
+		bind: func(c *Config) value { return (*stringValue)(&c.Auth.InviteSecret) }},
+	{key: "auth.invite_ttl", env: "AUTH_INVITE_TTL", usage: "default lifetime of guest invites",
+		bind: func(c *Config) value { return (*durationValue)(&c.Auth.InviteTTL) }},
+
+	{key: "calendar.email_domain", env: "CALENDAR_EMAIL_DOMAIN", usage: "mail domain of users whose ID is not an email address, in exported calendars",
+		bind: func(c *Config) value { return (*stringValue)(&c.Calendar.EmailDomain) }},
+	{key: "calendar.credentials_key", env: "CALENDAR_CREDENTIALS_KEY", usage: "key encrypting stored CalDAV passwords, CalDAV connections are disabled when empty", secret: true,
+		bind: func(c *Config) value { return (*stringValue)(&c.Calendar.CredentialsKey) }},
+	{key: "calendar.sync_interval", env: "CALENDAR_SYNC_INTERVAL", usage: "time between two refreshes of availability pulled from CalDAV calendars",
+		bind: func(c *Config) value { return (*durationValue)(&c.Calendar.SyncInterval) }},
+	{key: "calendar.caldav_timeout", env: "CALENDAR_CALDAV_TIMEOUT", usage: "time allowed for a CalDAV server to answer",
+		bind: func(c *Config) value { return (*durationValue)(&c.Calendar.CalDAVTimeout) }},
+	{key: "calendar.allowed_networks", env: "CALENDAR_ALLOWED_NETWORKS", usage: "comma-separated private networks CalDAV calendars may be read from, such as 10.0.0.0/8",
+		bind: func(c *Config) value { return (*prefixListValue)(&c.Calendar.AllowedNetworks) }},
+
+	{key: "webhooks.admins", env: "WEBHOOK_ADMINS", usage: "comma-separated users allowed to manage webhooks of every event",
+		bind: func(c *Config) value { return (*stringListValue)(&c.Webhooks.Admins) }},
+	{key: "webhooks.poll_interval", env: "WEBHOOK_POLL_INTERVAL", usage: "time between two scans for webhook deliveries to retry",
+		bind: func(c *Config) value { return (*durationValue)(&c.Webhooks.PollInterval) }},
+	{key: "webhooks.timeout", env: "WEBHOOK_TIMEOUT", usage: "time allowed for a webhook receiver to answer",
+		bind: func(c *Config) value { return (*durationValue)(&c.Webhooks.Timeout) }},
+	{key: "webhooks.max_attempts", env: "WEBHOOK_MAX_ATTEMPTS", usage: "attempts before a webhook delivery is given up",
+		bind: func(c *Config) value { return (*intValue)(&c.Webhooks.MaxAttempts) }},
+	{key: "webhooks.retry_base", env: "WEBHOOK_RETRY_BASE", usage: "delay before retrying a failed webhook delivery, doubled after each attempt",
+		bind: func(c *Config) value { return (*durationValue)(&c.Webhooks.RetryBase) }},
+	{key: "webhooks.allowed_networks", env: "WEBHOOK_ALLOWED_NETWORKS", usage: "comma-separated private networks webhooks may post to, such as 10.0.0.0/8",
+		bind: func(c *Config) value { return (*prefixListValue)(&c.Webhooks.AllowedNetworks) }},
+
+	{key: "mail.smtp_host", env: "MAIL_SMTP_HOST", usage: "SMTP server notifications are sent through, notifications are disabled when empty",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.SMTPHost) }},
+	{key: "mail.smtp_port", env: "MAIL_SMTP_PORT", usage: "SMTP server port",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.SMTPPort) }},
+	{key: "mail.smtp_username", env: "MAIL_SMTP_USERNAME", usage: "SMTP user, no authentication when empty",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.SMTPUsername) }},
+	{key: "mail.smtp_password", env: "MAIL_SMTP_PASSWORD", usage: "SMTP password", secret: true,
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.SMTPPassword) }},
+	{key: "mail.smtp_tls", env: "MAIL_SMTP_TLS", usage: "SMTP encryption: starttls, tls or none",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.SMTPTLS) }},
+	{key: "mail.timeout", env: "MAIL_TIMEOUT", usage: "time allowed to send one message",
+		bind: func(c *Config) value { return (*durationValue)(&c.Mail.Timeout) }},
+	{key: "mail.from", env: "MAIL_FROM", usage: "sender of notification emails",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.From) }},
+	{key: "mail.base_url", env: "MAIL_BASE_URL", usage: "address of the web application linked from notification emails",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.BaseURL) }},
+	{key: "mail.templates_dir", env: "MAIL_TEMPLATES_DIR", usage: "directory of email templates replacing the built-in ones",
+		bind: func(c *Config) value { return (*stringValue)(&c.Mail.TemplatesDir) }},
+
+	{key: "reminders.offsets", env: "REMINDER_OFFSETS", usage: "comma-separated durations before the deadline to remind participants who have not responded",
+		bind: func(c *Config) value { return (*durationListValue)(&c.Reminders.Offsets) }},
+	{key: "reminders.check_interval", env: "REMINDER_CHECK_INTERVAL", usage: "time between two scans for reminders to send",
+		bind: func(c *Config) value { return (*durationValue)(&c.Reminders.CheckInterval) }},
+
+	{key: "stream.heartbeat_interval", env: "STREAM_HEARTBEAT_INTERVAL", usage: "time between two heartbeats on an idle event stream",
+		bind: func(c *Config) value { return (*durationValue)(&c.Stream.HeartbeatInterval) }},
+	{key: "stream.history", env: "STREAM_HISTORY", usage: "messages kept per event for clients resuming an event stream",
+		bind: func(c *Config) value { return (*intValue)(&c.Stream.History) }},
+}
+
+// lookupSetting finds the setting with the given dotted key
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// loadFile applies the settings of a YAML or TOML file, chosen by its extension
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	document := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", document, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := lookupSetting(key)
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		if err := s.bind(c).Set(values[key]); err != nil {
+			return fmt.Errorf("config file %s: %s: invalid value %q: %v", path, key, values[key], err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested sections into dotted keys holding the textual form of their values
+func flatten(prefix string, section map[string]any, values map[string]string) {
+	for name, raw := range section {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := raw.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			// An empty section or value leaves the setting unchanged
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+
+type boolValue bool
+
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("expected true or false")
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("expected a whole number")
+	}
+	*v = intValue(i)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("expected a number")
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("expected a duration such as 30s or 15m")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type stringListValue []string
+
+func (v *stringListValue) String() string { return strings.Join(*v, ",") }
+func (v *stringListValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+
+type durationListValue []time.Duration
+
+func (v *durationListValue) String() string {
+	items := make([]string, len(*v))
+	for i, d := range *v {
+		items[i] = d.String()
+	}
+	return strings.Join(items, ",")
+}
+func (v *durationListValue) Set(s string) error {
+	var durations []time.Duration
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			return errors.New("expected comma-separated durations such as 48h,4h")
+		}
+		durations = append(durations, d)
+	}
+	*v = durations
+	return nil
+}
+
+type prefixListValue []netip.Prefix
+
+func (v *prefixListValue) String() string {
+	items := make([]string, len(*v))
+	for i, prefix := range *v {
+		items[i] = prefix.String()
+	}
+	return strings.Join(items, ",")
+}
+func (v *prefixListValue) Set(s string) error {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return errors.New("expected comma-separated networks such as 10.0.0.0/8,fd00::/8")
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	*v = prefixes
+	return nil
+}
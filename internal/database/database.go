@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/shani34/meeting-scheduler/internal/config"
 	_ "github.com/lib/pq"
+	"github.com/shani34/meeting-scheduler/internal/config"
+	"github.com/shani34/meeting-scheduler/internal/migrate"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/shani34/meeting-scheduler/migrations"
 )
 
 // DB represents the database connection
@@ -15,8 +18,8 @@ type DB struct {
 }
 
 // NewDB creates a new database connection
-func NewDB(cfg *config.Config) (*DB, error) {
-	connStr := cfg.GetDBConnectionString()
+func NewDB(cfg config.DatabaseConfig) (*DB, error) {
+	connStr := cfg.ConnectionString()
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %v", err)
@@ -31,9 +34,95 @@ func NewDB(cfg *config.Config) (*DB, error) {
 	return &DB{db}, nil
 }
 
+// NewStore opens the storage backend selected by the configuration
+func NewStore(cfg config.DatabaseConfig) (*repository.Store, error) {
+	switch cfg.Backend {
+	case "", "postgres":
+		db, err := NewDB(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.AutoMigrate {
+			if err := migrateUp(db.DB); err != nil {
+				db.DB.Close()
+				return nil, err
+			}
+		}
+		return repository.NewSQLStore(db.DB), nil
+	case "sqlite":
+		store, err := repository.OpenSQLite(cfg.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using sqlite database %s", cfg.SQLitePath)
+		return store, nil
+	case "memory":
+		log.Println("Using in-memory storage, records are lost on restart")
+		return repository.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// NewMigrator creates a migrator for the database of the configured storage backend.
+// The returned function closes the database.
+func NewMigrator(cfg config.DatabaseConfig) (*migrate.Migrator, func() error, error) {
+	switch cfg.Backend {
+	case "", "postgres":
+		db, err := NewDB(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err := newPostgresMigrator(db.DB)
+		if err != nil {
+			db.DB.Close()
+			return nil, nil, err
+		}
+		return migrator, db.DB.Close, nil
+	case "sqlite":
+		db, err := sql.Open("sqlite", repository.SQLiteDSN(cfg.SQLitePath))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening sqlite database: %v", err)
+		}
+		migrator, err := repository.NewSQLiteMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return migrator, db.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("storage backend %q has no schema to migrate", cfg.Backend)
+	}
+}
+
+// newPostgresMigrator creates a migrator for the embedded PostgreSQL migrations
+func newPostgresMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	postgresMigrations, err := migrate.Load(migrations.Postgres, ".")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, postgresMigrations), nil
+}
+
+// migrateUp applies the pending PostgreSQL migrations
+func migrateUp(db *sql.DB) error {
+	migrator, err := newPostgresMigrator(db)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		log.Printf("Applied migration %03d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("error migrating database: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() {
 	if err := db.DB.Close(); err != nil {
 		log.Printf("Error closing database connection: %v", err)
 	}
-} 
\ No newline at end of file
+}
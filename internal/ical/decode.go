@@ -0,0 +1,213 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMalformed is returned when a calendar does not follow the iCalendar syntax
+var ErrMalformed = errors.New("malformed calendar")
+
+// maxLineLength bounds unfolded content lines, so a stream without line breaks is rejected early
+const maxLineLength = 1 << 20
+
+// Decode parses an iCalendar stream and returns its top-level components, usually a single VCALENDAR.
+// Parameter values are unquoted; property values are returned as written.
+func Decode(r io.Reader) ([]*Component, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*Component
+	var open []*Component
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, i+1, err)
+		}
+
+		switch strings.ToUpper(prop.Name) {
+		case "BEGIN":
+			component := NewComponent(strings.ToUpper(prop.Value))
+			if len(open) == 0 {
+				roots = append(roots, component)
+			} else {
+				open[len(open)-1].AddComponent(component)
+			}
+			open = append(open, component)
+		case "END":
+			if len(open) == 0 || open[len(open)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrMalformed, i+1, prop.Value)
+			}
+			open = open[:len(open)-1]
+		default:
+			if len(open) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property %s outside of a component", ErrMalformed, i+1, prop.Name)
+			}
+			open[len(open)-1].Properties = append(open[len(open)-1].Properties, prop)
+		}
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("%w: %s is not closed", ErrMalformed, open[len(open)-1].Name)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("%w: no components", ErrMalformed)
+	}
+	return roots, nil
+}
+
+// unfoldLines splits a stream into content lines, joining folded continuation lines
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return lines, nil
+}
+
+// parseLine splits a content line into its name, parameters and value
+func parseLine(line string) (Property, error) {
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return Property{}, errors.New("missing property name")
+	}
+	prop := Property{Name: strings.ToUpper(line[:end])}
+	rest := line[end:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return Property{}, fmt.Errorf("invalid parameter in %s", prop.Name)
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+		// Values are quoted when they contain separators, and may list several values
+		var value strings.Builder
+		for {
+			if strings.HasPrefix(rest, `"`) {
+				closing := strings.IndexByte(rest[1:], '"')
+				if closing < 0 {
+					return Property{}, fmt.Errorf("unterminated quote in %s", prop.Name)
+				}
+				value.WriteString(rest[1 : closing+1])
+				rest = rest[closing+2:]
+			} else {
+				stop := strings.IndexAny(rest, ",;:")
+				if stop < 0 {
+					return Property{}, fmt.Errorf("missing value of %s", prop.Name)
+				}
+				value.WriteString(rest[:stop])
+				rest = rest[stop:]
+			}
+			if !strings.HasPrefix(rest, ",") {
+				break
+			}
+			value.WriteByte(',')
+			rest = rest[1:]
+		}
+		param.Value = value.String()
+		prop.Params = append(prop.Params, param)
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return Property{}, fmt.Errorf("missing value of %s", prop.Name)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// Param returns the value of a parameter, or an empty string when it is not set
+func (p Property) Param(name string) string {
+	for _, param := range p.Params {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// Get returns the first property with the given name
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// All returns every property with the given name
+func (c *Component) All(name string) []Property {
+	var props []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// ParseText unescapes a TEXT value
+func ParseText(value string) string {
+	return textUnescaper.Replace(value)
+}
+
+// ParseTime parses DATE and DATE-TIME values. Values without a trailing Z are read in loc.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	if len(value) == len("20060102") {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration parses a DURATION value such as P1DT2H or PT30M. Days are counted as 24 hours.
+func ParseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
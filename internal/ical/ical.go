@@ -0,0 +1,135 @@
+// Package ical reads and writes iCalendar files as specified by RFC 5545.
+//
+// A calendar is a tree of components (VCALENDAR, VEVENT, VTIMEZONE, ...) holding properties.
+// Values are kept as written, so callers escape TEXT values with Text and format dates with
+// DateTime or LocalDateTime, and read them back with ParseText and ParseTime. Written lines are
+// folded at 75 octets and end with CRLF.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before folding, excluding the line break
+const maxLineOctets = 75
+
+// Param is a property parameter such as TZID=Europe/London
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Property is a single content line of a component
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+}
+
+// Component is a calendar component, holding properties and nested components
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// NewComponent creates an empty component
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add appends a property to the component
+func (c *Component) Add(name, value string, params ...Param) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// AddComponent nests a component inside this one
+func (c *Component) AddComponent(child *Component) {
+	c.Components = append(c.Components, child)
+}
+
+// Encode writes the component and everything nested in it
+func (c *Component) Encode(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	c.encode(buf)
+	return buf.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		var line strings.Builder
+		line.WriteString(p.Name)
+		for _, param := range p.Params {
+			line.WriteString(";" + param.Name + "=" + paramValue(param.Value))
+		}
+		line.WriteString(":" + p.Value)
+		writeLine(w, line.String())
+	}
+	for _, child := range c.Components {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// writeLine writes a content line, folding it so no physical line exceeds 75 octets.
+// Lines are only broken between characters, never inside a UTF-8 sequence.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // Continuation lines start with a space
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// paramValue quotes parameter values containing separators. Double quotes cannot be escaped
+// and are dropped.
+func paramValue(value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsAny(value, ";:,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Text escapes a TEXT value
+func Text(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// DateTime formats an instant as a UTC DATE-TIME value
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// LocalDateTime formats the wall-clock time of t in its location, for use with a TZID parameter
+func LocalDateTime(t time.Time) string {
+	return t.Format("20060102T150405")
+}
+
+// TimeProperty adds a DATE-TIME property in t's location, referring to the zone by TZID unless
+// it is UTC. Zones referred to must be defined with a VTIMEZONE component, see Timezone.
+func (c *Component) TimeProperty(name string, t time.Time) {
+	if IsUTC(t.Location()) {
+		c.Add(name, DateTime(t))
+		return
+	}
+	c.Add(name, LocalDateTime(t), Param{Name: "TZID", Value: t.Location().String()})
+}
+
+// IsUTC reports whether times in loc are written in UTC, without a VTIMEZONE
+func IsUTC(loc *time.Location) bool {
+	return loc == time.UTC || loc.String() == "UTC" || loc.String() == "Local"
+}
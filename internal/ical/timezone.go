@@ -0,0 +1,85 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timezone builds the VTIMEZONE component defining loc between from and to. The zone database
+// does not expose its rules, so every offset change in the range is found and listed as an
+// observance of its own, preceded by one describing the zone at from.
+func Timezone(loc *time.Location, from, to time.Time) *Component {
+	tz := NewComponent("VTIMEZONE")
+	tz.Add("TZID", loc.String())
+
+	at := from.In(loc).Truncate(time.Second)
+	_, offset := at.Zone()
+	tz.AddComponent(observance(at, offset))
+	for {
+		next, ok := nextTransition(at, to)
+		if !ok {
+			return tz
+		}
+		_, offset = at.Zone()
+		tz.AddComponent(observance(next, offset))
+		at = next
+	}
+}
+
+// observance describes the zone from the instant at, when the offset changed from fromOffset
+func observance(at time.Time, fromOffset int) *Component {
+	name, offset := at.Zone()
+	kind := "STANDARD"
+	if at.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	c := NewComponent(kind)
+	// The start is written in the local time in force before the change
+	c.Add("DTSTART", LocalDateTime(at.UTC().Add(time.Duration(fromOffset)*time.Second)))
+	c.Add("TZOFFSETFROM", formatOffset(fromOffset))
+	c.Add("TZOFFSETTO", formatOffset(offset))
+	c.Add("TZNAME", Text(name))
+	return c
+}
+
+// nextTransition finds the first instant after at, and before to, at which the zone's offset or
+// abbreviation changes. Days are scanned first and the change is then narrowed down to the second.
+func nextTransition(at, to time.Time) (time.Time, bool) {
+	name, offset := at.Zone()
+	changed := func(t time.Time) bool {
+		n, o := t.Zone()
+		return n != name || o != offset
+	}
+
+	for day := at; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if !changed(next) {
+			continue
+		}
+		before, after := day, next
+		for after.Sub(before) > time.Second {
+			mid := before.Add(after.Sub(before) / 2).Truncate(time.Second)
+			if changed(mid) {
+				after = mid
+			} else {
+				before = mid
+			}
+		}
+		return after, !after.After(to)
+	}
+	return time.Time{}, false
+}
+
+// formatOffset formats a UTC offset in seconds as a UTC-OFFSET value such as +0530
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	value := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		value += fmt.Sprintf("%02d", seconds%60)
+	}
+	return value
+}
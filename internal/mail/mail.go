@@ -0,0 +1,277 @@
+// Package mail composes MIME email messages and sends them over SMTP.
+//
+// Messages carry a plain text and an HTML body, shown by clients as alternatives, and any number of
+// attachments. Bodies are quoted-printable encoded and attachments base64 encoded, so messages are
+// 7-bit clean whatever they contain.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrNoRecipients is returned when a message has nobody to send it to
+var ErrNoRecipients = errors.New("message has no recipients")
+
+// Message is an email with alternative text and HTML bodies
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string // Sent as an alternative to Text when not empty
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Bytes renders the message in MIME format, dated now
+func (m *Message) Bytes(now time.Time) ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", m.From, err)
+	}
+	to := make([]string, len(m.To))
+	for i, recipient := range m.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+		to[i] = address.String()
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	// The alternative bodies are rendered first, the outer part needs their boundary
+	var bodies bytes.Buffer
+	alternative := multipart.NewWriter(&bodies)
+	if err := m.writeBodies(alternative); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+	alternativeType := mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alternative.Boundary()})
+	if len(m.Attachments) == 0 {
+		header("Content-Type", alternativeType)
+		buf.WriteString("\r\n")
+		buf.Write(bodies.Bytes())
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {alternativeType}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(bodies.Bytes()); err != nil {
+		return nil, err
+	}
+	for _, attachment := range m.Attachments {
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, attachment.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBodies writes the text body and, when there is one, the HTML body as alternatives
+func (m *Message) writeBodies(w *multipart.Writer) error {
+	if err := writeQuotedPrintable(w, "text/plain; charset=utf-8", m.Text); err != nil {
+		return err
+	}
+	if m.HTML == "" {
+		return nil
+	}
+	return writeQuotedPrintable(w, "text/html; charset=utf-8", m.HTML)
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(sender string) string {
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	domain := "localhost"
+	if _, host, ok := strings.Cut(sender, "@"); ok && host != "" {
+		domain = host
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// TLS modes of SMTP connections
+const (
+	TLSStartTLS = "starttls" // Upgrade the connection with STARTTLS when the server offers it
+	TLSImplicit = "tls"      // Connect over TLS, usually on port 465
+	TLSNone     = "none"     // Never encrypt, for local relays
+)
+
+// SMTPConfig locates an SMTP server and the credentials to send with
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string // No authentication when empty
+	Password string
+	TLS      string // One of the TLS modes, TLSStartTLS when empty
+	Timeout  time.Duration
+}
+
+// SMTPSender sends messages through an SMTP server, one connection per message
+type SMTPSender struct {
+	cfg SMTPConfig
+	now func() time.Time
+}
+
+// NewSMTPSender creates a sender for the SMTP server described by cfg
+func NewSMTPSender(cfg SMTPConfig) *SMTPSender {
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	return &SMTPSender{cfg: cfg, now: time.Now}
+}
+
+// Send delivers a message to every recipient
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipients
+	}
+	body, err := msg.Bytes(s.now())
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+	address := net.JoinHostPort(s.cfg.Host, s.cfg.Port)
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+	if s.cfg.TLS == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.cfg.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range msg.To {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,95 @@
+// Package netguard keeps the requests the server makes to URLs its users give, such as webhook
+// deliveries and calendar reads, away from the server's own networks.
+//
+// Loopback, private, link-local, multicast and unspecified addresses reach the server's own host and
+// network, including cloud metadata services, so they are refused unless they lie in one of the
+// allowed networks. URLs are checked when they are given, and every connection is checked again
+// once host names have been resolved, so host names resolving to a refused address are caught too.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenTarget is returned when a URL or a connection targets a refused address
+var ErrForbiddenTarget = errors.New("target is not an allowed address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routed on the internet either
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Policy decides which addresses requests may be sent to
+type Policy struct {
+	Allowed []netip.Prefix // Loopback, private or link-local networks requests may still be sent to
+}
+
+// Permits reports whether requests may be sent to an address
+func (p Policy) Permits(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p.Allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// CheckURL refuses URLs naming a refused address or a local host name. Other host names are
+// checked against the addresses they resolve to when guarded clients connect.
+func (p Policy) CheckURL(target *url.URL) error {
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.Permits(addr) {
+			return fmt.Errorf("%w: %s is a loopback, private or link-local address", ErrForbiddenTarget, addr)
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		if !p.Permits(netip.IPv6Loopback()) || !p.Permits(netip.AddrFrom4([4]byte{127, 0, 0, 1})) {
+			return fmt.Errorf("%w: %s is the local host", ErrForbiddenTarget, host)
+		}
+	}
+	return nil
+}
+
+// Guard returns a copy of client whose connections are refused when they reach a refused address,
+// so host names resolving to one, now or after the URL was checked, are not reached either.
+// Clients with their own kind of transport are returned unchanged.
+func (p Policy) Guard(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var transport *http.Transport
+	switch rt := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = rt.Clone()
+	default:
+		return client
+	}
+
+	dialer := &net.Dialer{Control: p.control}
+	transport.DialContext = dialer.DialContext
+	guarded := *client
+	guarded.Transport = transport
+	return &guarded
+}
+
+// control refuses connections to refused addresses, once host names have been resolved
+func (p Policy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !p.Permits(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, addrPort.Addr())
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new instance of APIKeyRepository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey creates a new API key in the database
+func (r *APIKeyRepository) CreateAPIKey(key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (id, user_id, name, key_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.Exec(query, key.ID, key.UserID, key.Name, key.KeyHash, key.CreatedAt)
+	return err
+}
+
+// GetAPIKeyByHash retrieves the API key with the given hash
+func (r *APIKeyRepository) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_hash, created_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+	key := &models.APIKey{}
+	err := r.db.QueryRow(query, hash).Scan(&key.ID, &key.UserID, &key.Name, &key.KeyHash, &key.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return key, nil
+}
+
+// DeleteAPIKey deletes an API key from the database
+func (r *APIKeyRepository) DeleteAPIKey(id string) error {
+	result, err := r.db.Exec("DELETE FROM api_keys WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
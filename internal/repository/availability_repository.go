@@ -2,82 +2,156 @@ package repository
 
 import (
 	"database/sql"
-	"time"
 
+	"github.com/google/uuid"
 	"github.com/shani34/meeting-scheduler/api/models"
 )
 
 // AvailabilityRepository handles database operations for participant availabilities
 type AvailabilityRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	uow *UnitOfWork
 }
 
 // NewAvailabilityRepository creates a new instance of AvailabilityRepository
 func NewAvailabilityRepository(db *sql.DB) *AvailabilityRepository {
-	return &AvailabilityRepository{db: db}
+	return &AvailabilityRepository{db: db, uow: NewUnitOfWork(db)}
 }
 
 // CreateAvailability creates a new participant availability in the database
 func (r *AvailabilityRepository) CreateAvailability(availability *models.ParticipantAvailability) error {
+	return r.uow.Do(func(tx DBTX) error {
+		return insertAvailability(tx, availability)
+	})
+}
+
+// CreateGuestResponse stores the first availability a guest submits through an open invite together
+// with the personal invite issued to them, so neither is kept without the other. It returns
+// ErrAlreadyInvited when the guest's email already has an invite to the event that was not revoked.
+func (r *AvailabilityRepository) CreateGuestResponse(invite *models.EventInvite, availability *models.ParticipantAvailability) error {
+	return r.uow.Do(func(tx DBTX) error {
+		query := `
+			SELECT EXISTS (
+				SELECT 1 FROM event_invites
+				WHERE event_id = $1 AND email = $2 AND revoked_at IS NULL
+			)
+		`
+		var invited bool
+		if err := tx.QueryRow(query, invite.EventID, invite.Email).Scan(&invited); err != nil {
+			return err
+		}
+		if invited {
+			return ErrAlreadyInvited
+		}
+		if err := insertInvite(tx, invite); err != nil {
+			return err
+		}
+		return insertAvailability(tx, availability)
+	})
+}
+
+// insertAvailability stores a new participant availability with its time slots
+func insertAvailability(tx DBTX, availability *models.ParticipantAvailability) error {
 	query := `
-		INSERT INTO participant_availabilities (id, event_id, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO participant_availabilities (id, event_id, user_id, invite_id, guest_name, guest_email,
+			source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err := r.db.Exec(query,
+	inviteID, guestName, guestEmail := guestColumns(availability.Guest)
+	_, err := tx.Exec(query,
 		availability.ID,
 		availability.EventID,
 		availability.UserID,
+		inviteID,
+		guestName,
+		guestEmail,
+		availability.Source,
 		availability.CreatedAt,
 		availability.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
+	if err := bumpEventRevision(tx, availability.EventID); err != nil {
+		return err
+	}
 
 	// Insert time slots
-	for _, slot := range availability.TimeSlots {
-		slotQuery := `
-			INSERT INTO availability_time_slots (availability_id, start_time, end_time, time_zone)
-			VALUES ($1, $2, $3, $4)
+	return insertTimeSlots(tx, availability)
+}
+
+// UpsertAvailability stores the availability a user submitted to an event, replacing the time slots
+// of any availability they submitted before. On replacement the availability keeps its original ID
+// and creation time, which are written back into availability.
+func (r *AvailabilityRepository) UpsertAvailability(availability *models.ParticipantAvailability) error {
+	return r.uow.Do(func(tx DBTX) error {
+		query := `
+			INSERT INTO participant_availabilities (id, event_id, user_id, invite_id, guest_name, guest_email,
+				source, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (event_id, user_id) DO UPDATE
+			SET invite_id = $4, guest_name = $5, guest_email = $6, source = $7, updated_at = $9
+			RETURNING id, created_at
 		`
-		_, err = r.db.Exec(slotQuery,
+		inviteID, guestName, guestEmail := guestColumns(availability.Guest)
+		err := tx.QueryRow(query,
 			availability.ID,
-			slot.StartTime,
-			slot.EndTime,
-			slot.TimeZone,
-		)
+			availability.EventID,
+			availability.UserID,
+			inviteID,
+			guestName,
+			guestEmail,
+			availability.Source,
+			availability.CreatedAt,
+			availability.UpdatedAt,
+		).Scan(&availability.ID, &availability.CreatedAt)
 		if err != nil {
 			return err
 		}
-	}
+		if err := bumpEventRevision(tx, availability.EventID); err != nil {
+			return err
+		}
 
-	return nil
+		// Replace time slots
+		_, err = tx.Exec("DELETE FROM availability_time_slots WHERE availability_id = $1", availability.ID)
+		if err != nil {
+			return err
+		}
+		return insertTimeSlots(tx, availability)
+	})
 }
 
 // GetAvailability retrieves a participant availability by ID
 func (r *AvailabilityRepository) GetAvailability(id string) (*models.ParticipantAvailability, error) {
 	availability := &models.ParticipantAvailability{}
 	query := `
-		SELECT id, event_id, user_id, created_at, updated_at
+		SELECT id, event_id, user_id, invite_id, guest_name, guest_email, source, created_at, updated_at
 		FROM participant_availabilities
 		WHERE id = $1
 	`
+	var inviteID, guestName, guestEmail sql.NullString
 	err := r.db.QueryRow(query, id).Scan(
 		&availability.ID,
 		&availability.EventID,
 		&availability.UserID,
+		&inviteID,
+		&guestName,
+		&guestEmail,
+		&availability.Source,
 		&availability.CreatedAt,
 		&availability.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
+	availability.Guest = scanGuest(inviteID, guestName, guestEmail)
 
 	// Get time slots
 	slotsQuery := `
-		SELECT start_time, end_time, time_zone
+		SELECT start_time, end_time, time_zone, preference
 		FROM availability_time_slots
 		WHERE availability_id = $1
+		ORDER BY start_time
 	`
 	rows, err := r.db.Query(slotsQuery, id)
 	if err != nil {
@@ -87,7 +161,7 @@ func (r *AvailabilityRepository) GetAvailability(id string) (*models.Participant
 
 	for rows.Next() {
 		var slot models.TimeSlot
-		err := rows.Scan(&slot.StartTime, &slot.EndTime, &slot.TimeZone)
+		err := rows.Scan(&slot.StartTime, &slot.EndTime, &slot.TimeZone, &slot.Preference)
 		if err != nil {
 			return nil, err
 		}
@@ -97,57 +171,102 @@ func (r *AvailabilityRepository) GetAvailability(id string) (*models.Participant
 	return availability, nil
 }
 
-// UpdateAvailability updates an existing participant availability
+// UpdateAvailability replaces the time slots and source of an existing participant availability
 func (r *AvailabilityRepository) UpdateAvailability(availability *models.ParticipantAvailability) error {
-	query := `
-		UPDATE participant_availabilities
-		SET updated_at = $1
-		WHERE id = $2
-	`
-	_, err := r.db.Exec(query,
-		time.Now(),
-		availability.ID,
-	)
-	if err != nil {
-		return err
-	}
+	return r.uow.Do(func(tx DBTX) error {
+		query := `
+			UPDATE participant_availabilities
+			SET source = $1, updated_at = $2
+			WHERE id = $3
+		`
+		result, err := tx.Exec(query,
+			availability.Source,
+			availability.UpdatedAt,
+			availability.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if err := requireAffected(result); err != nil {
+			return err
+		}
+		if err := bumpAvailabilityEventRevision(tx, availability.ID); err != nil {
+			return err
+		}
 
-	// Delete existing time slots
-	_, err = r.db.Exec("DELETE FROM availability_time_slots WHERE availability_id = $1", availability.ID)
-	if err != nil {
+		// Delete existing time slots
+		_, err = tx.Exec("DELETE FROM availability_time_slots WHERE availability_id = $1", availability.ID)
+		if err != nil {
+			return err
+		}
+
+		// Insert new time slots
+		return insertTimeSlots(tx, availability)
+	})
+}
+
+// DeleteAvailability deletes a participant availability
+func (r *AvailabilityRepository) DeleteAvailability(id string) error {
+	return r.uow.Do(func(tx DBTX) error {
+		if err := bumpAvailabilityEventRevision(tx, id); err != nil {
+			return err
+		}
+
+		// Delete time slots first
+		_, err := tx.Exec("DELETE FROM availability_time_slots WHERE availability_id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		// Delete the availability
+		query := "DELETE FROM participant_availabilities WHERE id = $1"
+		_, err = tx.Exec(query, id)
 		return err
-	}
+	})
+}
 
-	// Insert new time slots
+// insertTimeSlots stores the time slots of an availability
+func insertTimeSlots(tx DBTX, availability *models.ParticipantAvailability) error {
 	for _, slot := range availability.TimeSlots {
 		slotQuery := `
-			INSERT INTO availability_time_slots (availability_id, start_time, end_time, time_zone)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO availability_time_slots (id, availability_id, start_time, end_time, time_zone, preference)
+			VALUES ($1, $2, $3, $4, $5, $6)
 		`
-		_, err = r.db.Exec(slotQuery,
+		_, err := tx.Exec(slotQuery,
+			uuid.New().String(),
 			availability.ID,
 			slot.StartTime,
 			slot.EndTime,
 			slot.TimeZone,
+			preferenceOrDefault(slot.Preference),
 		)
 		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-// DeleteAvailability deletes a participant availability
-func (r *AvailabilityRepository) DeleteAvailability(id string) error {
-	// Delete time slots first
-	_, err := r.db.Exec("DELETE FROM availability_time_slots WHERE availability_id = $1", id)
-	if err != nil {
-		return err
+// preferenceOrDefault stores unset preference levels as plainly available
+func preferenceOrDefault(level models.PreferenceLevel) models.PreferenceLevel {
+	if level == "" {
+		return models.PreferenceAvailable
 	}
+	return level
+}
 
-	// Delete the availability
-	query := "DELETE FROM participant_availabilities WHERE id = $1"
-	_, err = r.db.Exec(query, id)
-	return err
-} 
\ No newline at end of file
+// guestColumns flattens the guest who submitted an availability into its nullable columns
+func guestColumns(guest *models.GuestDetails) (sql.NullString, sql.NullString, sql.NullString) {
+	if guest == nil {
+		return sql.NullString{}, sql.NullString{}, sql.NullString{}
+	}
+	return nullString(guest.InviteID), nullString(guest.Name), nullString(guest.Email)
+}
+
+// scanGuest rebuilds the guest who submitted an availability, if any
+func scanGuest(inviteID, name, email sql.NullString) *models.GuestDetails {
+	if !inviteID.Valid {
+		return nil
+	}
+	return &models.GuestDetails{InviteID: inviteID.String, Name: name.String, Email: email.String}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// CalendarConnectionRepository handles database operations for participants' calendar connections
+type CalendarConnectionRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarConnectionRepository creates a new instance of CalendarConnectionRepository
+func NewCalendarConnectionRepository(db *sql.DB) *CalendarConnectionRepository {
+	return &CalendarConnectionRepository{db: db}
+}
+
+// UpsertCalendarConnection creates or replaces the calendar connection of a user.
+// Replacing a connection forgets the outcome of its last sync.
+func (r *CalendarConnectionRepository) UpsertCalendarConnection(connection *models.CalendarConnection) error {
+	query := `
+		INSERT INTO calendar_connections (user_id, url, username, secret, last_synced_at, last_error, updated_at)
+		VALUES ($1, $2, $3, $4, NULL, '', $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET url = $2, username = $3, secret = $4, last_synced_at = NULL, last_error = '', updated_at = $5
+	`
+	_, err := r.db.Exec(query,
+		connection.UserID,
+		connection.URL,
+		connection.Username,
+		connection.Secret,
+		connection.UpdatedAt.UTC(),
+	)
+	return err
+}
+
+// GetCalendarConnection retrieves the calendar connection of a user
+func (r *CalendarConnectionRepository) GetCalendarConnection(userID string) (*models.CalendarConnection, error) {
+	query := `
+		SELECT user_id, url, username, secret, last_synced_at, last_error, updated_at
+		FROM calendar_connections
+		WHERE user_id = $1
+	`
+	connection := &models.CalendarConnection{}
+	var lastSyncedAt sql.NullTime
+	err := r.db.QueryRow(query, userID).Scan(
+		&connection.UserID,
+		&connection.URL,
+		&connection.Username,
+		&connection.Secret,
+		&lastSyncedAt,
+		&connection.LastError,
+		&connection.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if lastSyncedAt.Valid {
+		connection.LastSyncedAt = &lastSyncedAt.Time
+	}
+	return connection, nil
+}
+
+// DeleteCalendarConnection deletes the calendar connection of a user
+func (r *CalendarConnectionRepository) DeleteCalendarConnection(userID string) error {
+	result, err := r.db.Exec("DELETE FROM calendar_connections WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RecordCalendarSync stores the outcome of pulling a user's availability, an empty error meaning success
+func (r *CalendarConnectionRepository) RecordCalendarSync(userID string, syncedAt time.Time, syncErr string) error {
+	result, err := r.db.Exec(
+		"UPDATE calendar_connections SET last_synced_at = $1, last_error = $2 WHERE user_id = $3",
+		syncedAt.UTC(), syncErr, userID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ListCalendarSyncTargets lists the participants with a calendar connection in every event still
+// collecting availability at now
+func (r *CalendarConnectionRepository) ListCalendarSyncTargets(now time.Time) ([]models.CalendarSyncTarget, error) {
+	query := `
+		SELECT p.event_id, p.user_id
+		FROM event_participants p
+		JOIN events e ON e.id = p.event_id
+		JOIN calendar_connections c ON c.user_id = p.user_id
+		WHERE e.status = 'polling' AND (e.response_deadline IS NULL OR e.response_deadline > $1)
+		ORDER BY p.event_id, p.user_id
+	`
+	rows, err := r.db.Query(query, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []models.CalendarSyncTarget
+	for rows.Next() {
+		var target models.CalendarSyncTarget
+		if err := rows.Scan(&target.EventID, &target.UserID); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
@@ -2,31 +2,50 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/shani34/meeting-scheduler/api/models"
 )
 
 // EventRepository handles database operations for events
 type EventRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	uow *UnitOfWork
 }
 
 // NewEventRepository creates a new instance of EventRepository
 func NewEventRepository(db *sql.DB) *EventRepository {
-	return &EventRepository{db: db}
+	return &EventRepository{db: db, uow: NewUnitOfWork(db)}
 }
 
-// CreateEvent creates a new event in the database
+// CreateEvent creates a new event in the database, along with its time slots and participant roles.
+// The creator of the event is made its organizer.
 func (r *EventRepository) CreateEvent(event *models.Event) error {
+	return r.uow.Do(func(tx DBTX) error {
+		return createEvent(tx, event)
+	})
+}
+
+func createEvent(tx DBTX, event *models.Event) error {
 	query := `
-		INSERT INTO events (id, title, duration, created_by, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO events (id, title, description, duration, rrule, recurrence_time_zone, exdates, status,
+			response_deadline, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
-	_, err := r.db.Exec(query,
+	rrule, recurrenceTimeZone, exdates := recurrenceColumns(event.Recurrence)
+	_, err := tx.Exec(query,
 		event.ID,
 		event.Title,
+		nullString(event.Description),
 		event.Duration,
+		rrule,
+		recurrenceTimeZone,
+		exdates,
+		event.Status,
+		nullTime(event.Deadline),
 		event.CreatedBy,
 		event.CreatedAt,
 		event.UpdatedAt,
@@ -35,44 +54,74 @@ func (r *EventRepository) CreateEvent(event *models.Event) error {
 		return err
 	}
 
-	// Insert time slots
-	for _, slot := range event.TimeSlots {
-		slotQuery := `
-			INSERT INTO event_time_slots (event_id, start_time, end_time, time_zone)
-			VALUES ($1, $2, $3, $4)
-		`
-		_, err = r.db.Exec(slotQuery,
-			event.ID,
-			slot.StartTime,
-			slot.EndTime,
-			slot.TimeZone,
-		)
-		if err != nil {
-			return err
-		}
+	// Insert time slots and participant roles
+	if err := insertEventTimeSlots(tx, event); err != nil {
+		return err
 	}
-
-	return nil
+	if err := insertParticipants(tx, event); err != nil {
+		return err
+	}
+	if event.CreatedBy == "" {
+		return nil
+	}
+	return assignEventRole(tx, &models.EventRoleAssignment{
+		EventID: event.ID,
+		UserID:  event.CreatedBy,
+		Role:    models.EventRoleOrganizer,
+	})
 }
 
 // GetEvent retrieves an event by ID
 func (r *EventRepository) GetEvent(id string) (*models.Event, error) {
 	event := &models.Event{}
 	query := `
-		SELECT id, title, duration, created_by, created_at, updated_at
+		SELECT id, title, description, duration, rrule, recurrence_time_zone, exdates, status, response_deadline,
+			final_start_time, final_end_time, final_time_zone, finalized_by, finalized_at,
+			created_by, revision, created_at, updated_at
 		FROM events
 		WHERE id = $1
 	`
+	var description, rrule, recurrenceTimeZone, exdates sql.NullString
+	var deadline, finalStart, finalEnd, finalizedAt sql.NullTime
+	var finalTimeZone, finalizedBy sql.NullString
 	err := r.db.QueryRow(query, id).Scan(
 		&event.ID,
 		&event.Title,
+		&description,
 		&event.Duration,
+		&rrule,
+		&recurrenceTimeZone,
+		&exdates,
+		&event.Status,
+		&deadline,
+		&finalStart,
+		&finalEnd,
+		&finalTimeZone,
+		&finalizedBy,
+		&finalizedAt,
 		&event.CreatedBy,
+		&event.Revision,
 		&event.CreatedAt,
 		&event.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
+	}
+	event.Description = description.String
+	event.Recurrence = parseRecurrenceColumns(rrule, recurrenceTimeZone, exdates)
+	if deadline.Valid {
+		event.Deadline = &deadline.Time
+	}
+	if finalStart.Valid && finalEnd.Valid {
+		event.FinalSlot = &models.TimeSlot{
+			StartTime: finalStart.Time,
+			EndTime:   finalEnd.Time,
+			TimeZone:  finalTimeZone.String,
+		}
+	}
+	event.FinalizedBy = finalizedBy.String
+	if finalizedAt.Valid {
+		event.FinalizedAt = &finalizedAt.Time
 	}
 
 	// Get time slots
@@ -80,6 +129,7 @@ func (r *EventRepository) GetEvent(id string) (*models.Event, error) {
 		SELECT start_time, end_time, time_zone
 		FROM event_time_slots
 		WHERE event_id = $1
+		ORDER BY start_time
 	`
 	rows, err := r.db.Query(slotsQuery, id)
 	if err != nil {
@@ -96,39 +146,90 @@ func (r *EventRepository) GetEvent(id string) (*models.Event, error) {
 		event.TimeSlots = append(event.TimeSlots, slot)
 	}
 
+	// Get participant roles
+	participantsQuery := `
+		SELECT user_id, required, weight
+		FROM event_participants
+		WHERE event_id = $1
+	`
+	participantRows, err := r.db.Query(participantsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer participantRows.Close()
+
+	for participantRows.Next() {
+		var participant models.EventParticipant
+		err := participantRows.Scan(&participant.UserID, &participant.Required, &participant.Weight)
+		if err != nil {
+			return nil, err
+		}
+		event.Participants = append(event.Participants, participant)
+	}
+
 	return event, nil
 }
 
-// UpdateEvent updates an existing event
+// UpdateEvent updates an existing event, replacing its time slots and participant roles, provided it
+// still has event.Status; it returns ErrStatusChanged otherwise. The event's new revision is written
+// back into event.
 func (r *EventRepository) UpdateEvent(event *models.Event) error {
+	return r.uow.Do(func(tx DBTX) error {
+		return updateEvent(tx, event)
+	})
+}
+
+func updateEvent(tx DBTX, event *models.Event) error {
 	query := `
 		UPDATE events
-		SET title = $1, duration = $2, updated_at = $3
-		WHERE id = $4
+		SET title = $1, description = $2, duration = $3, rrule = $4, recurrence_time_zone = $5, exdates = $6,
+			response_deadline = $7, deadline_processed = FALSE, revision = revision + 1, updated_at = $8
+		WHERE id = $9 AND status = $10
+		RETURNING revision
 	`
-	_, err := r.db.Exec(query,
+	rrule, recurrenceTimeZone, exdates := recurrenceColumns(event.Recurrence)
+	err := tx.QueryRow(query,
 		event.Title,
+		nullString(event.Description),
 		event.Duration,
+		rrule,
+		recurrenceTimeZone,
+		exdates,
+		nullTime(event.Deadline),
 		time.Now(),
 		event.ID,
-	)
+		event.Status,
+	).Scan(&event.Revision)
 	if err != nil {
+		return statusChanged(tx, event.ID, err)
+	}
+
+	// Replace time slots
+	_, err = tx.Exec("DELETE FROM event_time_slots WHERE event_id = $1", event.ID)
+	if err != nil {
+		return err
+	}
+	if err := insertEventTimeSlots(tx, event); err != nil {
 		return err
 	}
 
-	// Delete existing time slots
-	_, err = r.db.Exec("DELETE FROM event_time_slots WHERE event_id = $1", event.ID)
+	// Replace participant roles
+	_, err = tx.Exec("DELETE FROM event_participants WHERE event_id = $1", event.ID)
 	if err != nil {
 		return err
 	}
+	return insertParticipants(tx, event)
+}
 
-	// Insert new time slots
+// insertEventTimeSlots inserts the time slots of an event
+func insertEventTimeSlots(tx DBTX, event *models.Event) error {
 	for _, slot := range event.TimeSlots {
 		slotQuery := `
-			INSERT INTO event_time_slots (event_id, start_time, end_time, time_zone)
-			VALUES ($1, $2, $3, $4)
+			INSERT INTO event_time_slots (id, event_id, start_time, end_time, time_zone)
+			VALUES ($1, $2, $3, $4, $5)
 		`
-		_, err = r.db.Exec(slotQuery,
+		_, err := tx.Exec(slotQuery,
+			uuid.New().String(),
 			event.ID,
 			slot.StartTime,
 			slot.EndTime,
@@ -142,26 +243,205 @@ func (r *EventRepository) UpdateEvent(event *models.Event) error {
 	return nil
 }
 
-// DeleteEvent deletes an event
-func (r *EventRepository) DeleteEvent(id string) error {
-	// Delete time slots first
-	_, err := r.db.Exec("DELETE FROM event_time_slots WHERE event_id = $1", id)
+// insertParticipants inserts the participant roles of an event
+func insertParticipants(tx DBTX, event *models.Event) error {
+	for _, participant := range event.Participants {
+		participantQuery := `
+			INSERT INTO event_participants (event_id, user_id, required, weight)
+			VALUES ($1, $2, $3, $4)
+		`
+		_, err := tx.Exec(participantQuery,
+			event.ID,
+			participant.UserID,
+			participant.Required,
+			participant.Weight,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateEventStatus persists an event's lifecycle status and finalization details, provided the event
+// still has the status from; it returns ErrStatusChanged otherwise. The event's new revision is
+// written back into event.
+func (r *EventRepository) UpdateEventStatus(event *models.Event, from models.EventStatus) error {
+	query := `
+		UPDATE events
+		SET status = $1, final_start_time = $2, final_end_time = $3, final_time_zone = $4,
+			finalized_by = $5, finalized_at = $6, revision = revision + 1, updated_at = $7
+		WHERE id = $8 AND status = $9
+		RETURNING revision
+	`
+	var finalStart, finalEnd sql.NullTime
+	var finalTimeZone sql.NullString
+	if event.FinalSlot != nil {
+		finalStart = sql.NullTime{Time: event.FinalSlot.StartTime, Valid: true}
+		finalEnd = sql.NullTime{Time: event.FinalSlot.EndTime, Valid: true}
+		finalTimeZone = sql.NullString{String: event.FinalSlot.TimeZone, Valid: true}
+	}
+	err := r.db.QueryRow(query,
+		event.Status,
+		finalStart,
+		finalEnd,
+		finalTimeZone,
+		sql.NullString{String: event.FinalizedBy, Valid: event.FinalizedBy != ""},
+		nullTime(event.FinalizedAt),
+		event.UpdatedAt,
+		event.ID,
+		from,
+	).Scan(&event.Revision)
 	if err != nil {
+		return statusChanged(r.db, event.ID, err)
+	}
+	return nil
+}
+
+// statusChanged explains why a write conditioned on an event's status failed: ErrNotFound when the
+// event does not exist, ErrStatusChanged when it matched no row otherwise
+func statusChanged(q DBTX, eventID string, err error) error {
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM events WHERE id = $1)", eventID).Scan(&exists); err != nil {
 		return err
 	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrStatusChanged
+}
+
+// bumpEventRevision records a change to the availability submitted to an event in the event's revision
+func bumpEventRevision(tx DBTX, eventID string) error {
+	_, err := tx.Exec("UPDATE events SET revision = revision + 1 WHERE id = $1", eventID)
+	return err
+}
+
+// bumpAvailabilityEventRevision is bumpEventRevision for the event an availability was submitted to
+func bumpAvailabilityEventRevision(tx DBTX, availabilityID string) error {
+	query := `
+		UPDATE events SET revision = revision + 1
+		WHERE id = (SELECT event_id FROM participant_availabilities WHERE id = $1)
+	`
+	_, err := tx.Exec(query, availabilityID)
+	return err
+}
+
+// ListDueEventIDs returns the polling events whose response deadline passed and that were not processed yet
+func (r *EventRepository) ListDueEventIDs(now time.Time) ([]string, error) {
+	query := `
+		SELECT id
+		FROM events
+		WHERE status = 'polling' AND response_deadline <= $1 AND NOT deadline_processed
+		ORDER BY response_deadline
+	`
+	rows, err := r.db.Query(query, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
 
-	// Delete the event
-	query := "DELETE FROM events WHERE id = $1"
-	_, err = r.db.Exec(query, id)
+	return ids, rows.Err()
+}
+
+// MarkDeadlineProcessed records that an event's response deadline has been handled
+func (r *EventRepository) MarkDeadlineProcessed(id string) error {
+	_, err := r.db.Exec("UPDATE events SET deadline_processed = TRUE WHERE id = $1", id)
 	return err
 }
 
-// GetParticipantAvailabilities retrieves all participant availabilities for an event
+// nullString converts an optional string into a nullable column value
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime converts an optional time into a nullable column value, in UTC so stored instants compare in order
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// recurrenceColumns flattens an event recurrence into its nullable columns
+func recurrenceColumns(recurrence *models.Recurrence) (sql.NullString, sql.NullString, sql.NullString) {
+	if recurrence == nil {
+		return sql.NullString{}, sql.NullString{}, sql.NullString{}
+	}
+
+	exdates := make([]string, len(recurrence.ExDates))
+	for i, exdate := range recurrence.ExDates {
+		exdates[i] = exdate.Format(time.RFC3339)
+	}
+	return sql.NullString{String: recurrence.RRule, Valid: true},
+		sql.NullString{String: recurrence.TimeZone, Valid: recurrence.TimeZone != ""},
+		sql.NullString{String: strings.Join(exdates, ","), Valid: len(exdates) > 0}
+}
+
+// parseRecurrenceColumns rebuilds an event recurrence from its nullable columns
+func parseRecurrenceColumns(rrule, recurrenceTimeZone, exdates sql.NullString) *models.Recurrence {
+	if !rrule.Valid || rrule.String == "" {
+		return nil
+	}
+
+	recurrence := &models.Recurrence{RRule: rrule.String, TimeZone: recurrenceTimeZone.String}
+	if exdates.Valid && exdates.String != "" {
+		for _, value := range strings.Split(exdates.String, ",") {
+			if exdate, err := time.Parse(time.RFC3339, value); err == nil {
+				recurrence.ExDates = append(recurrence.ExDates, exdate)
+			}
+		}
+	}
+	return recurrence
+}
+
+// DeleteEvent deletes an event
+func (r *EventRepository) DeleteEvent(id string) error {
+	return r.uow.Do(func(tx DBTX) error {
+		// Delete time slots and participant roles first
+		_, err := tx.Exec("DELETE FROM event_time_slots WHERE event_id = $1", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM event_participants WHERE event_id = $1", id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM event_roles WHERE event_id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		// Delete the event
+		query := "DELETE FROM events WHERE id = $1"
+		_, err = tx.Exec(query, id)
+		return err
+	})
+}
+
+// GetParticipantAvailabilities retrieves all participant availabilities for an event.
+// The availabilities and their time slots are loaded with a single joined query.
 func (r *EventRepository) GetParticipantAvailabilities(eventID string) ([]models.ParticipantAvailability, error) {
 	query := `
-		SELECT id, event_id, user_id, created_at, updated_at
-		FROM participant_availabilities
-		WHERE event_id = $1
+		SELECT pa.id, pa.event_id, pa.user_id, pa.invite_id, pa.guest_name, pa.guest_email, pa.source,
+			pa.created_at, pa.updated_at, s.start_time, s.end_time, s.time_zone, s.preference
+		FROM participant_availabilities pa
+		LEFT JOIN availability_time_slots s ON s.availability_id = pa.id
+		WHERE pa.event_id = $1
+		ORDER BY pa.id, s.start_time
 	`
 	rows, err := r.db.Query(query, eventID)
 	if err != nil {
@@ -172,41 +452,42 @@ func (r *EventRepository) GetParticipantAvailabilities(eventID string) ([]models
 	var availabilities []models.ParticipantAvailability
 	for rows.Next() {
 		var availability models.ParticipantAvailability
+		var start, end sql.NullTime
+		var inviteID, guestName, guestEmail, timeZone, preference sql.NullString
 		err := rows.Scan(
 			&availability.ID,
 			&availability.EventID,
 			&availability.UserID,
+			&inviteID,
+			&guestName,
+			&guestEmail,
+			&availability.Source,
 			&availability.CreatedAt,
 			&availability.UpdatedAt,
+			&start,
+			&end,
+			&timeZone,
+			&preference,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		// Get time slots for this availability
-		slotsQuery := `
-			SELECT start_time, end_time, time_zone
-			FROM availability_time_slots
-			WHERE availability_id = $1
-		`
-		slotRows, err := r.db.Query(slotsQuery, availability.ID)
-		if err != nil {
-			return nil, err
+		// Rows are ordered by availability, so a new ID starts the next availability
+		if n := len(availabilities); n == 0 || availabilities[n-1].ID != availability.ID {
+			availability.Guest = scanGuest(inviteID, guestName, guestEmail)
+			availabilities = append(availabilities, availability)
 		}
-
-		for slotRows.Next() {
-			var slot models.TimeSlot
-			err := slotRows.Scan(&slot.StartTime, &slot.EndTime, &slot.TimeZone)
-			if err != nil {
-				slotRows.Close()
-				return nil, err
-			}
-			availability.TimeSlots = append(availability.TimeSlots, slot)
+		if start.Valid && end.Valid {
+			current := &availabilities[len(availabilities)-1]
+			current.TimeSlots = append(current.TimeSlots, models.TimeSlot{
+				StartTime:  start.Time,
+				EndTime:    end.Time,
+				TimeZone:   timeZone.String,
+				Preference: models.PreferenceLevel(preference.String),
+			})
 		}
-		slotRows.Close()
-
-		availabilities = append(availabilities, availability)
 	}
 
-	return availabilities, nil
-} 
\ No newline at end of file
+	return availabilities, rows.Err()
+}
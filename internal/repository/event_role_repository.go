@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// EventRoleRepository handles database operations for the roles users hold in events
+type EventRoleRepository struct {
+	db *sql.DB
+}
+
+// NewEventRoleRepository creates a new instance of EventRoleRepository
+func NewEventRoleRepository(db *sql.DB) *EventRoleRepository {
+	return &EventRoleRepository{db: db}
+}
+
+// AssignEventRole grants a user a role in an event, replacing the role they held before
+func (r *EventRoleRepository) AssignEventRole(assignment *models.EventRoleAssignment) error {
+	return assignEventRole(r.db, assignment)
+}
+
+func assignEventRole(tx DBTX, assignment *models.EventRoleAssignment) error {
+	query := `
+		INSERT INTO event_roles (event_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (event_id, user_id) DO UPDATE
+		SET role = $3
+	`
+	_, err := tx.Exec(query, assignment.EventID, assignment.UserID, assignment.Role)
+	return err
+}
+
+// GetEventRole retrieves the role a user was granted in an event
+func (r *EventRoleRepository) GetEventRole(eventID, userID string) (models.EventRole, error) {
+	var role models.EventRole
+	err := r.db.QueryRow(
+		"SELECT role FROM event_roles WHERE event_id = $1 AND user_id = $2",
+		eventID, userID,
+	).Scan(&role)
+	if err != nil {
+		return "", notFound(err)
+	}
+	return role, nil
+}
+
+// ListEventRoles retrieves every role granted in an event, ordered by user
+func (r *EventRoleRepository) ListEventRoles(eventID string) ([]models.EventRoleAssignment, error) {
+	rows, err := r.db.Query(
+		"SELECT event_id, user_id, role FROM event_roles WHERE event_id = $1 ORDER BY user_id",
+		eventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []models.EventRoleAssignment
+	for rows.Next() {
+		var assignment models.EventRoleAssignment
+		if err := rows.Scan(&assignment.EventID, &assignment.UserID, &assignment.Role); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, rows.Err()
+}
+
+// DeleteEventRole withdraws the role a user was granted in an event
+func (r *EventRoleRepository) DeleteEventRole(eventID, userID string) error {
+	result, err := r.db.Exec("DELETE FROM event_roles WHERE event_id = $1 AND user_id = $2", eventID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
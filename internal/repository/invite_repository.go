@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// InviteRepository handles database operations for event invites
+type InviteRepository struct {
+	db *sql.DB
+}
+
+// NewInviteRepository creates a new instance of InviteRepository
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+// CreateInvite creates a new invite in the database
+func (r *InviteRepository) CreateInvite(invite *models.EventInvite) error {
+	return insertInvite(r.db, invite)
+}
+
+// insertInvite stores an invite
+func insertInvite(q DBTX, invite *models.EventInvite) error {
+	query := `
+		INSERT INTO event_invites (id, event_id, name, email, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := q.Exec(query,
+		invite.ID,
+		invite.EventID,
+		invite.Name,
+		invite.Email,
+		invite.CreatedBy,
+		invite.ExpiresAt.UTC(),
+		invite.CreatedAt,
+	)
+	return err
+}
+
+// GetInvite retrieves an invite by ID
+func (r *InviteRepository) GetInvite(id string) (*models.EventInvite, error) {
+	query := `
+		SELECT id, event_id, name, email, created_by, expires_at, revoked_at, created_at
+		FROM event_invites
+		WHERE id = $1
+	`
+	invite, err := scanInvite(r.db.QueryRow(query, id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return invite, nil
+}
+
+// ListInvites retrieves the invites to an event, oldest first
+func (r *InviteRepository) ListInvites(eventID string) ([]models.EventInvite, error) {
+	query := `
+		SELECT id, event_id, name, email, created_by, expires_at, revoked_at, created_at
+		FROM event_invites
+		WHERE event_id = $1
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []models.EventInvite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite marks an invite as revoked. The invite is kept to explain the guest submissions made with it.
+func (r *InviteRepository) RevokeInvite(id string, revokedAt time.Time) error {
+	result, err := r.db.Exec("UPDATE event_invites SET revoked_at = $1 WHERE id = $2", revokedAt.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// scanInvite reads an invite from a row
+func scanInvite(row scanner) (*models.EventInvite, error) {
+	invite := &models.EventInvite{}
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&invite.ID,
+		&invite.EventID,
+		&invite.Name,
+		&invite.Email,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+		&revokedAt,
+		&invite.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+	return invite, nil
+}
@@ -0,0 +1,849 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+var (
+	errDuplicateAvailability = errors.New("availability already submitted to this event")
+	// errUnknownEvent and errUnknownWebhook mirror the foreign key violations of the SQL backends
+	errUnknownEvent   = errors.New("event does not exist")
+	errUnknownWebhook = errors.New("webhook does not exist")
+)
+
+// memoryStore keeps every record in process memory. It implements EventStore, AvailabilityStore,
+// WorkingHoursStore, EventRoleStore, InviteStore, APIKeyStore, CalendarConnectionStore, WebhookStore
+// and ReminderStore with the same observable behaviour as the SQL repositories, and is meant for
+// development and tests.
+type memoryStore struct {
+	mu                sync.RWMutex
+	events            map[string]*models.Event
+	deadlineProcessed map[string]bool
+	availabilities    map[string]*models.ParticipantAvailability
+	workingHours      map[string]*models.WorkingHours
+	eventRoles        map[string]map[string]models.EventRole // Event ID to user ID to role
+	invites           map[string]*models.EventInvite
+	apiKeys           map[string]*models.APIKey
+	calendars         map[string]*models.CalendarConnection
+	webhooks          map[string]*models.Webhook
+	deliveries        map[string]*models.WebhookDelivery
+	reminders         map[reminderKey]models.EventReminder
+}
+
+// reminderKey identifies a reminder sent to one participant
+type reminderKey struct {
+	eventID string
+	offset  time.Duration
+	userID  string
+}
+
+// NewMemoryStore creates a store that keeps every record in process memory
+func NewMemoryStore() *Store {
+	m := &memoryStore{
+		events:            make(map[string]*models.Event),
+		deadlineProcessed: make(map[string]bool),
+		availabilities:    make(map[string]*models.ParticipantAvailability),
+		workingHours:      make(map[string]*models.WorkingHours),
+		eventRoles:        make(map[string]map[string]models.EventRole),
+		invites:           make(map[string]*models.EventInvite),
+		apiKeys:           make(map[string]*models.APIKey),
+		calendars:         make(map[string]*models.CalendarConnection),
+		webhooks:          make(map[string]*models.Webhook),
+		deliveries:        make(map[string]*models.WebhookDelivery),
+		reminders:         make(map[reminderKey]models.EventReminder),
+	}
+	return &Store{
+		Events:         m,
+		Availabilities: m,
+		WorkingHours:   m,
+		EventRoles:     m,
+		Invites:        m,
+		APIKeys:        m,
+		Calendars:      m,
+		Webhooks:       m,
+		Reminders:      m,
+	}
+}
+
+// CreateEvent stores a new event and makes its creator the organizer
+func (m *memoryStore) CreateEvent(event *models.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[event.ID] = copyEvent(event)
+	if event.CreatedBy != "" {
+		m.eventRoles[event.ID] = map[string]models.EventRole{event.CreatedBy: models.EventRoleOrganizer}
+	}
+	return nil
+}
+
+// GetEvent retrieves an event by ID
+func (m *memoryStore) GetEvent(id string) (*models.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	event, ok := m.events[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyEvent(event), nil
+}
+
+// UpdateEvent replaces the details, time slots and participant roles of an event, provided it still
+// has event.Status
+func (m *memoryStore) UpdateEvent(event *models.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.events[event.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Status != event.Status {
+		return ErrStatusChanged
+	}
+
+	updated := copyEvent(stored)
+	update := copyEvent(event)
+	updated.Title = update.Title
+	updated.Description = update.Description
+	updated.Duration = update.Duration
+	updated.TimeSlots = update.TimeSlots
+	updated.Participants = update.Participants
+	updated.Recurrence = update.Recurrence
+	updated.Deadline = update.Deadline
+	updated.Revision++
+	updated.UpdatedAt = time.Now()
+	m.events[event.ID] = updated
+	event.Revision = updated.Revision
+	m.deadlineProcessed[event.ID] = false
+	return nil
+}
+
+// UpdateEventStatus persists an event's lifecycle status and finalization details, provided the event
+// still has the status from
+func (m *memoryStore) UpdateEventStatus(event *models.Event, from models.EventStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.events[event.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Status != from {
+		return ErrStatusChanged
+	}
+
+	updated := copyEvent(stored)
+	update := copyEvent(event)
+	updated.Status = update.Status
+	updated.FinalSlot = update.FinalSlot
+	updated.FinalizedBy = update.FinalizedBy
+	updated.FinalizedAt = update.FinalizedAt
+	updated.Revision++
+	updated.UpdatedAt = update.UpdatedAt
+	m.events[event.ID] = updated
+	event.Revision = updated.Revision
+	return nil
+}
+
+// DeleteEvent deletes an event along with the availability submitted to it
+func (m *memoryStore) DeleteEvent(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.events, id)
+	delete(m.deadlineProcessed, id)
+	delete(m.eventRoles, id)
+	for inviteID, invite := range m.invites {
+		if invite.EventID == id {
+			delete(m.invites, inviteID)
+		}
+	}
+	for availabilityID, availability := range m.availabilities {
+		if availability.EventID == id {
+			delete(m.availabilities, availabilityID)
+		}
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.EventID == id {
+			m.deleteWebhook(webhookID)
+		}
+	}
+	for key := range m.reminders {
+		if key.eventID == id {
+			delete(m.reminders, key)
+		}
+	}
+	return nil
+}
+
+// ListDueEventIDs returns the polling events whose response deadline passed and that were not processed yet
+func (m *memoryStore) ListDueEventIDs(now time.Time) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	due := make([]*models.Event, 0)
+	for _, event := range m.events {
+		if event.Status == models.EventStatusPolling && event.Deadline != nil &&
+			!event.Deadline.After(now) && !m.deadlineProcessed[event.ID] {
+			due = append(due, event)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Deadline.Before(*due[j].Deadline) })
+
+	var ids []string
+	for _, event := range due {
+		ids = append(ids, event.ID)
+	}
+	return ids, nil
+}
+
+// MarkDeadlineProcessed records that an event's response deadline has been handled
+func (m *memoryStore) MarkDeadlineProcessed(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[id]; ok {
+		m.deadlineProcessed[id] = true
+	}
+	return nil
+}
+
+// GetParticipantAvailabilities retrieves all participant availabilities for an event
+func (m *memoryStore) GetParticipantAvailabilities(eventID string) ([]models.ParticipantAvailability, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var availabilities []models.ParticipantAvailability
+	for _, availability := range m.availabilities {
+		if availability.EventID == eventID {
+			availabilities = append(availabilities, *copyAvailability(availability))
+		}
+	}
+	sort.Slice(availabilities, func(i, j int) bool { return availabilities[i].ID < availabilities[j].ID })
+	return availabilities, nil
+}
+
+// CreateAvailability stores a new participant availability
+func (m *memoryStore) CreateAvailability(availability *models.ParticipantAvailability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing := m.availabilityOf(availability.EventID, availability.UserID); existing != nil {
+		return errDuplicateAvailability
+	}
+	m.availabilities[availability.ID] = copyAvailability(availability)
+	m.bumpRevision(availability.EventID)
+	return nil
+}
+
+// CreateGuestResponse stores the first availability a guest submits through an open invite together
+// with the personal invite issued to them
+func (m *memoryStore) CreateGuestResponse(invite *models.EventInvite, availability *models.ParticipantAvailability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[invite.EventID]; !ok {
+		return errUnknownEvent
+	}
+	for _, existing := range m.invites {
+		if existing.EventID == invite.EventID && existing.Email == invite.Email && existing.RevokedAt == nil {
+			return ErrAlreadyInvited
+		}
+	}
+	if existing := m.availabilityOf(availability.EventID, availability.UserID); existing != nil {
+		return errDuplicateAvailability
+	}
+	m.invites[invite.ID] = copyInvite(invite)
+	m.availabilities[availability.ID] = copyAvailability(availability)
+	m.bumpRevision(availability.EventID)
+	return nil
+}
+
+// UpsertAvailability stores the availability a user submitted to an event, replacing the time slots
+// of any availability they submitted before
+func (m *memoryStore) UpsertAvailability(availability *models.ParticipantAvailability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing := m.availabilityOf(availability.EventID, availability.UserID); existing != nil {
+		availability.ID = existing.ID
+		availability.CreatedAt = existing.CreatedAt
+	}
+	m.availabilities[availability.ID] = copyAvailability(availability)
+	m.bumpRevision(availability.EventID)
+	return nil
+}
+
+// GetAvailability retrieves a participant availability by ID
+func (m *memoryStore) GetAvailability(id string) (*models.ParticipantAvailability, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	availability, ok := m.availabilities[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyAvailability(availability), nil
+}
+
+// UpdateAvailability replaces the time slots and source of an existing participant availability
+func (m *memoryStore) UpdateAvailability(availability *models.ParticipantAvailability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.availabilities[availability.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	updated := copyAvailability(stored)
+	updated.TimeSlots = copyAvailability(availability).TimeSlots
+	updated.Source = availability.Source
+	updated.UpdatedAt = availability.UpdatedAt
+	m.availabilities[availability.ID] = updated
+	m.bumpRevision(updated.EventID)
+	return nil
+}
+
+// DeleteAvailability deletes a participant availability
+func (m *memoryStore) DeleteAvailability(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if availability, ok := m.availabilities[id]; ok {
+		m.bumpRevision(availability.EventID)
+	}
+	delete(m.availabilities, id)
+	return nil
+}
+
+// bumpRevision records a change to the availability submitted to an event in the event's revision
+func (m *memoryStore) bumpRevision(eventID string) {
+	if event, ok := m.events[eventID]; ok {
+		event.Revision++
+	}
+}
+
+// availabilityOf returns the availability a user submitted to an event, if any
+func (m *memoryStore) availabilityOf(eventID, userID string) *models.ParticipantAvailability {
+	for _, availability := range m.availabilities {
+		if availability.EventID == eventID && availability.UserID == userID {
+			return availability
+		}
+	}
+	return nil
+}
+
+// UpsertWorkingHours creates or replaces the working hours of a participant
+func (m *memoryStore) UpsertWorkingHours(profile *models.WorkingHours) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *profile
+	stored.WorkDays = append([]int(nil), profile.WorkDays...)
+	m.workingHours[profile.UserID] = &stored
+	return nil
+}
+
+// GetWorkingHours retrieves the working hours of a participant
+func (m *memoryStore) GetWorkingHours(userID string) (*models.WorkingHours, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	profile, ok := m.workingHours[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *profile
+	result.WorkDays = append([]int(nil), profile.WorkDays...)
+	return &result, nil
+}
+
+// GetWorkingHoursForUsers retrieves the working hours of every listed participant that registered them
+func (m *memoryStore) GetWorkingHoursForUsers(userIDs []string) ([]models.WorkingHours, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool, len(userIDs))
+	var profiles []models.WorkingHours
+	for _, userID := range userIDs {
+		profile, ok := m.workingHours[userID]
+		if !ok || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		result := *profile
+		result.WorkDays = append([]int(nil), profile.WorkDays...)
+		profiles = append(profiles, result)
+	}
+	return profiles, nil
+}
+
+// copyEvent deep-copies an event so callers cannot mutate stored records
+// AssignEventRole grants a user a role in an event, replacing the role they held before
+func (m *memoryStore) AssignEventRole(assignment *models.EventRoleAssignment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[assignment.EventID]; !ok {
+		return errUnknownEvent
+	}
+	if m.eventRoles[assignment.EventID] == nil {
+		m.eventRoles[assignment.EventID] = make(map[string]models.EventRole)
+	}
+	m.eventRoles[assignment.EventID][assignment.UserID] = assignment.Role
+	return nil
+}
+
+// GetEventRole retrieves the role a user was granted in an event
+func (m *memoryStore) GetEventRole(eventID, userID string) (models.EventRole, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	role, ok := m.eventRoles[eventID][userID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return role, nil
+}
+
+// ListEventRoles retrieves every role granted in an event, ordered by user
+func (m *memoryStore) ListEventRoles(eventID string) ([]models.EventRoleAssignment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var assignments []models.EventRoleAssignment
+	for userID, role := range m.eventRoles[eventID] {
+		assignments = append(assignments, models.EventRoleAssignment{EventID: eventID, UserID: userID, Role: role})
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].UserID < assignments[j].UserID })
+	return assignments, nil
+}
+
+// DeleteEventRole withdraws the role a user was granted in an event
+func (m *memoryStore) DeleteEventRole(eventID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.eventRoles[eventID][userID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.eventRoles[eventID], userID)
+	return nil
+}
+
+// CreateInvite stores a new invite
+func (m *memoryStore) CreateInvite(invite *models.EventInvite) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[invite.EventID]; !ok {
+		return errUnknownEvent
+	}
+	m.invites[invite.ID] = copyInvite(invite)
+	return nil
+}
+
+// GetInvite retrieves an invite by ID
+func (m *memoryStore) GetInvite(id string) (*models.EventInvite, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	invite, ok := m.invites[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyInvite(invite), nil
+}
+
+// ListInvites retrieves the invites to an event, oldest first
+func (m *memoryStore) ListInvites(eventID string) ([]models.EventInvite, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var invites []models.EventInvite
+	for _, invite := range m.invites {
+		if invite.EventID == eventID {
+			invites = append(invites, *copyInvite(invite))
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool {
+		if !invites[i].CreatedAt.Equal(invites[j].CreatedAt) {
+			return invites[i].CreatedAt.Before(invites[j].CreatedAt)
+		}
+		return invites[i].ID < invites[j].ID
+	})
+	return invites, nil
+}
+
+// RevokeInvite marks an invite as revoked
+func (m *memoryStore) RevokeInvite(id string, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	invite, ok := m.invites[id]
+	if !ok {
+		return ErrNotFound
+	}
+	invite.RevokedAt = &revokedAt
+	return nil
+}
+
+// CreateAPIKey stores a new API key
+func (m *memoryStore) CreateAPIKey(key *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *key
+	m.apiKeys[key.ID] = &stored
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the API key with the given hash
+func (m *memoryStore) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.apiKeys {
+		if key.KeyHash == hash {
+			found := *key
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// DeleteAPIKey deletes an API key
+func (m *memoryStore) DeleteAPIKey(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.apiKeys[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.apiKeys, id)
+	return nil
+}
+
+// UpsertCalendarConnection creates or replaces the calendar connection of a user
+func (m *memoryStore) UpsertCalendarConnection(connection *models.CalendarConnection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *connection
+	stored.LastSyncedAt = nil
+	stored.LastError = ""
+	m.calendars[connection.UserID] = &stored
+	return nil
+}
+
+// GetCalendarConnection retrieves the calendar connection of a user
+func (m *memoryStore) GetCalendarConnection(userID string) (*models.CalendarConnection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	connection, ok := m.calendars[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyCalendarConnection(connection), nil
+}
+
+// DeleteCalendarConnection deletes the calendar connection of a user
+func (m *memoryStore) DeleteCalendarConnection(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.calendars[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.calendars, userID)
+	return nil
+}
+
+// RecordCalendarSync stores the outcome of pulling a user's availability
+func (m *memoryStore) RecordCalendarSync(userID string, syncedAt time.Time, syncErr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	connection, ok := m.calendars[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	connection.LastSyncedAt = &syncedAt
+	connection.LastError = syncErr
+	return nil
+}
+
+// ListCalendarSyncTargets lists the participants with a calendar connection in every event still
+// collecting availability at now
+func (m *memoryStore) ListCalendarSyncTargets(now time.Time) ([]models.CalendarSyncTarget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var targets []models.CalendarSyncTarget
+	for _, event := range m.events {
+		if event.Status != models.EventStatusPolling || (event.Deadline != nil && !event.Deadline.After(now)) {
+			continue
+		}
+		for _, p := range event.Participants {
+			if _, ok := m.calendars[p.UserID]; ok {
+				targets = append(targets, models.CalendarSyncTarget{EventID: event.ID, UserID: p.UserID})
+			}
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].EventID != targets[j].EventID {
+			return targets[i].EventID < targets[j].EventID
+		}
+		return targets[i].UserID < targets[j].UserID
+	})
+	return targets, nil
+}
+
+// CreateWebhook stores a new webhook
+func (m *memoryStore) CreateWebhook(webhook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[webhook.EventID]; webhook.EventID != "" && !ok {
+		return errUnknownEvent
+	}
+	m.webhooks[webhook.ID] = copyWebhook(webhook)
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (m *memoryStore) GetWebhook(id string) (*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyWebhook(webhook), nil
+}
+
+// ListWebhooks retrieves the webhooks subscribed to an event, or the global webhooks when eventID is empty
+func (m *memoryStore) ListWebhooks(eventID string) ([]models.Webhook, error) {
+	return m.listWebhooks(func(webhook *models.Webhook) bool { return webhook.EventID == eventID }), nil
+}
+
+// ListSubscribedWebhooks retrieves every webhook notified of changes to an event: its own and the global ones
+func (m *memoryStore) ListSubscribedWebhooks(eventID string) ([]models.Webhook, error) {
+	return m.listWebhooks(func(webhook *models.Webhook) bool {
+		return webhook.EventID == eventID || webhook.EventID == ""
+	}), nil
+}
+
+func (m *memoryStore) listWebhooks(match func(*models.Webhook) bool) []models.Webhook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var webhooks []models.Webhook
+	for _, webhook := range m.webhooks {
+		if match(webhook) {
+			webhooks = append(webhooks, *copyWebhook(webhook))
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks
+}
+
+// DeleteWebhook deletes a webhook along with its deliveries
+func (m *memoryStore) DeleteWebhook(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[id]; !ok {
+		return ErrNotFound
+	}
+	m.deleteWebhook(id)
+	return nil
+}
+
+// deleteWebhook deletes a webhook and its deliveries, with the lock held
+func (m *memoryStore) deleteWebhook(id string) {
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+}
+
+// CreateWebhookDelivery queues a payload for a webhook
+func (m *memoryStore) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[delivery.WebhookID]; !ok {
+		return errUnknownWebhook
+	}
+	m.deliveries[delivery.ID] = copyWebhookDelivery(delivery)
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt to deliver a payload
+func (m *memoryStore) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.deliveries[delivery.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := copyWebhookDelivery(delivery)
+	updated.WebhookID, updated.ChangeType, updated.Payload, updated.CreatedAt =
+		stored.WebhookID, stored.ChangeType, stored.Payload, stored.CreatedAt
+	m.deliveries[delivery.ID] = updated
+	return nil
+}
+
+// ListDueWebhookDeliveries retrieves up to limit pending deliveries whose next attempt is due, oldest first
+func (m *memoryStore) ListDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var due []models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, *copyWebhookDelivery(delivery))
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// ListWebhookDeliveries retrieves the latest deliveries of a webhook, newest first
+func (m *memoryStore) ListWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, *copyWebhookDelivery(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func copyEvent(event *models.Event) *models.Event {
+	c := *event
+	c.TimeSlots = sortedSlots(event.TimeSlots)
+	c.Participants = append([]models.EventParticipant(nil), event.Participants...)
+	if event.Recurrence != nil {
+		recurrence := *event.Recurrence
+		recurrence.ExDates = append([]time.Time(nil), event.Recurrence.ExDates...)
+		c.Recurrence = &recurrence
+	}
+	if event.Deadline != nil {
+		deadline := *event.Deadline
+		c.Deadline = &deadline
+	}
+	if event.FinalSlot != nil {
+		slot := *event.FinalSlot
+		c.FinalSlot = &slot
+	}
+	if event.FinalizedAt != nil {
+		finalizedAt := *event.FinalizedAt
+		c.FinalizedAt = &finalizedAt
+	}
+	return &c
+}
+
+// copyAvailability deep-copies an availability, storing unset preference levels as plainly available
+func copyAvailability(availability *models.ParticipantAvailability) *models.ParticipantAvailability {
+	c := *availability
+	if availability.Guest != nil {
+		guest := *availability.Guest
+		c.Guest = &guest
+	}
+	c.TimeSlots = sortedSlots(availability.TimeSlots)
+	for i := range c.TimeSlots {
+		c.TimeSlots[i].Preference = preferenceOrDefault(c.TimeSlots[i].Preference)
+	}
+	return &c
+}
+
+func copyInvite(invite *models.EventInvite) *models.EventInvite {
+	c := *invite
+	if invite.RevokedAt != nil {
+		revokedAt := *invite.RevokedAt
+		c.RevokedAt = &revokedAt
+	}
+	return &c
+}
+
+func copyCalendarConnection(connection *models.CalendarConnection) *models.CalendarConnection {
+	c := *connection
+	if connection.LastSyncedAt != nil {
+		lastSyncedAt := *connection.LastSyncedAt
+		c.LastSyncedAt = &lastSyncedAt
+	}
+	return &c
+}
+
+func copyWebhook(webhook *models.Webhook) *models.Webhook {
+	c := *webhook
+	c.ChangeTypes = append([]models.ChangeType(nil), webhook.ChangeTypes...)
+	return &c
+}
+
+func copyWebhookDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	c := *delivery
+	c.Payload = append([]byte(nil), delivery.Payload...)
+	if delivery.DeliveredAt != nil {
+		deliveredAt := *delivery.DeliveredAt
+		c.DeliveredAt = &deliveredAt
+	}
+	return &c
+}
+
+// sortedSlots copies time slots in start time order, the order the SQL repositories read them in
+func sortedSlots(slots []models.TimeSlot) []models.TimeSlot {
+	sorted := append([]models.TimeSlot(nil), slots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+	return sorted
+}
+
+// ListRemindableEventIDs returns the polling events whose response deadline is after now and no later than until
+func (m *memoryStore) ListRemindableEventIDs(now, until time.Time) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var remindable []*models.Event
+	for _, event := range m.events {
+		if event.Status == models.EventStatusPolling && event.Deadline != nil &&
+			event.Deadline.After(now) && !event.Deadline.After(until) {
+			remindable = append(remindable, event)
+		}
+	}
+	sort.Slice(remindable, func(i, j int) bool { return remindable[i].Deadline.Before(*remindable[j].Deadline) })
+
+	var ids []string
+	for _, event := range remindable {
+		ids = append(ids, event.ID)
+	}
+	return ids, nil
+}
+
+// ClaimReminder records a reminder about to be sent, reporting false when it was already recorded
+func (m *memoryStore) ClaimReminder(reminder *models.EventReminder) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[reminder.EventID]; !ok {
+		return false, errUnknownEvent
+	}
+	key := reminderKey{eventID: reminder.EventID, offset: reminder.Offset, userID: reminder.UserID}
+	if _, ok := m.reminders[key]; ok {
+		return false, nil
+	}
+	m.reminders[key] = *reminder
+	return true, nil
+}
+
+// ReleaseReminder forgets a claimed reminder that could not be sent, so it is tried again
+func (m *memoryStore) ReleaseReminder(eventID string, offset time.Duration, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := reminderKey{eventID: eventID, offset: offset, userID: userID}
+	if _, ok := m.reminders[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.reminders, key)
+	return nil
+}
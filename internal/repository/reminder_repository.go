@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// ReminderRepository handles database operations for the reminders sent to participants
+type ReminderRepository struct {
+	db *sql.DB
+}
+
+// NewReminderRepository creates a new instance of ReminderRepository
+func NewReminderRepository(db *sql.DB) *ReminderRepository {
+	return &ReminderRepository{db: db}
+}
+
+// ListRemindableEventIDs returns the polling events whose response deadline is after now and no later than until
+func (r *ReminderRepository) ListRemindableEventIDs(now, until time.Time) ([]string, error) {
+	query := `
+		SELECT id
+		FROM events
+		WHERE status = 'polling' AND response_deadline > $1 AND response_deadline <= $2
+		ORDER BY response_deadline
+	`
+	rows, err := r.db.Query(query, now.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ClaimReminder records a reminder about to be sent, reporting false when it was already recorded
+func (r *ReminderRepository) ClaimReminder(reminder *models.EventReminder) (bool, error) {
+	query := `
+		INSERT INTO event_reminders (event_id, offset_seconds, user_id, sent_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id, offset_seconds, user_id) DO NOTHING
+	`
+	result, err := r.db.Exec(query,
+		reminder.EventID,
+		int64(reminder.Offset/time.Second),
+		reminder.UserID,
+		reminder.SentAt.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	return claimed > 0, err
+}
+
+// ReleaseReminder forgets a claimed reminder that could not be sent, so it is tried again
+func (r *ReminderRepository) ReleaseReminder(eventID string, offset time.Duration, userID string) error {
+	result, err := r.db.Exec(
+		"DELETE FROM event_reminders WHERE event_id = $1 AND offset_seconds = $2 AND user_id = $3",
+		eventID, int64(offset/time.Second), userID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shani34/meeting-scheduler/internal/migrate"
+	"github.com/shani34/meeting-scheduler/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite opens the embedded SQLite database at path, creating it and its schema when needed.
+// The path ":memory:" opens a private in-memory database.
+func OpenSQLite(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", SQLiteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %w", err)
+	}
+	store, err := NewSQLiteStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// SQLiteDSN returns the data source name OpenSQLite uses for the database at path
+func SQLiteDSN(path string) string {
+	// Store timestamps in a format SQLite's date functions and string comparisons understand
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+}
+
+// NewSQLiteStore applies pending schema migrations to an open SQLite database and returns a store backed by it
+func NewSQLiteStore(db *sql.DB) (*Store, error) {
+	// SQLite allows a single writer, and every connection to ":memory:" is a separate database
+	db.SetMaxOpenConns(1)
+
+	migrator, err := NewSQLiteMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrator.Up(); err != nil {
+		return nil, fmt.Errorf("error migrating sqlite schema: %w", err)
+	}
+	return NewSQLStore(db), nil
+}
+
+// NewSQLiteMigrator creates a migrator for the embedded SQLite schema
+func NewSQLiteMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	sqliteMigrations, err := migrate.Load(migrations.SQLite, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, sqliteMigrations), nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+)
+
+// ErrNotFound is returned when a record to read or update does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrStatusChanged is returned when an event is written on the condition that it still has the status
+// it was read with, and its status changed meanwhile
+var ErrStatusChanged = errors.New("event status changed since it was read")
+
+// ErrAlreadyInvited is returned when a personal invite is issued to an email that already has an invite
+// to the event that was not revoked
+var ErrAlreadyInvited = errors.New("email already has an invite to this event")
+
+// EventStore persists events and their lifecycle
+type EventStore interface {
+	CreateEvent(event *models.Event) error
+	GetEvent(id string) (*models.Event, error)
+	UpdateEvent(event *models.Event) error
+	UpdateEventStatus(event *models.Event, from models.EventStatus) error
+	DeleteEvent(id string) error
+	ListDueEventIDs(now time.Time) ([]string, error)
+	MarkDeadlineProcessed(id string) error
+	GetParticipantAvailabilities(eventID string) ([]models.ParticipantAvailability, error)
+}
+
+// AvailabilityStore persists the availability participants submit to events
+type AvailabilityStore interface {
+	CreateAvailability(availability *models.ParticipantAvailability) error
+	UpsertAvailability(availability *models.ParticipantAvailability) error
+	CreateGuestResponse(invite *models.EventInvite, availability *models.ParticipantAvailability) error
+	GetAvailability(id string) (*models.ParticipantAvailability, error)
+	UpdateAvailability(availability *models.ParticipantAvailability) error
+	DeleteAvailability(id string) error
+}
+
+// AvailabilitySource reads the busy time of users from outside the scheduler, such as their
+// calendar server. Busy time is described as iCalendar components, so events and free/busy
+// data from any source are read the same way as uploaded calendars.
+type AvailabilitySource interface {
+	// Busy returns components describing when the user is busy between start and end.
+	// It returns ErrNotFound when the source knows nothing of the user.
+	Busy(ctx context.Context, userID string, start, end time.Time) ([]*ical.Component, error)
+}
+
+// WorkingHoursStore persists participants' working hours
+type WorkingHoursStore interface {
+	UpsertWorkingHours(profile *models.WorkingHours) error
+	GetWorkingHours(userID string) (*models.WorkingHours, error)
+	GetWorkingHoursForUsers(userIDs []string) ([]models.WorkingHours, error)
+}
+
+// EventRoleStore persists the roles users are granted in events
+type EventRoleStore interface {
+	AssignEventRole(assignment *models.EventRoleAssignment) error
+	GetEventRole(eventID, userID string) (models.EventRole, error)
+	ListEventRoles(eventID string) ([]models.EventRoleAssignment, error)
+	DeleteEventRole(eventID, userID string) error
+}
+
+// InviteStore persists the invites guests use to submit availability
+type InviteStore interface {
+	CreateInvite(invite *models.EventInvite) error
+	GetInvite(id string) (*models.EventInvite, error)
+	ListInvites(eventID string) ([]models.EventInvite, error)
+	RevokeInvite(id string, revokedAt time.Time) error
+}
+
+// APIKeyStore persists the hashed API keys users authenticate with
+type APIKeyStore interface {
+	CreateAPIKey(key *models.APIKey) error
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	DeleteAPIKey(id string) error
+}
+
+// CalendarConnectionStore persists the calendars participants' availability is pulled from
+type CalendarConnectionStore interface {
+	UpsertCalendarConnection(connection *models.CalendarConnection) error
+	GetCalendarConnection(userID string) (*models.CalendarConnection, error)
+	DeleteCalendarConnection(userID string) error
+	RecordCalendarSync(userID string, syncedAt time.Time, syncErr string) error
+	ListCalendarSyncTargets(now time.Time) ([]models.CalendarSyncTarget, error)
+}
+
+// WebhookStore persists webhook subscriptions and the queue of payloads delivered to them
+type WebhookStore interface {
+	CreateWebhook(webhook *models.Webhook) error
+	GetWebhook(id string) (*models.Webhook, error)
+	ListWebhooks(eventID string) ([]models.Webhook, error)
+	ListSubscribedWebhooks(eventID string) ([]models.Webhook, error)
+	DeleteWebhook(id string) error
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) error
+	UpdateWebhookDelivery(delivery *models.WebhookDelivery) error
+	ListDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ListWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error)
+}
+
+// ReminderStore tracks the reminders sent to participants who have not responded
+type ReminderStore interface {
+	// ListRemindableEventIDs returns the polling events whose response deadline is after now and no later than until
+	ListRemindableEventIDs(now, until time.Time) ([]string, error)
+	// ClaimReminder records a reminder about to be sent, reporting false when it was already recorded
+	ClaimReminder(reminder *models.EventReminder) (bool, error)
+	// ReleaseReminder forgets a claimed reminder that could not be sent, so it is tried again
+	ReleaseReminder(eventID string, offset time.Duration, userID string) error
+}
+
+// Store bundles the repositories of one storage backend
+type Store struct {
+	Events         EventStore
+	Availabilities AvailabilityStore
+	WorkingHours   WorkingHoursStore
+	EventRoles     EventRoleStore
+	Invites        InviteStore
+	APIKeys        APIKeyStore
+	Calendars      CalendarConnectionStore
+	Webhooks       WebhookStore
+	Reminders      ReminderStore
+
+	close func() error
+}
+
+// NewSQLStore creates a store backed by the SQL repositories on db
+func NewSQLStore(db *sql.DB) *Store {
+	return &Store{
+		Events:         NewEventRepository(db),
+		Availabilities: NewAvailabilityRepository(db),
+		WorkingHours:   NewWorkingHoursRepository(db),
+		EventRoles:     NewEventRoleRepository(db),
+		Invites:        NewInviteRepository(db),
+		APIKeys:        NewAPIKeyRepository(db),
+		Calendars:      NewCalendarConnectionRepository(db),
+		Webhooks:       NewWebhookRepository(db),
+		Reminders:      NewReminderRepository(db),
+		close:          db.Close,
+	}
+}
+
+// Close releases the resources held by the store
+func (s *Store) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// notFound translates missing rows into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// requireAffected returns ErrNotFound when a write matched no rows
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
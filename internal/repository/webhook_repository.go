@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// WebhookRepository handles database operations for webhooks and their deliveries
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookColumns = "id, event_id, url, change_types, secret, created_by, created_at"
+
+// CreateWebhook creates a new webhook in the database
+func (r *WebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, event_id, url, change_types, secret, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.Exec(query,
+		webhook.ID,
+		nullString(webhook.EventID),
+		webhook.URL,
+		formatChangeTypes(webhook.ChangeTypes),
+		webhook.Secret,
+		webhook.CreatedBy,
+		webhook.CreatedAt,
+	)
+	return err
+}
+
+// GetWebhook retrieves a webhook by ID
+func (r *WebhookRepository) GetWebhook(id string) (*models.Webhook, error) {
+	webhook, err := scanWebhook(r.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return webhook, nil
+}
+
+// ListWebhooks retrieves the webhooks subscribed to an event, or the global webhooks when eventID is empty
+func (r *WebhookRepository) ListWebhooks(eventID string) ([]models.Webhook, error) {
+	if eventID == "" {
+		return r.queryWebhooks("SELECT " + webhookColumns + " FROM webhooks WHERE event_id IS NULL ORDER BY created_at, id")
+	}
+	return r.queryWebhooks("SELECT "+webhookColumns+" FROM webhooks WHERE event_id = $1 ORDER BY created_at, id", eventID)
+}
+
+// ListSubscribedWebhooks retrieves every webhook notified of changes to an event: its own and the global ones
+func (r *WebhookRepository) ListSubscribedWebhooks(eventID string) ([]models.Webhook, error) {
+	return r.queryWebhooks("SELECT "+webhookColumns+" FROM webhooks WHERE event_id = $1 OR event_id IS NULL ORDER BY created_at, id", eventID)
+}
+
+func (r *WebhookRepository) queryWebhooks(query string, args ...any) ([]models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook deletes a webhook along with its deliveries
+func (r *WebhookRepository) DeleteWebhook(id string) error {
+	result, err := r.db.Exec("DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+const webhookDeliveryColumns = `id, webhook_id, change_type, payload, status, attempts, next_attempt_at,
+	response_status, last_error, created_at, delivered_at`
+
+// CreateWebhookDelivery queues a payload for a webhook
+func (r *WebhookRepository) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (` + webhookDeliveryColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err := r.db.Exec(query,
+		delivery.ID,
+		delivery.WebhookID,
+		delivery.ChangeType,
+		string(delivery.Payload),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.CreatedAt,
+		nullTime(delivery.DeliveredAt),
+	)
+	return err
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt to deliver a payload
+func (r *WebhookRepository) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, next_attempt_at = $3, response_status = $4, last_error = $5, delivered_at = $6
+		WHERE id = $7
+	`
+	result, err := r.db.Exec(query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt.UTC(),
+		delivery.ResponseStatus,
+		delivery.LastError,
+		nullTime(delivery.DeliveredAt),
+		delivery.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// ListDueWebhookDeliveries retrieves up to limit pending deliveries whose next attempt is due, oldest first
+func (r *WebhookRepository) ListDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at, created_at
+		LIMIT $2
+	`
+	return r.queryDeliveries(query, now.UTC(), limit)
+}
+
+// ListWebhookDeliveries retrieves the latest deliveries of a webhook, newest first
+func (r *WebhookRepository) ListWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2
+	`
+	return r.queryDeliveries(query, webhookID, limit)
+}
+
+func (r *WebhookRepository) queryDeliveries(query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		delivery := models.WebhookDelivery{}
+		var payload string
+		var deliveredAt sql.NullTime
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.ChangeType,
+			&payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&deliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		delivery.Payload = []byte(payload)
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// scanWebhook reads a webhook from a row
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var eventID sql.NullString
+	var changeTypes string
+	err := row.Scan(
+		&webhook.ID,
+		&eventID,
+		&webhook.URL,
+		&changeTypes,
+		&webhook.Secret,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	webhook.EventID = eventID.String
+	webhook.ChangeTypes = parseChangeTypes(changeTypes)
+	return webhook, nil
+}
+
+// formatChangeTypes stores change types as a comma-separated list
+func formatChangeTypes(types []models.ChangeType) string {
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = string(t)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseChangeTypes reads a list stored by formatChangeTypes
+func parseChangeTypes(value string) []models.ChangeType {
+	if value == "" {
+		return nil
+	}
+	var types []models.ChangeType
+	for _, part := range strings.Split(value, ",") {
+		types = append(types, models.ChangeType(part))
+	}
+	return types
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+)
+
+// WorkingHoursRepository handles database operations for participant working hours
+type WorkingHoursRepository struct {
+	db *sql.DB
+}
+
+// NewWorkingHoursRepository creates a new instance of WorkingHoursRepository
+func NewWorkingHoursRepository(db *sql.DB) *WorkingHoursRepository {
+	return &WorkingHoursRepository{db: db}
+}
+
+// UpsertWorkingHours creates or replaces the working hours of a participant
+func (r *WorkingHoursRepository) UpsertWorkingHours(profile *models.WorkingHours) error {
+	query := `
+		INSERT INTO working_hours (user_id, time_zone, start_time, end_time, work_days, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET time_zone = $2, start_time = $3, end_time = $4, work_days = $5, updated_at = $6
+	`
+	_, err := r.db.Exec(query,
+		profile.UserID,
+		profile.TimeZone,
+		profile.StartTime,
+		profile.EndTime,
+		formatWorkDays(profile.WorkDays),
+		profile.UpdatedAt,
+	)
+	return err
+}
+
+// GetWorkingHours retrieves the working hours of a participant
+func (r *WorkingHoursRepository) GetWorkingHours(userID string) (*models.WorkingHours, error) {
+	query := `
+		SELECT user_id, time_zone, start_time, end_time, work_days, updated_at
+		FROM working_hours
+		WHERE user_id = $1
+	`
+	profile, err := scanWorkingHours(r.db.QueryRow(query, userID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return profile, nil
+}
+
+// GetWorkingHoursForUsers retrieves the working hours of every listed participant that registered them
+func (r *WorkingHoursRepository) GetWorkingHoursForUsers(userIDs []string) ([]models.WorkingHours, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	// Build an IN list rather than binding an array, so every SQL backend can run the query
+	placeholders := make([]string, len(userIDs))
+	args := make([]any, len(userIDs))
+	for i, userID := range userIDs {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = userID
+	}
+	query := `
+		SELECT user_id, time_zone, start_time, end_time, work_days, updated_at
+		FROM working_hours
+		WHERE user_id IN (` + strings.Join(placeholders, ", ") + `)
+	`
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.WorkingHours
+	for rows.Next() {
+		profile, err := scanWorkingHours(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWorkingHours(row scanner) (*models.WorkingHours, error) {
+	profile := &models.WorkingHours{}
+	var workDays string
+	err := row.Scan(
+		&profile.UserID,
+		&profile.TimeZone,
+		&profile.StartTime,
+		&profile.EndTime,
+		&workDays,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	profile.WorkDays = parseWorkDays(workDays)
+	return profile, nil
+}
+
+func formatWorkDays(days []int) string {
+	parts := make([]string, len(days))
+	for i, day := range days {
+		parts[i] = strconv.Itoa(day)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseWorkDays(value string) []int {
+	days := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		if day, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			days = append(days, day)
+		}
+	}
+	return days
+}
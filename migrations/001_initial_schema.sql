@@ -42,4 +42,10 @@ CREATE TABLE IF NOT EXISTS availability_time_slots (
 -- Create indexes for better query performance
 CREATE INDEX IF NOT EXISTS idx_event_time_slots_event_id ON event_time_slots(event_id);
 CREATE INDEX IF NOT EXISTS idx_participant_availabilities_event_id ON participant_availabilities(event_id);
-CREATE INDEX IF NOT EXISTS idx_availability_time_slots_availability_id ON availability_time_slots(availability_id); 
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_availability_time_slots_availability_id ON availability_time_slots(availability_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS availability_time_slots;
+DROP TABLE IF EXISTS participant_availabilities;
+DROP TABLE IF EXISTS event_time_slots;
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,12 @@
+-- Create event_participants table
+CREATE TABLE IF NOT EXISTS event_participants (
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS event_participants;
@@ -0,0 +1,12 @@
+-- Create working_hours table
+CREATE TABLE IF NOT EXISTS working_hours (
+    user_id VARCHAR(36) PRIMARY KEY,
+    time_zone VARCHAR(50) NOT NULL,
+    start_time VARCHAR(5) NOT NULL, -- Local HH:MM
+    end_time VARCHAR(5) NOT NULL, -- Local HH:MM
+    work_days VARCHAR(20) NOT NULL, -- Comma separated weekdays, 0 = Sunday
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS working_hours;
@@ -0,0 +1,6 @@
+-- Add preference levels to availability_time_slots
+ALTER TABLE availability_time_slots
+    ADD COLUMN IF NOT EXISTS preference VARCHAR(20) NOT NULL DEFAULT 'available';
+
+-- migrate:down
+ALTER TABLE availability_time_slots DROP COLUMN IF EXISTS preference;
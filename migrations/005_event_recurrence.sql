@@ -0,0 +1,9 @@
+-- Add RFC 5545 recurrence to events
+ALTER TABLE events ADD COLUMN IF NOT EXISTS rrule TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS recurrence_time_zone VARCHAR(50);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS exdates TEXT; -- Comma separated RFC 3339 timestamps
+
+-- migrate:down
+ALTER TABLE events DROP COLUMN IF EXISTS rrule;
+ALTER TABLE events DROP COLUMN IF EXISTS recurrence_time_zone;
+ALTER TABLE events DROP COLUMN IF EXISTS exdates;
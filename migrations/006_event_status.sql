@@ -0,0 +1,15 @@
+-- Add lifecycle status and finalization details to events
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'polling';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS final_start_time TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS final_end_time TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS final_time_zone VARCHAR(50);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS finalized_by VARCHAR(36);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS finalized_at TIMESTAMP;
+
+-- migrate:down
+ALTER TABLE events DROP COLUMN IF EXISTS status;
+ALTER TABLE events DROP COLUMN IF EXISTS final_start_time;
+ALTER TABLE events DROP COLUMN IF EXISTS final_end_time;
+ALTER TABLE events DROP COLUMN IF EXISTS final_time_zone;
+ALTER TABLE events DROP COLUMN IF EXISTS finalized_by;
+ALTER TABLE events DROP COLUMN IF EXISTS finalized_at;
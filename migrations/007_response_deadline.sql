@@ -0,0 +1,10 @@
+-- Add availability response deadlines to events
+ALTER TABLE events ADD COLUMN IF NOT EXISTS response_deadline TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deadline_processed BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_events_response_deadline ON events(response_deadline) WHERE status = 'polling';
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_events_response_deadline;
+ALTER TABLE events DROP COLUMN IF EXISTS response_deadline;
+ALTER TABLE events DROP COLUMN IF EXISTS deadline_processed;
@@ -0,0 +1,13 @@
+-- Keep only the latest availability each user submitted to an event
+DELETE FROM participant_availabilities older
+USING participant_availabilities newer
+WHERE older.event_id = newer.event_id
+  AND older.user_id = newer.user_id
+  AND (older.updated_at, older.id) < (newer.updated_at, newer.id);
+
+-- Allow a single availability per user and event so resubmissions replace it
+CREATE UNIQUE INDEX IF NOT EXISTS idx_participant_availabilities_event_user
+    ON participant_availabilities(event_id, user_id);
+
+-- migrate:down
+DROP INDEX IF EXISTS idx_participant_availabilities_event_user;
@@ -0,0 +1,13 @@
+-- Store hashed API keys for authenticating callers without a token
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    key_hash VARCHAR(64) NOT NULL UNIQUE, -- Hex-encoded SHA-256 of the key
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+-- migrate:down
+DROP TABLE IF EXISTS api_keys;
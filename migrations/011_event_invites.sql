@@ -0,0 +1,25 @@
+-- Store invites that let guests without an account submit availability
+CREATE TABLE IF NOT EXISTS event_invites (
+    id VARCHAR(36) PRIMARY KEY,
+    event_id VARCHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '', -- Empty for open invites
+    created_by VARCHAR(36) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_invites_event_id ON event_invites(event_id);
+
+-- Record which guest submitted an availability through which invite
+ALTER TABLE participant_availabilities ADD COLUMN invite_id VARCHAR(36);
+ALTER TABLE participant_availabilities ADD COLUMN guest_name VARCHAR(255);
+ALTER TABLE participant_availabilities ADD COLUMN guest_email VARCHAR(255);
+
+-- migrate:down
+ALTER TABLE participant_availabilities DROP COLUMN guest_email;
+ALTER TABLE participant_availabilities DROP COLUMN guest_name;
+ALTER TABLE participant_availabilities DROP COLUMN invite_id;
+DROP TABLE IF EXISTS event_invites;
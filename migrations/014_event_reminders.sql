@@ -0,0 +1,12 @@
+-- Record the reminders sent to participants who had not responded, so none is sent twice
+CREATE TABLE IF NOT EXISTS event_reminders (
+    event_id VARCHAR(36) NOT NULL,
+    offset_seconds BIGINT NOT NULL, -- How long before the response deadline the reminder was due
+    user_id VARCHAR(255) NOT NULL,
+    sent_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, offset_seconds, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS event_reminders;
@@ -0,0 +1,10 @@
+-- Tell participants without a weight, who count by the default weight, from those weighted 0, who do not count.
+-- Weights of 0 stored so far were defaults.
+ALTER TABLE event_participants ALTER COLUMN weight DROP NOT NULL;
+ALTER TABLE event_participants ALTER COLUMN weight DROP DEFAULT;
+UPDATE event_participants SET weight = NULL WHERE weight = 0;
+
+-- migrate:down
+UPDATE event_participants SET weight = 1 WHERE weight IS NULL;
+ALTER TABLE event_participants ALTER COLUMN weight SET DEFAULT 1;
+ALTER TABLE event_participants ALTER COLUMN weight SET NOT NULL;
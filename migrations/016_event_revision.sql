@@ -0,0 +1,10 @@
+-- Count the changes to each event and the availability submitted to it, numbering calendar exports.
+-- Existing events start above the SEQUENCE exported so far, the seconds from their creation to their last change.
+ALTER TABLE events ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+UPDATE events e SET revision = 1 + GREATEST(0, FLOOR(EXTRACT(EPOCH FROM (
+    GREATEST(e.updated_at, COALESCE(
+        (SELECT MAX(pa.updated_at) FROM participant_availabilities pa WHERE pa.event_id = e.id), e.updated_at))
+    - e.created_at))))::INTEGER;
+
+-- migrate:down
+ALTER TABLE events DROP COLUMN revision;
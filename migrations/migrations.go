@@ -0,0 +1,14 @@
+// Package migrations embeds the schema migrations of the SQL storage backends
+package migrations
+
+import "embed"
+
+// Postgres holds the PostgreSQL migrations
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+// SQLite holds the migrations of the embedded SQLite backend, in the sqlite directory
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
@@ -0,0 +1,82 @@
+-- Schema of the embedded SQLite backend, equivalent to the PostgreSQL migrations
+CREATE TABLE IF NOT EXISTS events (
+    id VARCHAR(36) PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    duration INTEGER NOT NULL, -- Duration in minutes
+    rrule TEXT,
+    recurrence_time_zone VARCHAR(50),
+    exdates TEXT, -- Comma separated RFC 3339 timestamps
+    status VARCHAR(20) NOT NULL DEFAULT 'polling',
+    final_start_time TIMESTAMP,
+    final_end_time TIMESTAMP,
+    final_time_zone VARCHAR(50),
+    finalized_by VARCHAR(36),
+    finalized_at TIMESTAMP,
+    response_deadline TIMESTAMP,
+    deadline_processed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS event_time_slots (
+    id VARCHAR(36) PRIMARY KEY,
+    event_id VARCHAR(36) NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    time_zone VARCHAR(50) NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS event_participants (
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS participant_availabilities (
+    id VARCHAR(36) PRIMARY KEY,
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS availability_time_slots (
+    id VARCHAR(36) PRIMARY KEY,
+    availability_id VARCHAR(36) NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    time_zone VARCHAR(50) NOT NULL,
+    preference VARCHAR(20) NOT NULL DEFAULT 'available',
+    FOREIGN KEY (availability_id) REFERENCES participant_availabilities(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS working_hours (
+    user_id VARCHAR(36) PRIMARY KEY,
+    time_zone VARCHAR(50) NOT NULL,
+    start_time VARCHAR(5) NOT NULL, -- Local HH:MM
+    end_time VARCHAR(5) NOT NULL, -- Local HH:MM
+    work_days VARCHAR(20) NOT NULL, -- Comma separated weekdays, 0 = Sunday
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_time_slots_event_id ON event_time_slots(event_id);
+CREATE INDEX IF NOT EXISTS idx_participant_availabilities_event_id ON participant_availabilities(event_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_participant_availabilities_event_user
+    ON participant_availabilities(event_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_availability_time_slots_availability_id ON availability_time_slots(availability_id);
+CREATE INDEX IF NOT EXISTS idx_events_response_deadline ON events(response_deadline) WHERE status = 'polling';
+
+-- migrate:down
+DROP TABLE IF EXISTS working_hours;
+DROP TABLE IF EXISTS availability_time_slots;
+DROP TABLE IF EXISTS participant_availabilities;
+DROP TABLE IF EXISTS event_participants;
+DROP TABLE IF EXISTS event_time_slots;
+DROP TABLE IF EXISTS events;
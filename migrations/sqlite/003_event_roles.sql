@@ -0,0 +1,16 @@
+-- Store the roles users hold in events
+CREATE TABLE IF NOT EXISTS event_roles (
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role VARCHAR(20) NOT NULL, -- organizer, co_organizer, participant or viewer
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+-- Existing events are organized by the user who created them
+INSERT INTO event_roles (event_id, user_id, role)
+SELECT id, created_by, 'organizer' FROM events WHERE created_by IS NOT NULL AND created_by <> ''
+ON CONFLICT (event_id, user_id) DO NOTHING;
+
+-- migrate:down
+DROP TABLE IF EXISTS event_roles;
@@ -0,0 +1,13 @@
+-- Store the CalDAV servers participants' availability is pulled from
+CREATE TABLE IF NOT EXISTS calendar_connections (
+    user_id VARCHAR(36) PRIMARY KEY,
+    url TEXT NOT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    secret TEXT NOT NULL DEFAULT '', -- Password sealed with the configured credentials key
+    last_synced_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- migrate:down
+DROP TABLE IF EXISTS calendar_connections;
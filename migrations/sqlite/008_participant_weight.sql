@@ -0,0 +1,28 @@
+-- Tell participants without a weight, who count by the default weight, from those weighted 0, who do not count.
+-- Weights of 0 stored so far were defaults. SQLite cannot drop NOT NULL, so the table is rebuilt.
+CREATE TABLE event_participants_new (
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    weight DOUBLE PRECISION,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+INSERT INTO event_participants_new (event_id, user_id, required, weight)
+    SELECT event_id, user_id, required, NULLIF(weight, 0) FROM event_participants;
+DROP TABLE event_participants;
+ALTER TABLE event_participants_new RENAME TO event_participants;
+
+-- migrate:down
+CREATE TABLE event_participants_old (
+    event_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+    PRIMARY KEY (event_id, user_id),
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+INSERT INTO event_participants_old (event_id, user_id, required, weight)
+    SELECT event_id, user_id, required, COALESCE(weight, 1) FROM event_participants;
+DROP TABLE event_participants;
+ALTER TABLE event_participants_old RENAME TO event_participants;
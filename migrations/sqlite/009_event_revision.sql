@@ -0,0 +1,9 @@
+-- Count the changes to each event and the availability submitted to it, numbering calendar exports.
+-- Existing events start above the SEQUENCE exported so far, the seconds from their creation to their last change.
+ALTER TABLE events ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+UPDATE events SET revision = 1 + COALESCE(MAX(0, CAST((MAX(julianday(updated_at), COALESCE(
+        (SELECT MAX(julianday(pa.updated_at)) FROM participant_availabilities pa WHERE pa.event_id = events.id), 0))
+    - julianday(created_at)) * 86400 AS INTEGER)), 0);
+
+-- migrate:down
+ALTER TABLE events DROP COLUMN revision;
@@ -0,0 +1,8 @@
+-- Record where each availability was last submitted from, so calendar syncs leave availability entered by hand alone.
+-- Calendar syncs replaced the availability of every user with a connected calendar so far.
+ALTER TABLE participant_availabilities ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT '';
+UPDATE participant_availabilities SET source = 'calendar'
+    WHERE user_id IN (SELECT user_id FROM calendar_connections);
+
+-- migrate:down
+ALTER TABLE participant_availabilities DROP COLUMN source;
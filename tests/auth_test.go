@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHMACSecret = []byte("test-signing-secret")
+
+// newAuthRouter serves the authenticated user ID on /whoami
+func newAuthRouter(t *testing.T, opts middleware.JWTOptions, keys *services.APIKeyService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	jwtAuthenticator, err := middleware.NewJWTAuthenticator(opts)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(middleware.Authenticate(jwtAuthenticator, middleware.NewAPIKeyAuthenticator(keys)))
+	router.GET("/whoami", func(c *gin.Context) {
+		principal, ok := middleware.PrincipalFrom(c)
+		require.True(t, ok)
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "method": principal.Method})
+	})
+	return router
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func whoami(router *gin.Engine, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func validClaims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://id.example.com",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	router := newAuthRouter(t, middleware.JWTOptions{
+		HMACSecret:   testHMACSecret,
+		RSAPublicKey: &rsaKey.PublicKey,
+		Issuer:       "https://id.example.com",
+	}, services.NewAPIKeyService(repository.NewMemoryStore().APIKeys))
+
+	recorder := whoami(router, "Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, testHMACSecret, validClaims("alice")))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"user_id": "alice", "method": "jwt"}`, recorder.Body.String())
+
+	recorder = whoami(router, "Authorization", "Bearer "+signToken(t, jwt.SigningMethodRS256, rsaKey, validClaims("bob")))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"user_id": "bob", "method": "jwt"}`, recorder.Body.String())
+
+	expired := validClaims("alice")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := validClaims("alice")
+	wrongIssuer.Issuer = "https://evil.example.com"
+	noSubject := validClaims("")
+
+	rejected := map[string]string{
+		"expired":       signToken(t, jwt.SigningMethodHS256, testHMACSecret, expired),
+		"wrong issuer":  signToken(t, jwt.SigningMethodHS256, testHMACSecret, wrongIssuer),
+		"no subject":    signToken(t, jwt.SigningMethodHS256, testHMACSecret, noSubject),
+		"wrong secret":  signToken(t, jwt.SigningMethodHS256, []byte("guessed"), validClaims("alice")),
+		"unsigned":      signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims("alice")),
+		"not a token":   "garbage",
+		"HS512 no keys": signToken(t, jwt.SigningMethodHS512, testHMACSecret, validClaims("alice")),
+	}
+	for name, token := range rejected {
+		recorder := whoami(router, "Authorization", "Bearer "+token)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, name)
+		assert.Contains(t, recorder.Body.String(), "Invalid token", name)
+	}
+}
+
+func TestJWTAuthenticatorRejectsRS256WithOnlyHMACSecret(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	router := newAuthRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret},
+		services.NewAPIKeyService(repository.NewMemoryStore().APIKeys))
+
+	recorder := whoami(router, "Authorization", "Bearer "+signToken(t, jwt.SigningMethodRS256, rsaKey, validClaims("alice")))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	_, err = middleware.NewJWTAuthenticator(middleware.JWTOptions{})
+	assert.Error(t, err, "an authenticator without keys accepts nothing")
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	keys := services.NewAPIKeyService(repository.NewMemoryStore().APIKeys)
+	router := newAuthRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret}, keys)
+
+	key, raw, err := keys.Create("carol", "CI")
+	require.NoError(t, err)
+	assert.NotContains(t, key.KeyHash, raw, "only the hash is stored")
+
+	recorder := whoami(router, middleware.APIKeyHeader, raw)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"user_id": "carol", "method": "api_key"}`, recorder.Body.String())
+
+	recorder = whoami(router, middleware.APIKeyHeader, raw+"x")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Invalid API key")
+
+	require.NoError(t, keys.Revoke(key.ID))
+	assert.Equal(t, http.StatusUnauthorized, whoami(router, middleware.APIKeyHeader, raw).Code)
+	assert.ErrorIs(t, keys.Revoke(key.ID), services.ErrAPIKeyNotFound)
+}
+
+func TestAuthenticationRequired(t *testing.T) {
+	router := newAuthRouter(t, middleware.JWTOptions{HMACSecret: testHMACSecret},
+		services.NewAPIKeyService(repository.NewMemoryStore().APIKeys))
+
+	recorder := whoami(router, "", "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Authentication required")
+	assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+
+	// Other authorization schemes are not tokens
+	assert.Equal(t, http.StatusUnauthorized, whoami(router, "Authorization", "Basic YWxpY2U6c2VjcmV0").Code)
+}
+
+// doWithHeader sends a JSON request carrying one header, such as the caller's credentials
+func doWithHeader(t *testing.T, router *gin.Engine, method, path, header, value string, body interface{}, out interface{}) int {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if out != nil && recorder.Code < 300 {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), out))
+	}
+	return recorder.Code
+}
+
+func TestEventRoutesRefuseAnonymousCallers(t *testing.T) {
+	store := repository.NewMemoryStore()
+	jwtAuthenticator, err := middleware.NewJWTAuthenticator(middleware.JWTOptions{HMACSecret: testHMACSecret})
+	require.NoError(t, err)
+	// The middleware the server installs with authentication enabled and disabled
+	enabled := newRouterWith(store, func(invites middleware.Authenticator) []gin.HandlerFunc {
+		return []gin.HandlerFunc{middleware.Authenticate(jwtAuthenticator, invites)}
+	})
+	disabled := newRouterWith(store, func(invites middleware.Authenticator) []gin.HandlerFunc {
+		return []gin.HandlerFunc{middleware.AuthenticateOptional(invites)}
+	})
+	bearer := "Bearer " + signToken(t, jwt.SigningMethodHS256, testHMACSecret, validClaims("alice"))
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+	create := models.CreateEventRequest{
+		Title:        "Launch",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob"}},
+	}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doWithHeader(t, enabled, http.MethodPost, "/events", "Authorization", bearer, create, &event))
+	assert.Equal(t, "alice", event.CreatedBy)
+	var invite models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doWithHeader(t, enabled, http.MethodPost, "/events/"+event.ID+"/invites",
+		"Authorization", bearer, models.CreateInviteRequest{Email: "gina@example.com"}, &invite))
+
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPost, "/events", create},
+		{http.MethodGet, "/events?id=" + event.ID, nil},
+		{http.MethodPut, "/events?id=" + event.ID, models.UpdateEventRequest{Title: "Mine now", Duration: 60, TimeSlots: window}},
+		{http.MethodDelete, "/events?id=" + event.ID, nil},
+		{http.MethodPost, "/events/" + event.ID + "/finalize", models.FinalizeEventRequest{StartTime: start}},
+		{http.MethodPut, "/events/" + event.ID + "/roles/mallory", models.AssignEventRoleRequest{Role: models.EventRoleCoOrganizer}},
+		{http.MethodPost, "/events/" + event.ID + "/invites", models.CreateInviteRequest{}},
+		{http.MethodGet, "/events/" + event.ID + "/availabilities", nil},
+		{http.MethodPost, "/availabilities", models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}},
+		{http.MethodGet, "/working-hours/alice", nil},
+		{http.MethodPut, "/working-hours/alice", models.UpdateWorkingHoursRequest{TimeZone: "UTC"}},
+		{http.MethodGet, "/calendar-connections/alice", nil},
+	}
+	for name, router := range map[string]*gin.Engine{"enabled": enabled, "disabled": disabled} {
+		for _, r := range requests {
+			assert.Equal(t, http.StatusUnauthorized, doWithHeader(t, router, r.method, r.path, "", "", r.body, nil),
+				"%s %s with authentication %s", r.method, r.path, name)
+		}
+	}
+
+	// Guests holding an invite still take part with authentication disabled, but organize nothing
+	assert.Equal(t, http.StatusCreated, doGuest(t, disabled, http.MethodPost, "/availabilities", invite.Token,
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusForbidden, doGuest(t, disabled, http.MethodPost, "/events", invite.Token, create, nil))
+
+	var stored models.Event
+	require.Equal(t, http.StatusOK, doWithHeader(t, enabled, http.MethodGet, "/events?id="+event.ID, "Authorization", bearer, nil, &stored))
+	assert.Equal(t, "Launch", stored.Title)
+}
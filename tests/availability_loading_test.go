@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingConnector opens SQLite connections that count the queries run through them
+type countingConnector struct {
+	driver  driver.Driver
+	dsn     string
+	queries *atomic.Int64
+}
+
+func (c countingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{Conn: conn, queries: c.queries}, nil
+}
+
+func (c countingConnector) Driver() driver.Driver { return c.driver }
+
+type countingConn struct {
+	driver.Conn
+	queries *atomic.Int64
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.queries.Add(1)
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func (c countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+// newCountingSQLiteStore opens a SQLite store and returns the counter of queries it runs
+func newCountingSQLiteStore(tb testing.TB) (*repository.Store, *atomic.Int64) {
+	base, err := sql.Open("sqlite", "")
+	require.NoError(tb, err)
+	defer base.Close()
+
+	queries := &atomic.Int64{}
+	db := sql.OpenDB(countingConnector{
+		driver:  base.Driver(),
+		dsn:     repository.SQLiteDSN(filepath.Join(tb.TempDir(), "loading.db")),
+		queries: queries,
+	})
+	store, err := repository.NewSQLiteStore(db)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { store.Close() })
+	return store, queries
+}
+
+// seedAvailabilities creates an event with the given number of respondents, each free in three slots
+func seedAvailabilities(tb testing.TB, store *repository.Store, participants int) string {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	event := &models.Event{
+		ID:        fmt.Sprintf("all-hands-%d", participants),
+		Title:     "All hands",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{{StartTime: start, EndTime: start.Add(8 * time.Hour), TimeZone: "UTC"}},
+		Status:    models.EventStatusPolling,
+		CreatedAt: start,
+		UpdatedAt: start,
+	}
+	require.NoError(tb, store.Events.CreateEvent(event))
+
+	for i := 0; i < participants; i++ {
+		availability := &models.ParticipantAvailability{
+			ID:        fmt.Sprintf("%s-availability-%03d", event.ID, i),
+			EventID:   event.ID,
+			UserID:    fmt.Sprintf("user-%03d", i),
+			CreatedAt: start,
+			UpdatedAt: start,
+		}
+		for hour := 0; hour < 3; hour++ {
+			slotStart := start.Add(time.Duration(i%4+hour*2) * time.Hour)
+			availability.TimeSlots = append(availability.TimeSlots, models.TimeSlot{
+				StartTime: slotStart,
+				EndTime:   slotStart.Add(time.Hour),
+				TimeZone:  "UTC",
+			})
+		}
+		require.NoError(tb, store.Availabilities.CreateAvailability(availability))
+	}
+	return event.ID
+}
+
+func TestGetParticipantAvailabilitiesRunsOneQuery(t *testing.T) {
+	for _, participants := range []int{1, 10, 200} {
+		store, queries := newCountingSQLiteStore(t)
+		eventID := seedAvailabilities(t, store, participants)
+
+		queries.Store(0)
+		availabilities, err := store.Events.GetParticipantAvailabilities(eventID)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(1), queries.Load(), "%d participants", participants)
+		require.Len(t, availabilities, participants)
+		for _, availability := range availabilities {
+			assert.Len(t, availability.TimeSlots, 3)
+		}
+	}
+}
+
+func TestGetParticipantAvailabilitiesKeepsRespondentsWithoutSlots(t *testing.T) {
+	store, _ := newCountingSQLiteStore(t)
+	eventID := seedAvailabilities(t, store, 2)
+	require.NoError(t, store.Availabilities.UpsertAvailability(&models.ParticipantAvailability{
+		ID:      "empty",
+		EventID: eventID,
+		UserID:  "user-without-slots",
+	}))
+
+	availabilities, err := store.Events.GetParticipantAvailabilities(eventID)
+	require.NoError(t, err)
+	require.Len(t, availabilities, 3)
+	assert.Equal(t, "user-without-slots", availabilities[2].UserID)
+	assert.Empty(t, availabilities[2].TimeSlots)
+}
+
+func BenchmarkGetParticipantAvailabilities200(b *testing.B) {
+	store, queries := newCountingSQLiteStore(b)
+	eventID := seedAvailabilities(b, store, 200)
+
+	queries.Store(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Events.GetParticipantAvailabilities(eventID); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(queries.Load())/float64(b.N), "queries/op")
+}
@@ -0,0 +1,319 @@
+package tests
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/caldav"
+	"github.com/shani34/meeting-scheduler/internal/netguard"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCalDAV is a CalDAV server holding one calendar, busy 10:00-11:00 and 13:30-14:30 London
+// time on 3 June 2030. Servers without free/busy support only answer calendar queries.
+type fakeCalDAV struct {
+	*httptest.Server
+	freeBusy bool
+
+	mu      sync.Mutex
+	reports []string // Root element of every report received
+	ranges  []string // Time range of every report received
+}
+
+func newFakeCalDAV(t *testing.T, freeBusy bool) *fakeCalDAV {
+	fake := &fakeCalDAV{freeBusy: freeBusy}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeCalDAV) serve(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != "gina" || password != "s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method != "REPORT" || r.URL.Path != "/calendars/gina/work/" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var report struct {
+		XMLName xml.Name
+		Ranges  []struct {
+			Start string `xml:"start,attr"`
+			End   string `xml:"end,attr"`
+		} `xml:"time-range"`
+		Filter []struct {
+			Start string `xml:"start,attr"`
+			End   string `xml:"end,attr"`
+		} `xml:"filter>comp-filter>comp-filter>time-range"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if err := xml.Unmarshal(body, &report); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.reports = append(f.reports, report.XMLName.Local)
+	for _, ranges := range append(report.Ranges, report.Filter...) {
+		f.ranges = append(f.ranges, ranges.Start+"/"+ranges.End)
+	}
+	f.mu.Unlock()
+
+	switch {
+	case report.XMLName.Local == "free-busy-query" && f.freeBusy:
+		w.Header().Set("Content-Type", "text/calendar")
+		io.WriteString(w, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VFREEBUSY\r\n"+
+			"FREEBUSY:20300603T090000Z/20300603T100000Z,20300603T123000Z/PT1H\r\n"+
+			"END:VFREEBUSY\r\nEND:VCALENDAR\r\n")
+	case report.XMLName.Local == "calendar-query":
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0"?><D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`+
+			calendarResponse("standup", "20300603T090000Z", "20300603T100000Z")+
+			calendarResponse("review", "20300603T123000Z", "20300603T133000Z")+
+			`</D:multistatus>`)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// calendarResponse is the multistatus entry of an event
+func calendarResponse(uid, start, end string) string {
+	var data strings.Builder
+	xml.EscapeText(&data, []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nUID:"+uid+"\r\n"+
+		"DTSTART:"+start+"\r\nDTEND:"+end+"\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+	return `<D:response><D:href>/calendars/gina/work/` + uid + `.ics</D:href><D:propstat><D:prop>` +
+		`<C:calendar-data>` + data.String() + `</C:calendar-data>` +
+		`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`
+}
+
+// loopback lets CalDAV clients read the fake servers, which listen on the loopback interface
+var loopback = netguard.Policy{Allowed: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}
+
+func (f *fakeCalDAV) calendarURL() string {
+	return f.URL + "/calendars/gina/work/"
+}
+
+func TestCalDAVClient(t *testing.T) {
+	start := time.Date(2030, 6, 3, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	client := caldav.NewClient(5*time.Second, loopback)
+
+	for _, freeBusy := range []bool{true, false} {
+		fake := newFakeCalDAV(t, freeBusy)
+		account := caldav.Account{URL: fake.calendarURL(), Username: "gina", Password: "s3cret"}
+		components, err := client.Busy(context.Background(), account, start, end)
+		require.NoError(t, err)
+
+		var names []string
+		for _, calendar := range components {
+			for _, c := range calendar.Components {
+				names = append(names, c.Name)
+			}
+		}
+		if freeBusy {
+			assert.Equal(t, []string{"free-busy-query"}, fake.reports)
+			assert.Equal(t, []string{"VFREEBUSY"}, names)
+		} else {
+			// Servers without free/busy support are asked for their events instead
+			assert.Equal(t, []string{"free-busy-query", "calendar-query"}, fake.reports)
+			assert.Equal(t, []string{"VEVENT", "VEVENT"}, names)
+		}
+		require.Len(t, fake.ranges, len(fake.reports), "every report is limited to the time range")
+		for _, timeRange := range fake.ranges {
+			assert.Equal(t, "20300603T080000Z/20300603T160000Z", timeRange)
+		}
+
+		account.Password = "wrong"
+		_, err = client.Busy(context.Background(), account, start, end)
+		assert.ErrorIs(t, err, caldav.ErrUnauthorized)
+	}
+}
+
+// syncEvent creates an event for alice and bob open 09:00-17:00 London time on 3 June 2030
+func syncEvent(t *testing.T, store *repository.Store) *models.Event {
+	event := importEvent(t, store)
+	event.Participants = []models.EventParticipant{{UserID: "alice", Required: true}, {UserID: "bob"}}
+	require.NoError(t, store.Events.UpdateEvent(event))
+	return event
+}
+
+func TestCalendarSyncWorker(t *testing.T) {
+	store := repository.NewMemoryStore()
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	availabilityService := services.NewAvailabilityService(store.Availabilities, store.Events, eventService)
+	calendars := services.NewCalendarService(eventService, "example.com")
+	connections, err := services.NewCalendarConnectionService(store.Calendars, "key", caldav.NewClient(5*time.Second, loopback))
+	require.NoError(t, err)
+	syncs := services.NewCalendarSyncService(calendars, availabilityService, store.Calendars, connections)
+	event := syncEvent(t, store)
+
+	fake := newFakeCalDAV(t, false)
+	_, err = connections.Connect("alice", models.UpdateCalendarConnectionRequest{URL: fake.calendarURL(), Username: "gina", Password: "s3cret"})
+	require.NoError(t, err)
+	stored, err := store.Calendars.GetCalendarConnection("alice")
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Secret, "s3cret", "passwords are stored encrypted")
+
+	// Participants without a connection are left alone
+	services.NewCalendarSyncWorker(syncs, time.Minute).ProcessDue(context.Background())
+	availabilities, err := store.Events.GetParticipantAvailabilities(event.ID)
+	require.NoError(t, err)
+	require.Len(t, availabilities, 1)
+	assert.Equal(t, "alice", availabilities[0].UserID)
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	var free []string
+	for _, slot := range availabilities[0].TimeSlots {
+		free = append(free, slot.StartTime.In(london).Format("15:04")+"-"+slot.EndTime.In(london).Format("15:04"))
+	}
+	assert.Equal(t, []string{"09:00-10:00", "11:00-13:30", "14:30-17:00"}, free)
+
+	connection, err := connections.Get("alice")
+	require.NoError(t, err)
+	require.NotNil(t, connection.LastSyncedAt)
+	assert.Empty(t, connection.LastError)
+	assert.Equal(t, models.AvailabilitySourceCalendar, availabilities[0].Source)
+
+	// Refreshing availability the calendar still agrees with changes nothing
+	synced, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	services.NewCalendarSyncWorker(syncs, time.Minute).ProcessDue(context.Background())
+	refreshed, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, synced.Revision, refreshed.Revision, "nothing was submitted")
+
+	// Availability entered by hand is left alone by refreshes, but replaced when the user syncs
+	first := availabilities[0].TimeSlots[0]
+	byHand := []models.TimeSlot{{StartTime: first.StartTime, EndTime: first.EndTime, TimeZone: "UTC"}}
+	_, err = availabilityService.Update(availabilities[0].ID, "alice", byHand)
+	require.NoError(t, err)
+	services.NewCalendarSyncWorker(syncs, time.Minute).ProcessDue(context.Background())
+	kept, err := availabilityService.Get(availabilities[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, kept.TimeSlots, 1)
+	assert.Empty(t, kept.Source)
+	pulled, err := syncs.Sync(context.Background(), event.ID, "alice")
+	require.NoError(t, err)
+	assert.Len(t, pulled.TimeSlots, 3)
+	assert.Equal(t, models.AvailabilitySourceCalendar, pulled.Source)
+
+	// Failures are recorded on the connection
+	_, err = connections.Connect("alice", models.UpdateCalendarConnectionRequest{URL: fake.calendarURL(), Username: "gina", Password: "wrong"})
+	require.NoError(t, err)
+	_, err = syncs.Sync(context.Background(), event.ID, "alice")
+	assert.ErrorIs(t, err, services.ErrCalendarUnavailable)
+	connection, err = connections.Get("alice")
+	require.NoError(t, err)
+	assert.Contains(t, connection.LastError, caldav.ErrUnauthorized.Error())
+
+	_, err = syncs.Sync(context.Background(), event.ID, "bob")
+	assert.ErrorIs(t, err, services.ErrCalendarConnectionNotFound)
+}
+
+func TestCalendarConnectionTargets(t *testing.T) {
+	store := repository.NewMemoryStore()
+	connections, err := services.NewCalendarConnectionService(store.Calendars, "key", caldav.NewClient(5*time.Second, netguard.Policy{}))
+	require.NoError(t, err)
+
+	// Calendars on the server's own networks are refused
+	for _, target := range []string{
+		"http://127.0.0.1:8080/calendars/gina/",
+		"http://localhost/calendars/gina/",
+		"http://10.0.0.5/calendars/gina/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/calendars/gina/",
+	} {
+		_, err := connections.Connect("alice", models.UpdateCalendarConnectionRequest{URL: target, Username: "gina"})
+		assert.ErrorIs(t, err, services.ErrInvalidCalendarConnection, target)
+	}
+
+	// and not connected to either, whatever the URL looked like when it was given
+	fake := newFakeCalDAV(t, true)
+	start := time.Date(2030, 6, 3, 8, 0, 0, 0, time.UTC)
+	account := caldav.Account{URL: fake.calendarURL(), Username: "gina", Password: "s3cret"}
+	_, err = caldav.NewClient(5*time.Second, netguard.Policy{}).Busy(context.Background(), account, start, start.Add(time.Hour))
+	assert.ErrorIs(t, err, netguard.ErrForbiddenTarget)
+	assert.Empty(t, fake.reports)
+}
+
+func TestCalendarConnectionRoutes(t *testing.T) {
+	router := newTestRouter()
+	fake := newFakeCalDAV(t, true)
+	start := time.Date(2030, 6, 3, 8, 0, 0, 0, time.UTC)
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Workshop",
+		Duration:     60,
+		TimeSlots:    []models.TimeSlot{{StartTime: start, EndTime: start.Add(8 * time.Hour), TimeZone: "Europe/London"}},
+		Participants: []models.EventParticipant{{UserID: "alice"}, {UserID: "bob"}},
+	}, &event))
+
+	connect := models.UpdateCalendarConnectionRequest{URL: fake.calendarURL(), Username: "gina", Password: "s3cret"}
+	doForbidden(t, router, http.MethodPut, "/calendar-connections/alice", "mallory", connect)
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodPut, "/calendar-connections/alice", "alice",
+		models.UpdateCalendarConnectionRequest{URL: "ftp://caldav.example.com/"}, nil))
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/calendar-connections/alice", "alice", connect, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/calendar-connections/alice", nil)
+	req.Header.Set("X-User-ID", "alice")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), fake.calendarURL())
+	assert.NotContains(t, recorder.Body.String(), "s3cret")
+
+	var synced models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/availabilities/sync", "alice", nil, &synced))
+	assert.Equal(t, "alice", synced.UserID)
+	assert.Len(t, synced.TimeSlots, 3)
+	assert.Equal(t, http.StatusNotFound, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/availabilities/sync", "bob", nil, nil))
+
+	// Calendars that cannot be read are reported without the cause, which would tell hosts and ports apart
+	fake.Close()
+	req = httptest.NewRequest(http.MethodPost, "/events/"+event.ID+"/availabilities/sync", nil)
+	req.Header.Set("X-User-ID", "alice")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.JSONEq(t, `{"error":"Calendar server unavailable"}`, recorder.Body.String())
+
+	require.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, "/calendar-connections/alice", "alice", nil, nil))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, router, http.MethodGet, "/calendar-connections/alice", "alice", nil, nil))
+}
+
+func TestCalendarConnectionKey(t *testing.T) {
+	store := repository.NewMemoryStore()
+	fake := newFakeCalDAV(t, true)
+	client := caldav.NewClient(5*time.Second, loopback)
+	connections, err := services.NewCalendarConnectionService(store.Calendars, "old key", client)
+	require.NoError(t, err)
+	_, err = connections.Connect("alice", models.UpdateCalendarConnectionRequest{URL: fake.calendarURL(), Username: "gina", Password: "s3cret"})
+	require.NoError(t, err)
+
+	start := time.Date(2030, 6, 3, 8, 0, 0, 0, time.UTC)
+	_, err = connections.Busy(context.Background(), "alice", start, start.Add(time.Hour))
+	require.NoError(t, err)
+
+	// Passwords sealed with another key cannot be read back
+	rotated, err := services.NewCalendarConnectionService(store.Calendars, "new key", client)
+	require.NoError(t, err)
+	_, err = rotated.Busy(context.Background(), "alice", start, start.Add(time.Hour))
+	assert.ErrorIs(t, err, services.ErrInvalidCalendarConnection)
+	_, err = rotated.Busy(context.Background(), uuid.New().String(), start, start.Add(time.Hour))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// busyCalendar takes 10:00-11:00, 13:30-14:30 and 16:00-16:30 London time on 3 June 2030
+const busyCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Calendar//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"DTSTART;TZID=Europe/London:20300603T100000\r\n" +
+	"DTEND;TZID=Europe/London:20300603T110000\r\n" +
+	"SUMMARY:Stand-up\\, then a long planning session whose title needs more than one line to\r\n" +
+	"  be written\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:reminder@example.com\r\n" +
+	"DTSTART;TZID=Europe/London:20300603T120000\r\n" +
+	"DURATION:PT30M\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lunch@example.com\r\n" +
+	"DTSTART;TZID=Europe/London:20300501T130000\r\n" +
+	"DTEND;TZID=Europe/London:20300501T140000\r\n" +
+	"RRULE:FREQ=DAILY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lunch@example.com\r\n" +
+	"RECURRENCE-ID;TZID=Europe/London:20300603T130000\r\n" +
+	"DTSTART;TZID=Europe/London:20300603T133000\r\n" +
+	"DTEND;TZID=Europe/London:20300603T143000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VFREEBUSY\r\n" +
+	"FREEBUSY;FBTYPE=BUSY:20300603T150000Z/PT30M\r\n" +
+	"FREEBUSY;FBTYPE=FREE:20300603T080000Z/20300603T090000Z\r\n" +
+	"END:VFREEBUSY\r\n" +
+	"END:VCALENDAR\r\n"
+
+func importEvent(t *testing.T, store *repository.Store) *models.Event {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	start := time.Date(2030, 6, 3, 9, 0, 0, 0, london)
+	event := &models.Event{
+		ID:        uuid.New().String(),
+		Title:     "Workshop",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{{StartTime: start, EndTime: start.Add(8 * time.Hour), TimeZone: "Europe/London"}},
+		Status:    models.EventStatusPolling,
+		CreatedBy: "alice",
+		CreatedAt: start.Add(-72 * time.Hour),
+		UpdatedAt: start.Add(-72 * time.Hour),
+	}
+	require.NoError(t, store.Events.CreateEvent(event))
+	return event
+}
+
+func TestImportAvailability(t *testing.T) {
+	store := repository.NewMemoryStore()
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	calendars := services.NewCalendarService(eventService, "example.com")
+	event := importEvent(t, store)
+
+	slots, err := calendars.ImportAvailability(event, strings.NewReader(busyCalendar))
+	require.NoError(t, err)
+
+	var free []string
+	for _, slot := range slots {
+		assert.Equal(t, "Europe/London", slot.TimeZone)
+		free = append(free, slot.StartTime.Format("15:04")+"-"+slot.EndTime.Format("15:04"))
+	}
+	// 16:30-17:00 is too short for the meeting
+	assert.Equal(t, []string{"09:00-10:00", "11:00-13:30", "14:30-16:00"}, free)
+
+	_, err = calendars.ImportAvailability(event, strings.NewReader("BEGIN:VCALENDAR\r\nEND:VEVENT\r\n"))
+	assert.ErrorIs(t, err, services.ErrInvalidCalendar)
+	_, err = calendars.ImportAvailability(event, strings.NewReader(
+		"BEGIN:VEVENT\r\nDTSTART;TZID=Nowhere/City:20300603T100000\r\nDURATION:PT1H\r\nEND:VEVENT\r\n"))
+	assert.ErrorIs(t, err, services.ErrInvalidCalendar)
+}
+
+func TestICalDecode(t *testing.T) {
+	components, err := ical.Decode(strings.NewReader(busyCalendar))
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	require.Len(t, components[0].Components, 5)
+
+	standup := components[0].Components[0]
+	summary, ok := standup.Get("SUMMARY")
+	require.True(t, ok)
+	assert.Equal(t, "Stand-up, then a long planning session whose title needs more than one line to be written",
+		ical.ParseText(summary.Value))
+	dtstart, _ := standup.Get("DTSTART")
+	assert.Equal(t, "Europe/London", dtstart.Param("TZID"))
+
+	attendee, err := ical.Decode(strings.NewReader("BEGIN:VEVENT\r\nATTENDEE;CN=\"Doe, Gina\";ROLE=REQ-PARTICIPANT:mailto:gina@example.com\r\nEND:VEVENT\r\n"))
+	require.NoError(t, err)
+	prop, _ := attendee[0].Get("ATTENDEE")
+	assert.Equal(t, "Doe, Gina", prop.Param("CN"))
+	assert.Equal(t, "mailto:gina@example.com", prop.Value)
+
+	d, err := ical.ParseDuration("P1DT2H30M")
+	require.NoError(t, err)
+	assert.Equal(t, 26*time.Hour+30*time.Minute, d)
+	_, err = ical.ParseDuration("PT")
+	assert.Error(t, err)
+}
+
+func TestImportAvailabilityRoute(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 6, 3, 8, 0, 0, 0, time.UTC)
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:     "Workshop",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{{StartTime: start, EndTime: start.Add(8 * time.Hour), TimeZone: "Europe/London"}},
+	}, &event))
+	importPath := "/events/" + event.ID + "/availabilities/import"
+
+	// The calendar can be sent as the request body
+	req := httptest.NewRequest(http.MethodPost, importPath, strings.NewReader(busyCalendar))
+	req.Header.Set("Content-Type", "text/calendar")
+	req.Header.Set("X-User-ID", "alice")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var alice models.ParticipantAvailability
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &alice))
+	assert.Equal(t, "alice", alice.UserID)
+	assert.Len(t, alice.TimeSlots, 3)
+
+	// Guests upload it as a form file
+	var invite models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/invites", "alice",
+		models.CreateInviteRequest{}, &invite))
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	require.NoError(t, writer.WriteField("guest_email", "hal@example.com"))
+	file, err := writer.CreateFormFile("file", "busy.ics")
+	require.NoError(t, err)
+	_, err = file.Write([]byte(busyCalendar))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req = httptest.NewRequest(http.MethodPost, importPath, &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(middleware.InviteTokenHeader, invite.Token)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	var hal models.ParticipantAvailability
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &hal))
+	require.NotNil(t, hal.Guest)
+	assert.Equal(t, "hal@example.com", hal.Guest.Email)
+	assert.Equal(t, alice.TimeSlots, hal.TimeSlots)
+
+	// Unreadable calendars are rejected
+	req = httptest.NewRequest(http.MethodPost, importPath, strings.NewReader("not a calendar"))
+	req.Header.Set("X-User-ID", "alice")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/ical"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unfold joins folded content lines and splits a calendar into its lines
+func unfold(t *testing.T, calendar string) []string {
+	t.Helper()
+	for _, line := range strings.Split(strings.TrimSuffix(calendar, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "line %q is not folded", line)
+	}
+	return strings.Split(strings.ReplaceAll(strings.TrimSuffix(calendar, "\r\n"), "\r\n ", ""), "\r\n")
+}
+
+func TestICalWriter(t *testing.T) {
+	event := ical.NewComponent("VEVENT")
+	event.Add("SUMMARY", ical.Text("Planning; budget, hiring\nand roadmap \\ 2031"))
+	event.Add("ATTENDEE", "mailto:gina@example.com", ical.Param{Name: "CN", Value: `Doe, "Gina"`})
+	event.Add("DESCRIPTION", ical.Text(strings.Repeat("Réunion de planification ", 8)))
+	event.TimeProperty("DTSTART", time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC))
+
+	var buf strings.Builder
+	require.NoError(t, event.Encode(&buf))
+	lines := unfold(t, buf.String())
+	assert.Equal(t, []string{
+		"BEGIN:VEVENT",
+		`SUMMARY:Planning\; budget\, hiring\nand roadmap \\ 2031`,
+		`ATTENDEE;CN="Doe, Gina":mailto:gina@example.com`,
+		"DESCRIPTION:" + strings.Repeat("Réunion de planification ", 8),
+		"DTSTART:20300107T090000Z",
+		"END:VEVENT",
+	}, lines)
+}
+
+func TestICalTimezone(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	var buf strings.Builder
+	tz := ical.Timezone(london, time.Date(2030, 1, 1, 0, 0, 0, 0, london), time.Date(2030, 12, 31, 0, 0, 0, 0, london))
+	require.NoError(t, tz.Encode(&buf))
+	assert.Equal(t, []string{
+		"BEGIN:VTIMEZONE", "TZID:Europe/London",
+		"BEGIN:STANDARD", "DTSTART:20300101T000000", "TZOFFSETFROM:+0000", "TZOFFSETTO:+0000", "TZNAME:GMT", "END:STANDARD",
+		"BEGIN:DAYLIGHT", "DTSTART:20300331T010000", "TZOFFSETFROM:+0000", "TZOFFSETTO:+0100", "TZNAME:BST", "END:DAYLIGHT",
+		"BEGIN:STANDARD", "DTSTART:20301027T020000", "TZOFFSETFROM:+0100", "TZOFFSETTO:+0000", "TZNAME:GMT", "END:STANDARD",
+		"END:VTIMEZONE",
+	}, unfold(t, buf.String()))
+}
+
+// vevents returns the lines of every VEVENT in a calendar
+func vevents(lines []string) [][]string {
+	var events [][]string
+	var current []string
+	for _, line := range lines {
+		switch {
+		case line == "BEGIN:VEVENT":
+			current = []string{}
+		case line == "END:VEVENT":
+			events = append(events, current)
+			current = nil
+		case current != nil:
+			current = append(current, line)
+		}
+	}
+	return events
+}
+
+func TestCalendarExport(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	store := repository.NewMemoryStore()
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	calendars := services.NewCalendarService(eventService, "example.com")
+
+	start := time.Date(2030, 6, 3, 9, 0, 0, 0, london)
+	created := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	event := &models.Event{
+		ID:           uuid.New().String(),
+		Title:        "Design review",
+		Duration:     60,
+		TimeSlots:    []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "Europe/London"}},
+		Participants: []models.EventParticipant{{UserID: "alice", Required: true}, {UserID: "bob"}},
+		Status:       models.EventStatusPolling,
+		CreatedBy:    "alice",
+		CreatedAt:    created,
+		UpdatedAt:    created,
+	}
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	alice := conformanceAvailability(event.ID, "alice")
+	alice.TimeSlots = []models.TimeSlot{{StartTime: start, EndTime: start.Add(2 * time.Hour), TimeZone: "Europe/London"}}
+	alice.UpdatedAt = created.Add(time.Hour)
+	require.NoError(t, store.Availabilities.UpsertAvailability(alice))
+	guest := conformanceAvailability(event.ID, services.GuestUserID("invite"))
+	guest.TimeSlots = []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "Europe/London"}}
+	guest.Guest = &models.GuestDetails{InviteID: "invite", Name: "Gina", Email: "gina@example.com"}
+	guest.UpdatedAt = created.Add(2 * time.Hour)
+	require.NoError(t, store.Availabilities.UpsertAvailability(guest))
+
+	// Before finalization the top recommendations are tentative
+	event, err = eventService.GetEvent(event.ID)
+	require.NoError(t, err)
+	body, err := calendars.Export(event, 2)
+	require.NoError(t, err)
+	lines := unfold(t, string(body))
+	assert.Equal(t, []string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines[:2])
+	assert.Contains(t, lines, "TZID:Europe/London")
+	proposed := vevents(lines)
+	require.Len(t, proposed, 2)
+	assert.Contains(t, proposed[0], "UID:"+event.ID+"@meeting-scheduler")
+	assert.Contains(t, proposed[1], "UID:"+event.ID+"-candidate-2@meeting-scheduler")
+	assert.Contains(t, proposed[0], "STATUS:TENTATIVE")
+	assert.Contains(t, proposed[0], "SEQUENCE:2", "availability submitted after creation revises the event")
+	assert.Contains(t, proposed[0], "DTSTART;TZID=Europe/London:20300603T090000")
+	assert.Contains(t, proposed[0], "DTEND;TZID=Europe/London:20300603T100000")
+	assert.Contains(t, proposed[0], "ORGANIZER;CN=alice:mailto:alice@example.com")
+	assert.Contains(t, proposed[0], "ATTENDEE;CN=alice;ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED:mailto:alice@example.com")
+	assert.Contains(t, proposed[0], "ATTENDEE;CN=bob;ROLE=OPT-PARTICIPANT;PARTSTAT=NEEDS-ACTION:mailto:bob@example.com")
+	assert.Contains(t, proposed[0], "ATTENDEE;CN=Gina;ROLE=OPT-PARTICIPANT;PARTSTAT=ACCEPTED:mailto:gina@example.com")
+
+	// The final slot replaces the top candidate and withdraws the others
+	finalized, err := eventService.Finalize(event.ID, start, "alice")
+	require.NoError(t, err)
+	body, err = calendars.Export(finalized, 2)
+	require.NoError(t, err)
+	final := vevents(unfold(t, string(body)))
+	require.Len(t, final, 2)
+	assert.Contains(t, final[0], "UID:"+event.ID+"@meeting-scheduler")
+	assert.Contains(t, final[0], "STATUS:CONFIRMED")
+	assert.NotContains(t, final[0], "TRANSP:TRANSPARENT")
+	assert.Contains(t, final[1], "UID:"+event.ID+"-candidate-2@meeting-scheduler")
+	assert.Contains(t, final[1], "STATUS:CANCELLED")
+	assert.Contains(t, final[0], "SEQUENCE:3", "finalizing revises the event")
+
+	// Withdrawn availability revises the event too, though nothing is modified later than before
+	require.NoError(t, store.Availabilities.DeleteAvailability(guest.ID))
+	finalized, err = eventService.GetEvent(event.ID)
+	require.NoError(t, err)
+	body, err = calendars.Export(finalized, 2)
+	require.NoError(t, err)
+	assert.Contains(t, vevents(unfold(t, string(body)))[0], "SEQUENCE:4")
+}
+
+func TestCalendarExportRecurrence(t *testing.T) {
+	store := repository.NewMemoryStore()
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	calendars := services.NewCalendarService(eventService, "example.com")
+
+	event := conformanceEvent()
+	event.Participants = nil
+	event.Status = models.EventStatusPolling
+	event.Recurrence.RRule = "FREQ=WEEKLY;COUNT=6" // The last occurrences are in summer time
+	require.NoError(t, store.Events.CreateEvent(event))
+	require.NoError(t, store.Availabilities.UpsertAvailability(conformanceAvailability(event.ID, "alice", 0, 168, 504, 672, 840)))
+
+	body, err := calendars.Export(event, 1)
+	require.NoError(t, err)
+	lines := unfold(t, string(body))
+	exported := vevents(lines)
+	require.Len(t, exported, 1)
+	assert.Contains(t, exported[0], "RRULE:FREQ=WEEKLY;COUNT=6")
+	assert.Contains(t, exported[0], "EXDATE;TZID=Europe/London:20300318T090000")
+	assert.Contains(t, lines, "TZNAME:BST", "the zone is described up to the last occurrence")
+}
+
+func TestCalendarExportRoute(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:     "Retro",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{{StartTime: start, EndTime: start.Add(2 * time.Hour), TimeZone: "UTC"}},
+	}, &event))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "alice",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: event.TimeSlots}, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/events/"+event.ID+"/ics", nil)
+	req.Header.Set("X-User-ID", "alice")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "DTSTART:20300107T090000Z\r\n")
+	assert.NotContains(t, recorder.Body.String(), "VTIMEZONE", "UTC needs no time zone definition")
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/ics?candidates=0", "alice", nil, nil))
+	doForbidden(t, router, http.MethodGet, "/events/"+event.ID+"/ics", "mallory", nil)
+}
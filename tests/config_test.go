@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigDefaultsMatchServiceDefaults(t *testing.T) {
+	cfg := config.Default()
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, services.DefaultSlotStep, cfg.Scheduler.SlotStep)
+	assert.Equal(t, services.DefaultOffHoursPenalty, cfg.Scheduler.OffHoursPenalty)
+	assert.Equal(t, services.DefaultOccurrenceHorizon, cfg.Scheduler.OccurrenceHorizon)
+	assert.Equal(t, services.DefaultQuorum, cfg.Scheduler.Quorum)
+	assert.Equal(t, services.DefaultDeadlineCheckInterval, cfg.Scheduler.DeadlineCheckInterval)
+	assert.Equal(t, services.DefaultCalendarSyncInterval, cfg.Calendar.SyncInterval)
+	assert.Equal(t, services.DefaultWebhookPollInterval, cfg.Webhooks.PollInterval)
+	assert.Equal(t, services.DefaultWebhookMaxAttempts, cfg.Webhooks.MaxAttempts)
+	assert.Equal(t, services.DefaultWebhookRetryBase, cfg.Webhooks.RetryBase)
+	assert.Equal(t, services.DefaultReminderOffsets, cfg.Reminders.Offsets)
+	assert.Equal(t, services.DefaultReminderCheckInterval, cfg.Reminders.CheckInterval)
+	assert.Equal(t, services.DefaultStreamHeartbeat, cfg.Stream.HeartbeatInterval)
+	assert.Equal(t, services.DefaultStreamHistory, cfg.Stream.History)
+}
+
+func TestConfigLayersFileEnvAndFlags(t *testing.T) {
+	path := writeConfigFile(t, "server.yaml", `
+server:
+  port: 9000
+database:
+  backend: sqlite
+  sqlite_path: /var/lib/scheduler.db
+scheduler:
+  slot_step: 15m
+  quorum: 0.75
+auth:
+  enabled: true
+  jwt_issuer: https://id.example.com
+webhooks:
+  admins: [root, ops]
+  allowed_networks: [10.0.0.0/8, "fd00::/8"]
+`)
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("SCHEDULER_QUORUM", "0.6")
+
+	cfg, args, err := config.Load([]string{"-config", path, "-server.port", "9200", "migrate", "up"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "9200", cfg.Server.Port, "flags win over the environment")
+	assert.Equal(t, 0.6, cfg.Scheduler.Quorum, "the environment wins over the file")
+	assert.Equal(t, 15*time.Minute, cfg.Scheduler.SlotStep)
+	assert.Equal(t, "sqlite", cfg.Database.Backend)
+	assert.Equal(t, "/var/lib/scheduler.db", cfg.Database.SQLitePath)
+	assert.True(t, cfg.Auth.Enabled)
+	assert.Equal(t, "https://id.example.com", cfg.Auth.JWTIssuer)
+	assert.Equal(t, []string{"root", "ops"}, cfg.Webhooks.Admins)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}, cfg.Webhooks.AllowedNetworks)
+	assert.Equal(t, "localhost", cfg.Database.Host, "unset values keep their default")
+	assert.Equal(t, []string{"migrate", "up"}, args)
+}
+
+func TestConfigLoadsTOMLFromEnvironmentPath(t *testing.T) {
+	path := writeConfigFile(t, "server.toml", `
+[database]
+backend = "memory"
+
+[auth]
+jwt_secret = "signing-secret"
+
+[scheduler]
+occurrence_horizon = 8
+deadline_check_interval = "30s"
+
+[reminders]
+offsets = ["24h", "1h"]
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, _, err := config.Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Database.Backend)
+	assert.True(t, cfg.Auth.Enabled, "authentication is required unless disabled")
+	assert.Equal(t, 8, cfg.Scheduler.OccurrenceHorizon)
+	assert.Equal(t, 30*time.Second, cfg.Scheduler.DeadlineCheckInterval)
+	assert.Equal(t, []time.Duration{24 * time.Hour, time.Hour}, cfg.Reminders.Offsets)
+}
+
+func TestConfigReportsInvalidSettings(t *testing.T) {
+	path := writeConfigFile(t, "typo.yaml", "server:\n  prot: 9000\n")
+	_, _, err := config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, `unknown setting "server.prot"`)
+
+	_, _, err = config.Load([]string{"-scheduler.slot_step", "fortnight"})
+	assert.ErrorContains(t, err, "scheduler.slot_step")
+	_, _, err = config.Load([]string{"-webhooks.allowed_networks", "intranet"})
+	assert.ErrorContains(t, err, "webhooks.allowed_networks")
+
+	t.Setenv("STORAGE_BACKEND", "mongodb")
+	_, _, err = config.Load([]string{"-server.port", "0", "-scheduler.quorum", "1.5"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "database.backend")
+	assert.Contains(t, err.Error(), "scheduler.quorum")
+
+	_, _, err = config.Load([]string{"-reminders.offsets", "48h,-4h"})
+	assert.ErrorContains(t, err, "reminders.offsets: must be positive, got -4h0m0s")
+
+	_, _, err = config.Load([]string{"-database.backend", "memory"})
+	assert.ErrorContains(t, err, "the memory backend keeps no API keys")
+	_, _, err = config.Load([]string{"-database.backend", "memory", "-auth.enabled=false"})
+	assert.NoError(t, err)
+}
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Password = "hunter2"
+	cfg.Auth.JWTSecret = "signing-secret"
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, "REDACTED", redacted.Database.Password)
+	assert.Equal(t, "REDACTED", redacted.Auth.JWTSecret)
+	assert.Equal(t, cfg.Database.User, redacted.Database.User)
+
+	assert.Equal(t, "hunter2", cfg.Database.Password, "the original is left untouched")
+}
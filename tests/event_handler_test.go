@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shani34/meeting-scheduler/api/handlers"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/caldav"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testInviteSecret = []byte("test-invite-secret")
+
+// newTestRouter wires the event routes onto an in-memory store. Requests are authenticated
+// as the user named in the X-User-ID header or as the guest holding an invite token, and
+// anonymous without either.
+func newTestRouter() *gin.Engine {
+	return newTestRouterOn(repository.NewMemoryStore())
+}
+
+// newTestRouterOn wires the event routes onto a store, authenticating requests like newTestRouter
+func newTestRouterOn(store *repository.Store) *gin.Engine {
+	return newRouterWith(store, func(invites middleware.Authenticator) []gin.HandlerFunc {
+		return []gin.HandlerFunc{
+			middleware.AuthenticateOptional(invites),
+			func(c *gin.Context) {
+				if userID := c.GetHeader("X-User-ID"); userID != "" {
+					c.Set(middleware.PrincipalKey, &middleware.Principal{UserID: userID, Method: "test"})
+					c.Set(middleware.UserIDKey, userID)
+				}
+			},
+		}
+	})
+}
+
+// newRouterWith wires the event routes onto a store, authenticating requests with the middleware
+// built around the invite authenticator
+func newRouterWith(store *repository.Store, authenticate func(invites middleware.Authenticator) []gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	availabilityService := services.NewAvailabilityService(store.Availabilities, store.Events, eventService)
+	policy := services.NewEventPolicy(store.Events, store.EventRoles)
+	inviteService := services.NewInviteService(store.Invites, testInviteSecret, 24*time.Hour)
+	calendarService := services.NewCalendarService(eventService, "example.com")
+	connectionService, err := services.NewCalendarConnectionService(store.Calendars, "test-credentials-key", caldav.NewClient(5*time.Second, loopback))
+	if err != nil {
+		panic(err)
+	}
+	syncService := services.NewCalendarSyncService(calendarService, availabilityService, store.Calendars, connectionService)
+	eventHandler := handlers.NewEventHandler(store.Events, eventService, availabilityService, policy, inviteService, calendarService, syncService)
+	calendarConnectionHandler := handlers.NewCalendarConnectionHandler(connectionService)
+	webhookService := services.NewWebhookService(store.Webhooks, http.DefaultClient, services.WebhookOptions{Admins: []string{"root"}})
+	eventService.Changes().Subscribe(webhookService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, eventService, policy)
+	streamBroker := services.NewEventStreamBroker(eventService, 4)
+	eventService.Changes().Subscribe(streamBroker)
+	streamHandler := handlers.NewStreamHandler(streamBroker, eventService, policy, 50*time.Millisecond)
+	gridService := services.NewGridService(eventService, availabilityService, time.Hour)
+	eventService.Changes().Subscribe(gridService)
+	gridHandler := handlers.NewGridHandler(gridService, eventService, policy)
+	workingHoursHandler := handlers.NewWorkingHoursHandler(store.WorkingHours)
+
+	router := gin.New()
+	router.Use(authenticate(middleware.NewInviteAuthenticator(inviteService))...)
+	router.POST("/events", eventHandler.CreateEvent)
+	router.GET("/events", eventHandler.GetEvent)
+	router.PUT("/events", eventHandler.UpdateEvent)
+	router.DELETE("/events", eventHandler.DeleteEvent)
+	router.POST("/events/:id/open", eventHandler.OpenEvent)
+	router.POST("/events/:id/finalize", eventHandler.FinalizeEvent)
+	router.POST("/events/:id/cancel", eventHandler.CancelEvent)
+	router.GET("/events/:id/ics", eventHandler.ExportCalendar)
+	router.GET("/events/:id/roles", eventHandler.ListEventRoles)
+	router.PUT("/events/:id/roles/:user_id", eventHandler.AssignEventRole)
+	router.DELETE("/events/:id/roles/:user_id", eventHandler.UnassignEventRole)
+	router.POST("/events/:id/invites", eventHandler.CreateInvite)
+	router.GET("/events/:id/invites", eventHandler.ListInvites)
+	router.DELETE("/events/:id/invites/:invite_id", eventHandler.RevokeInvite)
+	router.GET("/events/:id/stream", streamHandler.StreamEvent)
+	router.GET("/events/:id/grid", gridHandler.JoinGrid)
+	router.POST("/availabilities", eventHandler.CreateAvailability)
+	router.PUT("/availabilities/:id", eventHandler.UpdateAvailability)
+	router.DELETE("/availabilities/:id", eventHandler.DeleteAvailability)
+	router.GET("/events/:id/availabilities", eventHandler.ListEventAvailabilities)
+	router.POST("/events/:id/availabilities/import", eventHandler.ImportAvailability)
+	router.POST("/events/:id/availabilities/sync", eventHandler.SyncAvailability)
+	router.GET("/calendar-connections/:user_id", calendarConnectionHandler.GetCalendarConnection)
+	router.PUT("/calendar-connections/:user_id", calendarConnectionHandler.UpdateCalendarConnection)
+	router.DELETE("/calendar-connections/:user_id", calendarConnectionHandler.DeleteCalendarConnection)
+	router.GET("/events/optimal-slots", eventHandler.GetOptimalTimeSlots)
+	router.GET("/working-hours/:user_id", workingHoursHandler.GetWorkingHours)
+	router.PUT("/working-hours/:user_id", workingHoursHandler.UpdateWorkingHours)
+	router.POST("/webhooks", webhookHandler.CreateWebhook)
+	router.GET("/webhooks", webhookHandler.ListWebhooks)
+	router.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", webhookHandler.ListWebhookDeliveries)
+	return router
+}
+
+func doJSON(t *testing.T, router *gin.Engine, method, path, userID string, body interface{}, out interface{}) int {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if out != nil && recorder.Code < 300 {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), out))
+	}
+	return recorder.Code
+}
+
+func TestEventHandlersOnMemoryStore(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	code := doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Design review",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}},
+	}, &event)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "alice", event.CreatedBy)
+
+	var alice, bob models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "alice",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, &alice))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: []models.TimeSlot{
+			{StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour), TimeZone: "UTC"},
+		}}, &bob))
+
+	// Only the submitting user may change an availability
+	update := models.UpdateAvailabilityRequest{TimeSlots: window}
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodPut, "/availabilities/"+bob.ID, "alice", update, nil))
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/availabilities/"+bob.ID, "bob", update, nil))
+
+	var availabilities []models.ParticipantAvailability
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "bob", nil, &availabilities))
+	assert.Len(t, availabilities, 2)
+
+	var recommendations []models.RecommendedTimeSlot
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/optimal-slots?event_id="+event.ID, "bob", nil, &recommendations))
+	require.NotEmpty(t, recommendations)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, recommendations[0].Participants)
+	assert.True(t, start.Equal(recommendations[0].TimeSlot.StartTime))
+}
+
+func TestRecommendationsReportInvalidRecurrence(t *testing.T) {
+	store := repository.NewMemoryStore()
+	router := newTestRouterOn(store)
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Standup",
+		Duration:     30,
+		TimeSlots:    []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}},
+		Participants: []models.EventParticipant{{UserID: "bob"}},
+		Recurrence:   &models.Recurrence{RRule: "FREQ=WEEKLY;COUNT=4"},
+	}, &event))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: event.TimeSlots}, nil))
+
+	// Rules are validated when they are given, so only a rule stored some other way, such as by an
+	// older release, fails to expand. That is a bad request rather than a conflict with the event state.
+	stored, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	stored.Recurrence = &models.Recurrence{RRule: "FREQ=HOURLY"}
+	require.NoError(t, store.Events.UpdateEvent(stored))
+
+	code := doJSON(t, router, http.MethodGet, "/events/optimal-slots?event_id="+event.ID, "alice", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+// doForbidden performs a request that must be refused and returns the reason
+func doForbidden(t *testing.T, router *gin.Engine, method, path, userID string, body interface{}) string {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", userID)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusForbidden, recorder.Code, "%s %s as %s", method, path, userID)
+
+	var response struct{ Error string }
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	return response.Error
+}
+
+func TestEventRolesAreEnforced(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Roadmap",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}},
+	}, &event))
+	eventPath := "/events?id=" + event.ID
+	rolesPath := "/events/" + event.ID + "/roles/"
+	edit := models.UpdateEventRequest{Title: "Roadmap 2031", Duration: 60, TimeSlots: window, Participants: event.Participants}
+
+	// Users without a role cannot even see the event
+	reason := doForbidden(t, router, http.MethodGet, eventPath, "carol", nil)
+	assert.Equal(t, "carol has no role in this event; viewing the event requires the organizer or co-organizer or participant or viewer or guest role", reason)
+
+	// Viewers only read the event and its recommendations
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, rolesPath+"carol", "alice",
+		models.AssignEventRoleRequest{Role: models.EventRoleViewer}, nil))
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, eventPath, "carol", nil, nil))
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/optimal-slots?event_id="+event.ID, "carol", nil, nil))
+	reason = doForbidden(t, router, http.MethodPost, "/availabilities", "carol",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window})
+	assert.Contains(t, reason, "carol is a viewer of this event; submitting availability requires")
+	doForbidden(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "carol", nil)
+
+	// Listed participants manage their availability but cannot edit the event
+	assert.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	reason = doForbidden(t, router, http.MethodPut, eventPath, "bob", edit)
+	assert.Equal(t, "bob is a participant of this event; editing the event requires the organizer or co-organizer role", reason)
+	doForbidden(t, router, http.MethodPost, "/events/"+event.ID+"/finalize", "bob", models.FinalizeEventRequest{StartTime: start})
+
+	// Co-organizers edit and finalize, but only the organizer deletes and manages roles
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, rolesPath+"dave", "alice",
+		models.AssignEventRoleRequest{Role: models.EventRoleCoOrganizer}, nil))
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, eventPath, "dave", edit, nil))
+	assert.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/finalize", "dave",
+		models.FinalizeEventRequest{StartTime: start}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPut, eventPath, "alice", edit, nil))
+	doForbidden(t, router, http.MethodDelete, eventPath, "dave", nil)
+	doForbidden(t, router, http.MethodPut, rolesPath+"erin", "dave", models.AssignEventRoleRequest{Role: models.EventRoleViewer})
+
+	var roles []models.EventRoleAssignment
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/roles", "bob", nil, &roles))
+	assert.Equal(t, []models.EventRoleAssignment{
+		{EventID: event.ID, UserID: "alice", Role: models.EventRoleOrganizer},
+		{EventID: event.ID, UserID: "carol", Role: models.EventRoleViewer},
+		{EventID: event.ID, UserID: "dave", Role: models.EventRoleCoOrganizer},
+		{EventID: event.ID, UserID: "bob", Role: models.EventRoleParticipant},
+	}, roles)
+
+	// The organizer keeps their role
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPut, rolesPath+"alice", "alice",
+		models.AssignEventRoleRequest{Role: models.EventRoleViewer}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodDelete, rolesPath+"alice", "alice", nil, nil))
+
+	// Withdrawn roles no longer grant access
+	assert.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, rolesPath+"carol", "alice", nil, nil))
+	doForbidden(t, router, http.MethodGet, eventPath, "carol", nil)
+	assert.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, eventPath, "alice", nil, nil))
+}
@@ -0,0 +1,332 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to models.EventStatus
+		allowed  bool
+	}{
+		{models.EventStatusDraft, models.EventStatusPolling, true},
+		{models.EventStatusDraft, models.EventStatusCancelled, true},
+		{models.EventStatusDraft, models.EventStatusFinalized, false},
+		{models.EventStatusPolling, models.EventStatusFinalized, true},
+		{models.EventStatusPolling, models.EventStatusCancelled, true},
+		{models.EventStatusPolling, models.EventStatusDraft, false},
+		{models.EventStatusFinalized, models.EventStatusCancelled, true},
+		{models.EventStatusFinalized, models.EventStatusPolling, false},
+		{models.EventStatusFinalized, models.EventStatusFinalized, false},
+		{models.EventStatusCancelled, models.EventStatusPolling, false},
+		{models.EventStatusCancelled, models.EventStatusFinalized, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, services.CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestMeetsQuorum(t *testing.T) {
+	recommendation := models.RecommendedTimeSlot{
+		Participants: []string{"user-1", "user-2"},
+		MissingUsers: []string{"user-3", "user-4", "user-5"},
+	}
+
+	assert.True(t, services.MeetsQuorum(recommendation, 0.4))
+	assert.False(t, services.MeetsQuorum(recommendation, 0.5))
+	assert.False(t, services.MeetsQuorum(models.RecommendedTimeSlot{}, 0))
+}
+
+func TestCheckAvailabilityOwner(t *testing.T) {
+	availability := &models.ParticipantAvailability{ID: "availability-1", UserID: "user-1"}
+
+	assert.NoError(t, services.CheckAvailabilityOwner(availability, "user-1"))
+	assert.ErrorIs(t, services.CheckAvailabilityOwner(availability, "user-2"), services.ErrNotAvailabilityOwner)
+	assert.ErrorIs(t, services.CheckAvailabilityOwner(availability, ""), services.ErrNotAvailabilityOwner)
+}
+
+func TestFinalizeEventEndpoint(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(2 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Planning",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}},
+		Status:       models.EventStatusDraft,
+	}, &event))
+	finalizePath := "/events/" + event.ID + "/finalize"
+
+	// Drafts collect no availability and cannot be finalized
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, finalizePath, "alice",
+		models.FinalizeEventRequest{StartTime: start}, nil))
+
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/open", "alice", nil, nil))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+
+	// Only recommended slots can be locked in
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodPost, finalizePath, "alice",
+		models.FinalizeEventRequest{StartTime: start.Add(15 * time.Minute)}, nil))
+
+	var finalized models.Event
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, finalizePath, "alice",
+		models.FinalizeEventRequest{StartTime: start.Add(30 * time.Minute)}, &finalized))
+	assert.Equal(t, models.EventStatusFinalized, finalized.Status)
+	require.NotNil(t, finalized.FinalSlot)
+	assert.True(t, finalized.FinalSlot.StartTime.Equal(start.Add(30*time.Minute)))
+	assert.True(t, finalized.FinalSlot.EndTime.Equal(start.Add(90*time.Minute)))
+	assert.Equal(t, "alice", finalized.FinalizedBy)
+	assert.NotNil(t, finalized.FinalizedAt)
+
+	var stored models.Event
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events?id="+event.ID, "bob", nil, &stored))
+	assert.Equal(t, models.EventStatusFinalized, stored.Status)
+	assert.Equal(t, finalized.FinalSlot, stored.FinalSlot)
+
+	// A finalized event keeps its slot and only moves on to cancelled
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, finalizePath, "alice",
+		models.FinalizeEventRequest{StartTime: start}, nil))
+	var cancelled models.Event
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/cancel", "alice", nil, &cancelled))
+	assert.Equal(t, models.EventStatusCancelled, cancelled.Status)
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/open", "alice", nil, nil))
+}
+
+func TestSubmissionsRejectedAfterFinalization(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Retro",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob"}, {UserID: "carol"}},
+	}, &event))
+
+	var submitted models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, &submitted))
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/finalize", "alice",
+		models.FinalizeEventRequest{StartTime: start}, nil))
+
+	// New, updated and withdrawn availability would change nothing anymore
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, "/availabilities", "carol",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPut, "/availabilities/"+submitted.ID, "bob",
+		models.UpdateAvailabilityRequest{TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodDelete, "/availabilities/"+submitted.ID, "bob", nil, nil))
+}
+
+func TestSubmissionsRejectedAfterDeadline(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+	deadline := time.Now().Add(time.Hour)
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Offsite",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob"}, {UserID: "carol"}},
+		Deadline:     &deadline,
+	}, &event))
+
+	var submitted models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, &submitted))
+
+	// Pulling the deadline into the past closes the poll while the event is still polling
+	passed := time.Now().Add(-time.Minute)
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/events?id="+event.ID, "alice", models.UpdateEventRequest{
+		Title:        event.Title,
+		Duration:     event.Duration,
+		TimeSlots:    event.TimeSlots,
+		Participants: event.Participants,
+		Deadline:     &passed,
+	}, nil))
+
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPost, "/availabilities", "carol",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusConflict, doJSON(t, router, http.MethodPut, "/availabilities/"+submitted.ID, "bob",
+		models.UpdateAvailabilityRequest{TimeSlots: window}, nil))
+
+	var availabilities []models.ParticipantAvailability
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &availabilities))
+	require.Len(t, availabilities, 1)
+	assert.Equal(t, "bob", availabilities[0].UserID)
+}
+
+func TestDeadlineWorkerFinalizesWhenQuorumIsMet(t *testing.T) {
+	store := repository.NewMemoryStore()
+	events := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	availabilities := services.NewAvailabilityService(store.Availabilities, store.Events, events)
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+	deadline := time.Now().Add(time.Hour)
+
+	newEvent := func(title string) *models.Event {
+		event := &models.Event{
+			ID:           title,
+			Title:        title,
+			Duration:     60,
+			TimeSlots:    window,
+			Participants: []models.EventParticipant{{UserID: "bob"}, {UserID: "carol"}, {UserID: "dave"}},
+			Status:       models.EventStatusPolling,
+			Deadline:     &deadline,
+		}
+		require.NoError(t, events.Create(event))
+		return event
+	}
+	expire := func(event *models.Event) {
+		passed := time.Now().Add(-time.Minute)
+		_, err := events.Update(event.ID, models.UpdateEventRequest{
+			Title:        event.Title,
+			Duration:     event.Duration,
+			TimeSlots:    event.TimeSlots,
+			Participants: event.Participants,
+			Deadline:     &passed,
+		})
+		require.NoError(t, err)
+	}
+
+	// Two of three participants can attend the first event, one of three the second
+	quorate := newEvent("quorate")
+	short := newEvent("short")
+	for _, userID := range []string{"bob", "carol"} {
+		_, err := availabilities.Submit(quorate.ID, userID, window)
+		require.NoError(t, err)
+	}
+	_, err := availabilities.Submit(short.ID, "bob", window)
+	require.NoError(t, err)
+
+	worker := services.NewDeadlineWorker(events, services.DefaultQuorum, time.Minute)
+	worker.ProcessDue()
+	due, err := events.DueEventIDs()
+	require.NoError(t, err)
+	assert.Empty(t, due, "deadlines that did not pass yet are left alone")
+
+	expire(quorate)
+	expire(short)
+	due, err = events.DueEventIDs()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"quorate", "short"}, due)
+
+	worker.ProcessDue()
+
+	finalized, err := events.GetEvent(quorate.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventStatusFinalized, finalized.Status)
+	require.NotNil(t, finalized.FinalSlot)
+	assert.True(t, finalized.FinalSlot.StartTime.Equal(start))
+	assert.Equal(t, services.AutoFinalizer, finalized.FinalizedBy)
+
+	polling, err := events.GetEvent(short.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventStatusPolling, polling.Status)
+	assert.Nil(t, polling.FinalSlot)
+
+	// Each deadline is handled once, whatever the outcome
+	due, err = events.DueEventIDs()
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	finalizedAgain, err := events.FinalizeAtDeadline(short.ID, services.DefaultQuorum)
+	require.NoError(t, err)
+	assert.False(t, finalizedAgain)
+	finalizedAgain, err = events.FinalizeAtDeadline(quorate.ID, services.DefaultQuorum)
+	require.NoError(t, err)
+	assert.False(t, finalizedAgain)
+}
+
+// cancellingEventStore cancels an event right before its status is written, as if an organizer
+// cancelled it while its deadline was being handled
+type cancellingEventStore struct {
+	repository.EventStore
+}
+
+func (s cancellingEventStore) UpdateEventStatus(event *models.Event, from models.EventStatus) error {
+	stored, err := s.EventStore.GetEvent(event.ID)
+	if err != nil {
+		return err
+	}
+	stored.Status = models.EventStatusCancelled
+	if err := s.EventStore.UpdateEventStatus(stored, models.EventStatusPolling); err != nil {
+		return err
+	}
+	return s.EventStore.UpdateEventStatus(event, from)
+}
+
+// recordedChanges records the types of the changes it is told about
+type recordedChanges struct {
+	types []models.ChangeType
+}
+
+func (r *recordedChanges) Notify(change services.Change) {
+	r.types = append(r.types, change.Type)
+}
+
+func TestFinalizeAtDeadlineLosesToConcurrentCancel(t *testing.T) {
+	store := repository.NewMemoryStore()
+	events := services.NewEventService(cancellingEventStore{store.Events}, store.WorkingHours, services.NewSchedulerService())
+	availabilities := services.NewAvailabilityService(store.Availabilities, store.Events, events)
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+	deadline := time.Now().Add(time.Hour)
+
+	event := &models.Event{
+		ID:           "racing",
+		Title:        "racing",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob"}},
+		Status:       models.EventStatusPolling,
+		Deadline:     &deadline,
+	}
+	require.NoError(t, events.Create(event))
+	_, err := availabilities.Submit(event.ID, "bob", window)
+	require.NoError(t, err)
+	passed := time.Now().Add(-time.Minute)
+	_, err = events.Update(event.ID, models.UpdateEventRequest{
+		Title:        event.Title,
+		Duration:     event.Duration,
+		TimeSlots:    event.TimeSlots,
+		Participants: event.Participants,
+		Deadline:     &passed,
+	})
+	require.NoError(t, err)
+
+	published := &recordedChanges{}
+	events.Changes().Subscribe(published)
+
+	finalized, err := events.FinalizeAtDeadline(event.ID, services.DefaultQuorum)
+	require.NoError(t, err)
+	assert.False(t, finalized, "the quorum was met on a snapshot the cancel made stale")
+
+	stored, err := events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventStatusCancelled, stored.Status)
+	assert.Nil(t, stored.FinalSlot)
+	assert.Empty(t, stored.FinalizedBy)
+	assert.NotContains(t, published.types, models.ChangeEventFinalized)
+
+	due, err := events.DueEventIDs()
+	require.NoError(t, err)
+	assert.Empty(t, due, "the deadline is handled even though the event was not finalized")
+}
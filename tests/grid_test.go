@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialGrid opens the availability grid of an event as a user
+func dialGrid(t *testing.T, server *httptest.Server, eventID, userID string) (*websocket.Conn, *http.Response, error) {
+	return dialGridWith(t, server, eventID, "", http.Header{"X-User-ID": {userID}})
+}
+
+// dialGridWith opens the availability grid of an event with the given query and request headers
+func dialGridWith(t *testing.T, server *httptest.Server, eventID, query string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/events/" + eventID + "/grid" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readGrid returns the next message of a grid
+func readGrid(t *testing.T, conn *websocket.Conn) models.GridMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var message models.GridMessage
+	require.NoError(t, conn.ReadJSON(&message))
+	return message
+}
+
+// readGridAvailability reads the next availability change of a grid, returning the user's slots as
+// start and end clock times
+func readGridAvailability(t *testing.T, conn *websocket.Conn, userID string) []string {
+	t.Helper()
+	message := readGrid(t, conn)
+	require.Equal(t, models.GridMessageAvailability, message.Type, message.Error)
+	require.Len(t, message.Availabilities, 1)
+	assert.Equal(t, userID, message.Availabilities[0].UserID)
+	ranges := make([]string, 0, len(message.Availabilities[0].TimeSlots))
+	for _, slot := range message.Availabilities[0].TimeSlots {
+		label := slot.StartTime.UTC().Format("Jan 2 15:04") + "-" + slot.EndTime.UTC().Format("15:04")
+		if slot.Preference != "" && slot.Preference != models.PreferenceAvailable {
+			label += " " + string(slot.Preference)
+		}
+		ranges = append(ranges, label)
+	}
+	return ranges
+}
+
+func TestAvailabilityGrid(t *testing.T) {
+	router := newTestRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	monday := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+	windows := []models.TimeSlot{
+		{StartTime: monday, EndTime: monday.Add(3 * time.Hour), TimeZone: "UTC"},
+		{StartTime: tuesday, EndTime: tuesday.Add(150 * time.Minute), TimeZone: "UTC"},
+	}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Design review",
+		Duration:     60,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}, {UserID: "carol"}},
+		TimeSlots:    windows,
+	}, &event))
+
+	_, resp, err := dialGrid(t, server, event.ID, "mallory")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Clients start from a snapshot and see who else joins
+	alice, _, err := dialGrid(t, server, event.ID, "alice")
+	require.NoError(t, err)
+	snapshot := readGrid(t, alice)
+	assert.Equal(t, models.GridMessageSnapshot, snapshot.Type)
+	assert.Equal(t, "alice", snapshot.UserID)
+	assert.Equal(t, 60, snapshot.CellMinutes)
+	assert.Len(t, snapshot.Windows, 2)
+	assert.Empty(t, snapshot.Availabilities)
+	assert.Equal(t, []string{"alice"}, snapshot.Viewers)
+
+	bob, _, err := dialGrid(t, server, event.ID, "bob")
+	require.NoError(t, err)
+	snapshot = readGrid(t, bob)
+	assert.Equal(t, []string{"alice", "bob"}, snapshot.Viewers)
+	presence := readGrid(t, alice)
+	assert.Equal(t, models.GridMessagePresence, presence.Type)
+	assert.Equal(t, []string{"alice", "bob"}, presence.Viewers)
+
+	// Painted cells are merged into ranges, pushed to everyone on the grid
+	paint := func(available bool, preference models.PreferenceLevel, cells ...time.Time) {
+		require.NoError(t, bob.WriteJSON(models.GridRequest{
+			Type: models.GridMessagePaint, Cells: cells, Available: available, Preference: preference,
+		}))
+	}
+	hour := func(start time.Time, n int) time.Time { return start.Add(time.Duration(n) * time.Hour) }
+
+	paint(true, "", hour(monday, 0), hour(monday, 2))
+	assert.Equal(t, []string{"Jan 7 09:00-10:00", "Jan 7 11:00-12:00"}, readGridAvailability(t, alice, "bob"))
+	assert.Equal(t, []string{"Jan 7 09:00-10:00", "Jan 7 11:00-12:00"}, readGridAvailability(t, bob, "bob"))
+
+	paint(true, "", hour(monday, 1))
+	assert.Equal(t, []string{"Jan 7 09:00-12:00"}, readGridAvailability(t, alice, "bob"))
+	readGrid(t, bob)
+
+	paint(true, models.PreferencePreferred, hour(monday, 1), hour(tuesday, 2))
+	assert.Equal(t, []string{"Jan 7 09:00-10:00", "Jan 7 10:00-11:00 preferred", "Jan 7 11:00-12:00", "Jan 8 11:00-11:30 preferred"},
+		readGridAvailability(t, alice, "bob"), "the last cell of a window ends with it")
+	readGrid(t, bob)
+
+	paint(false, "", hour(monday, 0), hour(tuesday, 2))
+	assert.Equal(t, []string{"Jan 7 10:00-11:00 preferred", "Jan 7 11:00-12:00"}, readGridAvailability(t, alice, "bob"))
+	readGrid(t, bob)
+
+	// Edits that do not fit the grid are rejected to their author only
+	paint(true, "", monday.Add(30*time.Minute))
+	rejected := readGrid(t, bob)
+	assert.Equal(t, models.GridMessageError, rejected.Type)
+	assert.Contains(t, rejected.Error, "no cell starts at 2030-01-07T09:30:00Z")
+
+	// Availability submitted through the API shows up, and grid edits are stored
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "carol",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: windows[:1]}, nil))
+	assert.Equal(t, []string{"Jan 7 09:00-12:00"}, readGridAvailability(t, alice, "carol"))
+	readGrid(t, bob)
+
+	var stored []models.ParticipantAvailability
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &stored))
+	require.Len(t, stored, 2)
+	for _, availability := range stored {
+		if availability.UserID == "bob" {
+			require.Len(t, availability.TimeSlots, 2)
+			assert.Equal(t, models.PreferencePreferred, availability.TimeSlots[0].Preference)
+			assert.True(t, hour(monday, 3).Equal(availability.TimeSlots[1].EndTime))
+		}
+	}
+
+	// Erasing every cell withdraws the availability
+	paint(false, "", hour(monday, 1), hour(monday, 2))
+	assert.Empty(t, readGridAvailability(t, alice, "bob"))
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &stored))
+	require.Len(t, stored, 1)
+	assert.Equal(t, "carol", stored[0].UserID)
+
+	require.NoError(t, bob.Close())
+	presence = readGrid(t, alice)
+	assert.Equal(t, models.GridMessagePresence, presence.Type)
+	assert.Equal(t, []string{"alice"}, presence.Viewers)
+}
+
+func TestAvailabilityGridIdentifiesPaintersByCredentials(t *testing.T) {
+	router := newTestRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	monday := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	windows := []models.TimeSlot{{StartTime: monday, EndTime: monday.Add(2 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Design review",
+		Duration:     60,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}},
+		TimeSlots:    windows,
+	}, &event))
+	var addressed, open models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/invites", "alice",
+		models.CreateInviteRequest{Name: "Dana", Email: "dana@example.org"}, &addressed))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/invites", "alice",
+		models.CreateInviteRequest{}, &open))
+	paint := func(conn *websocket.Conn) {
+		require.NoError(t, conn.WriteJSON(models.GridRequest{Type: models.GridMessagePaint, Cells: []time.Time{monday}, Available: true}))
+	}
+
+	// Anonymous callers are refused, and nobody paints as another participant
+	_, resp, err := dialGridWith(t, server, event.ID, "?user_id=bob", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	bob, _, err := dialGridWith(t, server, event.ID, "?user_id=alice", http.Header{"X-User-ID": {"bob"}})
+	require.NoError(t, err)
+	assert.Equal(t, "bob", readGrid(t, bob).UserID)
+	paint(bob)
+	readGridAvailability(t, bob, "bob")
+
+	// Guests do not see the others' availability, so whatever invite they hold they cannot open the grid
+	for _, token := range []string{addressed.Token, open.Token} {
+		_, resp, err = dialGridWith(t, server, event.ID, "?user_id=bob", http.Header{middleware.InviteTokenHeader: {token}})
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+
+	var stored []models.ParticipantAvailability
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &stored))
+	require.Len(t, stored, 1)
+	assert.Equal(t, "bob", stored[0].UserID)
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindOptimalTimeSlotsDeduplicatesResults(t *testing.T) {
+	scheduler := services.NewSchedulerService()
+
+	event := &models.Event{
+		ID:       "test-event",
+		Duration: 60,
+		TimeSlots: []models.TimeSlot{
+			{
+				StartTime: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC),
+				EndTime:   time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+				TimeZone:  "UTC",
+			},
+			{
+				// Overlaps the first window, shared starts must only be recommended once
+				StartTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				EndTime:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				TimeZone:  "UTC",
+			},
+		},
+	}
+
+	// user-1 submitted twice, user-2 once; together they overlap every candidate
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{slot(9, 0, 10, 30)}},
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{slot(10, 30, 12, 0)}},
+		{UserID: "user-2", TimeSlots: []models.TimeSlot{slot(9, 0, 12, 0)}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+
+	// 09:00, 09:30, 10:00, 10:30, 11:00
+	assert.Len(t, recommendations, 5)
+	starts := make(map[time.Time]bool)
+	for _, rec := range recommendations {
+		assert.False(t, starts[rec.TimeSlot.StartTime], "duplicate candidate %s", rec.TimeSlot.StartTime)
+		starts[rec.TimeSlot.StartTime] = true
+		assert.Equal(t, []string{"user-1", "user-2"}, rec.Participants)
+		assert.Empty(t, rec.MissingUsers)
+	}
+}
+
+func TestFindOptimalTimeSlotsMatchesBruteForce(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(15 * time.Minute))
+	rng := rand.New(rand.NewSource(42))
+	event, participantAvailabilities := randomSchedule(rng, 40, 30)
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.NotEmpty(t, recommendations)
+
+	duration := time.Duration(event.Duration) * time.Minute
+	for _, rec := range recommendations {
+		assert.Equal(t, duration, rec.TimeSlot.EndTime.Sub(rec.TimeSlot.StartTime))
+
+		expected := make([]string, 0)
+		for _, pa := range participantAvailabilities {
+			if coversFully(pa.TimeSlots, rec.TimeSlot) {
+				expected = append(expected, pa.UserID)
+			}
+		}
+		assert.Equal(t, expected, rec.Participants, "candidate %s", rec.TimeSlot.StartTime)
+		assert.Equal(t, float64(len(expected)), rec.Score)
+	}
+}
+
+func BenchmarkFindOptimalTimeSlots500x1000(b *testing.B) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(15 * time.Minute))
+	rng := rand.New(rand.NewSource(7))
+	event, participantAvailabilities := randomSchedule(rng, 500, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// slot builds a UTC time slot on 2024-01-01
+func slot(startHour, startMin, endHour, endMin int) models.TimeSlot {
+	return models.TimeSlot{
+		StartTime: time.Date(2024, 1, 1, startHour, startMin, 0, 0, time.UTC),
+		EndTime:   time.Date(2024, 1, 1, endHour, endMin, 0, 0, time.UTC),
+		TimeZone:  "UTC",
+	}
+}
+
+// randomSchedule builds a one-week event and participants with random 15-minute aligned slots
+func randomSchedule(rng *rand.Rand, participants, slotsPerParticipant int) (*models.Event, []models.ParticipantAvailability) {
+	weekStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := &models.Event{
+		ID:       "bench-event",
+		Duration: 60,
+		TimeSlots: []models.TimeSlot{
+			{StartTime: weekStart, EndTime: weekStart.AddDate(0, 0, 7), TimeZone: "UTC"},
+		},
+	}
+
+	quarters := 7 * 24 * 4
+	availabilities := make([]models.ParticipantAvailability, participants)
+	for p := range availabilities {
+		slots := make([]models.TimeSlot, slotsPerParticipant)
+		for i := range slots {
+			start := weekStart.Add(time.Duration(rng.Intn(quarters)) * 15 * time.Minute)
+			length := time.Duration(1+rng.Intn(8)) * 15 * time.Minute
+			slots[i] = models.TimeSlot{StartTime: start, EndTime: start.Add(length), TimeZone: "UTC"}
+		}
+		availabilities[p] = models.ParticipantAvailability{
+			UserID:    "user-" + string(rune('A'+p%26)) + string(rune('a'+p/26%26)) + string(rune('0'+p/676)),
+			TimeSlots: slots,
+		}
+	}
+	return event, availabilities
+}
+
+// coversFully reports whether the union of slots covers target without gaps
+func coversFully(slots []models.TimeSlot, target models.TimeSlot) bool {
+	sorted := append([]models.TimeSlot(nil), slots...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+
+	reached := target.StartTime
+	for _, s := range sorted {
+		if s.StartTime.After(reached) {
+			break
+		}
+		if s.EndTime.After(reached) {
+			reached = s.EndTime
+		}
+	}
+	return !reached.Before(target.EndTime)
+}
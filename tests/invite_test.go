@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shani34/meeting-scheduler/api/middleware"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doGuest performs a request with an invite token instead of a user
+func doGuest(t *testing.T, router *gin.Engine, method, path, token string, body interface{}, out interface{}) int {
+	t.Helper()
+	recorder := guestRequest(t, router, method, path, token, body)
+	if out != nil && recorder.Code < 300 {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), out))
+	}
+	return recorder.Code
+}
+
+func guestRequest(t *testing.T, router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.InviteTokenHeader, token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestGuestInvites(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+
+	createEvent := func(title string) models.Event {
+		var event models.Event
+		require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice",
+			models.CreateEventRequest{Title: title, Duration: 60, TimeSlots: window}, &event))
+		return event
+	}
+	event := createEvent("Offsite")
+	other := createEvent("Budget")
+	invitesPath := "/events/" + event.ID + "/invites"
+
+	// Only organizers hand out invites
+	doForbidden(t, router, http.MethodPost, invitesPath, "bob", models.CreateInviteRequest{})
+
+	var addressed, open models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, invitesPath, "alice",
+		models.CreateInviteRequest{Name: "Gina", Email: "Gina@Example.com"}, &addressed))
+	assert.Equal(t, "gina@example.com", addressed.Invite.Email)
+	assert.Equal(t, "alice", addressed.Invite.CreatedBy)
+	assert.NotEmpty(t, addressed.Token)
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, invitesPath, "alice",
+		models.CreateInviteRequest{}, &open))
+
+	// Guests see the event they were invited to and submit availability to it
+	assert.Equal(t, http.StatusOK, doGuest(t, router, http.MethodGet, "/events?id="+event.ID, addressed.Token, nil, nil))
+	var gina models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doGuest(t, router, http.MethodPost, "/availabilities", addressed.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window}, &gina))
+	assert.Equal(t, event.ID, gina.EventID)
+	assert.Equal(t, &models.GuestDetails{InviteID: addressed.Invite.ID, Name: "Gina", Email: "gina@example.com"}, gina.Guest)
+
+	// Submitting again replaces the guest's availability
+	var resubmitted models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doGuest(t, router, http.MethodPost, "/availabilities", addressed.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window[:1]}, &resubmitted))
+	assert.Equal(t, gina.ID, resubmitted.ID)
+
+	// Guests of an open invite identify themselves by email
+	assert.Equal(t, http.StatusBadRequest, doGuest(t, router, http.MethodPost, "/availabilities", open.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window}, nil))
+	var hal models.ParticipantAvailability
+	recorder := guestRequest(t, router, http.MethodPost, "/availabilities", open.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window, GuestName: "Hal", GuestEmail: "hal@example.com"})
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &hal))
+	assert.Equal(t, "hal@example.com", hal.Guest.Email)
+	assert.NotEqual(t, gina.UserID, hal.UserID)
+
+	// Their response is bound to a personal invite, so others holding the open invite cannot replace it
+	personal := recorder.Header().Get(middleware.InviteTokenHeader)
+	require.NotEmpty(t, personal)
+	assert.NotEqual(t, open.Invite.ID, hal.Guest.InviteID)
+	assert.Equal(t, http.StatusConflict, doGuest(t, router, http.MethodPost, "/availabilities", open.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window, GuestName: "Not Hal", GuestEmail: " HAL@example.com"}, nil))
+	var replaced models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doGuest(t, router, http.MethodPost, "/availabilities", personal,
+		models.CreateAvailabilityRequest{TimeSlots: window[:1]}, &replaced))
+	assert.Equal(t, hal.ID, replaced.ID)
+	assert.Equal(t, &models.GuestDetails{InviteID: hal.Guest.InviteID, Name: "Hal", Email: "hal@example.com"}, replaced.Guest)
+
+	// Nobody can respond through the open invite with an email that has an invite of its own
+	var ivy models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, invitesPath, "alice",
+		models.CreateInviteRequest{Name: "Ivy", Email: "ivy@example.com"}, &ivy))
+	claim := guestRequest(t, router, http.MethodPost, "/availabilities", open.Token,
+		models.CreateAvailabilityRequest{TimeSlots: window, GuestName: "Not Ivy", GuestEmail: "ivy@example.com"})
+	assert.Equal(t, http.StatusConflict, claim.Code)
+	assert.Empty(t, claim.Header().Get(middleware.InviteTokenHeader))
+
+	// A submission that fails issues no personal invite
+	failed := guestRequest(t, router, http.MethodPost, "/events/"+event.ID+"/availabilities/import?guest_name=Jo&guest_email=jo@example.com",
+		open.Token, "not a calendar")
+	assert.Equal(t, http.StatusBadRequest, failed.Code)
+	assert.Empty(t, failed.Header().Get(middleware.InviteTokenHeader))
+
+	var availabilities []models.ParticipantAvailability
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &availabilities))
+	assert.Len(t, availabilities, 2)
+
+	// Invites grant nothing in other events, nor anything beyond viewing and submitting
+	assert.Equal(t, http.StatusForbidden, doGuest(t, router, http.MethodPost, "/availabilities", addressed.Token,
+		models.CreateAvailabilityRequest{EventID: other.ID, TimeSlots: window}, nil))
+	assert.Equal(t, http.StatusForbidden, doGuest(t, router, http.MethodGet, "/events?id="+other.ID, open.Token, nil, nil))
+	assert.Equal(t, http.StatusForbidden, doGuest(t, router, http.MethodGet, invitesPath, addressed.Token, nil, nil))
+
+	// Revoked, expired and forged tokens are refused
+	var invites []models.EventInvite
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, invitesPath, "alice", nil, &invites))
+	assert.Len(t, invites, 4, "addressed, open, Hal's personal and Ivy's invites only")
+	assert.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, invitesPath+"/"+open.Invite.ID, "alice", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, doGuest(t, router, http.MethodGet, "/events?id="+event.ID, open.Token, nil, nil))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, router, http.MethodDelete,
+		"/events/"+other.ID+"/invites/"+addressed.Invite.ID, "alice", nil, nil))
+
+	expired := signToken(t, jwt.SigningMethodHS256, testInviteSecret, jwt.RegisteredClaims{
+		ID:        addressed.Invite.ID,
+		Audience:  jwt.ClaimStrings{"meeting-scheduler/invite"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	})
+	assert.Equal(t, http.StatusUnauthorized, doGuest(t, router, http.MethodGet, "/events?id="+event.ID, expired, nil, nil))
+	forged := signToken(t, jwt.SigningMethodHS256, testHMACSecret, jwt.RegisteredClaims{
+		ID:        addressed.Invite.ID,
+		Audience:  jwt.ClaimStrings{"meeting-scheduler/invite"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	assert.Equal(t, http.StatusUnauthorized, doGuest(t, router, http.MethodGet, "/events?id="+event.ID, forged, nil, nil))
+
+	// Availability submitted before revocation is kept
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/availabilities", "alice", nil, &availabilities))
+	assert.Len(t, availabilities, 2)
+}
+
+func TestGuestUserIDsAreStable(t *testing.T) {
+	invite := &models.EventInvite{ID: "invite", EventID: "event"}
+	first, _, err := services.Guest(invite, "", " Hal@Example.com")
+	require.NoError(t, err)
+	second, guest, err := services.Guest(invite, "Hal", "hal@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "open invite guests are identified by their email")
+	assert.Equal(t, "Hal", guest.Name)
+
+	_, _, err = services.Guest(invite, "Hal", "")
+	assert.ErrorIs(t, err, services.ErrGuestEmailRequired)
+}
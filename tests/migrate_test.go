@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shani34/meeting-scheduler/internal/migrate"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/shani34/meeting-scheduler/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openMigrationDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", repository.SQLiteDSN(filepath.Join(t.TempDir(), "migrate.db")))
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var testMigrations = fstest.MapFS{
+	"001_create_notes.sql": {Data: []byte(
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT);\n-- migrate:down\nDROP TABLE notes;\n")},
+	"002_add_author.sql": {Data: []byte(
+		"ALTER TABLE notes ADD COLUMN author TEXT;\n-- migrate:down\nALTER TABLE notes DROP COLUMN author;\n")},
+}
+
+func TestMigratorAppliesPendingMigrationsOnce(t *testing.T) {
+	db := openMigrationDB(t)
+	loaded, err := migrate.Load(testMigrations, ".")
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "create_notes", loaded[0].Name)
+
+	migrator := migrate.New(db, loaded[:1])
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, 1)
+
+	// A later release ships a second migration
+	migrator = migrate.New(db, loaded)
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	_, err = db.Exec("INSERT INTO notes (body, author) VALUES ('hello', 'alice')")
+	assert.NoError(t, err)
+}
+
+func TestMigratorDownAndStatus(t *testing.T) {
+	db := openMigrationDB(t)
+	loaded, err := migrate.Load(testMigrations, ".")
+	require.NoError(t, err)
+	migrator := migrate.New(db, loaded)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	reverted, err := migrator.Down(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, 2, reverted[0].Version)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	assert.False(t, statuses[1].Applied)
+
+	_, err = db.Exec("INSERT INTO notes (body, author) VALUES ('hello', 'alice')")
+	assert.Error(t, err, "the author column was dropped")
+}
+
+func TestMigratorDetectsEditedMigrations(t *testing.T) {
+	db := openMigrationDB(t)
+	loaded, err := migrate.Load(testMigrations, ".")
+	require.NoError(t, err)
+	_, err = migrate.New(db, loaded).Up()
+	require.NoError(t, err)
+
+	edited := fstest.MapFS{
+		"001_create_notes.sql": {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);\n")},
+		"002_add_author.sql":   testMigrations["002_add_author.sql"],
+	}
+	loaded, err = migrate.Load(edited, ".")
+	require.NoError(t, err)
+	migrator := migrate.New(db, loaded)
+
+	_, err = migrator.Up()
+	assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+	_, err = migrator.Down(1)
+	assert.ErrorIs(t, err, migrate.ErrChecksumMismatch)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Modified)
+	assert.False(t, statuses[1].Modified)
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	db := openMigrationDB(t)
+	broken := fstest.MapFS{
+		"001_create_notes.sql": testMigrations["001_create_notes.sql"],
+		"002_broken.sql":       {Data: []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY);\nNOT VALID SQL;\n")},
+	}
+	loaded, err := migrate.Load(broken, ".")
+	require.NoError(t, err)
+	migrator := migrate.New(db, loaded)
+
+	applied, err := migrator.Up()
+	assert.Error(t, err)
+	assert.Len(t, applied, 1)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.False(t, statuses[1].Applied)
+	_, err = db.Exec("INSERT INTO tags (id) VALUES (1)")
+	assert.Error(t, err, "the partially applied migration must be rolled back")
+}
+
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	postgres, err := migrate.Load(migrations.Postgres, ".")
+	require.NoError(t, err)
+	require.NotEmpty(t, postgres)
+	for i, migration := range postgres {
+		assert.Equal(t, i+1, migration.Version, "versions must be contiguous")
+		assert.NotEmpty(t, strings.TrimSpace(migration.Down), "%03d_%s has no down section", migration.Version, migration.Name)
+	}
+
+	// The SQLite schema can be applied and fully reverted
+	db := openMigrationDB(t)
+	sqlite, err := migrate.Load(migrations.SQLite, "sqlite")
+	require.NoError(t, err)
+	migrator := migrate.New(db, sqlite)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	reverted, err := migrator.Down(len(sqlite))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(sqlite))
+}
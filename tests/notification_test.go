@@ -0,0 +1,372 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/mail"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP is an in-process SMTP server keeping every message it receives
+type fakeSMTP struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []receivedMail
+	logins   []string        // Credentials given with AUTH PLAIN, as user:password
+	refused  map[string]bool // Recipients rejected at RCPT
+}
+
+// receivedMail is a message received by fakeSMTP, with its MIME parts decoded
+type receivedMail struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments map[string][]byte // By filename
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeSMTP{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(t, conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTP) config() mail.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return mail.SMTPConfig{Host: host, Port: port, Username: "mailer", Password: "s3cret", Timeout: 5 * time.Second}
+}
+
+func (s *fakeSMTP) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) { _ = text.PrintfLine("%s", line) }
+
+	reply("220 fake ESMTP")
+	var from string
+	var to []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			parts := strings.Split(string(credentials), "\x00")
+			s.mu.Lock()
+			s.logins = append(s.logins, parts[len(parts)-2]+":"+parts[len(parts)-1])
+			s.mu.Unlock()
+			reply("235 accepted")
+		case "MAIL":
+			from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			recipient := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			s.mu.Lock()
+			refused := s.refused[recipient]
+			s.mu.Unlock()
+			if refused {
+				reply("550 mailbox unavailable")
+				continue
+			}
+			to = append(to, recipient)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			received := parseMail(t, data)
+			received.From, received.To = from, to
+			s.mu.Lock()
+			s.messages = append(s.messages, received)
+			s.mu.Unlock()
+			from, to = "", nil
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// received returns the messages received so far, by recipient
+func (s *fakeSMTP) received() map[string]receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byRecipient := make(map[string]receivedMail)
+	for _, message := range s.messages {
+		for _, recipient := range message.To {
+			byRecipient[recipient] = message
+		}
+	}
+	return byRecipient
+}
+
+// refuse makes the server reject a recipient, or accept it again
+func (s *fakeSMTP) refuse(recipient string, refused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refused == nil {
+		s.refused = make(map[string]bool)
+	}
+	s.refused[recipient] = refused
+}
+
+func (s *fakeSMTP) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+func parseMail(t *testing.T, data []byte) receivedMail {
+	message, err := netmail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	require.NoError(t, err)
+	received := receivedMail{Subject: subject, Attachments: make(map[string][]byte)}
+	walkParts(t, message.Header.Get("Content-Type"), message.Body, &received)
+	return received
+}
+
+// walkParts decodes the bodies and attachments of a multipart entity into received
+func walkParts(t *testing.T, contentType string, body io.Reader, received *receivedMail) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(mediaType, "multipart/"), "unexpected %s", mediaType)
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		partType := part.Header.Get("Content-Type")
+		if strings.HasPrefix(partType, "multipart/") {
+			walkParts(t, partType, part, received)
+			continue
+		}
+		// The multipart reader decodes quoted-printable parts itself
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		if part.Header.Get("Content-Transfer-Encoding") == "base64" {
+			content, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(string(content), "\r\n", ""))
+			require.NoError(t, err)
+		}
+		switch {
+		case part.FileName() != "":
+			received.Attachments[part.FileName()] = content
+		case strings.HasPrefix(partType, "text/html"):
+			received.HTML = string(content)
+		default:
+			received.Text = string(content)
+		}
+	}
+}
+
+// notificationFixture wires notifications to a fake SMTP server on an in-memory store
+type notificationFixture struct {
+	store          *repository.Store
+	events         *services.EventService
+	availabilities *services.AvailabilityService
+	invites        *services.InviteService
+	notifications  *services.NotificationService
+	worker         *services.NotificationWorker
+	smtp           *fakeSMTP
+}
+
+func newNotificationFixture(t *testing.T, templatesDir string) *notificationFixture {
+	store := repository.NewMemoryStore()
+	events := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	availabilities := services.NewAvailabilityService(store.Availabilities, store.Events, events)
+	invites := services.NewInviteService(store.Invites, testInviteSecret, 24*time.Hour)
+	calendars := services.NewCalendarService(events, "example.com")
+	smtp := newFakeSMTP(t)
+	notifications, err := services.NewNotificationService(events, calendars, mail.NewSMTPSender(smtp.config()), services.NotificationOptions{
+		From:         "Meeting Scheduler <scheduler@example.com>",
+		BaseURL:      "https://scheduler.example.com/",
+		EmailDomain:  "example.com",
+		TemplatesDir: templatesDir,
+	})
+	require.NoError(t, err)
+	events.Changes().Subscribe(notifications)
+	invites.SetNotifier(notifications)
+
+	return &notificationFixture{
+		store:          store,
+		events:         events,
+		availabilities: availabilities,
+		invites:        invites,
+		notifications:  notifications,
+		worker:         services.NewNotificationWorker(notifications),
+		smtp:           smtp,
+	}
+}
+
+func (f *notificationFixture) createEvent(t *testing.T) *models.Event {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	deadline := start.Add(-2 * time.Hour)
+	event := &models.Event{
+		ID:          uuid.New().String(),
+		Title:       "Design review",
+		Description: "Walk through the storage redesign",
+		Duration:    60,
+		Status:      models.EventStatusPolling,
+		TimeSlots:   []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "Europe/London"}},
+		Participants: []models.EventParticipant{
+			{UserID: "alice", Required: true}, {UserID: "bob"}, {UserID: "carol@example.org"},
+		},
+		Deadline:  &deadline,
+		CreatedBy: "alice",
+		CreatedAt: start.Add(-48 * time.Hour),
+		UpdatedAt: start.Add(-48 * time.Hour),
+	}
+	require.NoError(t, f.events.Create(event))
+	return event
+}
+
+func TestNotificationEmails(t *testing.T) {
+	f := newNotificationFixture(t, "")
+	event := f.createEvent(t)
+	link := "https://scheduler.example.com/events/" + event.ID
+
+	// Participants but the organizer are invited once the event collects availability
+	f.worker.ProcessQueued(context.Background())
+	received := f.smtp.received()
+	require.Len(t, received, 2)
+	invitation := received["bob@example.com"]
+	assert.Equal(t, "scheduler@example.com", invitation.From)
+	assert.Equal(t, "When can you meet? Design review", invitation.Subject)
+	assert.Contains(t, invitation.Text, "Hello bob,")
+	assert.Contains(t, invitation.Text, "Walk through the storage redesign")
+	assert.Contains(t, invitation.Text, "Please respond before Monday 7 January 2030, 07:00 GMT.")
+	assert.Contains(t, invitation.Text, link)
+	assert.Contains(t, invitation.HTML, "<strong>Design review</strong>")
+	assert.Contains(t, invitation.HTML, `<a href="`+link+`">`)
+	assert.Contains(t, received, "carol@example.org")
+	assert.Contains(t, f.smtp.logins, "mailer:s3cret")
+
+	// Guests invited by email get a link carrying their invite token
+	f.smtp.reset()
+	_, token, err := f.invites.Create(event.ID, "alice", models.CreateInviteRequest{Name: "Dana", Email: "dana@example.net"})
+	require.NoError(t, err)
+	_, _, err = f.invites.Create(event.ID, "alice", models.CreateInviteRequest{})
+	require.NoError(t, err)
+	f.worker.ProcessQueued(context.Background())
+	received = f.smtp.received()
+	require.Len(t, received, 1, "open invites have nobody to email")
+	assert.Contains(t, received["dana@example.net"].Text, "Hello Dana,")
+	assert.Contains(t, received["dana@example.net"].Text, link+"?invite="+token)
+
+	// Reminders are sent on demand
+	f.smtp.reset()
+	require.NoError(t, f.notifications.Remind(context.Background(), event, f.notifications.Participants(event, "alice", "bob")))
+	received = f.smtp.received()
+	require.Len(t, received, 1)
+	assert.Equal(t, "Reminder: when can you meet? Design review", received["carol@example.org"].Subject)
+	assert.Contains(t, received["carol@example.org"].Text, "Responses close Monday 7 January 2030, 07:00 GMT.")
+
+	// Everyone but the organizer who chose the time gets it with a calendar file
+	f.smtp.reset()
+	_, err = f.availabilities.Submit(event.ID, "alice", event.TimeSlots)
+	require.NoError(t, err)
+	_, err = f.events.Finalize(event.ID, event.TimeSlots[0].StartTime.Add(time.Hour), "alice")
+	require.NoError(t, err)
+	f.worker.ProcessQueued(context.Background())
+	received = f.smtp.received()
+	require.Len(t, received, 2)
+	announcement := received["bob@example.com"]
+	assert.Equal(t, "Meeting scheduled: Design review", announcement.Subject)
+	assert.Contains(t, announcement.Text, "is scheduled for Monday 7 January 2030, 10:00 UTC to 11:00 UTC.")
+	calendar := string(announcement.Attachments["meeting.ics"])
+	assert.Contains(t, calendar, "BEGIN:VCALENDAR")
+	assert.Contains(t, calendar, "STATUS:CONFIRMED")
+	assert.Contains(t, calendar, "UID:"+event.ID+"@meeting-scheduler")
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invitation.subject.tmpl"),
+		[]byte(`[{{.Organizer}}] {{.Event.Title}}`), 0o600))
+	f := newNotificationFixture(t, dir)
+	f.createEvent(t)
+	f.worker.ProcessQueued(context.Background())
+
+	invitation := f.smtp.received()["bob@example.com"]
+	assert.Equal(t, "[alice] Design review", invitation.Subject, "templates in the directory replace the built-in ones")
+	assert.Contains(t, invitation.Text, "Hello bob,", "other templates stay built in")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reminder.html.tmpl"), []byte(`{{.Event.Title`), 0o600))
+	_, err := services.NewNotificationService(nil, nil, nil, services.NotificationOptions{TemplatesDir: dir})
+	assert.ErrorContains(t, err, "reminder.html.tmpl")
+}
+
+func TestMailMessageEncoding(t *testing.T) {
+	msg := &mail.Message{
+		From:    "scheduler@example.com",
+		To:      []string{"Zoë <zoe@example.com>"},
+		Subject: "Réunion à 10:00",
+		Text:    strings.Repeat("A long line that needs soft breaks. ", 5),
+		Attachments: []mail.Attachment{
+			{Filename: "notes.bin", ContentType: "application/octet-stream", Data: []byte{0, 1, 2, 0xff}},
+		},
+	}
+	data, err := msg.Bytes(time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	for _, line := range strings.Split(string(data), "\r\n") {
+		assert.LessOrEqual(t, len(line), 998)
+		for _, c := range []byte(line) {
+			require.Less(t, c, byte(0x80), "messages are 7-bit clean")
+		}
+	}
+
+	received := parseMail(t, data)
+	assert.Equal(t, "Réunion à 10:00", received.Subject)
+	assert.Equal(t, msg.Text, received.Text)
+	assert.Empty(t, received.HTML)
+	assert.Equal(t, []byte{0, 1, 2, 0xff}, received.Attachments["notes.bin"])
+
+	_, err = (&mail.Message{From: "scheduler@example.com", To: []string{"not an address"}}).Bytes(time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withPreference(s models.TimeSlot, level models.PreferenceLevel) models.TimeSlot {
+	s.Preference = level
+	return s
+}
+
+func TestFindOptimalTimeSlotsRanksByPreference(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour))
+
+	event := &models.Event{
+		ID:        "test-event",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{slot(9, 0, 13, 0)},
+	}
+
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{
+			withPreference(slot(9, 0, 10, 0), models.PreferenceIfNeeded),
+			slot(10, 0, 11, 0),
+			withPreference(slot(11, 0, 13, 0), models.PreferencePreferred),
+		}},
+		{UserID: "user-2", TimeSlots: []models.TimeSlot{
+			withPreference(slot(9, 0, 11, 0), models.PreferenceIfNeeded),
+			withPreference(slot(11, 0, 12, 0), models.PreferencePreferred),
+			withPreference(slot(12, 0, 13, 0), models.PreferenceAvailable),
+		}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.Len(t, recommendations, 4)
+
+	// Everyone attends every slot, so preference decides the order
+	expected := []struct {
+		hour  int
+		score float64
+	}{
+		{11, 3},   // preferred + preferred
+		{12, 2.5}, // preferred + available
+		{10, 1.5}, // available + if needed
+		{9, 1},    // if needed + if needed
+	}
+	for i, want := range expected {
+		assert.Equal(t, time.Date(2024, 1, 1, want.hour, 0, 0, 0, time.UTC), recommendations[i].TimeSlot.StartTime)
+		assert.Equal(t, want.score, recommendations[i].Score)
+		assert.Len(t, recommendations[i].Participants, 2)
+	}
+	assert.Equal(t, 2, recommendations[0].Breakdown.PreferredAttendees)
+	assert.Equal(t, 2, recommendations[3].Breakdown.IfNeededAttendees)
+}
+
+func TestFindOptimalTimeSlotsMixedPreferenceUsesWeakestLevel(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(30 * time.Minute))
+
+	event := &models.Event{
+		ID:        "test-event",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{slot(9, 0, 11, 0)},
+	}
+
+	// A meeting spanning a preferred and an if-needed slot is only "if needed" overall
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{
+			withPreference(slot(9, 0, 10, 0), models.PreferencePreferred),
+			withPreference(slot(10, 0, 11, 0), models.PreferenceIfNeeded),
+		}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.Len(t, recommendations, 3)
+
+	// Ties are broken by earliest start
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), recommendations[0].TimeSlot.StartTime)
+	assert.Equal(t, 1.5, recommendations[0].Score)
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC), recommendations[1].TimeSlot.StartTime)
+	assert.Equal(t, 0.5, recommendations[1].Score)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), recommendations[2].TimeSlot.StartTime)
+	assert.Equal(t, 0.5, recommendations[2].Score)
+}
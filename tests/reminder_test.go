@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remindableEvent creates a polling event of alice with bob and carol responding before deadline
+func (f *notificationFixture) remindableEvent(t *testing.T, deadline time.Time) *models.Event {
+	start := deadline.Add(24 * time.Hour).Truncate(time.Hour)
+	event := &models.Event{
+		ID:        uuid.New().String(),
+		Title:     "Design review",
+		Duration:  60,
+		Status:    models.EventStatusPolling,
+		TimeSlots: []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}},
+		Participants: []models.EventParticipant{
+			{UserID: "alice", Required: true}, {UserID: "bob"}, {UserID: "carol@example.org"},
+		},
+		Deadline:  &deadline,
+		CreatedBy: "alice",
+		CreatedAt: time.Now().Add(-time.Hour),
+		UpdatedAt: time.Now().Add(-time.Hour),
+	}
+	require.NoError(t, f.events.Create(event))
+	return event
+}
+
+func TestReminders(t *testing.T) {
+	ctx := context.Background()
+	f := newNotificationFixture(t, "")
+	offsets := []time.Duration{4 * time.Hour, 48 * time.Hour}
+	reminders := services.NewReminderService(f.events, f.invites, f.store.Reminders, f.notifications, offsets)
+
+	// Both reminders are due, only the one closest to the deadline is sent
+	soon := f.remindableEvent(t, time.Now().Add(3*time.Hour))
+	later := f.remindableEvent(t, time.Now().Add(30*time.Hour))
+	notYet := f.remindableEvent(t, time.Now().Add(72*time.Hour))
+	due, err := reminders.DueEventIDs()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{soon.ID, later.ID}, due)
+
+	_, err = f.availabilities.Submit(soon.ID, "bob", soon.TimeSlots)
+	require.NoError(t, err)
+	_, token, err := f.invites.Create(soon.ID, "alice", models.CreateInviteRequest{Name: "Dana", Email: "dana@example.net"})
+	require.NoError(t, err)
+	_, _, err = f.invites.Create(soon.ID, "alice", models.CreateInviteRequest{})
+	require.NoError(t, err)
+	revoked, _, err := f.invites.Create(soon.ID, "alice", models.CreateInviteRequest{Name: "Erin", Email: "erin@example.net"})
+	require.NoError(t, err)
+	require.NoError(t, f.invites.Revoke(soon.ID, revoked.ID))
+
+	// Only those who have not responded are reminded, guests with a link to their invite
+	sent, err := reminders.Remind(ctx, soon.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	received := f.smtp.received()
+	require.Len(t, received, 2)
+	assert.Equal(t, "Reminder: when can you meet? Design review", received["carol@example.org"].Subject)
+	assert.Contains(t, received["dana@example.net"].Text, "?invite="+token)
+
+	claimed, err := f.store.Reminders.ClaimReminder(&models.EventReminder{
+		EventID: soon.ID, Offset: 48 * time.Hour, UserID: "carol@example.org", SentAt: time.Now(),
+	})
+	require.NoError(t, err)
+	assert.True(t, claimed, "earlier reminders are skipped once a later one is due")
+
+	// Reminders already sent are not sent again, even by a restarted server
+	restarted := services.NewReminderService(f.events, f.invites, f.store.Reminders, f.notifications, offsets)
+	sent, err = restarted.Remind(ctx, soon.ID)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	sent, err = restarted.Remind(ctx, notYet.ID)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "no reminder is due yet")
+
+	// Reminders that could not be sent are tried again
+	f.smtp.reset()
+	f.smtp.refuse("carol@example.org", true)
+	worker := services.NewReminderWorker(restarted, time.Minute)
+	worker.ProcessDue(ctx)
+	received = f.smtp.received()
+	require.Len(t, received, 1)
+	assert.Contains(t, received, "bob@example.com")
+	assert.Equal(t, "Reminder: when can you meet? Design review", received["bob@example.com"].Subject)
+
+	f.smtp.reset()
+	f.smtp.refuse("carol@example.org", false)
+	worker.ProcessDue(ctx)
+	worker.ProcessDue(ctx)
+	received = f.smtp.received()
+	require.Len(t, received, 1)
+	assert.Contains(t, received, "carol@example.org")
+}
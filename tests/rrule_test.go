@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRRuleOccurrences(t *testing.T) {
+	dtstart := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC) // Monday
+
+	tests := []struct {
+		name     string
+		rrule    string
+		exdates  []time.Time
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "weekly on two days with count",
+			rrule:    "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			expected: []string{"2024-01-01", "2024-01-03", "2024-01-08", "2024-01-10"},
+		},
+		{
+			name:     "biweekly",
+			rrule:    "FREQ=WEEKLY;INTERVAL=2",
+			limit:    3,
+			expected: []string{"2024-01-01", "2024-01-15", "2024-01-29"},
+		},
+		{
+			name:     "daily until",
+			rrule:    "FREQ=DAILY;UNTIL=20240103T100000Z",
+			expected: []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:     "exdates are removed after count",
+			rrule:    "RRULE:FREQ=WEEKLY;COUNT=3",
+			exdates:  []time.Time{time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+			expected: []string{"2024-01-01", "2024-01-15"},
+		},
+		{
+			name:     "last friday of the month",
+			rrule:    "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			expected: []string{"2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name:     "monthly on a day missing from some months",
+			rrule:    "FREQ=MONTHLY;COUNT=3",
+			expected: []string{"2024-01-01", "2024-02-01", "2024-03-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := services.ParseRRule(tt.rrule, time.UTC)
+			require.NoError(t, err)
+
+			occurrences := rule.Occurrences(dtstart, tt.exdates, tt.limit)
+			dates := make([]string, len(occurrences))
+			for i, occurrence := range occurrences {
+				dates[i] = occurrence.Format("2006-01-02")
+				assert.Equal(t, 10, occurrence.Hour())
+			}
+			assert.Equal(t, tt.expected, dates)
+		})
+	}
+}
+
+func TestRRuleOccurrencesKeepLocalTimeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// DST starts on 2024-03-10 in New York
+	rule, err := services.ParseRRule("FREQ=WEEKLY;COUNT=3", loc)
+	require.NoError(t, err)
+	occurrences := rule.Occurrences(time.Date(2024, 3, 3, 9, 30, 0, 0, loc), nil, 0)
+
+	require.Len(t, occurrences, 3)
+	for _, occurrence := range occurrences {
+		assert.Equal(t, 9, occurrence.Hour())
+		assert.Equal(t, 30, occurrence.Minute())
+	}
+	assert.Equal(t, 14, occurrences[0].UTC().Hour())
+	assert.Equal(t, 13, occurrences[1].UTC().Hour())
+}
+
+func TestParseRRuleRejectsInvalidRules(t *testing.T) {
+	for _, rrule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;COUNT=2;UNTIL=20240101T000000Z",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+	} {
+		_, err := services.ParseRRule(rrule, time.UTC)
+		assert.Error(t, err, rrule)
+	}
+}
+
+func TestFindOptimalTimeSlotsRecurringMajority(t *testing.T) {
+	now := time.Date(2023, 12, 29, 12, 0, 0, 0, time.UTC)
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour), services.WithOccurrenceHorizon(3),
+		services.WithClock(func() time.Time { return now }))
+
+	// Weekly on Mondays, 2024-01-01, 2024-01-08 and 2024-01-15
+	event := &models.Event{
+		ID:         "weekly-sync",
+		Duration:   60,
+		TimeSlots:  []models.TimeSlot{slot(9, 0, 11, 0)},
+		Recurrence: &models.Recurrence{RRule: "FREQ=WEEKLY;COUNT=10"},
+	}
+
+	week := func(weeks int, s models.TimeSlot) models.TimeSlot {
+		s.StartTime = s.StartTime.AddDate(0, 0, 7*weeks)
+		s.EndTime = s.EndTime.AddDate(0, 0, 7*weeks)
+		return s
+	}
+	participantAvailabilities := []models.ParticipantAvailability{
+		// Free at 09:00 on two of three Mondays and at 10:00 only once
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{
+			slot(9, 0, 10, 0), week(1, slot(9, 0, 11, 0)),
+		}},
+		// Free at 10:00 every week
+		{UserID: "user-2", TimeSlots: []models.TimeSlot{
+			slot(10, 0, 11, 0), week(1, slot(10, 0, 11, 0)), week(2, slot(10, 0, 11, 0)),
+		}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 2)
+
+	for _, rec := range recommendations {
+		assert.Len(t, rec.Occurrences, 3)
+		assert.Equal(t, 1.0, rec.Score)
+	}
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), recommendations[0].TimeSlot.StartTime)
+	assert.Equal(t, []string{"user-1"}, recommendations[0].Participants)
+	assert.Equal(t, map[string]int{"user-1": 2}, recommendations[0].Attendance)
+
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), recommendations[1].TimeSlot.StartTime)
+	assert.Equal(t, []string{"user-2"}, recommendations[1].Participants)
+	assert.Equal(t, map[string]int{"user-1": 1, "user-2": 3}, recommendations[1].Attendance)
+}
+
+func TestFindOptimalTimeSlotsRecurringFromNow(t *testing.T) {
+	now := time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC)
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour), services.WithOccurrenceHorizon(2),
+		services.WithClock(func() time.Time { return now }))
+
+	// Weekly on Mondays from 2024-01-01, of which 2024-01-15 and 2024-01-22 are still to come
+	event := &models.Event{
+		ID:         "weekly-sync",
+		Duration:   60,
+		TimeSlots:  []models.TimeSlot{slot(9, 0, 10, 0)},
+		Recurrence: &models.Recurrence{RRule: "FREQ=WEEKLY;COUNT=4"},
+	}
+	week := func(weeks int) models.TimeSlot {
+		s := slot(9, 0, 10, 0)
+		s.StartTime = s.StartTime.AddDate(0, 0, 7*weeks)
+		s.EndTime = s.EndTime.AddDate(0, 0, 7*weeks)
+		return s
+	}
+	participantAvailabilities := []models.ParticipantAvailability{
+		// Free on the two Mondays that passed only
+		{UserID: "user-1", TimeSlots: []models.TimeSlot{week(0), week(1)}},
+		// Free on the two Mondays to come
+		{UserID: "user-2", TimeSlots: []models.TimeSlot{week(2), week(3)}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	require.Len(t, recommendations, 1)
+	assert.Equal(t, []time.Time{week(2).StartTime, week(3).StartTime}, recommendations[0].Occurrences)
+	assert.Equal(t, []string{"user-2"}, recommendations[0].Participants)
+
+	// Nothing is left to schedule once every occurrence passed
+	now = week(4).StartTime
+	recommendations, err = scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.Empty(t, recommendations)
+
+	// Rules that cannot be expanded are reported rather than scheduled as a single meeting
+	event.Recurrence.RRule = "FREQ=HOURLY"
+	_, err = scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	assert.ErrorIs(t, err, services.ErrInvalidRecurrence)
+}
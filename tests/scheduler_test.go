@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/shani34/meeting-scheduler/api/models"
 	"github.com/shani34/meeting-scheduler/api/services"
 )
@@ -55,20 +56,87 @@ func TestFindOptimalTimeSlots(t *testing.T) {
 	}
 
 	// Test finding optimal time slots
-	recommendations := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
 
 	// Assertions
 	assert.NotNil(t, recommendations)
-	assert.Greater(t, len(recommendations), 0)
+	assert.Len(t, recommendations, 2)
 
-	// Check if the recommended slot has the correct participants
+	// Each recommendation is a full-length slot that its participants can attend entirely
 	for _, rec := range recommendations {
-		assert.Contains(t, rec.Participants, "user-1")
-		assert.Contains(t, rec.Participants, "user-2")
+		assert.Equal(t, time.Hour, rec.TimeSlot.EndTime.Sub(rec.TimeSlot.StartTime))
+		assert.Len(t, rec.Participants, 1)
+		assert.Len(t, rec.MissingUsers, 1)
 	}
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), recommendations[0].TimeSlot.StartTime)
+	assert.Equal(t, []string{"user-1"}, recommendations[0].Participants)
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), recommendations[1].TimeSlot.StartTime)
+	assert.Equal(t, []string{"user-2"}, recommendations[1].Participants)
 }
 
-func TestTimeSlotOverlap(t *testing.T) {
+func TestFindOptimalTimeSlotsSlidesDurationWindow(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(30 * time.Minute))
+
+	event := &models.Event{
+		ID:       "test-event",
+		Title:    "Short Sync",
+		Duration: 30,
+		TimeSlots: []models.TimeSlot{
+			{
+				StartTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				EndTime:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				TimeZone:  "UTC",
+			},
+		},
+	}
+
+	participantAvailabilities := []models.ParticipantAvailability{
+		{
+			UserID: "user-1",
+			TimeSlots: []models.TimeSlot{
+				{
+					StartTime: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+					EndTime:   time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+					TimeZone:  "UTC",
+				},
+				{
+					StartTime: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+					EndTime:   time.Date(2024, 1, 1, 11, 30, 0, 0, time.UTC),
+					TimeZone:  "UTC",
+				},
+			},
+		},
+		{
+			UserID: "user-2",
+			TimeSlots: []models.TimeSlot{
+				{
+					StartTime: time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC),
+					EndTime:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+					TimeZone:  "UTC",
+				},
+			},
+		},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+
+	// 10:00, 10:30, 11:00 work for user-1; 11:00, 11:30 work for user-2
+	assert.Len(t, recommendations, 4)
+	best := recommendations[0]
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), best.TimeSlot.StartTime)
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 30, 0, 0, time.UTC), best.TimeSlot.EndTime)
+	assert.ElementsMatch(t, []string{"user-1", "user-2"}, best.Participants)
+	assert.Equal(t, 2.0, best.Score)
+
+	for _, rec := range recommendations[1:] {
+		assert.Equal(t, 1.0, rec.Score)
+		assert.Equal(t, 30*time.Minute, rec.TimeSlot.EndTime.Sub(rec.TimeSlot.StartTime))
+	}
+}
+
+func TestFindOptimalTimeSlotsCountsOverlappingAvailabilityOnly(t *testing.T) {
 	scheduler := services.NewSchedulerService()
 
 	slot1 := models.TimeSlot{
@@ -83,14 +151,27 @@ func TestTimeSlotOverlap(t *testing.T) {
 		TimeZone:  "UTC",
 	}
 
-	// Test overlapping slots
-	assert.True(t, scheduler.IsOverlapping(slot1, slot2))
+	event := &models.Event{ID: "test-event", Duration: 60, TimeSlots: []models.TimeSlot{slot1}}
+	attendees := func(slot models.TimeSlot) [][]string {
+		recommendations, err := scheduler.FindOptimalTimeSlots(event, []models.ParticipantAvailability{
+			{ID: "availability-1", EventID: "test-event", UserID: "user-1", TimeSlots: []models.TimeSlot{slot}},
+		})
+		require.NoError(t, err)
+		participants := make([][]string, 0, len(recommendations))
+		for _, rec := range recommendations {
+			participants = append(participants, rec.Participants)
+		}
+		return participants
+	}
 
-	// Test non-overlapping slots
+	// Availability overlapping the event window by the duration makes the user an attendee
+	assert.Contains(t, attendees(slot2), []string{"user-1"})
+
+	// Availability outside the window does not
 	slot3 := models.TimeSlot{
 		StartTime: time.Date(2024, 1, 1, 13, 0, 0, 0, time.UTC),
 		EndTime:   time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC),
 		TimeZone:  "UTC",
 	}
-	assert.False(t, scheduler.IsOverlapping(slot1, slot3))
-} 
\ No newline at end of file
+	assert.NotContains(t, attendees(slot3), []string{"user-1"})
+}
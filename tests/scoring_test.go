@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// weight returns the weight of an optional participant
+func weight(w float64) *float64 {
+	return &w
+}
+
+func TestFindOptimalTimeSlotsRequiredAndWeightedAttendees(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour))
+
+	event := &models.Event{
+		ID:        "test-event",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{slot(9, 0, 13, 0)},
+		Participants: []models.EventParticipant{
+			{UserID: "lead", Required: true},
+			{UserID: "designer", Weight: weight(3)},
+			{UserID: "intern", Weight: weight(0.5)},
+		},
+	}
+
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "lead", TimeSlots: []models.TimeSlot{slot(10, 0, 13, 0)}},
+		{UserID: "designer", TimeSlots: []models.TimeSlot{slot(9, 0, 11, 0)}},
+		{UserID: "intern", TimeSlots: []models.TimeSlot{slot(9, 0, 10, 0), slot(11, 0, 13, 0)}},
+		{UserID: "guest", TimeSlots: []models.TimeSlot{slot(11, 0, 12, 0)}},
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+
+	// 09:00 is dropped because the required lead is busy
+	assert.Len(t, recommendations, 3)
+	for _, rec := range recommendations {
+		assert.Contains(t, rec.Participants, "lead")
+		assert.Equal(t, 1, rec.Breakdown.RequiredAttendees)
+	}
+
+	// 10:00 lead + designer (3), 11:00 lead + intern + unlisted guest (0.5 + 1), 12:00 lead + intern (0.5)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), recommendations[0].TimeSlot.StartTime)
+	assert.Equal(t, 4.0, recommendations[0].Score)
+	assert.Equal(t, models.ScoreBreakdown{RequiredAttendees: 1, OptionalAttendees: 1, OptionalWeight: 3}, recommendations[0].Breakdown)
+
+	assert.Equal(t, time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), recommendations[1].TimeSlot.StartTime)
+	assert.Equal(t, 2.5, recommendations[1].Score)
+	assert.Equal(t, 2, recommendations[1].Breakdown.OptionalAttendees)
+
+	assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), recommendations[2].TimeSlot.StartTime)
+	assert.Equal(t, 1.5, recommendations[2].Score)
+	assert.ElementsMatch(t, []string{"designer", "guest"}, recommendations[2].MissingUsers)
+}
+
+func TestFindOptimalTimeSlotsRequiredParticipantWithoutResponse(t *testing.T) {
+	scheduler := services.NewSchedulerService()
+
+	event := &models.Event{
+		ID:        "test-event",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{slot(9, 0, 12, 0)},
+		Participants: []models.EventParticipant{
+			{UserID: "lead", Required: true},
+			{UserID: "optional"},
+		},
+	}
+
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "optional", TimeSlots: []models.TimeSlot{slot(9, 0, 12, 0)}},
+	}
+
+	// No slot is valid until the required participant responds
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.Empty(t, recommendations)
+
+	// Optional participants that have not responded are reported as missing
+	event.Participants[0].Required = false
+	recommendations, err = scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.NotEmpty(t, recommendations)
+	assert.Equal(t, []string{"lead"}, recommendations[0].MissingUsers)
+}
+
+func TestFindOptimalTimeSlotsZeroWeight(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour))
+
+	// An explicit weight of 0 is kept apart from an omitted one
+	var participants []models.EventParticipant
+	assert.NoError(t, json.Unmarshal([]byte(`[{"user_id": "lead", "required": true}, {"user_id": "observer", "weight": 0}, {"user_id": "peer"}]`), &participants))
+	event := &models.Event{
+		ID:           "test-event",
+		Duration:     60,
+		TimeSlots:    []models.TimeSlot{slot(9, 0, 11, 0)},
+		Participants: participants,
+	}
+
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "lead", TimeSlots: []models.TimeSlot{slot(9, 0, 11, 0)}},
+		{UserID: "observer", TimeSlots: []models.TimeSlot{slot(9, 0, 10, 0)}},
+		{UserID: "peer", TimeSlots: []models.TimeSlot{slot(10, 0, 11, 0)}},
+	}
+
+	// Participants weighted 0 attend without counting towards the score
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities)
+	require.NoError(t, err)
+	assert.Len(t, recommendations, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), recommendations[0].TimeSlot.StartTime)
+	assert.Equal(t, 2.0, recommendations[0].Score)
+	assert.Equal(t, 1.0, recommendations[1].Score)
+	assert.Equal(t, models.ScoreBreakdown{RequiredAttendees: 1, OptionalAttendees: 1}, recommendations[1].Breakdown)
+}
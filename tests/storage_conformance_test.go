@@ -0,0 +1,778 @@
+package tests
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storageBackends opens a fresh store of every backend. PostgreSQL is only included when
+// TEST_POSTGRES_DSN points at a migrated database.
+func storageBackends(t *testing.T) map[string]func(t *testing.T) *repository.Store {
+	backends := map[string]func(t *testing.T) *repository.Store{
+		"memory": func(t *testing.T) *repository.Store {
+			return repository.NewMemoryStore()
+		},
+		"sqlite": func(t *testing.T) *repository.Store {
+			store, err := repository.OpenSQLite(filepath.Join(t.TempDir(), "conformance.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) *repository.Store {
+			db, err := sql.Open("postgres", dsn)
+			require.NoError(t, err)
+			store := repository.NewSQLStore(db)
+			t.Cleanup(func() { store.Close() })
+			return store
+		}
+	}
+	return backends
+}
+
+// TestStorageConformance runs the same behavioural checks against every storage backend
+func TestStorageConformance(t *testing.T) {
+	checks := map[string]func(t *testing.T, store *repository.Store){
+		"event round trip":                   checkEventRoundTrip,
+		"missing records":                    checkMissingRecords,
+		"update replaces slots and roles":    checkUpdateEventReplaces,
+		"status and finalization":            checkUpdateEventStatus,
+		"due deadlines":                      checkDueDeadlines,
+		"availability upsert":                checkAvailabilityUpsert,
+		"availability update and delete":     checkAvailabilityUpdateDelete,
+		"deleting an event":                  checkDeleteEvent,
+		"working hours":                      checkWorkingHours,
+		"availabilities of different events": checkAvailabilitiesPerEvent,
+		"event roles":                        checkEventRoles,
+		"api keys":                           checkAPIKeys,
+		"invites and guest availability":     checkInvites,
+		"guest responses":                    checkGuestResponses,
+		"calendar connections":               checkCalendarConnections,
+		"webhooks":                           checkWebhooks,
+		"reminders":                          checkReminders,
+		"revisions":                          checkRevisions,
+	}
+
+	for backend, open := range storageBackends(t) {
+		for name, check := range checks {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				check(t, open(t))
+			})
+		}
+	}
+}
+
+var conformanceStart = time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+
+func conformanceEvent() *models.Event {
+	deadline := conformanceStart.Add(-24 * time.Hour)
+	return &models.Event{
+		ID:          uuid.New().String(),
+		Title:       "Quarterly planning",
+		Description: "Goals for the next quarter",
+		Duration:    60,
+		TimeSlots: []models.TimeSlot{
+			{StartTime: conformanceStart, EndTime: conformanceStart.Add(2 * time.Hour), TimeZone: "Europe/London"},
+			{StartTime: conformanceStart.Add(24 * time.Hour), EndTime: conformanceStart.Add(26 * time.Hour), TimeZone: "Europe/London"},
+		},
+		Participants: []models.EventParticipant{
+			{UserID: "alice", Required: true},
+			{UserID: "bob", Weight: weight(0.5)},
+		},
+		Recurrence: &models.Recurrence{
+			RRule:    "FREQ=WEEKLY;COUNT=4",
+			ExDates:  []time.Time{conformanceStart.Add(14 * 24 * time.Hour)},
+			TimeZone: "Europe/London",
+		},
+		Status:    models.EventStatusPolling,
+		Deadline:  &deadline,
+		CreatedBy: "alice",
+		CreatedAt: conformanceStart.Add(-72 * time.Hour),
+		UpdatedAt: conformanceStart.Add(-72 * time.Hour),
+	}
+}
+
+func conformanceAvailability(eventID, userID string, hours ...int) *models.ParticipantAvailability {
+	availability := &models.ParticipantAvailability{
+		ID:        uuid.New().String(),
+		EventID:   eventID,
+		UserID:    userID,
+		CreatedAt: conformanceStart.Add(-48 * time.Hour),
+		UpdatedAt: conformanceStart.Add(-48 * time.Hour),
+	}
+	for _, hour := range hours {
+		start := conformanceStart.Add(time.Duration(hour) * time.Hour)
+		availability.TimeSlots = append(availability.TimeSlots, models.TimeSlot{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			TimeZone:  "UTC",
+		})
+	}
+	return availability
+}
+
+func assertSameInstant(t *testing.T, expected, actual time.Time) {
+	t.Helper()
+	assert.True(t, expected.Equal(actual), "expected %s, got %s", expected, actual)
+}
+
+func checkEventRoundTrip(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	stored, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, event.Title, stored.Title)
+	assert.Equal(t, event.Description, stored.Description)
+	assert.Equal(t, event.Duration, stored.Duration)
+	assert.Equal(t, event.Status, stored.Status)
+	assert.Equal(t, event.CreatedBy, stored.CreatedBy)
+	assertSameInstant(t, event.CreatedAt, stored.CreatedAt)
+	require.NotNil(t, stored.Deadline)
+	assertSameInstant(t, *event.Deadline, *stored.Deadline)
+
+	require.Len(t, stored.TimeSlots, 2)
+	for i, slot := range event.TimeSlots {
+		assertSameInstant(t, slot.StartTime, stored.TimeSlots[i].StartTime)
+		assertSameInstant(t, slot.EndTime, stored.TimeSlots[i].EndTime)
+		assert.Equal(t, slot.TimeZone, stored.TimeSlots[i].TimeZone)
+	}
+	assert.ElementsMatch(t, event.Participants, stored.Participants)
+
+	require.NotNil(t, stored.Recurrence)
+	assert.Equal(t, event.Recurrence.RRule, stored.Recurrence.RRule)
+	assert.Equal(t, event.Recurrence.TimeZone, stored.Recurrence.TimeZone)
+	require.Len(t, stored.Recurrence.ExDates, 1)
+	assertSameInstant(t, event.Recurrence.ExDates[0], stored.Recurrence.ExDates[0])
+	assert.Nil(t, stored.FinalSlot)
+}
+
+func checkMissingRecords(t *testing.T, store *repository.Store) {
+	_, err := store.Events.GetEvent(uuid.New().String())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.Events.UpdateEvent(conformanceEvent()), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Events.UpdateEventStatus(conformanceEvent(), models.EventStatusPolling), repository.ErrNotFound)
+
+	_, err = store.Availabilities.GetAvailability(uuid.New().String())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.Availabilities.UpdateAvailability(conformanceAvailability("event", "alice", 0)), repository.ErrNotFound)
+
+	_, err = store.WorkingHours.GetWorkingHours(uuid.New().String())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func checkUpdateEventReplaces(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	event.Title = "Rescheduled planning"
+	event.TimeSlots = event.TimeSlots[1:]
+	event.Participants = []models.EventParticipant{{UserID: "carol", Required: true, Weight: weight(1)}}
+	event.Recurrence = nil
+	event.Deadline = nil
+	require.NoError(t, store.Events.UpdateEvent(event))
+
+	stored, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Rescheduled planning", stored.Title)
+	require.Len(t, stored.TimeSlots, 1)
+	assertSameInstant(t, event.TimeSlots[0].StartTime, stored.TimeSlots[0].StartTime)
+	assert.Equal(t, event.Participants, stored.Participants)
+	assert.Nil(t, stored.Recurrence)
+	assert.Nil(t, stored.Deadline)
+}
+
+func checkUpdateEventStatus(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	finalizedAt := conformanceStart.Add(-time.Hour)
+	event.Status = models.EventStatusFinalized
+	event.FinalSlot = &models.TimeSlot{StartTime: conformanceStart, EndTime: conformanceStart.Add(time.Hour), TimeZone: "UTC"}
+	event.FinalizedBy = "alice"
+	event.FinalizedAt = &finalizedAt
+	event.UpdatedAt = finalizedAt
+	require.NoError(t, store.Events.UpdateEventStatus(event, models.EventStatusPolling))
+
+	stored, err := store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventStatusFinalized, stored.Status)
+	require.NotNil(t, stored.FinalSlot)
+	assertSameInstant(t, conformanceStart, stored.FinalSlot.StartTime)
+	assertSameInstant(t, conformanceStart.Add(time.Hour), stored.FinalSlot.EndTime)
+	assert.Equal(t, "alice", stored.FinalizedBy)
+	require.NotNil(t, stored.FinalizedAt)
+	assertSameInstant(t, finalizedAt, *stored.FinalizedAt)
+	assert.Len(t, stored.TimeSlots, 2, "changing the status keeps the time slots")
+
+	// Writes read from a status the event has left change nothing
+	cancelled := *event
+	cancelled.Status = models.EventStatusCancelled
+	assert.ErrorIs(t, store.Events.UpdateEventStatus(&cancelled, models.EventStatusPolling), repository.ErrStatusChanged)
+	edited := *event
+	edited.Status = models.EventStatusPolling
+	edited.Title = "Stale edit"
+	assert.ErrorIs(t, store.Events.UpdateEvent(&edited), repository.ErrStatusChanged)
+	stored, err = store.Events.GetEvent(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventStatusFinalized, stored.Status)
+	assert.Equal(t, event.Title, stored.Title)
+	assert.Equal(t, event.Revision, stored.Revision)
+}
+
+func checkDueDeadlines(t *testing.T, store *repository.Store) {
+	later, earlier, future := conformanceEvent(), conformanceEvent(), conformanceEvent()
+	laterDeadline := conformanceStart.Add(-time.Hour)
+	futureDeadline := conformanceStart.Add(time.Hour)
+	later.Deadline = &laterDeadline
+	future.Deadline = &futureDeadline
+	for _, event := range []*models.Event{later, earlier, future} {
+		require.NoError(t, store.Events.CreateEvent(event))
+	}
+
+	due, err := store.Events.ListDueEventIDs(conformanceStart)
+	require.NoError(t, err)
+	assert.Equal(t, []string{earlier.ID, later.ID}, due)
+
+	require.NoError(t, store.Events.MarkDeadlineProcessed(earlier.ID))
+	due, err = store.Events.ListDueEventIDs(conformanceStart)
+	require.NoError(t, err)
+	assert.Equal(t, []string{later.ID}, due)
+
+	// Editing an event re-arms its deadline
+	require.NoError(t, store.Events.UpdateEvent(earlier))
+	due, err = store.Events.ListDueEventIDs(conformanceStart)
+	require.NoError(t, err)
+	assert.Equal(t, []string{earlier.ID, later.ID}, due)
+}
+
+func checkAvailabilityUpsert(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	first := conformanceAvailability(event.ID, "alice", 0, 1)
+	first.TimeSlots[1].Preference = models.PreferencePreferred
+	first.Source = models.AvailabilitySourceCalendar
+	require.NoError(t, store.Availabilities.UpsertAvailability(first))
+	pulled, err := store.Availabilities.GetAvailability(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AvailabilitySourceCalendar, pulled.Source)
+
+	resubmitted := conformanceAvailability(event.ID, "alice", 3)
+	resubmitted.UpdatedAt = conformanceStart
+	require.NoError(t, store.Availabilities.UpsertAvailability(resubmitted))
+	assert.Equal(t, first.ID, resubmitted.ID, "resubmitting keeps the original availability")
+
+	availabilities, err := store.Events.GetParticipantAvailabilities(event.ID)
+	require.NoError(t, err)
+	require.Len(t, availabilities, 1)
+	assert.Equal(t, "alice", availabilities[0].UserID)
+	require.Len(t, availabilities[0].TimeSlots, 1)
+	assertSameInstant(t, conformanceStart.Add(3*time.Hour), availabilities[0].TimeSlots[0].StartTime)
+	assert.Equal(t, models.PreferenceAvailable, availabilities[0].TimeSlots[0].Preference)
+	assertSameInstant(t, conformanceStart, availabilities[0].UpdatedAt)
+	assert.Empty(t, availabilities[0].Source, "resubmitting replaces the source")
+}
+
+func checkAvailabilityUpdateDelete(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+	availability := conformanceAvailability(event.ID, "bob", 0)
+	availability.Source = models.AvailabilitySourceCalendar
+	require.NoError(t, store.Availabilities.CreateAvailability(availability))
+
+	availability.TimeSlots = conformanceAvailability(event.ID, "bob", 5, 2).TimeSlots
+	availability.TimeSlots[0].Preference = models.PreferenceIfNeeded
+	availability.Source = ""
+	require.NoError(t, store.Availabilities.UpdateAvailability(availability))
+
+	stored, err := store.Availabilities.GetAvailability(availability.ID)
+	require.NoError(t, err)
+	require.Len(t, stored.TimeSlots, 2)
+	assertSameInstant(t, conformanceStart.Add(2*time.Hour), stored.TimeSlots[0].StartTime)
+	assert.Equal(t, models.PreferenceAvailable, stored.TimeSlots[0].Preference)
+	assert.Equal(t, models.PreferenceIfNeeded, stored.TimeSlots[1].Preference)
+	assert.Empty(t, stored.Source)
+
+	require.NoError(t, store.Availabilities.DeleteAvailability(availability.ID))
+	_, err = store.Availabilities.GetAvailability(availability.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func checkDeleteEvent(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+	availability := conformanceAvailability(event.ID, "alice", 0)
+	require.NoError(t, store.Availabilities.CreateAvailability(availability))
+
+	require.NoError(t, store.Events.DeleteEvent(event.ID))
+
+	_, err := store.Events.GetEvent(event.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = store.Availabilities.GetAvailability(availability.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func checkWorkingHours(t *testing.T, store *repository.Store) {
+	alice := &models.WorkingHours{
+		UserID:    uuid.New().String(),
+		TimeZone:  "Asia/Singapore",
+		StartTime: "09:00",
+		EndTime:   "17:00",
+		WorkDays:  []int{1, 2, 3, 4, 5},
+		UpdatedAt: conformanceStart,
+	}
+	require.NoError(t, store.WorkingHours.UpsertWorkingHours(alice))
+	alice.StartTime = "08:00"
+	alice.WorkDays = []int{0, 1}
+	require.NoError(t, store.WorkingHours.UpsertWorkingHours(alice))
+
+	stored, err := store.WorkingHours.GetWorkingHours(alice.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, "08:00", stored.StartTime)
+	assert.Equal(t, []int{0, 1}, stored.WorkDays)
+
+	profiles, err := store.WorkingHours.GetWorkingHoursForUsers([]string{alice.UserID, uuid.New().String()})
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, alice.UserID, profiles[0].UserID)
+
+	profiles, err = store.WorkingHours.GetWorkingHoursForUsers(nil)
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+}
+
+func checkAvailabilitiesPerEvent(t *testing.T, store *repository.Store) {
+	planning, retro := conformanceEvent(), conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(planning))
+	require.NoError(t, store.Events.CreateEvent(retro))
+
+	require.NoError(t, store.Availabilities.UpsertAvailability(conformanceAvailability(planning.ID, "alice", 0)))
+	require.NoError(t, store.Availabilities.UpsertAvailability(conformanceAvailability(planning.ID, "bob", 1)))
+	require.NoError(t, store.Availabilities.UpsertAvailability(conformanceAvailability(retro.ID, "alice", 2)))
+
+	availabilities, err := store.Events.GetParticipantAvailabilities(planning.ID)
+	require.NoError(t, err)
+	users := make([]string, 0, len(availabilities))
+	for _, availability := range availabilities {
+		assert.Equal(t, planning.ID, availability.EventID)
+		users = append(users, availability.UserID)
+	}
+	assert.ElementsMatch(t, []string{"alice", "bob"}, users)
+}
+
+func checkAPIKeys(t *testing.T, store *repository.Store) {
+	key := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    "alice",
+		Name:      "CI",
+		KeyHash:   services.HashAPIKey("msk_conformance"),
+		CreatedAt: conformanceStart,
+	}
+	require.NoError(t, store.APIKeys.CreateAPIKey(key))
+
+	stored, err := store.APIKeys.GetAPIKeyByHash(key.KeyHash)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, stored.ID)
+	assert.Equal(t, "alice", stored.UserID)
+	assert.Equal(t, "CI", stored.Name)
+	assert.True(t, conformanceStart.Equal(stored.CreatedAt))
+
+	_, err = store.APIKeys.GetAPIKeyByHash(services.HashAPIKey("msk_unknown"))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, store.APIKeys.DeleteAPIKey(key.ID))
+	_, err = store.APIKeys.GetAPIKeyByHash(key.KeyHash)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.APIKeys.DeleteAPIKey(key.ID), repository.ErrNotFound)
+}
+
+func checkEventRoles(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	role, err := store.EventRoles.GetEventRole(event.ID, event.CreatedBy)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventRoleOrganizer, role, "the creator organizes the event")
+
+	viewer := &models.EventRoleAssignment{EventID: event.ID, UserID: "viewer", Role: models.EventRoleViewer}
+	require.NoError(t, store.EventRoles.AssignEventRole(viewer))
+	viewer.Role = models.EventRoleCoOrganizer
+	require.NoError(t, store.EventRoles.AssignEventRole(viewer))
+
+	roles, err := store.EventRoles.ListEventRoles(event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.EventRoleAssignment{
+		{EventID: event.ID, UserID: event.CreatedBy, Role: models.EventRoleOrganizer},
+		{EventID: event.ID, UserID: "viewer", Role: models.EventRoleCoOrganizer},
+	}, roles)
+
+	require.NoError(t, store.EventRoles.DeleteEventRole(event.ID, "viewer"))
+	_, err = store.EventRoles.GetEventRole(event.ID, "viewer")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.EventRoles.DeleteEventRole(event.ID, "viewer"), repository.ErrNotFound)
+
+	assert.Error(t, store.EventRoles.AssignEventRole(&models.EventRoleAssignment{
+		EventID: uuid.New().String(), UserID: "viewer", Role: models.EventRoleViewer,
+	}), "roles belong to existing events")
+
+	require.NoError(t, store.Events.DeleteEvent(event.ID))
+	roles, err = store.EventRoles.ListEventRoles(event.ID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func checkInvites(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	invite := &models.EventInvite{
+		ID:        uuid.New().String(),
+		EventID:   event.ID,
+		Name:      "Gina",
+		Email:     "gina@example.com",
+		CreatedBy: "alice",
+		ExpiresAt: conformanceStart,
+		CreatedAt: conformanceStart.Add(-time.Hour),
+	}
+	open := &models.EventInvite{
+		ID:        uuid.New().String(),
+		EventID:   event.ID,
+		CreatedBy: "alice",
+		ExpiresAt: conformanceStart,
+		CreatedAt: conformanceStart.Add(-time.Minute),
+	}
+	require.NoError(t, store.Invites.CreateInvite(invite))
+	require.NoError(t, store.Invites.CreateInvite(open))
+
+	stored, err := store.Invites.GetInvite(invite.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Gina", stored.Name)
+	assert.Equal(t, "gina@example.com", stored.Email)
+	assertSameInstant(t, conformanceStart, stored.ExpiresAt)
+	assert.Nil(t, stored.RevokedAt)
+
+	revokedAt := conformanceStart.Add(-30 * time.Minute)
+	require.NoError(t, store.Invites.RevokeInvite(open.ID, revokedAt))
+	invites, err := store.Invites.ListInvites(event.ID)
+	require.NoError(t, err)
+	require.Len(t, invites, 2)
+	assert.Equal(t, invite.ID, invites[0].ID)
+	require.NotNil(t, invites[1].RevokedAt)
+	assertSameInstant(t, revokedAt, *invites[1].RevokedAt)
+	assert.ErrorIs(t, store.Invites.RevokeInvite(uuid.New().String(), revokedAt), repository.ErrNotFound)
+
+	availability := conformanceAvailability(event.ID, services.GuestUserID(invite.ID), 0)
+	availability.Guest = &models.GuestDetails{InviteID: invite.ID, Name: "Gina", Email: "gina@example.com"}
+	require.NoError(t, store.Availabilities.UpsertAvailability(availability))
+	availabilities, err := store.Events.GetParticipantAvailabilities(event.ID)
+	require.NoError(t, err)
+	require.Len(t, availabilities, 1)
+	assert.Equal(t, availability.Guest, availabilities[0].Guest)
+
+	require.NoError(t, store.Events.DeleteEvent(event.ID))
+	_, err = store.Invites.GetInvite(invite.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func checkGuestResponses(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+	personal := func(email string) (*models.EventInvite, *models.ParticipantAvailability) {
+		invite := &models.EventInvite{
+			ID:        uuid.New().String(),
+			EventID:   event.ID,
+			Email:     email,
+			CreatedBy: "alice",
+			ExpiresAt: conformanceStart,
+			CreatedAt: conformanceStart.Add(-time.Hour),
+		}
+		availability := conformanceAvailability(event.ID, services.GuestUserID(event.ID+"/"+email), 0)
+		availability.Guest = &models.GuestDetails{InviteID: invite.ID, Email: email}
+		return invite, availability
+	}
+
+	// The personal invite and the response are stored together
+	hal, response := personal("hal@example.com")
+	require.NoError(t, store.Availabilities.CreateGuestResponse(hal, response))
+	_, err := store.Invites.GetInvite(hal.ID)
+	require.NoError(t, err)
+	stored, err := store.Availabilities.GetAvailability(response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Guest, stored.Guest)
+
+	// Emails with an invite that was not revoked get no other, and nothing of the response is kept
+	again, retry := personal("hal@example.com")
+	assert.ErrorIs(t, store.Availabilities.CreateGuestResponse(again, retry), repository.ErrAlreadyInvited)
+	_, err = store.Invites.GetInvite(again.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = store.Availabilities.GetAvailability(retry.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// A response that fails leaves no invite behind
+	ivy, first := personal("ivy@example.com")
+	require.NoError(t, store.Availabilities.UpsertAvailability(first))
+	_, duplicate := personal("ivy@example.com")
+	assert.Error(t, store.Availabilities.CreateGuestResponse(ivy, duplicate))
+	_, err = store.Invites.GetInvite(ivy.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// Revoked invites no longer hold on to their email
+	require.NoError(t, store.Invites.RevokeInvite(hal.ID, conformanceStart.Add(-time.Minute)))
+	require.NoError(t, store.Availabilities.DeleteAvailability(response.ID))
+	again, retry = personal("hal@example.com")
+	require.NoError(t, store.Availabilities.CreateGuestResponse(again, retry))
+	invites, err := store.Invites.ListInvites(event.ID)
+	require.NoError(t, err)
+	assert.Len(t, invites, 2)
+}
+
+func checkCalendarConnections(t *testing.T, store *repository.Store) {
+	userID := uuid.New().String()
+	event := conformanceEvent()
+	event.Participants = append(event.Participants, models.EventParticipant{UserID: userID})
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	connection := &models.CalendarConnection{
+		UserID:    userID,
+		URL:       "https://caldav.example.com/calendars/gina/work/",
+		Username:  "gina",
+		Secret:    "sealed",
+		UpdatedAt: conformanceStart.Add(-48 * time.Hour),
+	}
+	require.NoError(t, store.Calendars.UpsertCalendarConnection(connection))
+	syncedAt := conformanceStart.Add(-47 * time.Hour)
+	require.NoError(t, store.Calendars.RecordCalendarSync(userID, syncedAt, "calendar server answered 500"))
+
+	stored, err := store.Calendars.GetCalendarConnection(userID)
+	require.NoError(t, err)
+	assert.Equal(t, connection.URL, stored.URL)
+	assert.Equal(t, "sealed", stored.Secret)
+	assert.Equal(t, "calendar server answered 500", stored.LastError)
+	require.NotNil(t, stored.LastSyncedAt)
+	assertSameInstant(t, syncedAt, *stored.LastSyncedAt)
+
+	// Replacing the connection forgets the last sync
+	require.NoError(t, store.Calendars.UpsertCalendarConnection(connection))
+	stored, err = store.Calendars.GetCalendarConnection(userID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.LastSyncedAt)
+	assert.Empty(t, stored.LastError)
+
+	// Only participants with a connection are synced, and only until the deadline
+	targetsOf := func(now time.Time) []models.CalendarSyncTarget {
+		targets, err := store.Calendars.ListCalendarSyncTargets(now)
+		require.NoError(t, err)
+		var ofEvent []models.CalendarSyncTarget
+		for _, target := range targets {
+			if target.EventID == event.ID {
+				ofEvent = append(ofEvent, target)
+			}
+		}
+		return ofEvent
+	}
+	assert.Equal(t, []models.CalendarSyncTarget{{EventID: event.ID, UserID: userID}}, targetsOf(conformanceStart.Add(-48*time.Hour)))
+	assert.Empty(t, targetsOf(conformanceStart))
+
+	require.NoError(t, store.Calendars.DeleteCalendarConnection(userID))
+	_, err = store.Calendars.GetCalendarConnection(userID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.ErrorIs(t, store.Calendars.DeleteCalendarConnection(userID), repository.ErrNotFound)
+	assert.ErrorIs(t, store.Calendars.RecordCalendarSync(userID, syncedAt, ""), repository.ErrNotFound)
+}
+
+func checkWebhooks(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+
+	perEvent := &models.Webhook{
+		ID:          uuid.New().String(),
+		EventID:     event.ID,
+		URL:         "https://hooks.example.com/event",
+		ChangeTypes: []models.ChangeType{models.ChangeEventFinalized, models.ChangeAvailabilitySubmitted},
+		Secret:      "secret",
+		CreatedBy:   "alice",
+		CreatedAt:   conformanceStart,
+	}
+	global := &models.Webhook{
+		ID:        uuid.New().String(),
+		URL:       "https://hooks.example.com/all",
+		Secret:    "secret",
+		CreatedBy: "root",
+		CreatedAt: conformanceStart,
+	}
+	require.NoError(t, store.Webhooks.CreateWebhook(perEvent))
+	require.NoError(t, store.Webhooks.CreateWebhook(global))
+
+	stored, err := store.Webhooks.GetWebhook(perEvent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, event.ID, stored.EventID)
+	assert.Equal(t, perEvent.ChangeTypes, stored.ChangeTypes)
+	assert.Equal(t, "secret", stored.Secret)
+	stored, err = store.Webhooks.GetWebhook(global.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.EventID)
+	assert.Empty(t, stored.ChangeTypes)
+
+	ids := func(webhooks []models.Webhook, err error) []string {
+		require.NoError(t, err)
+		var ids []string
+		for _, webhook := range webhooks {
+			if webhook.ID == perEvent.ID || webhook.ID == global.ID {
+				ids = append(ids, webhook.ID)
+			}
+		}
+		return ids
+	}
+	assert.Equal(t, []string{perEvent.ID}, ids(store.Webhooks.ListWebhooks(event.ID)))
+	assert.Equal(t, []string{global.ID}, ids(store.Webhooks.ListWebhooks("")))
+	assert.ElementsMatch(t, []string{perEvent.ID, global.ID}, ids(store.Webhooks.ListSubscribedWebhooks(event.ID)))
+
+	// Deliveries are due once their next attempt is reached, until they leave the pending state
+	delivery := &models.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     perEvent.ID,
+		ChangeType:    models.ChangeEventFinalized,
+		Payload:       []byte(`{"type":"event.finalized"}`),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: conformanceStart,
+		CreatedAt:     conformanceStart,
+	}
+	require.NoError(t, store.Webhooks.CreateWebhookDelivery(delivery))
+	dueAt := func(now time.Time) []string {
+		deliveries, err := store.Webhooks.ListDueWebhookDeliveries(now, 1000)
+		require.NoError(t, err)
+		var ids []string
+		for _, due := range deliveries {
+			if due.WebhookID == perEvent.ID {
+				ids = append(ids, due.ID)
+			}
+		}
+		return ids
+	}
+	assert.Empty(t, dueAt(conformanceStart.Add(-time.Minute)))
+	assert.Equal(t, []string{delivery.ID}, dueAt(conformanceStart))
+
+	deliveredAt := conformanceStart.Add(time.Minute)
+	delivery.Status = models.WebhookDeliveryDelivered
+	delivery.Attempts = 2
+	delivery.ResponseStatus = 204
+	delivery.DeliveredAt = &deliveredAt
+	require.NoError(t, store.Webhooks.UpdateWebhookDelivery(delivery))
+	assert.Empty(t, dueAt(conformanceStart.Add(time.Hour)))
+
+	deliveries, err := store.Webhooks.ListWebhookDeliveries(perEvent.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, 204, deliveries[0].ResponseStatus)
+	assert.JSONEq(t, `{"type":"event.finalized"}`, string(deliveries[0].Payload))
+	require.NotNil(t, deliveries[0].DeliveredAt)
+	assertSameInstant(t, deliveredAt, *deliveries[0].DeliveredAt)
+
+	// Deleting the event drops its webhooks along with their deliveries
+	require.NoError(t, store.Events.DeleteEvent(event.ID))
+	_, err = store.Webhooks.GetWebhook(perEvent.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	deliveries, err = store.Webhooks.ListWebhookDeliveries(perEvent.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	require.NoError(t, store.Webhooks.DeleteWebhook(global.ID))
+	assert.ErrorIs(t, store.Webhooks.DeleteWebhook(global.ID), repository.ErrNotFound)
+}
+
+func checkReminders(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+	deadline := *event.Deadline
+
+	// Polling events are remindable until their deadline, from as early as the longest offset
+	remindable := func(now, until time.Time) bool {
+		ids, err := store.Reminders.ListRemindableEventIDs(now, until)
+		require.NoError(t, err)
+		return slices.Contains(ids, event.ID)
+	}
+	assert.True(t, remindable(deadline.Add(-48*time.Hour), deadline))
+	assert.False(t, remindable(deadline.Add(-48*time.Hour), deadline.Add(-time.Minute)))
+	assert.False(t, remindable(deadline, deadline.Add(48*time.Hour)), "past deadlines are not reminded")
+
+	// Each reminder is claimed once, until it is released
+	reminder := &models.EventReminder{EventID: event.ID, Offset: 4 * time.Hour, UserID: "bob", SentAt: deadline.Add(-4 * time.Hour)}
+	claimed, err := store.Reminders.ClaimReminder(reminder)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.Reminders.ClaimReminder(reminder)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	other := *reminder
+	other.Offset = 48 * time.Hour
+	claimed, err = store.Reminders.ClaimReminder(&other)
+	require.NoError(t, err)
+	assert.True(t, claimed, "reminders at other offsets are claimed separately")
+
+	require.NoError(t, store.Reminders.ReleaseReminder(event.ID, reminder.Offset, "bob"))
+	assert.ErrorIs(t, store.Reminders.ReleaseReminder(event.ID, reminder.Offset, "bob"), repository.ErrNotFound)
+	claimed, err = store.Reminders.ClaimReminder(reminder)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Cancelled events are not reminded, and deleting an event forgets its reminders
+	event.Status = models.EventStatusCancelled
+	require.NoError(t, store.Events.UpdateEventStatus(event, models.EventStatusPolling))
+	assert.False(t, remindable(deadline.Add(-48*time.Hour), deadline))
+	require.NoError(t, store.Events.DeleteEvent(event.ID))
+	require.NoError(t, store.Events.CreateEvent(event))
+	claimed, err = store.Reminders.ClaimReminder(reminder)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func checkRevisions(t *testing.T, store *repository.Store) {
+	event := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(event))
+	other := conformanceEvent()
+	require.NoError(t, store.Events.CreateEvent(other))
+	revision := func() int {
+		t.Helper()
+		stored, err := store.Events.GetEvent(event.ID)
+		require.NoError(t, err)
+		return stored.Revision
+	}
+	assert.Equal(t, 0, revision())
+
+	// Every change to the event or its availability counts, whatever the timestamps say
+	event.UpdatedAt = event.CreatedAt
+	require.NoError(t, store.Events.UpdateEvent(event))
+	assert.Equal(t, 1, event.Revision)
+	event.Status = models.EventStatusCancelled
+	require.NoError(t, store.Events.UpdateEventStatus(event, models.EventStatusPolling))
+	assert.Equal(t, 2, event.Revision)
+	assert.Equal(t, 2, revision())
+
+	alice := conformanceAvailability(event.ID, "alice", 0)
+	require.NoError(t, store.Availabilities.UpsertAvailability(alice))
+	require.NoError(t, store.Availabilities.CreateAvailability(conformanceAvailability(event.ID, "bob", 1)))
+	alice.UpdatedAt = alice.CreatedAt
+	require.NoError(t, store.Availabilities.UpdateAvailability(alice))
+	require.NoError(t, store.Availabilities.DeleteAvailability(alice.ID))
+	assert.Equal(t, 6, revision())
+
+	stored, err := store.Events.GetEvent(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stored.Revision, "other events keep their revision")
+}
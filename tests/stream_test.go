@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseMessage is a message read from an event stream, or a comment such as a heartbeat
+type sseMessage struct {
+	ID      string
+	Event   string
+	Data    string
+	Comment string
+}
+
+// openStream streams an event until the test ends or close is called
+func openStream(t *testing.T, server *httptest.Server, eventID, userID, lastEventID string) (<-chan sseMessage, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events/"+eventID+"/stream", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-User-ID", userID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	messages := make(chan sseMessage, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(messages)
+		scanner := bufio.NewScanner(resp.Body)
+		var message sseMessage
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "":
+				if value == "" && message != (sseMessage{}) {
+					select {
+					case messages <- message:
+					case <-ctx.Done():
+						return
+					}
+				}
+				message = sseMessage{Comment: value}
+			case "id":
+				message.ID = value
+			case "event":
+				message.Event = value
+			case "data":
+				message.Data = value
+			}
+		}
+	}()
+	return messages, cancel
+}
+
+// nextMessage returns the next message of a stream, skipping heartbeats
+func nextMessage(t *testing.T, messages <-chan sseMessage) sseMessage {
+	t.Helper()
+	for {
+		select {
+		case message, ok := <-messages:
+			require.True(t, ok, "stream closed")
+			if message.Comment == "" {
+				return message
+			}
+		case <-time.After(2 * time.Second):
+			require.FailNow(t, "no message streamed")
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	router := newTestRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // Run last, it waits for the streams to be closed
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:    "Design review",
+		Duration: 60,
+		Participants: []models.EventParticipant{
+			{UserID: "bob", Required: true}, {UserID: "carol"},
+		},
+		TimeSlots: window,
+	}, &event))
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodGet, "/events/"+event.ID+"/stream", "mallory", nil, nil))
+
+	// Streams start from the current recommendations
+	messages, closeStream := openStream(t, server, event.ID, "alice", "")
+	message := nextMessage(t, messages)
+	assert.Equal(t, services.StreamRecommendationsChanged, message.Event)
+	var recommendations models.StreamRecommendations
+	require.NoError(t, json.Unmarshal([]byte(message.Data), &recommendations))
+	assert.Equal(t, event.ID, recommendations.EventID)
+	first, err := strconv.ParseUint(message.ID, 10, 64)
+	require.NoError(t, err)
+	id := func(n uint64) string { return strconv.FormatUint(first+n, 10) }
+
+	// Responses are pushed, followed by the recommendations they changed
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(1), message.ID)
+	assert.Equal(t, services.StreamAvailabilitySubmitted, message.Event)
+	var submitted models.StreamAvailability
+	require.NoError(t, json.Unmarshal([]byte(message.Data), &submitted))
+	assert.Equal(t, "bob", submitted.UserID)
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(2), message.ID)
+	assert.Equal(t, services.StreamRecommendationsChanged, message.Event)
+	require.NoError(t, json.Unmarshal([]byte(message.Data), &recommendations))
+	require.NotEmpty(t, recommendations.Recommendations)
+	assert.Equal(t, []string{"bob"}, recommendations.Recommendations[0].Participants)
+
+	// Idle streams get heartbeats
+	select {
+	case message := <-messages:
+		assert.Equal(t, "heartbeat", message.Comment)
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "no heartbeat")
+	}
+
+	// Clients resuming a stream get the messages they missed
+	closeStream()
+	var carol models.ParticipantAvailability
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "carol",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, &carol))
+	messages, _ = openStream(t, server, event.ID, "alice", id(2))
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(3), message.ID)
+	assert.Equal(t, services.StreamAvailabilitySubmitted, message.Event)
+	assert.Contains(t, message.Data, `"user_id":"carol"`)
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(4), message.ID)
+	assert.Equal(t, services.StreamRecommendationsChanged, message.Event)
+
+	// Recommendations are only pushed when they change
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/availabilities/"+carol.ID, "carol",
+		models.UpdateAvailabilityRequest{TimeSlots: window}, nil))
+	message = nextMessage(t, messages)
+	assert.Equal(t, services.StreamAvailabilitySubmitted, message.Event)
+	require.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, "/availabilities/"+carol.ID, "carol", nil, nil))
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(6), message.ID)
+	assert.Equal(t, services.StreamAvailabilityWithdrawn, message.Event)
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(7), message.ID)
+	assert.Equal(t, services.StreamRecommendationsChanged, message.Event)
+
+	// Clients too far behind for the history start over from the current recommendations
+	messages, _ = openStream(t, server, event.ID, "bob", id(1))
+	message = nextMessage(t, messages)
+	assert.Equal(t, id(7), message.ID)
+	assert.Equal(t, services.StreamRecommendationsChanged, message.Event)
+	require.NoError(t, json.Unmarshal([]byte(message.Data), &recommendations))
+	assert.Equal(t, []string{"bob"}, recommendations.Recommendations[0].Participants)
+}
+
+func TestEventStreamPushesEventChanges(t *testing.T) {
+	router := newTestRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+	participants := []models.EventParticipant{{UserID: "bob", Required: true}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Design review",
+		Duration:     60,
+		Participants: participants,
+		TimeSlots:    window,
+		Status:       models.EventStatusDraft,
+	}, &event))
+	messages, _ := openStream(t, server, event.ID, "alice", "")
+	assert.Equal(t, services.StreamRecommendationsChanged, nextMessage(t, messages).Event)
+
+	// Each change to the event is pushed with the event, followed by its current recommendations
+	expectEventChange := func(change models.ChangeType) (models.StreamEvent, models.StreamRecommendations) {
+		t.Helper()
+		message := nextMessage(t, messages)
+		require.Equal(t, services.StreamEventUpdated, message.Event)
+		var updated models.StreamEvent
+		require.NoError(t, json.Unmarshal([]byte(message.Data), &updated))
+		assert.Equal(t, change, updated.Change)
+		assert.Equal(t, event.ID, updated.EventID)
+		require.NotNil(t, updated.Event)
+
+		message = nextMessage(t, messages)
+		require.Equal(t, services.StreamRecommendationsChanged, message.Event)
+		var recommendations models.StreamRecommendations
+		require.NoError(t, json.Unmarshal([]byte(message.Data), &recommendations))
+		return updated, recommendations
+	}
+
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/open", "alice", nil, nil))
+	opened, _ := expectEventChange(models.ChangeEventOpened)
+	assert.Equal(t, models.EventStatusPolling, opened.Event.Status)
+
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	assert.Equal(t, services.StreamAvailabilitySubmitted, nextMessage(t, messages).Event)
+	assert.Equal(t, services.StreamRecommendationsChanged, nextMessage(t, messages).Event)
+
+	// Edits that move the slots push the recommendations they change
+	narrowed := []models.TimeSlot{{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"}}
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/events?id="+event.ID, "alice", models.UpdateEventRequest{
+		Title:        "Design review, short",
+		Duration:     60,
+		TimeSlots:    narrowed,
+		Participants: participants,
+	}, nil))
+	updated, recommendations := expectEventChange(models.ChangeEventUpdated)
+	assert.Equal(t, "Design review, short", updated.Event.Title)
+	require.Len(t, recommendations.Recommendations, 1)
+	assert.True(t, recommendations.Recommendations[0].TimeSlot.StartTime.Equal(start))
+
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/finalize", "alice",
+		models.FinalizeEventRequest{StartTime: start}, nil))
+	finalized, recommendations := expectEventChange(models.ChangeEventFinalized)
+	assert.Equal(t, models.EventStatusFinalized, finalized.Event.Status)
+	require.NotNil(t, finalized.Event.FinalSlot)
+	assert.Len(t, recommendations.Recommendations, 1)
+
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/cancel", "alice", nil, nil))
+	cancelled, _ := expectEventChange(models.ChangeEventCancelled)
+	assert.Equal(t, models.EventStatusCancelled, cancelled.Event.Status)
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInjected = errors.New("injected failure")
+
+// recordingStore is a fake database that records every statement it persists. Statements run in a
+// transaction are only persisted on commit. The failAt-th statement containing failOn fails.
+type recordingStore struct {
+	mu        sync.Mutex
+	persisted []string
+	failOn    string
+	failAt    int
+	seen      int
+}
+
+func (s *recordingStore) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{store: s}, nil
+}
+func (s *recordingStore) Driver() driver.Driver { return nil }
+
+func (s *recordingStore) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.persisted...)
+}
+
+type recordingConn struct {
+	store   *recordingStore
+	pending []string
+	inTx    bool
+}
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *recordingConn) Close() error                        { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.record(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// QueryContext records writes returning a value, such as an event's new revision, and returns 1
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.record(query); err != nil {
+		return nil, err
+	}
+	return &singleValueRows{}, nil
+}
+
+func (c *recordingConn) record(query string) error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	if c.store.failOn != "" && strings.Contains(query, c.store.failOn) {
+		c.store.seen++
+		if c.store.seen == c.store.failAt {
+			return errInjected
+		}
+	}
+	statement := strings.Join(strings.Fields(query), " ")
+	if c.inTx {
+		c.pending = append(c.pending, statement)
+	} else {
+		c.store.persisted = append(c.store.persisted, statement)
+	}
+	return nil
+}
+
+// singleValueRows is a result of one row holding the value 1
+type singleValueRows struct{ done bool }
+
+func (r *singleValueRows) Columns() []string { return []string{"value"} }
+func (r *singleValueRows) Close() error      { return nil }
+func (r *singleValueRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func (c *recordingConn) Commit() error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.store.persisted = append(c.store.persisted, c.pending...)
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func newRecordingDB(t *testing.T, failOn string, failAt int) (*sql.DB, *recordingStore) {
+	store := &recordingStore{failOn: failOn, failAt: failAt}
+	db := sql.OpenDB(store)
+	t.Cleanup(func() { db.Close() })
+	return db, store
+}
+
+func transactionTestEvent() *models.Event {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	return &models.Event{
+		ID:       "event-1",
+		Title:    "Planning",
+		Duration: 60,
+		TimeSlots: []models.TimeSlot{
+			{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"},
+			{StartTime: start.Add(24 * time.Hour), EndTime: start.Add(25 * time.Hour), TimeZone: "UTC"},
+			{StartTime: start.Add(48 * time.Hour), EndTime: start.Add(49 * time.Hour), TimeZone: "UTC"},
+		},
+		Participants: []models.EventParticipant{{UserID: "user-1", Required: true}},
+		Status:       models.EventStatusPolling,
+	}
+}
+
+func TestCreateEventPersistsNothingWhenASlotInsertFails(t *testing.T) {
+	db, store := newRecordingDB(t, "INSERT INTO event_time_slots", 2)
+	repo := repository.NewEventRepository(db)
+
+	err := repo.CreateEvent(transactionTestEvent())
+
+	assert.ErrorIs(t, err, errInjected)
+	assert.Empty(t, store.statements())
+}
+
+func TestUpdateEventKeepsSlotsWhenReplacingThemFails(t *testing.T) {
+	db, store := newRecordingDB(t, "INSERT INTO event_participants", 1)
+	repo := repository.NewEventRepository(db)
+
+	err := repo.UpdateEvent(transactionTestEvent())
+
+	assert.ErrorIs(t, err, errInjected)
+	assert.Empty(t, store.statements(), "the slot deletion must be rolled back")
+}
+
+func TestCreateEventCommitsEveryStatement(t *testing.T) {
+	db, store := newRecordingDB(t, "", 0)
+	repo := repository.NewEventRepository(db)
+
+	require.NoError(t, repo.CreateEvent(transactionTestEvent()))
+
+	// The event, its three slots and its participant role
+	assert.Len(t, store.statements(), 5)
+}
+
+func TestAvailabilityWritesPersistNothingOnFailure(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	availability := &models.ParticipantAvailability{
+		ID:      "availability-1",
+		EventID: "event-1",
+		UserID:  "user-1",
+		TimeSlots: []models.TimeSlot{
+			{StartTime: start, EndTime: start.Add(time.Hour), TimeZone: "UTC"},
+			{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"},
+		},
+	}
+
+	db, store := newRecordingDB(t, "INSERT INTO availability_time_slots", 2)
+	repo := repository.NewAvailabilityRepository(db)
+	assert.ErrorIs(t, repo.CreateAvailability(availability), errInjected)
+	assert.Empty(t, store.statements())
+
+	db, store = newRecordingDB(t, "INSERT INTO availability_time_slots", 1)
+	repo = repository.NewAvailabilityRepository(db)
+	assert.ErrorIs(t, repo.UpdateAvailability(availability), errInjected)
+	assert.Empty(t, store.statements())
+
+	db, store = newRecordingDB(t, "DELETE FROM participant_availabilities", 1)
+	repo = repository.NewAvailabilityRepository(db)
+	assert.ErrorIs(t, repo.DeleteAvailability(availability.ID), errInjected)
+	assert.Empty(t, store.statements())
+}
+
+func TestUnitOfWorkRollsBackOnPanic(t *testing.T) {
+	db, store := newRecordingDB(t, "", 0)
+	uow := repository.NewUnitOfWork(db)
+
+	assert.Panics(t, func() {
+		_ = uow.Do(func(tx repository.DBTX) error {
+			_, err := tx.Exec("DELETE FROM events WHERE id = $1", "event-1")
+			require.NoError(t, err)
+			panic("boom")
+		})
+	})
+	assert.Empty(t, store.statements())
+}
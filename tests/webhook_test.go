@@ -0,0 +1,274 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/shani34/meeting-scheduler/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the payloads posted to it, answering with status
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	payloads []models.WebhookPayload
+	invalid  int // Requests whose signature did not match
+}
+
+func newWebhookReceiver(t *testing.T, secret *string) *webhookReceiver {
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+
+		signature := services.SignWebhookPayload(*secret, r.Header.Get(services.WebhookTimestampHeader), body)
+		if r.Header.Get(services.WebhookSignatureHeader) != signature {
+			receiver.invalid++
+		}
+		var payload models.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err == nil {
+			receiver.payloads = append(receiver.payloads, payload)
+		}
+		w.WriteHeader(receiver.status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() (types []models.ChangeType, invalid int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, payload := range r.payloads {
+		types = append(types, payload.Type)
+	}
+	return types, r.invalid
+}
+
+func (r *webhookReceiver) answer(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	store := repository.NewMemoryStore()
+	eventService := services.NewEventService(store.Events, store.WorkingHours, services.NewSchedulerService())
+	availabilityService := services.NewAvailabilityService(store.Availabilities, store.Events, eventService)
+	webhooks := services.NewWebhookService(store.Webhooks, http.DefaultClient, services.WebhookOptions{
+		MaxAttempts:     2,
+		RetryBase:       time.Millisecond,
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	})
+	eventService.Changes().Subscribe(webhooks)
+	worker := services.NewWebhookWorker(webhooks, time.Minute)
+
+	var secret string
+	receiver := newWebhookReceiver(t, &secret)
+	global, secret, err := webhooks.Create("root", models.CreateWebhookRequest{URL: receiver.URL})
+	require.NoError(t, err)
+
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+	event := &models.Event{
+		ID:           uuid.New().String(),
+		Title:        "Design review",
+		Duration:     60,
+		Status:       models.EventStatusPolling,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "alice", Required: true}},
+		CreatedBy:    "alice",
+		CreatedAt:    start.Add(-24 * time.Hour),
+		UpdatedAt:    start.Add(-24 * time.Hour),
+	}
+	require.NoError(t, eventService.Create(event))
+
+	// Webhooks of another event are not notified
+	other := *event
+	other.ID = uuid.New().String()
+	require.NoError(t, store.Events.CreateEvent(&other))
+	_, _, err = webhooks.Create("alice", models.CreateWebhookRequest{
+		URL:         receiver.URL,
+		EventID:     other.ID,
+		ChangeTypes: []models.ChangeType{models.ChangeAvailabilitySubmitted},
+	})
+	require.NoError(t, err)
+
+	_, err = availabilityService.Submit(event.ID, "alice", window)
+	require.NoError(t, err)
+	worker.ProcessDue(context.Background())
+
+	types, invalid := receiver.received()
+	assert.Equal(t, []models.ChangeType{models.ChangeEventCreated, models.ChangeAvailabilitySubmitted}, types)
+	assert.Zero(t, invalid, "payloads are signed with the webhook's secret")
+
+	deliveries, err := webhooks.Deliveries(global.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		assert.Equal(t, models.WebhookDeliveryDelivered, delivery.Status)
+		assert.Equal(t, http.StatusNoContent, delivery.ResponseStatus)
+		assert.NotNil(t, delivery.DeliveredAt)
+	}
+
+	// Failed deliveries are retried with backoff, then given up on after the last attempt
+	receiver.answer(http.StatusInternalServerError)
+	_, err = eventService.Finalize(event.ID, start, "alice")
+	require.NoError(t, err)
+	worker.ProcessDue(context.Background())
+
+	deliveries, err = webhooks.Deliveries(global.ID)
+	require.NoError(t, err)
+	failed := deliveries[0]
+	assert.Equal(t, models.ChangeEventFinalized, failed.ChangeType)
+	assert.Equal(t, models.WebhookDeliveryPending, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, failed.ResponseStatus)
+	assert.Contains(t, failed.LastError, "500")
+
+	time.Sleep(5 * time.Millisecond)
+	worker.ProcessDue(context.Background())
+	deliveries, err = webhooks.Deliveries(global.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Nil(t, deliveries[0].DeliveredAt)
+
+	types, _ = receiver.received()
+	assert.Len(t, types, 4, "dead deliveries are not attempted again")
+	worker.ProcessDue(context.Background())
+	types, _ = receiver.received()
+	assert.Len(t, types, 4)
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	router := newTestRouter()
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	window := []models.TimeSlot{{StartTime: start, EndTime: start.Add(3 * time.Hour), TimeZone: "UTC"}}
+
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title:        "Design review",
+		Duration:     60,
+		TimeSlots:    window,
+		Participants: []models.EventParticipant{{UserID: "bob", Required: true}},
+	}, &event))
+
+	// Organizers manage the webhooks of their event, participants do not
+	request := models.CreateWebhookRequest{URL: "https://hooks.example.com/review", EventID: event.ID}
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodPost, "/webhooks", "bob", request, nil))
+	var created models.CreateWebhookResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/webhooks", "alice", request, &created))
+	assert.NotEmpty(t, created.Secret)
+	assert.Equal(t, event.ID, created.Webhook.EventID)
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodPost, "/webhooks", "alice",
+		models.CreateWebhookRequest{URL: "ftp://hooks.example.com", EventID: event.ID}, nil))
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodPost, "/webhooks", "alice",
+		models.CreateWebhookRequest{URL: request.URL, EventID: event.ID, ChangeTypes: []models.ChangeType{"event.renamed"}}, nil))
+
+	// Webhooks cannot target the server's own networks
+	assert.Equal(t, http.StatusBadRequest, doJSON(t, router, http.MethodPost, "/webhooks", "alice",
+		models.CreateWebhookRequest{URL: "http://169.254.169.254/latest/meta-data", EventID: event.ID}, nil))
+
+	// Only webhook admins manage global webhooks, and anonymous callers manage none
+	global := models.CreateWebhookRequest{URL: "https://hooks.example.com/all"}
+	assert.Equal(t, http.StatusUnauthorized, doJSON(t, router, http.MethodPost, "/webhooks", "", global, nil))
+	assert.Equal(t, http.StatusUnauthorized, doJSON(t, router, http.MethodGet, "/webhooks", "", nil, nil))
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodPost, "/webhooks", "alice", global, nil))
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/webhooks", "root", global, nil))
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodGet, "/webhooks", "alice", nil, nil))
+
+	var listed []models.Webhook
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/webhooks?event_id="+event.ID, "alice", nil, &listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.Webhook.ID, listed[0].ID)
+
+	// Changes are queued for delivery as they happen
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/availabilities", "bob",
+		models.CreateAvailabilityRequest{EventID: event.ID, TimeSlots: window}, nil))
+	var deliveries []models.WebhookDelivery
+	path := "/webhooks/" + created.Webhook.ID + "/deliveries"
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodGet, path, "bob", nil, nil))
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, path, "alice", nil, &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.ChangeAvailabilitySubmitted, deliveries[0].ChangeType)
+	assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+
+	var payload models.WebhookPayload
+	require.NoError(t, json.Unmarshal(deliveries[0].Payload, &payload))
+	assert.Equal(t, event.ID, payload.Event.ID)
+	require.NotNil(t, payload.Availability)
+	assert.Equal(t, "bob", payload.Availability.UserID)
+
+	assert.Equal(t, http.StatusForbidden, doJSON(t, router, http.MethodDelete, "/webhooks/"+created.Webhook.ID, "bob", nil, nil))
+	assert.Equal(t, http.StatusNoContent, doJSON(t, router, http.MethodDelete, "/webhooks/"+created.Webhook.ID, "alice", nil, nil))
+	assert.Equal(t, http.StatusNotFound, doJSON(t, router, http.MethodGet, path, "alice", nil, nil))
+}
+
+func TestWebhookTargets(t *testing.T) {
+	store := repository.NewMemoryStore()
+	webhooks := services.NewWebhookService(store.Webhooks, http.DefaultClient, services.WebhookOptions{})
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://10.1.2.3/hook",
+		"http://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+		"http://100.64.0.1/hook",
+	} {
+		_, _, err := webhooks.Create("root", models.CreateWebhookRequest{URL: target})
+		assert.ErrorIs(t, err, services.ErrInvalidWebhook, target)
+	}
+	_, _, err := webhooks.Create("root", models.CreateWebhookRequest{URL: "https://hooks.example.com/all"})
+	assert.NoError(t, err)
+
+	// Allowed networks may be targeted
+	allowing := services.NewWebhookService(store.Webhooks, http.DefaultClient, services.WebhookOptions{
+		AllowedNetworks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.0/8")},
+	})
+	_, _, err = allowing.Create("root", models.CreateWebhookRequest{URL: "http://10.1.2.3/hook"})
+	assert.NoError(t, err)
+	_, _, err = allowing.Create("root", models.CreateWebhookRequest{URL: "http://192.168.1.10/hook"})
+	assert.ErrorIs(t, err, services.ErrInvalidWebhook)
+
+	// Deliveries do not connect to refused addresses, whatever the URL looked like when it was created
+	var secret string
+	receiver := newWebhookReceiver(t, &secret)
+	webhook, secret, err := allowing.Create("root", models.CreateWebhookRequest{URL: receiver.URL})
+	require.NoError(t, err)
+	delivery := &models.WebhookDelivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhook.ID,
+		ChangeType:    models.ChangeEventCreated,
+		Payload:       []byte(`{}`),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.Webhooks.CreateWebhookDelivery(delivery))
+	require.NoError(t, webhooks.Deliver(context.Background(), delivery))
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Contains(t, delivery.LastError, "not an allowed address")
+	types, _ := receiver.received()
+	assert.Empty(t, types)
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shani34/meeting-scheduler/api/models"
+	"github.com/shani34/meeting-scheduler/api/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindOptimalTimeSlotsPenalisesOffHours(t *testing.T) {
+	scheduler := services.NewSchedulerService(services.WithSlotStep(time.Hour), services.WithOffHoursPenalty(0.5))
+
+	// 2024-01-01 is a Monday; Singapore is UTC+8 and London is UTC+0 in January
+	event := &models.Event{
+		ID:        "test-event",
+		Duration:  60,
+		TimeSlots: []models.TimeSlot{slot(0, 0, 10, 0)},
+	}
+	participantAvailabilities := []models.ParticipantAvailability{
+		{UserID: "singapore", TimeSlots: []models.TimeSlot{slot(0, 0, 10, 0)}},
+		{UserID: "london", TimeSlots: []models.TimeSlot{slot(0, 0, 10, 0)}},
+	}
+	profiles := []models.WorkingHours{
+		{UserID: "singapore", TimeZone: "Asia/Singapore"},
+		{UserID: "london", TimeZone: "Europe/London"},
+	}
+	for i := range profiles {
+		assert.NoError(t, services.ValidateWorkingHours(&profiles[i]))
+	}
+
+	recommendations, err := scheduler.FindOptimalTimeSlots(event, participantAvailabilities, profiles...)
+	require.NoError(t, err)
+	assert.Len(t, recommendations, 10)
+
+	// Singapore works 01:00-09:00 UTC and London 09:00-17:00 UTC, so every slot costs someone;
+	// 00:00 UTC is outside both working days and ranks last
+	for _, rec := range recommendations[:9] {
+		assert.Equal(t, 1, rec.Breakdown.OffHoursAttendees)
+		assert.Equal(t, 1.5, rec.Score)
+	}
+	worst := recommendations[9]
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), worst.TimeSlot.StartTime)
+	assert.Equal(t, 2, worst.Breakdown.OffHoursAttendees)
+	assert.Equal(t, 1.0, worst.Breakdown.OffHoursPenalty)
+	assert.Equal(t, 1.0, worst.Score)
+
+	// 01:00 UTC is 09:00 in Singapore and 01:00 in London
+	early := recommendations[0]
+	assert.Equal(t, time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC), early.TimeSlot.StartTime)
+	assert.Len(t, early.LocalTimes, 2)
+	for _, local := range early.LocalTimes {
+		switch local.UserID {
+		case "singapore":
+			assert.Equal(t, "Asia/Singapore", local.TimeZone)
+			assert.Equal(t, 9, local.StartTime.Hour())
+			assert.True(t, *local.WithinWorkingHours)
+		case "london":
+			assert.Equal(t, 1, local.StartTime.Hour())
+			assert.False(t, *local.WithinWorkingHours)
+		}
+	}
+}
+
+func TestValidateWorkingHours(t *testing.T) {
+	profile := models.WorkingHours{UserID: "user-1", TimeZone: "America/New_York"}
+	assert.NoError(t, services.ValidateWorkingHours(&profile))
+	assert.Equal(t, "09:00", profile.StartTime)
+	assert.Equal(t, "17:00", profile.EndTime)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, profile.WorkDays)
+
+	assert.Error(t, services.ValidateWorkingHours(&models.WorkingHours{TimeZone: "Mars/Olympus"}))
+	assert.Error(t, services.ValidateWorkingHours(&models.WorkingHours{TimeZone: "UTC", StartTime: "18:00", EndTime: "09:00"}))
+	assert.Error(t, services.ValidateWorkingHours(&models.WorkingHours{TimeZone: "UTC", WorkDays: []int{7}}))
+}
+
+func TestWorkingHoursOwnership(t *testing.T) {
+	router := newTestRouter()
+	profile := models.UpdateWorkingHoursRequest{TimeZone: "Asia/Singapore"}
+
+	// Only the user can change their working hours, which weigh on every event they take part in
+	assert.Equal(t, "Working hours can only be changed by their owner",
+		doForbidden(t, router, http.MethodPut, "/working-hours/alice", "mallory", profile))
+	var stored models.WorkingHours
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodPut, "/working-hours/alice", "alice", profile, &stored))
+	assert.Equal(t, "alice", stored.UserID)
+	assert.Equal(t, "09:00", stored.StartTime)
+
+	// Guests have no working hours
+	var event models.Event
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events", "alice", models.CreateEventRequest{
+		Title: "Offsite", Duration: 60, TimeSlots: []models.TimeSlot{{
+			StartTime: time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC), EndTime: time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC), TimeZone: "UTC",
+		}},
+	}, &event))
+	var invite models.CreateInviteResponse
+	require.Equal(t, http.StatusCreated, doJSON(t, router, http.MethodPost, "/events/"+event.ID+"/invites", "alice",
+		models.CreateInviteRequest{Email: "gina@example.com"}, &invite))
+	assert.Equal(t, http.StatusForbidden, doGuest(t, router, http.MethodPut, "/working-hours/alice", invite.Token,
+		models.UpdateWorkingHoursRequest{TimeZone: "UTC"}, nil))
+
+	// Nor can anyone else read them
+	assert.Equal(t, "Working hours can only be read by their owner",
+		doForbidden(t, router, http.MethodGet, "/working-hours/alice", "bob", nil))
+	assert.Equal(t, http.StatusForbidden, doGuest(t, router, http.MethodGet, "/working-hours/alice", invite.Token, nil, nil))
+	require.Equal(t, http.StatusOK, doJSON(t, router, http.MethodGet, "/working-hours/alice", "alice", nil, &stored))
+	assert.Equal(t, "Asia/Singapore", stored.TimeZone)
+}